@@ -109,3 +109,28 @@ func (LinearTanh) DActivateDCombination(sum, output float64) float64 {
 func (LinearTanh) String() string {
 	return "LinearTanh"
 }
+
+// ReLU is the rectified linear activation function, out = max(0, sum). The
+// derivative at zero is taken to be zero.
+type ReLU struct{}
+
+// Activate computes the ReLU activation function
+func (ReLU) Activate(sum float64) float64 {
+	if sum > 0 {
+		return sum
+	}
+	return 0
+}
+
+// DActivateDCombination computes the derivative of the ReLU activation function
+// with respect to the weighted sum
+func (ReLU) DActivateDCombination(sum, output float64) float64 {
+	if sum > 0 {
+		return 1
+	}
+	return 0
+}
+
+func (ReLU) String() string {
+	return "ReLU"
+}
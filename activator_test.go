@@ -80,3 +80,23 @@ func TestLinearTanh(t *testing.T) {
 		t.Errorf("Derivative does not match. %v expected, %v found", trueDeriv, deriv)
 	}
 }
+
+func TestReLU(t *testing.T) {
+	s := ReLU{}
+	for _, test := range []struct {
+		sum, out, deriv float64
+	}{
+		{1.23456789, 1.23456789, 1},
+		{-1.23456789, 0, 0},
+		{0, 0, 0},
+	} {
+		output := s.Activate(test.sum)
+		if output != test.out {
+			t.Errorf("Activation output does not match. %v expected, %v found", test.out, output)
+		}
+		deriv := s.DActivateDCombination(test.sum, output)
+		if deriv != test.deriv {
+			t.Errorf("Derivative does not match. %v expected, %v found", test.deriv, deriv)
+		}
+	}
+}
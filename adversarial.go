@@ -0,0 +1,101 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+// FGSM generates an adversarial example using the fast gradient sign method.
+// The input is perturbed by epsilon in the direction of the sign of the
+// gradient of the squared error between the prediction and the target, i.e.
+//
+//	adversarial = input + epsilon * sign(dLoss/dInput)
+//
+// The result is stored in adversarial. If adversarial is nil, a new slice is
+// allocated.
+func FGSM(n *Net, input, target []float64, epsilon float64, adversarial []float64) ([]float64, error) {
+	if len(input) != n.inputDim {
+		return nil, dimensionError("fgsm", ErrInputDim, n.inputDim, len(input))
+	}
+	if len(target) != n.outputDim {
+		return nil, dimensionError("fgsm", ErrOutputDim, n.outputDim, len(target))
+	}
+	if adversarial == nil {
+		adversarial = make([]float64, n.inputDim)
+	} else {
+		if len(adversarial) != n.inputDim {
+			return nil, dimensionError("fgsm", ErrInputDim, n.inputDim, len(adversarial))
+		}
+	}
+	mem := newGradMemory(n.layers)
+	fgsm(input, target, epsilon, n.layers, mem, make([]float64, n.outputDim), adversarial)
+	return adversarial, nil
+}
+
+// FGSMBatch generates an adversarial example for every row of inputs in parallel.
+// See FGSM for details. If adversarial is nil, a new matrix is allocated.
+func FGSMBatch(n *Net, inputs, targets RowMatrix, epsilon float64, adversarial MutableRowMatrix) (MutableRowMatrix, error) {
+	nSamples, inputDim := inputs.Dims()
+	if inputDim != n.inputDim {
+		return adversarial, dimensionError("fgsm", ErrInputDim, n.inputDim, inputDim)
+	}
+	nTargets, outputDim := targets.Dims()
+	if outputDim != n.outputDim {
+		return adversarial, dimensionError("fgsm", ErrOutputDim, n.outputDim, outputDim)
+	}
+	if nTargets != nSamples {
+		return adversarial, dimensionError("fgsm", ErrRows, nSamples, nTargets)
+	}
+	if adversarial == nil {
+		s := make(SosMatrix, nSamples)
+		for i := range s {
+			s[i] = make([]float64, inputDim)
+		}
+		adversarial = s
+	} else {
+		r, c := adversarial.Dims()
+		if c != inputDim {
+			return adversarial, dimensionError("fgsm", ErrInputDim, inputDim, c)
+		}
+		if r != nSamples {
+			return adversarial, dimensionError("fgsm", ErrRows, nSamples, r)
+		}
+	}
+
+	f := func(start, end int) {
+		mem := newGradMemory(n.layers)
+		input := make([]float64, inputDim)
+		target := make([]float64, outputDim)
+		dObjDOutput := make([]float64, outputDim)
+		adv := make([]float64, inputDim)
+		for i := start; i < end; i++ {
+			inputs.Row(input, i)
+			targets.Row(target, i)
+			fgsm(input, target, epsilon, n.layers, mem, dObjDOutput, adv)
+			adversarial.SetRow(i, adv)
+		}
+	}
+	ParallelFor(nSamples, n.grainSize, f)
+	return adversarial, nil
+}
+
+func fgsm(input, target []float64, epsilon float64, layers []Layer, mem *gradMemory, dObjDOutput, adversarial []float64) {
+	// The loss is 1/2 sum (output - target)^2, so the derivative with respect to
+	// the outputs is output - target. backprop runs the forward pass itself, so
+	// run it here first to get the outputs.
+	forward(input, layers, mem)
+	output := mem.outputs[len(layers)-1]
+	for j, v := range output {
+		dObjDOutput[j] = v - target[j]
+	}
+	backprop(input, dObjDOutput, layers, mem, nil, adversarial)
+	for j, d := range adversarial {
+		switch {
+		case d > 0:
+			adversarial[j] = input[j] + epsilon
+		case d < 0:
+			adversarial[j] = input[j] - epsilon
+		default:
+			adversarial[j] = input[j]
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestFGSM(t *testing.T) {
+	const eps = 1e-3
+	for i, test := range netIniters {
+		n := testNets[i].Net
+		nSamples := 10
+		inputs := RandomMat(nSamples, test.inputDim, rand.NormFloat64)
+		targets := RandomMat(nSamples, test.outputDim, rand.NormFloat64)
+		adversarial, err := FGSMBatch(n, inputs, targets, eps, nil)
+		if err != nil {
+			t.Fatalf("%v: error in FGSMBatch: %v", test.name, err)
+		}
+		for r := 0; r < nSamples; r++ {
+			adv, err := FGSM(n, inputs[r], targets[r], eps, nil)
+			if err != nil {
+				t.Fatalf("%v: error in FGSM: %v", test.name, err)
+			}
+			if !floatsEqual(adv, adversarial.(SosMatrix)[r]) {
+				t.Errorf("%v: FGSM and FGSMBatch mismatch for row %v", test.name, r)
+			}
+			for j, v := range adv {
+				if math.Abs(math.Abs(v-inputs[r][j])-eps) > 1e-12 {
+					t.Errorf("%v: perturbation is not epsilon for row %v, input %v", test.name, r, j)
+				}
+			}
+			loss := func(x []float64) float64 {
+				out, _ := n.Predict(x, nil)
+				var l float64
+				for j, v := range out {
+					l += (v - targets[r][j]) * (v - targets[r][j])
+				}
+				return l
+			}
+			if loss(adv) < loss(inputs[r]) {
+				t.Errorf("%v: adversarial example decreased the loss for row %v", test.name, r)
+			}
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math/rand"
+)
+
+// Augmenter modifies a mini-batch of training samples in place, for example by
+// perturbing the inputs, to improve the generalization of the trained net.
+// Row i of targets is the target for row i of inputs. Random numbers should be
+// drawn from rnd so that the augmentation is reproducible.
+type Augmenter interface {
+	Augment(inputs, targets MutableRowMatrix, rnd *rand.Rand)
+}
+
+// GaussianNoise adds Gaussian noise with standard deviation Std to every input
+type GaussianNoise struct {
+	Std float64
+}
+
+func (g GaussianNoise) Augment(inputs, targets MutableRowMatrix, rnd *rand.Rand) {
+	r, c := inputs.Dims()
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			inputs.Set(i, j, inputs.At(i, j)+g.Std*rnd.NormFloat64())
+		}
+	}
+}
+
+// FeatureDropout sets each input to zero with probability Prob. If Scale is
+// true, the remaining inputs are divided by 1 - Prob so that the expected value
+// of each input is unchanged.
+type FeatureDropout struct {
+	Prob  float64
+	Scale bool
+}
+
+func (f FeatureDropout) Augment(inputs, targets MutableRowMatrix, rnd *rand.Rand) {
+	scale := 1.0
+	if f.Scale && f.Prob < 1 {
+		scale = 1 / (1 - f.Prob)
+	}
+	r, c := inputs.Dims()
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			if rnd.Float64() < f.Prob {
+				inputs.Set(i, j, 0)
+			} else if scale != 1 {
+				inputs.Set(i, j, scale*inputs.At(i, j))
+			}
+		}
+	}
+}
+
+// ScheduledDropout is a FeatureDropout whose probability follows a schedule,
+// such as a LinearDecay to zero, evaluated at the number of mini-batches
+// augmented so far.
+type ScheduledDropout struct {
+	Schedule Schedule
+	Scale    bool
+
+	step int
+}
+
+func (s *ScheduledDropout) Augment(inputs, targets MutableRowMatrix, rnd *rand.Rand) {
+	FeatureDropout{Prob: s.Schedule.Rate(s.step), Scale: s.Scale}.Augment(inputs, targets, rnd)
+	s.step++
+}
+
+// Mixup replaces every sample with a convex combination of itself and another
+// random sample of the mini-batch, applying the same combination to the inputs
+// and the targets. The weight of the other sample is drawn from U(0, Alpha), so
+// Alpha should be in (0, 0.5].
+//
+// This is a simplification of the Beta(α, α) weights of the original method.
+type Mixup struct {
+	Alpha float64
+}
+
+func (m Mixup) Augment(inputs, targets MutableRowMatrix, rnd *rand.Rand) {
+	r, inputDim := inputs.Dims()
+	if r < 2 {
+		return
+	}
+	_, outputDim := targets.Dims()
+	// Mix with a copy so that every sample is combined with an original one
+	origInputs := make([][]float64, r)
+	origTargets := make([][]float64, r)
+	for i := 0; i < r; i++ {
+		origInputs[i] = inputs.Row(make([]float64, inputDim), i)
+		origTargets[i] = targets.Row(make([]float64, outputDim), i)
+	}
+	input := make([]float64, inputDim)
+	target := make([]float64, outputDim)
+	for i := 0; i < r; i++ {
+		other := rnd.Intn(r)
+		lambda := m.Alpha * rnd.Float64()
+		for j := range input {
+			input[j] = (1-lambda)*origInputs[i][j] + lambda*origInputs[other][j]
+		}
+		for j := range target {
+			target[j] = (1-lambda)*origTargets[i][j] + lambda*origTargets[other][j]
+		}
+		inputs.SetRow(i, input)
+		targets.SetRow(i, target)
+	}
+}
+
+// Augmenters applies each of the augmenters in order
+type Augmenters []Augmenter
+
+func (a Augmenters) Augment(inputs, targets MutableRowMatrix, rnd *rand.Rand) {
+	for _, aug := range a {
+		aug.Augment(inputs, targets, rnd)
+	}
+}
+
+// AugmentedDataset is a Dataset which applies an augmenter to every mini-batch
+// of another Dataset. The mini-batches are copied before augmentation, so the
+// underlying data is not modified.
+type AugmentedDataset struct {
+	data Dataset
+	aug  Augmenter
+	rnd  *rand.Rand
+}
+
+// NewAugmentedDataset returns a Dataset which augments the mini-batches of data
+// with aug, drawing random numbers from rnd. If rnd is nil, the global source
+// of math/rand is used.
+func NewAugmentedDataset(data Dataset, aug Augmenter, rnd *rand.Rand) *AugmentedDataset {
+	return &AugmentedDataset{data: data, aug: aug, rnd: orGlobal(rnd)}
+}
+
+// Next returns the next augmented mini-batch as SosMatrix values
+func (a *AugmentedDataset) Next() (inputs, targets RowMatrix, err error) {
+	inputs, targets, err = a.data.Next()
+	if err != nil {
+		return nil, nil, err
+	}
+	in := copySos(inputs)
+	out := copySos(targets)
+	a.aug.Augment(in, out, a.rnd)
+	return in, out, nil
+}
+
+// copySos returns a copy of m as a SosMatrix
+func copySos(m RowMatrix) SosMatrix {
+	r, c := m.Dims()
+	s := make(SosMatrix, r)
+	for i := range s {
+		s[i] = m.Row(make([]float64, c), i)
+	}
+	return s
+}
@@ -0,0 +1,126 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"io"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestAugmenters(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	const n = 2000
+	ones := func() (SosMatrix, SosMatrix) {
+		inputs := RandomMat(n, 4, func() float64 { return 1 })
+		targets := RandomMat(n, 2, func() float64 { return 1 })
+		return inputs, targets
+	}
+	mean := func(m SosMatrix) float64 {
+		var sum float64
+		for _, row := range m {
+			for _, v := range row {
+				sum += v
+			}
+		}
+		return sum / float64(len(m)*len(m[0]))
+	}
+
+	inputs, targets := ones()
+	GaussianNoise{Std: 0.5}.Augment(inputs, targets, rnd)
+	var variance float64
+	for _, row := range inputs {
+		for _, v := range row {
+			variance += (v - 1) * (v - 1)
+		}
+	}
+	variance /= 4 * n
+	if math.Abs(math.Sqrt(variance)-0.5) > 0.05 {
+		t.Errorf("Wrong noise standard deviation. Expected 0.5, found %v", math.Sqrt(variance))
+	}
+	if mean(targets) != 1 {
+		t.Errorf("Targets changed by GaussianNoise")
+	}
+
+	inputs, targets = ones()
+	FeatureDropout{Prob: 0.25}.Augment(inputs, targets, rnd)
+	if math.Abs(mean(inputs)-0.75) > 0.05 {
+		t.Errorf("Wrong fraction of dropped features. Expected mean 0.75, found %v", mean(inputs))
+	}
+	inputs, targets = ones()
+	FeatureDropout{Prob: 0.25, Scale: true}.Augment(inputs, targets, rnd)
+	if math.Abs(mean(inputs)-1) > 0.05 {
+		t.Errorf("Scaled dropout changed the mean. Expected 1, found %v", mean(inputs))
+	}
+
+	// A dropout probability decaying to zero over two batches
+	dropout := &ScheduledDropout{Schedule: LinearDecay{Start: 0.5, End: 0, Steps: 2}}
+	for _, want := range []float64{0.5, 0.75, 1, 1} {
+		inputs, targets = ones()
+		dropout.Augment(inputs, targets, rnd)
+		if math.Abs(mean(inputs)-want) > 0.05 {
+			t.Errorf("Wrong fraction of dropped features. Expected mean %v, found %v", want, mean(inputs))
+		}
+	}
+
+	// Mixup of samples whose targets are a linear function of the inputs keeps
+	// the relationship
+	inputs = RandomMat(n, 1, rnd.NormFloat64)
+	targets = make(SosMatrix, n)
+	for i := range targets {
+		targets[i] = []float64{3*inputs[i][0] + 1}
+	}
+	orig := copySos(inputs)
+	Augmenters{Mixup{Alpha: 0.5}}.Augment(inputs, targets, rnd)
+	var changed bool
+	for i := range inputs {
+		if math.Abs(targets[i][0]-(3*inputs[i][0]+1)) > 1e-12 {
+			t.Fatalf("Mixup inputs and targets combined differently")
+		}
+		if inputs[i][0] != orig[i][0] {
+			changed = true
+		}
+	}
+	if !changed {
+		t.Errorf("Mixup did not change the inputs")
+	}
+}
+
+func TestAugmentedDataset(t *testing.T) {
+	inputs := SosMatrix{{1}, {2}, {3}}
+	targets := SosMatrix{{1}, {2}, {3}}
+	data := NewAugmentedDataset(&sliceDataset{inputs, targets}, GaussianNoise{Std: 1}, rand.New(rand.NewSource(1)))
+	in, out, err := data.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sosEqual(inputs, SosMatrix{{1}, {2}, {3}}) {
+		t.Errorf("Underlying data modified")
+	}
+	if sosEqual(in.(SosMatrix), inputs) {
+		t.Errorf("Inputs not augmented")
+	}
+	if !sosEqual(out.(SosMatrix), targets) {
+		t.Errorf("Targets changed")
+	}
+	if _, _, err := data.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF, found %v", err)
+	}
+}
+
+// sliceDataset is a Dataset with a single mini-batch
+type sliceDataset struct {
+	inputs, targets SosMatrix
+}
+
+func (s *sliceDataset) Next() (inputs, targets RowMatrix, err error) {
+	if s.inputs == nil {
+		return nil, nil, io.EOF
+	}
+	inputs, targets = s.inputs, s.targets
+	s.inputs, s.targets = nil, nil
+	return inputs, targets, nil
+}
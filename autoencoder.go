@@ -0,0 +1,189 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import "errors"
+
+// AutoencoderTrainer is a net trained to reconstruct its input through a
+// narrow code layer, so that the code is a compressed representation of the
+// input. The encoder maps the input to the code and the decoder, which mirrors
+// the encoder, maps the code back to the input.
+type AutoencoderTrainer struct {
+	*Trainer
+	nEncoder   int // number of layers of the encoder, including the code layer
+	codeDim    int
+	corruption Augmenter // corruption of the inputs during training, none if nil
+}
+
+// NewAutoencoderTrainer constructs a symmetric autoencoder. The encoder has a
+// layer for each of encoderSizes followed by the code layer of codeDim neurons,
+// all with the given activator. The decoder has the encoder's hidden layers in
+// reverse order with the same activator, followed by a linear output layer
+// with inputDim neurons. The loss is the reconstruction error, SquaredDistance.
+func NewAutoencoderTrainer(inputDim int, encoderSizes []int, codeDim int, activator Activator) (*AutoencoderTrainer, error) {
+	if inputDim <= 0 {
+		return nil, errors.New("non-positive input dimension")
+	}
+	if codeDim <= 0 {
+		return nil, errors.New("autoencoder: non-positive code dimension")
+	}
+	sizes := append([]int(nil), encoderSizes...)
+	sizes = append(sizes, codeDim)
+	for i := len(encoderSizes) - 1; i >= 0; i-- {
+		sizes = append(sizes, encoderSizes[i])
+	}
+	neurons := make([][]Neuron, len(sizes)+1)
+	for i, size := range sizes {
+		if size <= 0 {
+			return nil, errors.New("non-positive number of neurons per layer")
+		}
+		neurons[i] = make([]Neuron, size)
+		for j := range neurons[i] {
+			neurons[i][j] = SumNeuron{Activator: activator}
+		}
+	}
+	neurons[len(sizes)] = make([]Neuron, inputDim)
+	for j := range neurons[len(sizes)] {
+		neurons[len(sizes)][j] = LinearNeuron
+	}
+	trainer, err := NewTrainer(inputDim, inputDim, neurons)
+	if err != nil {
+		return nil, err
+	}
+	return &AutoencoderTrainer{
+		Trainer:  trainer,
+		nEncoder: len(encoderSizes) + 1,
+		codeDim:  codeDim,
+	}, nil
+}
+
+// CodeDim returns the dimension of the code
+func (a *AutoencoderTrainer) CodeDim() int {
+	return a.codeDim
+}
+
+// SetTiedWeights sets whether the weights of each decoder layer are the
+// transpose of those of the mirroring encoder layer. Tying halves the number of
+// free weights, which regularizes the autoencoder. When tying is turned on, the
+// decoder weights are set from the encoder. The biases are never tied. Changing
+// the size of the layers with surgery unties the weights.
+func (a *AutoencoderTrainer) SetTiedWeights(tied bool) {
+	a.tied = nil
+	if !tied {
+		return
+	}
+	nLayers := a.NumLayers()
+	for i := 0; i < a.nEncoder; i++ {
+		a.tied = append(a.tied, tiedLayers{encoder: i, decoder: nLayers - 1 - i})
+	}
+	a.tieParameters()
+}
+
+// TiedWeights returns whether the decoder weights are tied to the encoder
+func (a *AutoencoderTrainer) TiedWeights() bool {
+	return a.tied != nil
+}
+
+// SetCorruption sets the corruption of the inputs during training, which makes
+// the autoencoder a denoising autoencoder: it learns to reconstruct the clean
+// inputs from corrupted ones, which gives more robust codes. Typical choices
+// are masking noise, FeatureDropout without Scale, and GaussianNoise. The
+// inputs are corrupted anew at every epoch, and only during training, so
+// Predict, Encode and Decode are not affected. If aug is nil, the inputs are
+// not corrupted, which is the default.
+func (a *AutoencoderTrainer) SetCorruption(aug Augmenter) {
+	a.corruption = aug
+}
+
+// TrainReconstruction performs one epoch of mini-batch training to reconstruct
+// the inputs (see Trainer.TrainEpoch) and returns the mean reconstruction loss.
+// If a corruption is set, the net is given corrupted copies of the inputs.
+func (a *AutoencoderTrainer) TrainReconstruction(inputs RowMatrix, batchSize int) (float64, error) {
+	if a.corruption == nil {
+		return a.Trainer.TrainEpoch(inputs, inputs, batchSize, nil)
+	}
+	corrupted, targets := copySos(inputs), copySos(inputs)
+	a.corruption.Augment(corrupted, targets, a.rand())
+	return a.Trainer.TrainEpoch(corrupted, targets, batchSize, nil)
+}
+
+// Encode computes the code of the input. If code is nil, new memory is
+// allocated.
+func (a *AutoencoderTrainer) Encode(input, code []float64) ([]float64, error) {
+	if len(input) != a.inputDim {
+		return nil, dimensionError("encode", ErrInputDim, a.inputDim, len(input))
+	}
+	if code == nil {
+		code = make([]float64, a.codeDim)
+	}
+	if len(code) != a.codeDim {
+		return nil, dimensionError("encode", ErrOutputDim, a.codeDim, len(code))
+	}
+	layers := a.layers[:a.nEncoder]
+	prevOutput, output := newPredictMemory(layers)
+	predict(input, layers, prevOutput, output, code)
+	return code, nil
+}
+
+// Decode computes the reconstruction of the input from the code. If output is
+// nil, new memory is allocated.
+func (a *AutoencoderTrainer) Decode(code, output []float64) ([]float64, error) {
+	if len(code) != a.codeDim {
+		return nil, dimensionError("decode", ErrInputDim, a.codeDim, len(code))
+	}
+	if output == nil {
+		output = make([]float64, a.outputDim)
+	}
+	if len(output) != a.outputDim {
+		return nil, dimensionError("decode", ErrOutputDim, a.outputDim, len(output))
+	}
+	layers := a.layers[a.nEncoder:]
+	prevOutput, tmp := newPredictMemory(layers)
+	predict(code, layers, prevOutput, tmp, output)
+	return output, nil
+}
+
+// tiedLayers is a pair of layers of SumNeurons where the weights of the decoder
+// layer are the transpose of those of the encoder layer
+type tiedLayers struct {
+	encoder, decoder int
+}
+
+// tiedIndices calls f with the flat indices of every pair of tied weights
+func (s *Trainer) tiedIndices(f func(enc, dec int)) {
+	offsets := make([]int, s.NumLayers())
+	for i := 1; i < len(offsets); i++ {
+		offsets[i] = offsets[i-1] + s.LayerNumParameters(i-1)
+	}
+	for _, t := range s.tied {
+		enc, dec := s.parameters[t.encoder], s.parameters[t.decoder]
+		encStride, decStride := len(enc[0]), len(dec[0])
+		for j := range enc {
+			for i := range dec {
+				f(offsets[t.encoder]+j*encStride+i, offsets[t.decoder]+i*decStride+j)
+			}
+		}
+	}
+}
+
+// tieGradient sums the gradients of every pair of tied weights so that both
+// weights take the same step
+func (s *Trainer) tieGradient(grad []float64) {
+	s.tiedIndices(func(enc, dec int) {
+		g := grad[enc] + grad[dec]
+		grad[enc], grad[dec] = g, g
+	})
+}
+
+// tieParameters sets the tied decoder weights from the encoder weights
+func (s *Trainer) tieParameters() {
+	for _, t := range s.tied {
+		for j, p := range s.parameters[t.encoder] {
+			for i, q := range s.parameters[t.decoder] {
+				q[j] = p[i]
+			}
+		}
+	}
+}
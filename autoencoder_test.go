@@ -0,0 +1,164 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// lowRankData returns samples of dimension dim which lie in a subspace of
+// dimension rank
+func lowRankData(rnd *rand.Rand, nSamples, dim, rank int) SosMatrix {
+	basis := RandomMat(rank, dim, rnd.NormFloat64)
+	data := make(SosMatrix, nSamples)
+	for i := range data {
+		data[i] = make([]float64, dim)
+		for _, b := range basis {
+			c := rnd.NormFloat64()
+			for j, v := range b {
+				data[i][j] += c * v
+			}
+		}
+	}
+	return data
+}
+
+func TestAutoencoder(t *testing.T) {
+	a, err := NewAutoencoderTrainer(6, []int{5, 4}, 2, Tanh{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalInts(a.LayerSizes(), []int{5, 4, 2, 4, 5, 6}) || a.CodeDim() != 2 {
+		t.Errorf("Wrong layer sizes %v", a.LayerSizes())
+	}
+	rnd := rand.New(rand.NewSource(1))
+	a.SetRand(rnd)
+	a.RandomizeParameters()
+
+	inputs := lowRankData(rnd, 10, 6, 2)
+	for i, input := range inputs {
+		code, err := a.Encode(input, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := a.Decode(code, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := a.Predict(input, nil)
+		if !floatsEqualApprox(got, want, 1e-14) {
+			t.Errorf("Decoded code %v differs from prediction. Expected %v, found %v", i, want, got)
+		}
+	}
+	if _, err := a.Encode(make([]float64, 2), nil); err == nil {
+		t.Errorf("Expected input dimension error")
+	}
+	if _, err := a.Decode(make([]float64, 6), nil); err == nil {
+		t.Errorf("Expected code dimension error")
+	}
+}
+
+func TestAutoencoderTraining(t *testing.T) {
+	for _, tied := range []bool{false, true} {
+		a, err := NewAutoencoderTrainer(8, []int{6}, 3, Tanh{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rnd := rand.New(rand.NewSource(2))
+		a.SetRand(rnd)
+		a.RandomizeParameters()
+		a.SetTiedWeights(tied)
+		a.SetOptimizer(&Adam{Rate: 0.01})
+		inputs := lowRankData(rnd, 200, 8, 2)
+		first, err := a.TrainReconstruction(inputs, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var last float64
+		for i := 0; i < 100; i++ {
+			last, err = a.TrainReconstruction(inputs, 20)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if last > first/4 {
+			t.Errorf("Tied %v: reconstruction loss only reduced from %v to %v", tied, first, last)
+		}
+		if a.TiedWeights() != tied {
+			t.Errorf("Wrong tied weights")
+		}
+		if !tied {
+			continue
+		}
+		// The decoder weights are the transpose of the encoder weights, but
+		// the biases are separate
+		n := a.NumLayers()
+		for l := 0; l < 2; l++ {
+			enc, dec := a.parameters[l], a.parameters[n-1-l]
+			for j, p := range enc {
+				for i, q := range dec {
+					if p[i] != q[j] {
+						t.Fatalf("Layer %v: weight %v, %v not tied", l, j, i)
+					}
+				}
+			}
+		}
+		if a.parameters[0][0][8] == a.parameters[n-1][0][6] {
+			t.Errorf("Biases tied")
+		}
+		if c := a.Clone(); len(c.tied) != 2 {
+			t.Errorf("Tied weights not cloned")
+		}
+	}
+}
+
+func TestDenoisingAutoencoder(t *testing.T) {
+	a, err := NewAutoencoderTrainer(8, []int{6}, 2, Tanh{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(3))
+	a.SetRand(rnd)
+	a.RandomizeParameters()
+	a.SetOptimizer(&Adam{Rate: 0.01})
+	noise := GaussianNoise{Std: 0.3}
+	a.SetCorruption(noise)
+
+	clean := lowRankData(rnd, 300, 8, 2)
+	orig := copySos(clean)
+	for i := 0; i < 150; i++ {
+		if _, err := a.TrainReconstruction(clean, 20); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !sosEqual(clean, orig) {
+		t.Errorf("Training data modified by the corruption")
+	}
+
+	// The net removes most of the noise from corrupted inputs
+	noisy := copySos(clean)
+	noise.Augment(noisy, nil, rnd)
+	denoised, err := a.PredictBatch(noisy, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	noisyErr := MeanSquaredError(noisy, clean)
+	denoisedErr := MeanSquaredError(denoised, clean)
+	if denoisedErr > noisyErr/2 {
+		t.Errorf("Noise not removed. Error of noisy inputs %v, of reconstruction %v", noisyErr, denoisedErr)
+	}
+
+	// Corruption which removes all of the information can't be undone
+	a.SetCorruption(FeatureDropout{Prob: 1})
+	loss, err := a.TrainReconstruction(clean, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.SetCorruption(nil)
+	if cleanLoss, _ := a.TrainReconstruction(clean, 20); loss < 2*cleanLoss {
+		t.Errorf("Corruption not applied. Loss %v with full masking, %v without", loss, cleanLoss)
+	}
+}
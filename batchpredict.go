@@ -4,91 +4,240 @@
 
 package nnet
 
-import "errors"
+import (
+	"context"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
 
 func BatchPredict(batch BatchPredictor, inputs RowMatrix, outputs MutableRowMatrix,
 	inputDim, outputDim int, grainSize int) (MutableRowMatrix, error) {
+	return BatchPredictCtx(context.Background(), batch, inputs, outputs, inputDim, outputDim, grainSize)
+}
 
-	// TODO: Add in something about error
+// BatchPredictCtx is like BatchPredict but stops starting new chunks of rows once
+// the context is done, in which case the context's error is returned and the
+// contents of outputs are only partially computed.
+//
+// If the prediction fails for any rows, the other rows are still predicted and
+// the returned error is a *BatchError listing the rows which failed.
+func BatchPredictCtx(ctx context.Context, batch BatchPredictor, inputs RowMatrix, outputs MutableRowMatrix,
+	inputDim, outputDim int, grainSize int) (MutableRowMatrix, error) {
+	return BatchPredictChunked(ctx, batch, inputs, outputs, inputDim, outputDim, grainSize, nil)
+}
 
-	// Check that the inputs and outputs are the right sizes
-	nSamples, dimInputs := inputs.Dims()
-	if inputDim != dimInputs {
-		return outputs, errors.New("predict batch: input dimension mismatch")
-	}
+// BatchPredictChunked is like BatchPredictCtx but calls onChunk with the range of
+// rows [start, end) as soon as the outputs of each chunk of rows are complete, so
+// consumers of the outputs can start before the whole batch is done. The chunks
+// complete in no particular order. onChunk is never called concurrently, but it
+// blocks the worker that finished the chunk, so it should be fast. If onChunk is
+// nil, it is not called.
+//
+// If batch has a method Partition() Partition, the chunks are divided among the
+// workers with the returned partition, and otherwise with Dynamic (see
+// ParallelForPartition).
+func BatchPredictChunked(ctx context.Context, batch BatchPredictor, inputs RowMatrix, outputs MutableRowMatrix,
+	inputDim, outputDim int, grainSize int, onChunk func(start, end int)) (MutableRowMatrix, error) {
 
-	if outputs == nil {
-		// In the real code this would be cause the creation of a mat64.Dense, but
-		// for this benchmark suite we wish to avoid the mat64 dependency
-		s := make(SosMatrix, nSamples)
-		for i := range s {
-			s[i] = make([]float64, outputDim)
-		}
-		outputs = s
-	} else {
-		nOutputSamples, dimOutputs := outputs.Dims()
-		if dimOutputs != outputDim {
-			return outputs, errors.New("predict batch: output dimension mismatch")
-		}
-		if nSamples != nOutputSamples {
-			return outputs, errors.New("predict batch: rows mismatch")
-		}
+	outputs, err := checkBatch(inputs, outputs, inputDim, outputDim)
+	if err != nil {
+		return outputs, err
 	}
+	nSamples, _ := inputs.Dims()
 
-	// Perform predictions in parallel. For each parallel call, form a new predictor so that
-	// memory allocations are saved and no race condition happens.
+	// Perform predictions in parallel. Each chunk takes a predictor from the pool
+	// so that no race condition happens, and the predictors and their memory are
+	// reused by later chunks.
+	pool := newPredictorPool(batch, inputDim, outputDim)
 
 	// If the input and/or output is a RowViewer, save time by avoiding a copy
 	inputRVer, inputIsRowViewer := inputs.(RowViewer)
 	outputRVer, outputIsRowViewer := outputs.(RowViewer)
 
 	var f func(start, end int)
+	var errs rowErrors
 
-	// wrapper function to allow parallel prediction. Uses RowView if the type has it
+	// wrapper function to allow parallel prediction. Uses RowView if the type has
+	// it, and otherwise copies the row into the memory of the predictor. The
+	// output of a row is overwritten, so it is not copied in before predicting.
 	switch {
-	default:
-		panic("Shouldn't be here")
-	case inputIsRowViewer, outputIsRowViewer:
+	case inputIsRowViewer && outputIsRowViewer:
 		f = func(start, end int) {
-			p := batch.NewPredictor()
+			p := pool.get()
 			for i := start; i < end; i++ {
-				p.Predict(inputRVer.RowView(i), outputRVer.RowView(i))
+				if _, err := p.Predict(inputRVer.RowView(i), outputRVer.RowView(i)); err != nil {
+					errs.add(i, err)
+				}
 			}
+			pool.put(p)
 		}
-
-	case inputIsRowViewer && !outputIsRowViewer:
+	case inputIsRowViewer:
 		f = func(start, end int) {
-			p := batch.NewPredictor()
-			output := make([]float64, outputDim)
+			p := pool.get()
 			for i := start; i < end; i++ {
-				outputs.Row(output, i)
-				p.Predict(inputRVer.RowView(i), output)
-				outputs.SetRow(i, output)
+				if _, err := p.Predict(inputRVer.RowView(i), p.output); err != nil {
+					errs.add(i, err)
+					continue
+				}
+				outputs.SetRow(i, p.output)
 			}
+			pool.put(p)
 		}
-	case !inputIsRowViewer && outputIsRowViewer:
+	case outputIsRowViewer:
 		f = func(start, end int) {
-			p := batch.NewPredictor()
-			input := make([]float64, inputDim)
+			p := pool.get()
 			for i := start; i < end; i++ {
-				inputs.Row(input, i)
-				p.Predict(input, outputRVer.RowView(i))
+				inputs.Row(p.input, i)
+				if _, err := p.Predict(p.input, outputRVer.RowView(i)); err != nil {
+					errs.add(i, err)
+				}
 			}
+			pool.put(p)
 		}
-	case !inputIsRowViewer && !outputIsRowViewer:
+	default:
 		f = func(start, end int) {
-			p := batch.NewPredictor()
-			input := make([]float64, inputDim)
-			output := make([]float64, outputDim)
+			p := pool.get()
 			for i := start; i < end; i++ {
-				inputs.Row(input, i)
-				outputs.Row(output, i)
-				p.Predict(input, output)
-				outputs.SetRow(i, output)
+				inputs.Row(p.input, i)
+				if _, err := p.Predict(p.input, p.output); err != nil {
+					errs.add(i, err)
+					continue
+				}
+				outputs.SetRow(i, p.output)
 			}
+			pool.put(p)
+		}
+	}
+
+	if onChunk != nil {
+		var mux sync.Mutex
+		predictChunk := f
+		f = func(start, end int) {
+			predictChunk(start, end)
+			mux.Lock()
+			onChunk(start, end)
+			mux.Unlock()
 		}
 	}
 
-	ParallelFor(nSamples, grainSize, f)
+	atomic.AddUint64(&statBatches, 1)
+	atomic.AddUint64(&statPredictions, uint64(nSamples))
+	part := Dynamic
+	if p, ok := batch.(interface {
+		Partition() Partition
+	}); ok {
+		part = p.Partition()
+	}
+	if err := ParallelForPartition(ctx, nSamples, grainSize, part, f); err != nil {
+		return outputs, err
+	}
+	return outputs, errs.err()
+}
+
+// checkBatch checks that the inputs and outputs of a batch prediction are the
+// right sizes. If outputs is nil, a new matrix is allocated and returned.
+func checkBatch(inputs RowMatrix, outputs MutableRowMatrix, inputDim, outputDim int) (MutableRowMatrix, error) {
+	nSamples, dimInputs := inputs.Dims()
+	if inputDim != dimInputs {
+		return outputs, dimensionError("predict batch", ErrInputDim, inputDim, dimInputs)
+	}
+
+	if outputs == nil {
+		// In the real code this would be cause the creation of a mat64.Dense, but
+		// for this benchmark suite we wish to avoid the mat64 dependency
+		s := make(SosMatrix, nSamples)
+		for i := range s {
+			s[i] = make([]float64, outputDim)
+		}
+		return s, nil
+	}
+	nOutputSamples, dimOutputs := outputs.Dims()
+	if dimOutputs != outputDim {
+		return outputs, dimensionError("predict batch", ErrOutputDim, outputDim, dimOutputs)
+	}
+	if nSamples != nOutputSamples {
+		return outputs, dimensionError("predict batch", ErrRows, nSamples, nOutputSamples)
+	}
 	return outputs, nil
 }
+
+// predictorPool hands out the predictors of a batch to the chunks of a parallel
+// loop. A chunk reuses the predictor of a finished chunk if there is one, so at
+// most one predictor is created per worker rather than one per chunk.
+type predictorPool struct {
+	batch     BatchPredictor
+	inputDim  int
+	outputDim int
+
+	mux  sync.Mutex
+	free []*pooledPredictor
+}
+
+// pooledPredictor is a predictor along with memory for copying rows of matrices
+// which are not RowViewers
+type pooledPredictor struct {
+	Predictor
+	input  []float64
+	output []float64
+}
+
+func newPredictorPool(batch BatchPredictor, inputDim, outputDim int) *predictorPool {
+	return &predictorPool{
+		batch:     batch,
+		inputDim:  inputDim,
+		outputDim: outputDim,
+		free:      make([]*pooledPredictor, 0, runtime.GOMAXPROCS(0)),
+	}
+}
+
+func (pool *predictorPool) get() *pooledPredictor {
+	pool.mux.Lock()
+	if n := len(pool.free); n > 0 {
+		p := pool.free[n-1]
+		pool.free = pool.free[:n-1]
+		pool.mux.Unlock()
+		return p
+	}
+	pool.mux.Unlock()
+	return &pooledPredictor{
+		Predictor: pool.batch.NewPredictor(),
+		input:     make([]float64, pool.inputDim),
+		output:    make([]float64, pool.outputDim),
+	}
+}
+
+func (pool *predictorPool) put(p *pooledPredictor) {
+	pool.mux.Lock()
+	pool.free = append(pool.free, p)
+	pool.mux.Unlock()
+}
+
+// rowErrors collects the errors of individual rows from concurrent workers
+type rowErrors struct {
+	mux  sync.Mutex
+	errs []RowError
+}
+
+func (r *rowErrors) add(row int, err error) {
+	r.mux.Lock()
+	r.errs = append(r.errs, RowError{Row: row, Err: err})
+	r.mux.Unlock()
+}
+
+// err returns a *BatchError with the errors sorted by row, or nil if there
+// were no errors
+func (r *rowErrors) err() error {
+	if len(r.errs) == 0 {
+		return nil
+	}
+	sort.Sort(byRow(r.errs))
+	return &BatchError{Errors: r.errs}
+}
+
+type byRow []RowError
+
+func (b byRow) Len() int           { return len(b) }
+func (b byRow) Less(i, j int) bool { return b[i].Row < b[j].Row }
+func (b byRow) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
@@ -0,0 +1,178 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"errors"
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+var errNegative = errors.New("negative input")
+
+// failingPredictor copies the input to the output and fails for rows whose
+// first input is negative
+type failingPredictor struct {
+	dim int
+}
+
+func (f failingPredictor) NewPredictor() Predictor { return f }
+func (f failingPredictor) InputDim() int           { return f.dim }
+func (f failingPredictor) OutputDim() int          { return f.dim }
+
+func (f failingPredictor) Predict(input, output []float64) ([]float64, error) {
+	if input[0] < 0 {
+		return output, errNegative
+	}
+	copy(output, input)
+	return output, nil
+}
+
+func (f failingPredictor) PredictBatch(inputs RowMatrix, outputs MutableRowMatrix) (MutableRowMatrix, error) {
+	return BatchPredict(f, inputs, outputs, f.dim, f.dim, 3)
+}
+
+func TestBatchPredictRowErrors(t *testing.T) {
+	nSamples := 20
+	inputs := make(SosMatrix, nSamples)
+	var failed []int
+	for i := range inputs {
+		inputs[i] = []float64{float64(i), 1}
+		if i%7 == 3 {
+			inputs[i][0] = -1
+			failed = append(failed, i)
+		}
+	}
+	outputs, err := failingPredictor{dim: 2}.PredictBatch(inputs, nil)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("Wrong error type: %v", err)
+	}
+	if !equalInts(batchErr.Rows(), failed) {
+		t.Errorf("Wrong failed rows. Expected %v, found %v", failed, batchErr.Rows())
+	}
+	if !errors.Is(err, errNegative) {
+		t.Errorf("Batch error doesn't wrap the row errors")
+	}
+	for i := range inputs {
+		if inputs[i][0] >= 0 && !floatsEqual(inputs[i], outputs.(SosMatrix)[i]) {
+			t.Errorf("Row %v not predicted", i)
+		}
+	}
+}
+
+func TestBatchPredictAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocations are not counted with the race detector")
+	}
+	s, err := NewSimpleTrainer(4, 2, 2, 5, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.RandomizeParameters()
+	// Use one sample per chunk so that allocations per chunk are found
+	s.HackGrainSize(1)
+	frozen := s.Freeze()
+	for _, nSamples := range []int{10, 1000} {
+		inputs := RandomMat(nSamples, 4, rand.NormFloat64)
+		outputs := RandomMat(nSamples, 2, rand.NormFloat64)
+		for _, p := range []BatchPredictor{s.batchPredictor(), frozen} {
+			// Warm up so that one-time allocations are not counted
+			if _, err := BatchPredict(p, inputs, outputs, 4, 2, 1); err != nil {
+				t.Fatal(err)
+			}
+			allocs := testing.AllocsPerRun(10, func() {
+				if _, err := BatchPredict(p, inputs, outputs, 4, 2, 1); err != nil {
+					t.Fatal(err)
+				}
+			})
+			if allocs > batchAllocBound() {
+				t.Errorf("%T: %v samples: %v allocations, want at most %v", p, nSamples, allocs, batchAllocBound())
+			}
+		}
+	}
+}
+
+// batchAllocBound is the most allocations a call to PredictBatch may make when
+// the inputs and outputs are RowViewers. There is a fixed number for the call,
+// and then each worker has a goroutine and a predictor with its memory, so the
+// number of allocations doesn't depend on the number of samples or chunks.
+func batchAllocBound() float64 {
+	return float64(10 + 8*runtime.GOMAXPROCS(0))
+}
+
+// copyMatrix is a matrix which is not a RowViewer, so its rows must be copied
+type copyMatrix struct {
+	s SosMatrix
+}
+
+func (m copyMatrix) Dims() (r, c int)                 { return m.s.Dims() }
+func (m copyMatrix) At(i, j int) float64              { return m.s.At(i, j) }
+func (m copyMatrix) Set(i, j int, v float64)          { m.s.Set(i, j, v) }
+func (m copyMatrix) Row(d []float64, i int) []float64 { return m.s.Row(d, i) }
+func (m copyMatrix) SetRow(i int, d []float64) int    { return m.s.SetRow(i, d) }
+
+func TestBatchPredictMatrixTypes(t *testing.T) {
+	s, err := NewSimpleTrainer(3, 2, 1, 4, Tanh{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.RandomizeParameters()
+	s.HackGrainSize(3)
+	nSamples := 20
+	inputs := RandomMat(nSamples, 3, rand.NormFloat64)
+	want := make(SosMatrix, nSamples)
+	for i := range want {
+		want[i], _ = s.Predict(inputs[i], nil)
+	}
+	failInputs := make(SosMatrix, nSamples)
+	for i := range failInputs {
+		failInputs[i] = []float64{float64(i), 1}
+		if i%5 == 2 {
+			failInputs[i][0] = -1
+		}
+	}
+
+	for _, test := range []struct {
+		name           string
+		inputRowViewer bool
+		outRowViewer   bool
+	}{
+		{"both", true, true},
+		{"input", true, false},
+		{"output", false, true},
+		{"neither", false, false},
+	} {
+		wrap := func(m SosMatrix, rowViewer bool) MutableRowMatrix {
+			if rowViewer {
+				return m
+			}
+			return copyMatrix{m}
+		}
+		outputs := RandomMat(nSamples, 2, rand.NormFloat64)
+		if _, err := s.PredictBatch(wrap(inputs, test.inputRowViewer), wrap(outputs, test.outRowViewer)); err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		for i := range want {
+			if !floatsEqual(outputs[i], want[i]) {
+				t.Errorf("%v: wrong prediction %v. Expected %v, found %v", test.name, i, want[i], outputs[i])
+			}
+		}
+
+		// The other rows are predicted when some rows fail
+		outputs = RandomMat(nSamples, 2, rand.NormFloat64)
+		_, err := BatchPredict(failingPredictor{dim: 2}, wrap(failInputs, test.inputRowViewer), wrap(outputs, test.outRowViewer), 2, 2, 3)
+		var batchErr *BatchError
+		if !errors.As(err, &batchErr) || len(batchErr.Errors) != 4 {
+			t.Errorf("%v: wrong error %v", test.name, err)
+		}
+		for i := range failInputs {
+			if failInputs[i][0] >= 0 && !floatsEqual(outputs[i], failInputs[i]) {
+				t.Errorf("%v: wrong output %v. Expected %v, found %v", test.name, i, failInputs[i], outputs[i])
+			}
+		}
+	}
+}
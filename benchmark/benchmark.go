@@ -0,0 +1,406 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+// Package benchmark runs the neural net prediction benchmarks programmatically
+// and records the results in a machine-readable form, so that results from
+// different machines and commits can be collected and compared.
+package benchmark
+
+import (
+	"bufio"
+	"errors"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	nnet "github.com/btracey/netbench"
+)
+
+// Config is a single benchmark configuration. See benchmarkpredict_test.go in
+// package nnet for a description of the parameters.
+type Config struct {
+	InputDim   int `json:"inputDim"`
+	OutputDim  int `json:"outputDim"`
+	NumLayers  int `json:"numLayers"`  // Number of hidden layers
+	NumNeurons int `json:"numNeurons"` // Number of neurons per hidden layer
+	NumSamples int `json:"numSamples"`
+
+	// Seed is the seed of the random parameters and inputs of the benchmark. If
+	// it is zero, a seed derived from the name of the configuration is used, so
+	// that every configuration has a fixed workload. The seed used is recorded
+	// in the result.
+	Seed int64 `json:"seed"`
+}
+
+// Name returns the name of the configuration in the same format as the names
+// of the Go benchmarks, InputDim_NumLayers_NumNeurons_NumSamples. The Go
+// benchmarks all have one output, so an OutputDim other than one is appended
+// as _outOutputDim, which keeps configurations of different output dimension
+// apart.
+func (c Config) Name() string {
+	name := strconv.Itoa(c.InputDim) + "_" + strconv.Itoa(c.NumLayers) + "_" +
+		strconv.Itoa(c.NumNeurons) + "_" + strconv.Itoa(c.NumSamples)
+	if c.OutputDim != 1 {
+		name += "_out" + strconv.Itoa(c.OutputDim)
+	}
+	return name
+}
+
+// seed returns the seed used for the configuration
+func (c Config) seed() int64 {
+	if c.Seed != 0 {
+		return c.Seed
+	}
+	h := fnv.New64a()
+	h.Write([]byte(c.Name()))
+	return int64(h.Sum64() >> 1)
+}
+
+// Standard are the configurations of the PredictBatch benchmarks in package nnet
+var Standard = []Config{
+	{InputDim: 10, OutputDim: 1, NumLayers: 1, NumNeurons: 5, NumSamples: 100000},
+	{InputDim: 4, OutputDim: 1, NumLayers: 15, NumNeurons: 15, NumSamples: 10000},
+	{InputDim: 100, OutputDim: 1, NumLayers: 10, NumNeurons: 50, NumSamples: 1000},
+	{InputDim: 10, OutputDim: 1, NumLayers: 100, NumNeurons: 2, NumSamples: 10000},
+	{InputDim: 1000, OutputDim: 1, NumLayers: 1, NumNeurons: 10, NumSamples: 10000},
+}
+
+// Result is the result of running one benchmark configuration along with the
+// environment it was run in
+type Result struct {
+	Benchmark string `json:"benchmark"` // The benchmark that was run, for example "PredictBatch"
+	Name      string `json:"name"`
+	Config
+
+	Iterations    int     `json:"iterations"`
+	NsPerOp       float64 `json:"nsPerOp"`
+	SamplesPerSec float64 `json:"samplesPerSec"`
+	AllocsPerOp   int64   `json:"allocsPerOp"`
+	BytesPerOp    int64   `json:"bytesPerOp"`
+
+	// The memory statistics per predicted sample and the garbage collections
+	// per op, from the difference in runtime.MemStats over the final run
+	AllocsPerSample float64 `json:"allocsPerSample"`
+	BytesPerSample  float64 `json:"bytesPerSample"`
+	GCPerOp         float64 `json:"gcPerOp"`
+	GCPauseNsPerOp  float64 `json:"gcPauseNsPerOp"`
+
+	// The percentiles of the time of a single prediction. These are only set by
+	// the PredictLatency benchmark.
+	P50Ns float64 `json:"p50Ns,omitempty"`
+	P95Ns float64 `json:"p95Ns,omitempty"`
+	P99Ns float64 `json:"p99Ns,omitempty"`
+
+	GOMAXPROCS int    `json:"gomaxprocs"`
+	GrainSize  int    `json:"grainSize"`
+	CPU        string `json:"cpu"`
+	GOOS       string `json:"goos"`
+	GOARCH     string `json:"goarch"`
+	GoVersion  string `json:"goVersion"`
+}
+
+// PredictBatch runs the PredictBatch benchmark for the configuration and returns
+// the result. Like the other benchmarks, it returns an error if the
+// configuration does not describe a valid net.
+func PredictBatch(c Config) (Result, error) {
+	c.Seed = c.seed()
+	rnd := rand.New(rand.NewSource(c.Seed))
+	trainer, err := newTrainer(c, rnd)
+	if err != nil {
+		return Result{}, err
+	}
+	net := trainer.Predictor()
+
+	input := randomMat(c.NumSamples, c.InputDim, rnd)
+	output := randomMat(c.NumSamples, c.OutputDim, rnd)
+
+	return run("PredictBatch", c, trainer.GrainSize(), func() error {
+		_, err := net.PredictBatch(input, output)
+		return err
+	})
+}
+
+// BatchGradient runs a benchmark of the parameter gradient summed over all of
+// the samples of the configuration
+func BatchGradient(c Config) (Result, error) {
+	c.Seed = c.seed()
+	op, grainSize, err := batchGradientOp(c)
+	if err != nil {
+		return Result{}, err
+	}
+	return run("BatchGradient", c, grainSize, op)
+}
+
+// BatchGradientOp returns the operation timed by BatchGradient, so that it can
+// also be run by Go benchmarks
+func BatchGradientOp(c Config) (func() error, error) {
+	op, _, err := batchGradientOp(c)
+	return op, err
+}
+
+func batchGradientOp(c Config) (op func() error, grainSize int, err error) {
+	rnd := rand.New(rand.NewSource(c.seed()))
+	trainer, err := newTrainer(c, rnd)
+	if err != nil {
+		return nil, 0, err
+	}
+	input := randomMat(c.NumSamples, c.InputDim, rnd)
+	dObjDOutput := randomMat(c.NumSamples, c.OutputDim, rnd)
+	deriv := make([]float64, trainer.TotalNumParameters())
+
+	return func() error {
+		_, err := trainer.BatchParameterGradient(input, dObjDOutput, deriv)
+		return err
+	}, trainer.GrainSize(), nil
+}
+
+// Minibatch is the number of samples per minibatch in the SGDEpoch benchmark
+const Minibatch = 100
+
+// SGDEpoch runs a benchmark of one epoch of minibatch stochastic gradient
+// descent on the squared error, including the predictions, gradients and
+// parameter updates of every minibatch
+func SGDEpoch(c Config) (Result, error) {
+	c.Seed = c.seed()
+	op, grainSize, err := sgdEpochOp(c)
+	if err != nil {
+		return Result{}, err
+	}
+	return run("SGDEpoch", c, grainSize, op)
+}
+
+// SGDEpochOp returns the operation timed by SGDEpoch, so that it can also be
+// run by Go benchmarks
+func SGDEpochOp(c Config) (func() error, error) {
+	op, _, err := sgdEpochOp(c)
+	return op, err
+}
+
+func sgdEpochOp(c Config) (op func() error, grainSize int, err error) {
+	rnd := rand.New(rand.NewSource(c.seed()))
+	trainer, err := newTrainer(c, rnd)
+	if err != nil {
+		return nil, 0, err
+	}
+	input := randomMat(c.NumSamples, c.InputDim, rnd)
+	target := randomMat(c.NumSamples, c.OutputDim, rnd)
+
+	params := trainer.Parameters(nil)
+	deriv := make([]float64, len(params))
+	output := randomMat(Minibatch, c.OutputDim, rnd)
+	// A small step so that the parameters stay bounded over many epochs
+	const stepSize = 1e-6
+
+	return func() error {
+		for start := 0; start < c.NumSamples; start += Minibatch {
+			end := start + Minibatch
+			if end > c.NumSamples {
+				end = c.NumSamples
+			}
+			out := output[:end-start]
+			if _, err := trainer.PredictBatch(input[start:end], out); err != nil {
+				return err
+			}
+			// The derivative of the squared error is stored in place of the
+			// predictions
+			for k, row := range out {
+				for j := range row {
+					row[j] -= target[start+k][j]
+				}
+			}
+			if _, err := trainer.BatchParameterGradient(input[start:end], out, deriv); err != nil {
+				return err
+			}
+			for k, d := range deriv {
+				params[k] -= stepSize * d
+			}
+			trainer.SetParameters(params)
+		}
+		return nil
+	}, trainer.GrainSize(), nil
+}
+
+// LatencySamples is the number of predictions timed individually by the
+// PredictLatency benchmark
+const LatencySamples = 10000
+
+// PredictLatency runs a benchmark of single predictions with Net.Predict, one
+// for each of the samples of the configuration in turn, and the result includes
+// the percentiles of the prediction times, which matter more than the mean when
+// the net is used to serve online requests. The benchmarked loop does not read
+// the clock. The percentiles are measured afterwards by timing LatencySamples
+// predictions individually, cycling through the samples, so they include the
+// cost of reading the clock.
+func PredictLatency(c Config) (Result, error) {
+	c.Seed = c.seed()
+	rnd := rand.New(rand.NewSource(c.Seed))
+	trainer, err := newTrainer(c, rnd)
+	if err != nil {
+		return Result{}, err
+	}
+	net := trainer.Predictor()
+
+	input := randomMat(c.NumSamples, c.InputDim, rnd)
+	output := make([]float64, c.OutputDim)
+
+	r, err := run("PredictLatency", c, trainer.GrainSize(), func() error {
+		for _, row := range input {
+			if _, err := net.Predict(row, output); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil || len(input) == 0 {
+		return r, err
+	}
+	times := make([]time.Duration, LatencySamples)
+	for i := range times {
+		start := time.Now()
+		net.Predict(input[i%len(input)], output)
+		times[i] = time.Since(start)
+	}
+	sort.Sort(byDuration(times))
+	r.P50Ns = percentile(times, 0.50)
+	r.P95Ns = percentile(times, 0.95)
+	r.P99Ns = percentile(times, 0.99)
+	return r, nil
+}
+
+// percentile returns the p quantile of the sorted durations in nanoseconds
+// using the nearest rank
+func percentile(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return float64(sorted[idx].Nanoseconds())
+}
+
+type byDuration []time.Duration
+
+func (b byDuration) Len() int           { return len(b) }
+func (b byDuration) Less(i, j int) bool { return b[i] < b[j] }
+func (b byDuration) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+// Benchmarks are the benchmarks which can be run by name
+var Benchmarks = map[string]func(Config) (Result, error){
+	"PredictBatch":   PredictBatch,
+	"PredictLatency": PredictLatency,
+	"BatchGradient":  BatchGradient,
+	"SGDEpoch":       SGDEpoch,
+}
+
+func newTrainer(c Config, rnd *rand.Rand) (*nnet.Trainer, error) {
+	trainer, err := nnet.NewSimpleTrainer(c.InputDim, c.OutputDim, c.NumLayers, c.NumNeurons, nnet.Linear{})
+	if err != nil {
+		return nil, errors.New("benchmark " + c.Name() + ": " + err.Error())
+	}
+	trainer.SetRand(rnd)
+	trainer.RandomizeParameters()
+	return trainer, nil
+}
+
+// run benchmarks op, which processes all of the samples of the configuration
+// once. It stops at the first error returned by op.
+func run(benchmark string, c Config, grainSize int, op func() error) (Result, error) {
+	var mem memDelta
+	var err error
+	br := testing.Benchmark(func(b *testing.B) {
+		b.ReportAllocs()
+		mem.start()
+		for i := 0; i < b.N && err == nil; i++ {
+			err = op()
+		}
+		b.StopTimer()
+		mem.stop()
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	return newResult(benchmark, c, br, mem, grainSize), nil
+}
+
+// memDelta records the change in the memory statistics over a benchmark run.
+// testing.Benchmark calls the benchmark function several times with increasing
+// b.N, so the final values are those of the run reported in the result.
+type memDelta struct {
+	before, after runtime.MemStats
+}
+
+func (m *memDelta) start() {
+	runtime.ReadMemStats(&m.before)
+}
+
+func (m *memDelta) stop() {
+	runtime.ReadMemStats(&m.after)
+}
+
+func newResult(benchmark string, c Config, br testing.BenchmarkResult, mem memDelta, grainSize int) Result {
+	nsPerOp := float64(br.T.Nanoseconds()) / float64(br.N)
+	n := float64(br.N)
+	samples := n * float64(c.NumSamples)
+	return Result{
+		Benchmark:     benchmark,
+		Name:          benchmark + "_" + c.Name(),
+		Config:        c,
+		Iterations:    br.N,
+		NsPerOp:       nsPerOp,
+		SamplesPerSec: float64(c.NumSamples) / nsPerOp * 1e9,
+		AllocsPerOp:   br.AllocsPerOp(),
+		BytesPerOp:    br.AllocedBytesPerOp(),
+
+		AllocsPerSample: float64(mem.after.Mallocs-mem.before.Mallocs) / samples,
+		BytesPerSample:  float64(mem.after.TotalAlloc-mem.before.TotalAlloc) / samples,
+		GCPerOp:         float64(mem.after.NumGC-mem.before.NumGC) / n,
+		GCPauseNsPerOp:  float64(mem.after.PauseTotalNs-mem.before.PauseTotalNs) / n,
+
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		GrainSize:  grainSize,
+		CPU:        cpuModel(),
+		GOOS:       runtime.GOOS,
+		GOARCH:     runtime.GOARCH,
+		GoVersion:  runtime.Version(),
+	}
+}
+
+func randomMat(r, c int, rnd *rand.Rand) nnet.SosMatrix {
+	s := make(nnet.SosMatrix, r)
+	for i := range s {
+		s[i] = make([]float64, c)
+		for j := range s[i] {
+			s[i][j] = rnd.NormFloat64()
+		}
+	}
+	return s
+}
+
+// cpuModel returns the model name of the CPU if it can be determined
+func cpuModel() string {
+	f, err := os.Open("/proc/cpuinfo")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "model name") {
+			if i := strings.Index(line, ":"); i >= 0 {
+				return strings.TrimSpace(line[i+1:])
+			}
+		}
+	}
+	return ""
+}
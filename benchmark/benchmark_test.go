@@ -0,0 +1,94 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package benchmark
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestSeed(t *testing.T) {
+	c := Config{InputDim: 3, OutputDim: 2, NumLayers: 1, NumNeurons: 4, NumSamples: 5}
+	if c.seed() == 0 {
+		t.Errorf("Zero derived seed")
+	}
+	if c.seed() != c.seed() {
+		t.Errorf("Derived seed not fixed")
+	}
+	other := c
+	other.NumSamples = 6
+	if c.seed() == other.seed() {
+		t.Errorf("Same derived seed for different configurations")
+	}
+	other.Seed = 7
+	if other.seed() != 7 {
+		t.Errorf("Explicit seed not used")
+	}
+
+	// The same seed gives the same net and inputs
+	newWorkload := func() ([]float64, [][]float64) {
+		rnd := rand.New(rand.NewSource(c.seed()))
+		trainer, err := newTrainer(c, rnd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return trainer.Parameters(nil), randomMat(c.NumSamples, c.InputDim, rnd)
+	}
+	params1, input1 := newWorkload()
+	params2, input2 := newWorkload()
+	for i := range params1 {
+		if params1[i] != params2[i] {
+			t.Fatalf("Parameters not reproducible")
+		}
+	}
+	for i := range input1 {
+		for j := range input1[i] {
+			if input1[i][j] != input2[i][j] {
+				t.Fatalf("Inputs not reproducible")
+			}
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var times []time.Duration
+	for i := 1; i <= 100; i++ {
+		times = append(times, time.Duration(i))
+	}
+	for _, test := range []struct {
+		p    float64
+		want float64
+	}{
+		{0, 1},
+		{0.5, 50},
+		{0.95, 95},
+		{0.99, 99},
+		{1, 100},
+	} {
+		if got := percentile(times, test.p); got != test.want {
+			t.Errorf("Wrong percentile %v. Expected %v, found %v", test.p, test.want, got)
+		}
+	}
+	if percentile(nil, 0.5) != 0 {
+		t.Errorf("Nonzero percentile with no durations")
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	c := Config{InputDim: 0, OutputDim: 1, NumLayers: 1, NumNeurons: 4, NumSamples: 5}
+	for name, bench := range Benchmarks {
+		if _, err := bench(c); err == nil {
+			t.Errorf("%v: expected error for an invalid configuration", name)
+		}
+	}
+
+	// Errors of the benchmarked operation are returned
+	opErr := errors.New("op failed")
+	if _, err := run("Op", c, 1, func() error { return opErr }); err != opErr {
+		t.Errorf("Expected the error of the operation, found %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package benchmark
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// Delta is the change in run time of one benchmark between two sets of results.
+// Each set may contain several runs of the same benchmark (for example from
+// netbench bench -count), in which case the mean and standard deviation of the
+// runs are used. Results with different GOMAXPROCS, such as those of a scaling
+// run, are different benchmarks.
+type Delta struct {
+	Name       string
+	GOMAXPROCS int
+	OldNs      float64 // Mean ns/op of the old results
+	NewNs      float64 // Mean ns/op of the new results
+	OldStd     float64 // Standard deviation of the old ns/op, zero with a single run
+	NewStd     float64 // Standard deviation of the new ns/op, zero with a single run
+	Change     float64 // Relative change in ns/op, (NewNs - OldNs) / OldNs
+	OldRuns    int
+	NewRuns    int
+
+	// Significant is true if the change is larger than the noise in the runs,
+	// that is if the difference in the means is larger than twice the combined
+	// standard deviation. With single runs all changes are significant.
+	Significant bool
+}
+
+// Regression returns true if the benchmark became slower by more than the
+// relative threshold and the change is significant
+func (d Delta) Regression(threshold float64) bool {
+	return d.Significant && d.Change > threshold
+}
+
+// Compare computes the change of every benchmark present in both old and new,
+// matched by name and GOMAXPROCS. The deltas are sorted by name and then by
+// GOMAXPROCS. Benchmarks only present in one of the sets are ignored.
+func Compare(old, new []Result) []Delta {
+	oldRuns := groupRuns(old)
+	newRuns := groupRuns(new)
+	var deltas []Delta
+	for key, o := range oldRuns {
+		n, ok := newRuns[key]
+		if !ok {
+			continue
+		}
+		oldMean, oldStd := meanStd(o)
+		newMean, newStd := meanStd(n)
+		diff := newMean - oldMean
+		deltas = append(deltas, Delta{
+			Name:        key.name,
+			GOMAXPROCS:  key.procs,
+			OldNs:       oldMean,
+			NewNs:       newMean,
+			OldStd:      oldStd,
+			NewStd:      newStd,
+			Change:      diff / oldMean,
+			OldRuns:     len(o),
+			NewRuns:     len(n),
+			Significant: math.Abs(diff) > 2*math.Hypot(oldStd, newStd),
+		})
+	}
+	sort.Sort(byName(deltas))
+	return deltas
+}
+
+// WriteDeltas writes a table of the deltas to w, marking the regressions beyond
+// the threshold
+func WriteDeltas(w io.Writer, deltas []Delta, threshold float64) error {
+	if _, err := fmt.Fprintf(w, "%-40s %5s %14s %14s %9s\n", "name", "procs", "old ns/op", "new ns/op", "delta"); err != nil {
+		return err
+	}
+	for _, d := range deltas {
+		var mark string
+		switch {
+		case d.Regression(threshold):
+			mark = "  REGRESSION"
+		case !d.Significant:
+			mark = "  (not significant)"
+		}
+		_, err := fmt.Fprintf(w, "%-40s %5d %14.0f %14.0f %+8.2f%%%s\n", d.Name, d.GOMAXPROCS, d.OldNs, d.NewNs, 100*d.Change, mark)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runKey identifies the runs of one benchmark
+type runKey struct {
+	name  string
+	procs int
+}
+
+// groupRuns groups the ns/op of the results by benchmark name and GOMAXPROCS
+func groupRuns(results []Result) map[runKey][]float64 {
+	runs := make(map[runKey][]float64)
+	for _, r := range results {
+		key := runKey{name: r.Name, procs: r.GOMAXPROCS}
+		runs[key] = append(runs[key], r.NsPerOp)
+	}
+	return runs
+}
+
+// meanStd returns the mean and the sample standard deviation of x. The standard
+// deviation of a single value is zero.
+func meanStd(x []float64) (mean, std float64) {
+	for _, v := range x {
+		mean += v
+	}
+	mean /= float64(len(x))
+	if len(x) < 2 {
+		return mean, 0
+	}
+	for _, v := range x {
+		std += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(std / float64(len(x)-1))
+}
+
+type byName []Delta
+
+func (b byName) Len() int { return len(b) }
+
+func (b byName) Less(i, j int) bool {
+	if b[i].Name != b[j].Name {
+		return b[i].Name < b[j].Name
+	}
+	return b[i].GOMAXPROCS < b[j].GOMAXPROCS
+}
+
+func (b byName) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
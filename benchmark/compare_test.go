@@ -0,0 +1,76 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package benchmark
+
+import "testing"
+
+func runs(name string, ns ...float64) []Result {
+	var results []Result
+	for _, v := range ns {
+		results = append(results, Result{Benchmark: "PredictBatch", Name: name, NsPerOp: v})
+	}
+	return results
+}
+
+func TestCompare(t *testing.T) {
+	var old, new []Result
+	old = append(old, runs("a", 100, 100)...)
+	new = append(new, runs("a", 120, 120)...) // Clear regression
+	old = append(old, runs("b", 100, 60, 140)...)
+	new = append(new, runs("b", 120, 80, 160)...) // Within the noise
+	old = append(old, runs("c", 100)...)
+	new = append(new, runs("c", 90)...)  // Improvement
+	old = append(old, runs("d", 100)...) // Only in old
+
+	deltas := Compare(old, new)
+	if len(deltas) != 3 {
+		t.Fatalf("Wrong number of deltas. Expected 3, found %v", len(deltas))
+	}
+	for i, test := range []struct {
+		name        string
+		change      float64
+		significant bool
+		regression  bool
+	}{
+		{"a", 0.2, true, true},
+		{"b", 0.2, false, false},
+		{"c", -0.1, true, false},
+	} {
+		d := deltas[i]
+		if d.Name != test.name {
+			t.Errorf("Wrong name. Expected %v, found %v", test.name, d.Name)
+		}
+		if d.Change < test.change-1e-12 || d.Change > test.change+1e-12 {
+			t.Errorf("Wrong change for %v. Expected %v, found %v", d.Name, test.change, d.Change)
+		}
+		if d.Significant != test.significant {
+			t.Errorf("Wrong significance for %v. Expected %v, found %v", d.Name, test.significant, d.Significant)
+		}
+		if d.Regression(0.05) != test.regression {
+			t.Errorf("Wrong regression for %v. Expected %v", d.Name, test.regression)
+		}
+	}
+}
+
+func TestCompareGOMAXPROCS(t *testing.T) {
+	// A scaling run has one result per GOMAXPROCS with the same name, which
+	// are separate benchmarks rather than repeated runs
+	scaling := func(ns1, ns8 float64) []Result {
+		r := runs("a", ns1, ns8)
+		r[0].GOMAXPROCS = 1
+		r[1].GOMAXPROCS = 8
+		return r
+	}
+	deltas := Compare(scaling(800, 100), scaling(800, 150))
+	if len(deltas) != 2 {
+		t.Fatalf("Wrong number of deltas. Expected 2, found %v", len(deltas))
+	}
+	if d := deltas[0]; d.GOMAXPROCS != 1 || d.Change != 0 || d.OldRuns != 1 {
+		t.Errorf("Wrong delta with GOMAXPROCS=1: %+v", d)
+	}
+	if d := deltas[1]; d.GOMAXPROCS != 8 || !d.Regression(0.05) {
+		t.Errorf("Regression with GOMAXPROCS=8 not found: %+v", d)
+	}
+}
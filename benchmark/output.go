@@ -0,0 +1,100 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package benchmark
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// WriteJSON writes the results to w as an indented JSON array
+func WriteJSON(w io.Writer, results []Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(results)
+}
+
+// ReadJSON reads results written by WriteJSON
+func ReadJSON(r io.Reader) ([]Result, error) {
+	var results []Result
+	err := json.NewDecoder(r).Decode(&results)
+	return results, err
+}
+
+var csvHeader = []string{
+	"benchmark", "name", "inputDim", "outputDim", "numLayers", "numNeurons", "numSamples", "seed",
+	"iterations", "nsPerOp", "samplesPerSec", "allocsPerOp", "bytesPerOp",
+	"allocsPerSample", "bytesPerSample", "gcPerOp", "gcPauseNsPerOp",
+	"p50Ns", "p95Ns", "p99Ns",
+	"gomaxprocs", "grainSize", "cpu", "goos", "goarch", "goVersion",
+}
+
+// WriteCSV writes the results to w as CSV with a header row
+func WriteCSV(w io.Writer, results []Result) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, r := range results {
+		record := []string{
+			r.Benchmark,
+			r.Name,
+			strconv.Itoa(r.InputDim),
+			strconv.Itoa(r.OutputDim),
+			strconv.Itoa(r.NumLayers),
+			strconv.Itoa(r.NumNeurons),
+			strconv.Itoa(r.NumSamples),
+			strconv.FormatInt(r.Seed, 10),
+			strconv.Itoa(r.Iterations),
+			strconv.FormatFloat(r.NsPerOp, 'g', -1, 64),
+			strconv.FormatFloat(r.SamplesPerSec, 'g', -1, 64),
+			strconv.FormatInt(r.AllocsPerOp, 10),
+			strconv.FormatInt(r.BytesPerOp, 10),
+			strconv.FormatFloat(r.AllocsPerSample, 'g', -1, 64),
+			strconv.FormatFloat(r.BytesPerSample, 'g', -1, 64),
+			strconv.FormatFloat(r.GCPerOp, 'g', -1, 64),
+			strconv.FormatFloat(r.GCPauseNsPerOp, 'g', -1, 64),
+			strconv.FormatFloat(r.P50Ns, 'g', -1, 64),
+			strconv.FormatFloat(r.P95Ns, 'g', -1, 64),
+			strconv.FormatFloat(r.P99Ns, 'g', -1, 64),
+			strconv.Itoa(r.GOMAXPROCS),
+			strconv.Itoa(r.GrainSize),
+			r.CPU,
+			r.GOOS,
+			r.GOARCH,
+			r.GoVersion,
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteText writes the results to w in a format similar to the output of
+// go test -bench
+func WriteText(w io.Writer, results []Result) error {
+	for _, r := range results {
+		_, err := fmt.Fprintf(w, "Benchmark%s-%d\t%8d\t%12.0f ns/op\t%12.0f samples/s\t%8d B/op\t%6d allocs/op\t%8.3g allocs/sample\t%8.3g GC/op",
+			r.Name, r.GOMAXPROCS, r.Iterations, r.NsPerOp, r.SamplesPerSec, r.BytesPerOp, r.AllocsPerOp, r.AllocsPerSample, r.GCPerOp)
+		if err != nil {
+			return err
+		}
+		if r.P50Ns != 0 {
+			_, err = fmt.Fprintf(w, "\t%8.0f p50-ns\t%8.0f p95-ns\t%8.0f p99-ns", r.P50Ns, r.P95Ns, r.P99Ns)
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package benchmark
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"testing"
+)
+
+var testResults = []Result{
+	{
+		Benchmark:     "PredictBatch",
+		Name:          "PredictBatch_10_1_5_100000",
+		Config:        Standard[0],
+		Iterations:    100,
+		NsPerOp:       1.5e6,
+		SamplesPerSec: 6.6e7,
+		AllocsPerOp:   12,
+		BytesPerOp:    960,
+		GOMAXPROCS:    8,
+		GrainSize:     20,
+		CPU:           "Test CPU",
+		GOOS:          "linux",
+		GOARCH:        "amd64",
+		GoVersion:     "go1.x",
+	},
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, testResults); err != nil {
+		t.Fatal(err)
+	}
+	results, err := ReadJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results, testResults) {
+		t.Errorf("Results changed in JSON round trip. Expected %v, found %v", testResults, results)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, testResults); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Wrong number of records. Expected 2, found %v", len(records))
+	}
+	for i, v := range []string{"PredictBatch", "PredictBatch_10_1_5_100000", "10", "1", "1", "5", "100000"} {
+		if records[1][i] != v {
+			t.Errorf("Wrong value for %v. Expected %v, found %v", records[0][i], v, records[1][i])
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package benchmark
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+)
+
+// ProcCounts returns the GOMAXPROCS values 1, 2, 4, ... up to max, including max
+// even if it is not a power of two
+func ProcCounts(max int) []int {
+	var procs []int
+	for p := 1; p < max; p *= 2 {
+		procs = append(procs, p)
+	}
+	return append(procs, max)
+}
+
+// RunScaling runs the benchmark for the configuration once with GOMAXPROCS set
+// to each of the values in procs. GOMAXPROCS is restored before returning. It
+// stops at the first error returned by the benchmark.
+func RunScaling(c Config, bench func(Config) (Result, error), procs []int) ([]Result, error) {
+	old := runtime.GOMAXPROCS(0)
+	defer runtime.GOMAXPROCS(old)
+	results := make([]Result, len(procs))
+	for i, p := range procs {
+		runtime.GOMAXPROCS(p)
+		var err error
+		results[i], err = bench(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// ScalingPoint is the parallel scaling of one benchmark at one GOMAXPROCS
+type ScalingPoint struct {
+	Name       string
+	GOMAXPROCS int
+	NsPerOp    float64
+	Speedup    float64 // The ns/op with GOMAXPROCS=1 divided by NsPerOp
+	Efficiency float64 // Speedup divided by GOMAXPROCS, one for perfect scaling
+}
+
+// Scaling computes the speedup and the parallel efficiency of the results
+// relative to the result with the same name and GOMAXPROCS=1. Results without
+// a corresponding GOMAXPROCS=1 result are ignored. The points are sorted by name
+// and then by GOMAXPROCS.
+func Scaling(results []Result) []ScalingPoint {
+	serial := make(map[string]float64)
+	for _, r := range results {
+		if r.GOMAXPROCS == 1 {
+			serial[r.Name] = r.NsPerOp
+		}
+	}
+	var points []ScalingPoint
+	for _, r := range results {
+		base, ok := serial[r.Name]
+		if !ok {
+			continue
+		}
+		speedup := base / r.NsPerOp
+		points = append(points, ScalingPoint{
+			Name:       r.Name,
+			GOMAXPROCS: r.GOMAXPROCS,
+			NsPerOp:    r.NsPerOp,
+			Speedup:    speedup,
+			Efficiency: speedup / float64(r.GOMAXPROCS),
+		})
+	}
+	sort.Sort(byNameProcs(points))
+	return points
+}
+
+// WriteScaling writes a table of the scaling points to w
+func WriteScaling(w io.Writer, points []ScalingPoint) error {
+	if _, err := fmt.Fprintf(w, "%-40s %6s %14s %8s %10s\n", "name", "procs", "ns/op", "speedup", "efficiency"); err != nil {
+		return err
+	}
+	for _, p := range points {
+		_, err := fmt.Fprintf(w, "%-40s %6d %14.0f %8.2f %9.0f%%\n", p.Name, p.GOMAXPROCS, p.NsPerOp, p.Speedup, 100*p.Efficiency)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type byNameProcs []ScalingPoint
+
+func (b byNameProcs) Len() int { return len(b) }
+func (b byNameProcs) Less(i, j int) bool {
+	if b[i].Name != b[j].Name {
+		return b[i].Name < b[j].Name
+	}
+	return b[i].GOMAXPROCS < b[j].GOMAXPROCS
+}
+func (b byNameProcs) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
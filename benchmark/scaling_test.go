@@ -0,0 +1,66 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package benchmark
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestProcCounts(t *testing.T) {
+	for _, test := range []struct {
+		max   int
+		procs []int
+	}{
+		{1, []int{1}},
+		{4, []int{1, 2, 4}},
+		{6, []int{1, 2, 4, 6}},
+	} {
+		procs := ProcCounts(test.max)
+		if len(procs) != len(test.procs) {
+			t.Errorf("Wrong proc counts for %v. Expected %v, found %v", test.max, test.procs, procs)
+			continue
+		}
+		for i := range procs {
+			if procs[i] != test.procs[i] {
+				t.Errorf("Wrong proc counts for %v. Expected %v, found %v", test.max, test.procs, procs)
+				break
+			}
+		}
+	}
+}
+
+func TestScaling(t *testing.T) {
+	old := runtime.GOMAXPROCS(0)
+	results, err := RunScaling(Config{}, func(Config) (Result, error) {
+		p := runtime.GOMAXPROCS(0)
+		return Result{Name: "a", GOMAXPROCS: p, NsPerOp: 100 / float64(p) * 1.25}, nil
+	}, []int{2, 1, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOMAXPROCS(0) != old {
+		t.Errorf("GOMAXPROCS not restored")
+	}
+	results = append(results, Result{Name: "b", GOMAXPROCS: 2, NsPerOp: 10}) // No serial result
+
+	points := Scaling(results)
+	if len(points) != 3 {
+		t.Fatalf("Wrong number of points. Expected 3, found %v", len(points))
+	}
+	for i, p := range points {
+		procs := 1 << uint(i)
+		if p.GOMAXPROCS != procs {
+			t.Errorf("Points not sorted. Expected procs %v, found %v", procs, p.GOMAXPROCS)
+		}
+		if !approxEqual(p.Speedup, float64(procs)) || !approxEqual(p.Efficiency, 1) {
+			t.Errorf("Wrong scaling at %v procs. Found speedup %v, efficiency %v", procs, p.Speedup, p.Efficiency)
+		}
+	}
+}
+
+func approxEqual(a, b float64) bool {
+	return a-b < 1e-12 && b-a < 1e-12
+}
@@ -0,0 +1,94 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package benchmark
+
+// Sweep describes a grid of benchmark configurations. The configurations are
+// the cross product of the values of each field, filtered to bound the total
+// run time of the sweep.
+type Sweep struct {
+	InputDims  []int
+	OutputDims []int // If empty, an output dimension of one is used
+	NumLayers  []int
+	NumNeurons []int
+	NumSamples []int
+
+	// MaxParameters, if positive, skips the configurations whose nets have more
+	// than MaxParameters parameters.
+	MaxParameters int
+
+	// MaxWork, if positive, skips the configurations where the number of
+	// parameters times the number of samples, roughly the number of floating
+	// point operations of one prediction of the batch, exceeds MaxWork.
+	MaxWork int
+
+	// Filter, if non-nil, skips the configurations for which it returns false
+	Filter func(Config) bool
+}
+
+// Configs returns the configurations of the sweep that pass the filters, in
+// order of increasing input dimension, then number of layers, then number of
+// neurons, then number of samples.
+func (s Sweep) Configs() []Config {
+	outputDims := s.OutputDims
+	if len(outputDims) == 0 {
+		outputDims = []int{1}
+	}
+	var configs []Config
+	for _, inputDim := range s.InputDims {
+		for _, outputDim := range outputDims {
+			for _, nLayers := range s.NumLayers {
+				for _, nNeurons := range s.NumNeurons {
+					for _, nSamples := range s.NumSamples {
+						c := Config{
+							InputDim:   inputDim,
+							OutputDim:  outputDim,
+							NumLayers:  nLayers,
+							NumNeurons: nNeurons,
+							NumSamples: nSamples,
+						}
+						if s.include(c) {
+							configs = append(configs, c)
+						}
+					}
+				}
+			}
+		}
+	}
+	return configs
+}
+
+func (s Sweep) include(c Config) bool {
+	nParams := c.NumParameters()
+	if s.MaxParameters > 0 && nParams > s.MaxParameters {
+		return false
+	}
+	if s.MaxWork > 0 && nParams*c.NumSamples > s.MaxWork {
+		return false
+	}
+	return s.Filter == nil || s.Filter(c)
+}
+
+// NumParameters returns the number of parameters of the net created for the
+// configuration by nnet.NewSimpleTrainer
+func (c Config) NumParameters() int {
+	nInputs := c.InputDim
+	var total int
+	for i := 0; i < c.NumLayers; i++ {
+		total += c.NumNeurons * (nInputs + 1)
+		nInputs = c.NumNeurons
+	}
+	return total + c.OutputDim*(nInputs+1)
+}
+
+// Run runs the benchmark for each of the configurations, for example
+//
+//	Run(Sweep{...}.Configs(), PredictBatch)
+func Run(configs []Config, bench func(Config) Result) []Result {
+	results := make([]Result, len(configs))
+	for i, c := range configs {
+		results[i] = bench(c)
+	}
+	return results
+}
@@ -0,0 +1,71 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package benchmark
+
+import (
+	"testing"
+
+	nnet "github.com/btracey/netbench"
+)
+
+func TestSweep(t *testing.T) {
+	s := Sweep{
+		InputDims:  []int{2, 10},
+		NumLayers:  []int{1, 3},
+		NumNeurons: []int{4},
+		NumSamples: []int{10, 1000},
+	}
+	configs := s.Configs()
+	if len(configs) != 8 {
+		t.Fatalf("Wrong number of configurations. Expected 8, found %v", len(configs))
+	}
+	if configs[1] != (Config{InputDim: 2, OutputDim: 1, NumLayers: 1, NumNeurons: 4, NumSamples: 1000}) {
+		t.Errorf("Wrong configuration order. Found %v", configs[1])
+	}
+
+	// 2 inputs, 3 layers has 12 + 20 + 20 + 5 = 57 parameters, 10 inputs, 3 layers has 89
+	s.MaxParameters = 60
+	if n := len(s.Configs()); n != 6 {
+		t.Errorf("Wrong number of configurations with MaxParameters. Expected 6, found %v", n)
+	}
+	s.MaxParameters = 0
+	s.MaxWork = 10000
+	for _, c := range s.Configs() {
+		if c.NumParameters()*c.NumSamples > s.MaxWork {
+			t.Errorf("Configuration %v exceeds MaxWork", c)
+		}
+	}
+	s.MaxWork = 0
+	s.Filter = func(c Config) bool { return c.NumLayers == 1 }
+	if n := len(s.Configs()); n != 4 {
+		t.Errorf("Wrong number of configurations with Filter. Expected 4, found %v", n)
+	}
+
+	// Configurations which differ only in output dimension have different
+	// names and seeds
+	s = Sweep{InputDims: []int{2}, OutputDims: []int{1, 3}, NumLayers: []int{1}, NumNeurons: []int{4}, NumSamples: []int{10}}
+	configs = s.Configs()
+	if len(configs) != 2 {
+		t.Fatalf("Wrong number of configurations with OutputDims. Expected 2, found %v", len(configs))
+	}
+	if configs[0].Name() != "2_1_4_10" || configs[1].Name() != "2_1_4_10_out3" {
+		t.Errorf("Wrong names %v and %v", configs[0].Name(), configs[1].Name())
+	}
+	if configs[0].seed() == configs[1].seed() {
+		t.Errorf("Same seed for different output dimensions")
+	}
+}
+
+func TestNumParameters(t *testing.T) {
+	for _, c := range Standard {
+		trainer, err := nnet.NewSimpleTrainer(c.InputDim, c.OutputDim, c.NumLayers, c.NumNeurons, nnet.Linear{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.NumParameters() != trainer.TotalNumParameters() {
+			t.Errorf("Wrong number of parameters for %v. Expected %v, found %v", c.Name(), trainer.TotalNumParameters(), c.NumParameters())
+		}
+	}
+}
@@ -6,6 +6,7 @@ package nnet
 
 import (
 	"math/rand"
+	"runtime"
 	"testing"
 )
 
@@ -69,17 +70,28 @@ func benchmarkPredictBatch(b *testing.B, inputDim, outputDim, nLayers, nNeurons,
 	if err != nil {
 		panic(err)
 	}
+	rnd := rand.New(rand.NewSource(1))
+	trainer.SetRand(rnd)
 	trainer.RandomizeParameters()
 	//fmt.Println("Grain size is ", trainer.GrainSize(), "For input = ", inputDim, " nLayers = ", nLayers, " nNeuronsPerLayer = ", nNeurons)
 
 	net := trainer.Predictor()
 
-	input := RandomMat(nSamples, inputDim, rand.NormFloat64)
+	input := RandomMat(nSamples, inputDim, rnd.NormFloat64)
 
-	output := RandomMat(nSamples, outputDim, rand.NormFloat64)
+	output := RandomMat(nSamples, outputDim, rnd.NormFloat64)
 
+	b.ReportAllocs()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		net.PredictBatch(input, output)
 	}
+	b.StopTimer()
+	runtime.ReadMemStats(&after)
+	// Report the allocations per sample as well, which should be nearly zero
+	// as the predictors are reused across chunks of samples
+	b.ReportMetric(float64(after.Mallocs-before.Mallocs)/float64(b.N*nSamples), "allocs/sample")
+	b.ReportMetric(float64(after.NumGC-before.NumGC)/float64(b.N), "GC/op")
 }
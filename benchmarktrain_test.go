@@ -0,0 +1,86 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet_test
+
+import (
+	"testing"
+
+	"github.com/btracey/netbench/benchmark"
+)
+
+/*
+These benchmarks test the speed of training computations on the same
+architectures as the PredictBatch benchmarks (see benchmarkpredict_test.go for
+the meaning of the numbers in the names).
+
+BatchGradient computes the summed parameter gradient over all of the samples.
+SGDEpoch makes one pass over the samples with minibatch stochastic gradient
+descent on the squared error, which in addition to the gradient includes the
+predictions and the parameter updates of every minibatch.
+
+The operations are those of the BatchGradient and SGDEpoch benchmarks of package
+benchmark, so the results of go test and of netbench bench are comparable.
+*/
+
+func BenchmarkBatchGradient_10_1_5_100000(b *testing.B) {
+	benchmarkOp(b, benchmark.BatchGradientOp, 10, 1, 1, 5, 100000)
+}
+
+func BenchmarkBatchGradient_4_15_15_10000(b *testing.B) {
+	benchmarkOp(b, benchmark.BatchGradientOp, 4, 1, 15, 15, 10000)
+}
+
+func BenchmarkBatchGradient_100_10_50_1000(b *testing.B) {
+	benchmarkOp(b, benchmark.BatchGradientOp, 100, 1, 10, 50, 1000)
+}
+
+func BenchmarkBatchGradient_10_100_2_10000(b *testing.B) {
+	benchmarkOp(b, benchmark.BatchGradientOp, 10, 1, 100, 2, 10000)
+}
+
+func BenchmarkBatchGradient_1000_1_10_10000(b *testing.B) {
+	benchmarkOp(b, benchmark.BatchGradientOp, 1000, 1, 1, 10, 10000)
+}
+
+func BenchmarkSGDEpoch_10_1_5_100000(b *testing.B) {
+	benchmarkOp(b, benchmark.SGDEpochOp, 10, 1, 1, 5, 100000)
+}
+
+func BenchmarkSGDEpoch_4_15_15_10000(b *testing.B) {
+	benchmarkOp(b, benchmark.SGDEpochOp, 4, 1, 15, 15, 10000)
+}
+
+func BenchmarkSGDEpoch_100_10_50_1000(b *testing.B) {
+	benchmarkOp(b, benchmark.SGDEpochOp, 100, 1, 10, 50, 1000)
+}
+
+func BenchmarkSGDEpoch_10_100_2_10000(b *testing.B) {
+	benchmarkOp(b, benchmark.SGDEpochOp, 10, 1, 100, 2, 10000)
+}
+
+func BenchmarkSGDEpoch_1000_1_10_10000(b *testing.B) {
+	benchmarkOp(b, benchmark.SGDEpochOp, 1000, 1, 1, 10, 10000)
+}
+
+// benchmarkOp benchmarks the operation returned by newOp for the configuration
+func benchmarkOp(b *testing.B, newOp func(benchmark.Config) (func() error, error), inputDim, outputDim, nLayers, nNeurons, nSamples int) {
+	op, err := newOp(benchmark.Config{
+		InputDim:   inputDim,
+		OutputDim:  outputDim,
+		NumLayers:  nLayers,
+		NumNeurons: nNeurons,
+		NumSamples: nSamples,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := op(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
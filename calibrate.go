@@ -0,0 +1,526 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+)
+
+// A Calibrator maps the outputs of a classifier to calibrated probabilities,
+// so that, for example, of the samples predicted with probability 0.8 about
+// 80% are in the class. Calibrators are fit on the predictions of the
+// classifier on validation data which was not used for training.
+//
+// As for CrossEntropy, a classifier with a single output predicts the
+// probability of the positive class, and a classifier with several outputs
+// predicts a distribution over the classes. PlattScaling and
+// IsotonicCalibration calibrate each output one versus the rest, and with
+// several outputs the calibrated probabilities are normalized to sum to one.
+type Calibrator interface {
+	// Calibrate replaces the outputs of the classifier in x by calibrated
+	// probabilities
+	Calibrate(x []float64) error
+
+	// OutputDim returns the number of outputs of the classifier
+	OutputDim() int
+}
+
+// PlattScaling calibrates output i as the sigmoid of A[i] * x[i] + B[i]. It
+// works well when the miscalibration is sigmoid-shaped, as is typical of
+// outputs which are logits or margins, and needs little validation data.
+type PlattScaling struct {
+	A []float64 `json:"a"`
+	B []float64 `json:"b"`
+}
+
+// FitPlattScaling fits Platt scaling by maximum likelihood to the predictions
+// of a classifier and the true values, where the targets are in [0, 1]. The
+// targets are regularized towards the class frequencies as described by
+// Platt (1999) to avoid overfitting.
+func FitPlattScaling(predictions, targets RowMatrix) (*PlattScaling, error) {
+	cols, err := calibrationColumns("platt scaling", predictions, targets)
+	if err != nil {
+		return nil, err
+	}
+	p := &PlattScaling{
+		A: make([]float64, len(cols)),
+		B: make([]float64, len(cols)),
+	}
+	for j, col := range cols {
+		p.A[j], p.B[j] = fitPlatt(col.scores, col.targets)
+	}
+	return p, nil
+}
+
+// OutputDim returns the number of outputs of the classifier
+func (p *PlattScaling) OutputDim() int {
+	return len(p.A)
+}
+
+func (p *PlattScaling) Calibrate(x []float64) error {
+	if len(x) != len(p.A) {
+		return dimensionError("platt scaling", ErrOutputDim, len(p.A), len(x))
+	}
+	for i, v := range x {
+		x[i] = Sigmoid{}.Activate(p.A[i]*v + p.B[i])
+	}
+	normalizeProbabilities(x)
+	return nil
+}
+
+// fitPlatt returns the parameters of the sigmoid which minimize the cross
+// entropy with the regularized targets, found by Newton's method with a
+// backtracking line search (Lin, Lin and Weng, 2007)
+func fitPlatt(scores, targets []float64) (a, b float64) {
+	var nPos float64
+	for _, t := range targets {
+		nPos += t
+	}
+	nNeg := float64(len(targets)) - nPos
+	hi := (nPos + 1) / (nPos + 2)
+	lo := 1 / (nNeg + 2)
+	t := make([]float64, len(targets))
+	for i, v := range targets {
+		t[i] = v*hi + (1-v)*lo
+	}
+	loss := func(a, b float64) float64 {
+		var l float64
+		for i, s := range scores {
+			z := a*s + b
+			l += t[i]*softplus(-z) + (1-t[i])*softplus(z)
+		}
+		return l
+	}
+
+	a, b = 0, math.Log((nPos+1)/(nNeg+1))
+	f := loss(a, b)
+	const (
+		maxIter = 100
+		minStep = 1e-10
+		sigma   = 1e-12 // Keeps the Hessian positive definite
+		tol     = 1e-5
+	)
+	for iter := 0; iter < maxIter; iter++ {
+		var gA, gB, hAA, hAB, hBB float64
+		for i, s := range scores {
+			p := Sigmoid{}.Activate(a*s + b)
+			d := p - t[i]
+			w := p * (1 - p)
+			gA += d * s
+			gB += d
+			hAA += w * s * s
+			hAB += w * s
+			hBB += w
+		}
+		if math.Abs(gA) < tol && math.Abs(gB) < tol {
+			break
+		}
+		hAA += sigma
+		hBB += sigma
+		det := hAA*hBB - hAB*hAB
+		dA := -(hBB*gA - hAB*gB) / det
+		dB := -(hAA*gB - hAB*gA) / det
+		decrease := gA*dA + gB*dB
+		step := 1.0
+		for ; step >= minStep; step /= 2 {
+			newA, newB := a+step*dA, b+step*dB
+			if newF := loss(newA, newB); newF < f+1e-4*step*decrease {
+				a, b, f = newA, newB, newF
+				break
+			}
+		}
+		if step < minStep {
+			break
+		}
+	}
+	return a, b
+}
+
+// IsotonicCalibration calibrates each output with a non-decreasing function
+// fit by isotonic regression. It can correct any monotonic miscalibration but
+// needs more validation data than PlattScaling to avoid overfitting. The
+// function for output i interpolates linearly between the points
+// (Scores[i][k], Probabilities[i][k]), and is constant beyond the first and
+// last points.
+type IsotonicCalibration struct {
+	Scores        [][]float64 `json:"scores"`
+	Probabilities [][]float64 `json:"probabilities"`
+}
+
+// FitIsotonicCalibration fits isotonic regression to the predictions of a
+// classifier and the true values, where the targets are in [0, 1], using the
+// pool adjacent violators algorithm
+func FitIsotonicCalibration(predictions, targets RowMatrix) (*IsotonicCalibration, error) {
+	cols, err := calibrationColumns("isotonic calibration", predictions, targets)
+	if err != nil {
+		return nil, err
+	}
+	c := &IsotonicCalibration{
+		Scores:        make([][]float64, len(cols)),
+		Probabilities: make([][]float64, len(cols)),
+	}
+	for j, col := range cols {
+		c.Scores[j], c.Probabilities[j] = fitIsotonic(col.scores, col.targets)
+	}
+	return c, nil
+}
+
+// OutputDim returns the number of outputs of the classifier
+func (c *IsotonicCalibration) OutputDim() int {
+	return len(c.Scores)
+}
+
+func (c *IsotonicCalibration) Calibrate(x []float64) error {
+	if len(x) != len(c.Scores) {
+		return dimensionError("isotonic calibration", ErrOutputDim, len(c.Scores), len(x))
+	}
+	for i, v := range x {
+		x[i] = interpolate(c.Scores[i], c.Probabilities[i], v)
+	}
+	normalizeProbabilities(x)
+	return nil
+}
+
+// interpolate returns the piecewise linear interpolation of the points (xs, ys)
+// at x, where xs is sorted, holding the end values constant outside the points
+func interpolate(xs, ys []float64, x float64) float64 {
+	if x <= xs[0] {
+		return ys[0]
+	}
+	k := sort.SearchFloat64s(xs, x)
+	if k == len(xs) {
+		return ys[len(ys)-1]
+	}
+	if xs[k] == x {
+		return ys[k]
+	}
+	frac := (x - xs[k-1]) / (xs[k] - xs[k-1])
+	return ys[k-1] + frac*(ys[k]-ys[k-1])
+}
+
+// fitIsotonic returns the points of the non-decreasing piecewise linear fit to
+// the targets as a function of the scores. Each block of the fit is stored by
+// its first and last scores.
+func fitIsotonic(scores, targets []float64) (xs, ys []float64) {
+	order := make([]int, len(scores))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return scores[order[i]] < scores[order[j]] })
+
+	// Pool adjacent violators. Samples with equal scores start in one block.
+	type block struct {
+		first, last float64 // Scores at the ends of the block
+		sum, weight float64 // Sum and number of the targets
+	}
+	var blocks []block
+	for _, i := range order {
+		s, t := scores[i], targets[i]
+		if len(blocks) != 0 && blocks[len(blocks)-1].last == s {
+			blocks[len(blocks)-1].sum += t
+			blocks[len(blocks)-1].weight++
+		} else {
+			blocks = append(blocks, block{first: s, last: s, sum: t, weight: 1})
+		}
+		for len(blocks) > 1 {
+			n := len(blocks)
+			prev, cur := blocks[n-2], blocks[n-1]
+			if prev.sum/prev.weight <= cur.sum/cur.weight {
+				break
+			}
+			blocks[n-2] = block{first: prev.first, last: cur.last, sum: prev.sum + cur.sum, weight: prev.weight + cur.weight}
+			blocks = blocks[:n-1]
+		}
+	}
+	for _, b := range blocks {
+		y := b.sum / b.weight
+		xs = append(xs, b.first)
+		ys = append(ys, y)
+		if b.last != b.first {
+			xs = append(xs, b.last)
+			ys = append(ys, y)
+		}
+	}
+	return xs, ys
+}
+
+// calibrationColumn holds the predictions and targets of one output
+type calibrationColumn struct {
+	scores, targets []float64
+}
+
+// calibrationColumns checks the predictions and targets used to fit a
+// calibrator and returns them by output
+func calibrationColumns(op string, predictions, targets RowMatrix) ([]calibrationColumn, error) {
+	nSamples, dim := predictions.Dims()
+	nTargets, targetDim := targets.Dims()
+	if targetDim != dim {
+		return nil, dimensionError(op, ErrOutputDim, dim, targetDim)
+	}
+	if nTargets != nSamples {
+		return nil, dimensionError(op, ErrRows, nSamples, nTargets)
+	}
+	if nSamples == 0 {
+		return nil, errors.New(op + ": no samples")
+	}
+	cols := make([]calibrationColumn, dim)
+	for j := range cols {
+		cols[j] = calibrationColumn{
+			scores:  make([]float64, nSamples),
+			targets: make([]float64, nSamples),
+		}
+	}
+	for i := 0; i < nSamples; i++ {
+		for j := range cols {
+			t := targets.At(i, j)
+			if t < 0 || t > 1 {
+				return nil, errors.New(op + ": target not in [0, 1]")
+			}
+			cols[j].scores[i] = predictions.At(i, j)
+			cols[j].targets[i] = t
+		}
+	}
+	return cols, nil
+}
+
+// normalizeProbabilities scales the class probabilities to sum to one if there
+// is more than one class
+func normalizeProbabilities(x []float64) {
+	if len(x) < 2 {
+		return
+	}
+	var sum float64
+	for _, v := range x {
+		sum += v
+	}
+	if sum <= 0 {
+		return
+	}
+	for i := range x {
+		x[i] /= sum
+	}
+}
+
+// TemperatureScaling divides the logits of a classifier by Temperature before
+// converting them to probabilities with the softmax, or with the sigmoid for a
+// single output. A temperature above one softens the predicted distribution
+// and a temperature below one sharpens it, without changing the most probable
+// class. The temperature can be set directly, for example to soften the
+// outputs of a teacher for distillation, or fit to validation data with
+// FitTemperatureScaling, which is an effective calibration of nets which are
+// overconfident. A Temperature of zero is treated as one.
+type TemperatureScaling struct {
+	Temperature float64 `json:"temperature"`
+	Outputs     int     `json:"outputs"`
+}
+
+// FitTemperatureScaling returns the temperature which minimizes the
+// cross-entropy (see CrossEntropy) of the scaled logits predicted by a
+// classifier with the true values
+func FitTemperatureScaling(logits, targets RowMatrix) (*TemperatureScaling, error) {
+	nSamples, dim := logits.Dims()
+	nTargets, targetDim := targets.Dims()
+	if targetDim != dim {
+		return nil, dimensionError("temperature scaling", ErrOutputDim, dim, targetDim)
+	}
+	if nTargets != nSamples {
+		return nil, dimensionError("temperature scaling", ErrRows, nSamples, nTargets)
+	}
+	if nSamples == 0 {
+		return nil, errors.New("temperature scaling: no samples")
+	}
+	z := make([]float64, dim)
+	truth := make([]float64, dim)
+	scaled := make([]float64, dim)
+	deriv := make([]float64, dim)
+	// The loss is convex in the inverse temperature, so it is unimodal in its
+	// logarithm and can be minimized by golden section search
+	loss := func(logInvTemp float64) float64 {
+		invTemp := math.Exp(logInvTemp)
+		var l float64
+		for i := 0; i < nSamples; i++ {
+			z = logits.Row(z, i)
+			truth = targets.Row(truth, i)
+			for j, v := range z {
+				scaled[j] = v * invTemp
+			}
+			l += CrossEntropy{}.LossDeriv(scaled, truth, deriv)
+		}
+		return l
+	}
+	const (
+		lo, hi = -7.0, 7.0 // Range of the logarithm of the inverse temperature
+		tol    = 1e-6
+	)
+	ratio := (math.Sqrt(5) - 1) / 2
+	a, b := lo, hi
+	c, d := b-ratio*(b-a), a+ratio*(b-a)
+	fc, fd := loss(c), loss(d)
+	for b-a > tol {
+		if fc < fd {
+			b, d, fd = d, c, fc
+			c = b - ratio*(b-a)
+			fc = loss(c)
+		} else {
+			a, c, fc = c, d, fd
+			d = a + ratio*(b-a)
+			fd = loss(d)
+		}
+	}
+	return &TemperatureScaling{Temperature: math.Exp(-(a + b) / 2), Outputs: dim}, nil
+}
+
+// OutputDim returns the number of outputs of the classifier
+func (t *TemperatureScaling) OutputDim() int {
+	return t.Outputs
+}
+
+func (t *TemperatureScaling) Calibrate(x []float64) error {
+	if len(x) != t.Outputs {
+		return dimensionError("temperature scaling", ErrOutputDim, t.Outputs, len(x))
+	}
+	temp := t.Temperature
+	if temp == 0 {
+		temp = 1
+	}
+	if len(x) == 1 {
+		x[0] = Sigmoid{}.Activate(x[0] / temp)
+		return nil
+	}
+	logSum := logSumExp(x, temp)
+	for i, v := range x {
+		x[i] = math.Exp(v/temp - logSum)
+	}
+	return nil
+}
+
+// Calibrated is a classifier whose outputs are mapped to calibrated
+// probabilities by a Calibrator. Its batch predictions are calibrated as well.
+type Calibrated struct {
+	*Hooked
+	predictor  Predictor
+	calibrator Calibrator
+}
+
+// NewCalibrated wraps the classifier with the calibrator. The calibrator must
+// have the output dimension of the classifier.
+func NewCalibrated(p Predictor, c Calibrator) (*Calibrated, error) {
+	if c.OutputDim() != p.OutputDim() {
+		return nil, dimensionError("calibrated", ErrOutputDim, p.OutputDim(), c.OutputDim())
+	}
+	return &Calibrated{
+		Hooked:     NewHooked(p, nil, []Hook{c.Calibrate}),
+		predictor:  p,
+		calibrator: c,
+	}, nil
+}
+
+// Predictor returns the classifier without calibration
+func (c *Calibrated) Predictor() Predictor {
+	return c.predictor
+}
+
+// Calibrator returns the calibrator of the outputs
+func (c *Calibrated) Calibrator() Calibrator {
+	return c.calibrator
+}
+
+// jsonCalibrated is the JSON form of a calibrated net
+type jsonCalibrated struct {
+	Net      *Net                 `json:"net"`
+	Platt    *PlattScaling        `json:"platt,omitempty"`
+	Isotonic *IsotonicCalibration `json:"isotonic,omitempty"`
+
+	Temperature *TemperatureScaling `json:"temperature,omitempty"`
+}
+
+// MarshalJSON encodes the classifier and the calibrator as JSON. The
+// classifier must be a *Net, and the calibrator a *PlattScaling,
+// *IsotonicCalibration or *TemperatureScaling.
+func (c *Calibrated) MarshalJSON() ([]byte, error) {
+	net, ok := c.predictor.(*Net)
+	if !ok {
+		if t, isTrainer := c.predictor.(*Trainer); isTrainer {
+			net = t.Net
+		} else {
+			return nil, errors.New("calibrated: classifier is not a net")
+		}
+	}
+	j := jsonCalibrated{Net: net}
+	switch cal := c.calibrator.(type) {
+	case *PlattScaling:
+		j.Platt = cal
+	case *IsotonicCalibration:
+		j.Isotonic = cal
+	case *TemperatureScaling:
+		j.Temperature = cal
+	default:
+		return nil, errors.New("calibrated: calibrator cannot be encoded")
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes a calibrated net encoded by MarshalJSON, replacing the
+// receiver
+func (c *Calibrated) UnmarshalJSON(data []byte) error {
+	var j jsonCalibrated
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if j.Net == nil {
+		return errors.New("calibrated: no net")
+	}
+	var cal Calibrator
+	var nCalibrators int
+	if j.Platt != nil {
+		if len(j.Platt.B) != len(j.Platt.A) {
+			return dimensionError("platt scaling", ErrOutputDim, len(j.Platt.A), len(j.Platt.B))
+		}
+		cal = j.Platt
+		nCalibrators++
+	}
+	if j.Isotonic != nil {
+		if err := j.Isotonic.check(); err != nil {
+			return err
+		}
+		cal = j.Isotonic
+		nCalibrators++
+	}
+	if j.Temperature != nil {
+		if j.Temperature.Temperature < 0 {
+			return errors.New("temperature scaling: negative temperature")
+		}
+		cal = j.Temperature
+		nCalibrators++
+	}
+	if nCalibrators != 1 {
+		return errors.New("calibrated: need exactly one calibrator")
+	}
+	calibrated, err := NewCalibrated(j.Net, cal)
+	if err != nil {
+		return err
+	}
+	*c = *calibrated
+	return nil
+}
+
+// check returns an error if the points of the calibration are malformed
+func (c *IsotonicCalibration) check() error {
+	if len(c.Probabilities) != len(c.Scores) {
+		return dimensionError("isotonic calibration", ErrOutputDim, len(c.Scores), len(c.Probabilities))
+	}
+	for i, xs := range c.Scores {
+		if len(xs) == 0 || len(c.Probabilities[i]) != len(xs) {
+			return errors.New("isotonic calibration: wrong number of points")
+		}
+		if !sort.Float64sAreSorted(xs) {
+			return errors.New("isotonic calibration: scores not sorted")
+		}
+	}
+	return nil
+}
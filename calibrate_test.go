@@ -0,0 +1,241 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// miscalibrated returns scores and binary targets where the probability of the
+// positive class is sigmoid(a*score + b)
+func miscalibrated(rnd *rand.Rand, n int, a, b float64) (scores, targets SosMatrix) {
+	scores = make(SosMatrix, n)
+	targets = make(SosMatrix, n)
+	for i := range scores {
+		s := rnd.NormFloat64()
+		scores[i] = []float64{s}
+		targets[i] = []float64{0}
+		if rnd.Float64() < (Sigmoid{}).Activate(a*s+b) {
+			targets[i][0] = 1
+		}
+	}
+	return scores, targets
+}
+
+func TestPlattScaling(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	scores, targets := miscalibrated(rnd, 20000, 3, -1)
+	p, err := FitPlattScaling(scores, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(p.A[0]-3) > 0.2 || math.Abs(p.B[0]+1) > 0.1 {
+		t.Errorf("Wrong parameters. Expected 3, -1, found %v, %v", p.A[0], p.B[0])
+	}
+	x := []float64{0.5}
+	if err := p.Calibrate(x); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Sigmoid{}).Activate(p.A[0]*0.5 + p.B[0]); x[0] != want {
+		t.Errorf("Wrong calibration. Expected %v, found %v", want, x[0])
+	}
+	if err := p.Calibrate([]float64{1, 2}); err == nil {
+		t.Errorf("Expected dimension error")
+	}
+	if _, err := FitPlattScaling(scores, SosMatrix{{2}}); err == nil {
+		t.Errorf("Expected rows error")
+	}
+}
+
+func TestIsotonicCalibration(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+	scores, targets := miscalibrated(rnd, 20000, 3, -1)
+	c, err := FitIsotonicCalibration(scores, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sort.Float64sAreSorted(c.Scores[0]) || !sort.Float64sAreSorted(c.Probabilities[0]) {
+		t.Errorf("Calibration is not monotonic")
+	}
+	for _, s := range []float64{-1, 0, 0.3, 1} {
+		x := []float64{s}
+		if err := c.Calibrate(x); err != nil {
+			t.Fatal(err)
+		}
+		if want := (Sigmoid{}).Activate(3*s - 1); math.Abs(x[0]-want) > 0.08 {
+			t.Errorf("Wrong calibration at %v. Expected about %v, found %v", s, want, x[0])
+		}
+	}
+
+	// Pool adjacent violators with ties
+	xs, ys := fitIsotonic([]float64{1, 2, 2, 3, 4}, []float64{0, 1, 0, 0, 1})
+	if !floatsEqual(xs, []float64{1, 2, 3, 4}) || !floatsEqualApprox(ys, []float64{0, 1.0 / 3, 1.0 / 3, 1}, 1e-15) {
+		t.Errorf("Wrong isotonic fit %v, %v", xs, ys)
+	}
+	for _, test := range []struct{ x, want float64 }{{0, 0}, {1.5, 1.0 / 6}, {2.5, 1.0 / 3}, {5, 1}} {
+		if got := interpolate(xs, ys, test.x); math.Abs(got-test.want) > 1e-15 {
+			t.Errorf("Wrong interpolation at %v. Expected %v, found %v", test.x, test.want, got)
+		}
+	}
+}
+
+func TestCalibrated(t *testing.T) {
+	s, err := NewSimpleTrainer(3, 3, 1, 4, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.RandomizeParameters()
+	rnd := rand.New(rand.NewSource(3))
+	inputs := RandomMat(200, 3, rnd.NormFloat64)
+	predictions, err := s.PredictBatch(inputs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets := make(SosMatrix, len(inputs))
+	for i, row := range predictions.(SosMatrix) {
+		targets[i] = make([]float64, 3)
+		best := 0
+		for j := range row {
+			if row[j]+rnd.NormFloat64() > row[best] {
+				best = j
+			}
+		}
+		targets[i][best] = 1
+	}
+	platt, err := FitPlattScaling(predictions, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isotonic, err := FitIsotonicCalibration(predictions, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cal := range []Calibrator{platt, isotonic} {
+		c, err := NewCalibrated(s.Net, cal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs, err := c.PredictBatch(inputs, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, row := range outputs.(SosMatrix) {
+			want := append([]float64(nil), predictions.(SosMatrix)[i]...)
+			cal.Calibrate(want)
+			if !floatsEqual(row, want) {
+				t.Errorf("%T: wrong batch prediction %v. Expected %v, found %v", cal, i, want, row)
+			}
+			var sum float64
+			for _, p := range row {
+				sum += p
+			}
+			if math.Abs(sum-1) > 1e-14 {
+				t.Errorf("%T: probabilities sum to %v", cal, sum)
+			}
+		}
+
+		b, err := json.Marshal(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded Calibrated
+		if err := json.Unmarshal(b, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		for i, input := range inputs {
+			want, _ := c.Predict(input, nil)
+			got, err := decoded.Predict(input, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !floatsEqual(got, want) {
+				t.Errorf("%T: prediction %v changed by encoding. Expected %v, found %v", cal, i, want, got)
+			}
+		}
+	}
+	if _, err := NewCalibrated(s.Net, &PlattScaling{A: []float64{1}, B: []float64{0}}); err == nil {
+		t.Errorf("Expected dimension error")
+	}
+	if err := json.Unmarshal([]byte(`{"net":null}`), &Calibrated{}); err == nil {
+		t.Errorf("Expected error without net")
+	}
+}
+
+func TestTemperatureScaling(t *testing.T) {
+	// The logits are twice as large as those of the true distribution, so the
+	// best temperature is two
+	rnd := rand.New(rand.NewSource(4))
+	nSamples, nClasses := 5000, 3
+	logits := make(SosMatrix, nSamples)
+	targets := make(SosMatrix, nSamples)
+	probs := make([]float64, nClasses)
+	for i := range logits {
+		logits[i] = make([]float64, nClasses)
+		for j := range logits[i] {
+			logits[i][j] = 2 * rnd.NormFloat64()
+			probs[j] = logits[i][j] / 2
+		}
+		(&TemperatureScaling{Outputs: nClasses}).Calibrate(probs)
+		targets[i] = make([]float64, nClasses)
+		u := rnd.Float64()
+		for j, p := range probs {
+			if u -= p; u < 0 || j == nClasses-1 {
+				targets[i][j] = 1
+				break
+			}
+		}
+	}
+	ts, err := FitTemperatureScaling(logits, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(ts.Temperature-2) > 0.15 || ts.OutputDim() != nClasses {
+		t.Errorf("Wrong temperature. Expected about 2, found %v", ts.Temperature)
+	}
+
+	// Softmax and sigmoid of the scaled logits
+	x := []float64{1, 2, 3}
+	if err := (&TemperatureScaling{Temperature: 2, Outputs: 3}).Calibrate(x); err != nil {
+		t.Fatal(err)
+	}
+	sum := math.Exp(0.5) + math.Exp(1) + math.Exp(1.5)
+	if !floatsEqualApprox(x, []float64{math.Exp(0.5) / sum, math.Exp(1) / sum, math.Exp(1.5) / sum}, 1e-15) {
+		t.Errorf("Wrong softmax %v", x)
+	}
+	x = []float64{1}
+	(&TemperatureScaling{Temperature: 0.5, Outputs: 1}).Calibrate(x)
+	if x[0] != (Sigmoid{}).Activate(2) {
+		t.Errorf("Wrong sigmoid %v", x)
+	}
+	if err := (&TemperatureScaling{Outputs: 2}).Calibrate(x); err == nil {
+		t.Errorf("Expected dimension error")
+	}
+
+	// The temperature is serialized with the net
+	s, err := NewSimpleTrainer(2, 3, 1, 4, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.RandomizeParameters()
+	c, err := NewCalibrated(s.Net, &TemperatureScaling{Temperature: 1.7, Outputs: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Calibrated
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if temp := decoded.Calibrator().(*TemperatureScaling).Temperature; temp != 1.7 {
+		t.Errorf("Temperature changed by encoding to %v", temp)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+)
+
+// jsonCheckpoint is the JSON form of a checkpoint. The net is stored in its own
+// JSON form (see Net.MarshalJSON).
+type jsonCheckpoint struct {
+	Net       *Net          `json:"net"`
+	Steps     int           `json:"steps"`
+	Epochs    int           `json:"epochs"`
+	Pruned    []bool        `json:"pruned,omitempty"`
+	Optimizer jsonOptimizer `json:"optimizer"`
+	Seed      *int64        `json:"seed,omitempty"`
+}
+
+type jsonOptimizer struct {
+	Type  string          `json:"type"`
+	State json.RawMessage `json:"state"`
+}
+
+// WriteCheckpoint writes the state of the trainer needed to resume training as
+// JSON: the net, the numbers of steps and epochs, which are the position of the
+// learning rate schedule, the prune mask, and the state of the optimizer, which
+// must be a StatefulOptimizer.
+//
+// If a source of randomness was set with SetRand, WriteCheckpoint reseeds it
+// with a seed drawn from it and stores the seed, so that the training resumed
+// by ReadCheckpoint draws the same random numbers as the training continued by
+// the trainer. The source must have been created by rand.NewSource for the
+// numbers to match. The global source is not reseeded or stored.
+func WriteCheckpoint(w io.Writer, s *Trainer) error {
+	opt, ok := s.Optimizer().(StatefulOptimizer)
+	if !ok {
+		return errors.New("checkpoint: optimizer " + optimizerType(s.Optimizer()) + " has no state")
+	}
+	state, err := opt.OptimizerState()
+	if err != nil {
+		return err
+	}
+	j := jsonCheckpoint{
+		Net:       s.Net,
+		Steps:     s.steps,
+		Epochs:    s.epochs,
+		Pruned:    s.pruned,
+		Optimizer: jsonOptimizer{Type: optimizerType(opt), State: state},
+	}
+	if s.rnd != nil {
+		seed := s.rnd.Int63()
+		s.rnd.Seed(seed)
+		j.Seed = &seed
+	}
+	return json.NewEncoder(w).Encode(j)
+}
+
+// ReadCheckpoint reads a checkpoint written by WriteCheckpoint into the trainer.
+// The trainer must have the architecture of the checkpointed net and an
+// optimizer of the same type, whose settings, such as the learning rate, are
+// kept. The loss, the learning rate schedule and the other settings of the
+// trainer are not stored in the checkpoint, so they must be set up as for the
+// original training. If the checkpoint has a seed, the trainer's source of
+// randomness is replaced by one with that seed.
+func ReadCheckpoint(r io.Reader, s *Trainer) error {
+	var j jsonCheckpoint
+	if err := json.NewDecoder(r).Decode(&j); err != nil {
+		return err
+	}
+	if j.Net == nil {
+		return errors.New("checkpoint: no net")
+	}
+	if !equalSizes(j.Net, s.Net) {
+		return errors.New("checkpoint: net architecture does not match the trainer")
+	}
+	opt, ok := s.Optimizer().(StatefulOptimizer)
+	if typ := optimizerType(s.Optimizer()); !ok || typ != j.Optimizer.Type {
+		return errors.New("checkpoint: optimizer " + typ + " does not match " + j.Optimizer.Type)
+	}
+	if j.Steps < 0 || j.Epochs < 0 {
+		return errors.New("checkpoint: negative number of steps or epochs")
+	}
+	if j.Pruned != nil && len(j.Pruned) != s.totalNumParameters {
+		return dimensionError("checkpoint", ErrParameterDim, s.totalNumParameters, len(j.Pruned))
+	}
+	if err := opt.SetOptimizerState(j.Optimizer.State); err != nil {
+		return err
+	}
+	s.SetParameters(j.Net.Parameters(nil))
+	s.SetPruneMask(j.Pruned)
+	s.steps, s.epochs = j.Steps, j.Epochs
+	if j.Seed != nil {
+		s.rnd = rand.New(rand.NewSource(*j.Seed))
+	}
+	return nil
+}
+
+// equalSizes returns whether the nets have the same dimensions and layer sizes
+// and the same number of parameters in each layer
+func equalSizes(a, b *Net) bool {
+	if a.inputDim != b.inputDim || a.outputDim != b.outputDim || len(a.neurons) != len(b.neurons) {
+		return false
+	}
+	for i := range a.neurons {
+		if len(a.neurons[i]) != len(b.neurons[i]) || a.LayerNumParameters(i) != b.LayerNumParameters(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// optimizerType returns the name of the type of the optimizer, which
+// identifies the form of its state
+func optimizerType(opt Optimizer) string {
+	return fmt.Sprintf("%T", opt)
+}
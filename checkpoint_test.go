@@ -0,0 +1,128 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestCheckpoint(t *testing.T) {
+	inputs, targets := NoisySine(100, 0.05, rand.New(rand.NewSource(1)))
+	for _, test := range []struct {
+		name   string
+		newOpt func(s *Trainer) Optimizer
+	}{
+		{"SGD", func(*Trainer) Optimizer { return &SGD{Rate: 0.01, Momentum: 0.9} }},
+		{"Adam", func(*Trainer) Optimizer { return &Adam{Rate: 0.01} }},
+		{"AdamW", func(*Trainer) Optimizer { return &AdamW{Rate: 0.01, WeightDecay: 0.01} }},
+		{"Lookahead", func(*Trainer) Optimizer { return &Lookahead{Inner: &Adam{Rate: 0.01}, K: 3} }},
+		{"Group", func(s *Trainer) Optimizer {
+			g, err := NewGroupOptimizer(s.Net, &SGD{Rate: 0.01, Momentum: 0.9}, ParameterGroup{Layers: []int{0}, Optimizer: &Adam{Rate: 0.001}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return g
+		}},
+	} {
+		newTrainer := func() *Trainer {
+			s, err := NewSimpleTrainer(1, 1, 1, 6, Linear{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.SetOptimizer(test.newOpt(s))
+			s.SetSchedule(LinearDecay{Start: 0.01, Steps: 100})
+			s.SetGradientNoise(GradientNoise{Eta: 1e-4})
+			return s
+		}
+		train := func(s *Trainer, epochs int) {
+			for i := 0; i < epochs; i++ {
+				if _, err := s.TrainEpoch(inputs, targets, 10, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		s := newTrainer()
+		s.SetRand(rand.New(rand.NewSource(2)))
+		s.RandomizeParameters()
+		if _, err := s.PruneMagnitude(0.2); err != nil {
+			t.Fatal(err)
+		}
+		train(s, 3)
+		var buf bytes.Buffer
+		if err := WriteCheckpoint(&buf, s); err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		train(s, 2)
+
+		// Training resumed from the checkpoint matches the uninterrupted training
+		resumed := newTrainer()
+		if err := ReadCheckpoint(bytes.NewReader(buf.Bytes()), resumed); err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		if !equalBools(resumed.PruneMask(), s.PruneMask()) {
+			t.Errorf("%v: prune mask not restored", test.name)
+		}
+		train(resumed, 2)
+		if !floatsEqual(resumed.Parameters(nil), s.Parameters(nil)) {
+			t.Errorf("%v: resumed training differs from uninterrupted training", test.name)
+		}
+		if resumed.Steps() != s.Steps() || resumed.Epochs() != s.Epochs() {
+			t.Errorf("%v: wrong steps and epochs. Expected %v and %v, found %v and %v",
+				test.name, s.Steps(), s.Epochs(), resumed.Steps(), resumed.Epochs())
+		}
+	}
+
+	// The trainer must match the checkpoint
+	s, err := NewSimpleTrainer(1, 1, 1, 6, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetOptimizer(&Adam{Rate: 0.01})
+	var buf bytes.Buffer
+	if err := WriteCheckpoint(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewSimpleTrainer(1, 1, 1, 6, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ReadCheckpoint(bytes.NewReader(buf.Bytes()), other); err == nil {
+		t.Errorf("Expected optimizer mismatch error")
+	}
+	other, err = NewSimpleTrainer(1, 1, 1, 7, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.SetOptimizer(&Adam{Rate: 0.01})
+	if err := ReadCheckpoint(bytes.NewReader(buf.Bytes()), other); err == nil {
+		t.Errorf("Expected architecture mismatch error")
+	}
+	s.SetOptimizer(&statelessOptimizer{})
+	if err := WriteCheckpoint(&buf, s); err == nil {
+		t.Errorf("Expected error for an optimizer without state")
+	}
+}
+
+// statelessOptimizer is an Optimizer which is not a StatefulOptimizer
+type statelessOptimizer struct {
+	rate float64
+}
+
+func (o *statelessOptimizer) Step(parameters, gradient []float64) {
+	for i, g := range gradient {
+		parameters[i] -= o.rate * g
+	}
+}
+
+func (o *statelessOptimizer) LearningRate() float64 {
+	return o.rate
+}
+
+func (o *statelessOptimizer) SetLearningRate(rate float64) {
+	o.rate = rate
+}
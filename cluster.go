@@ -0,0 +1,377 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math/bits"
+	"sort"
+	"strconv"
+)
+
+// ClusterConfig configures the weight clustering of ClusterWeights
+type ClusterConfig struct {
+	// Clusters is the number of shared weight values of each layer, at most
+	// 256. If Clusters is zero, 16 is used.
+	Clusters int
+	// Iterations is the maximum number of k-means iterations. If Iterations is
+	// zero, 50 is used.
+	Iterations int
+}
+
+// ClusteredNet is a net compressed by weight clustering. The weights of each
+// layer are replaced by a small set of shared values, the centroids, and every
+// weight is stored as the index of its centroid, which takes only a few bits
+// per weight. The biases of layers of SumNeurons are few and sensitive, so they
+// are kept exactly. A ClusteredNet is used for storage and transmission, and
+// is decompressed with Net for inference.
+type ClusteredNet struct {
+	inputDim  int
+	outputDim int
+	layers    []clusteredLayer
+}
+
+type clusteredLayer struct {
+	// A layer of SumNeurons with a common activator has the name of the
+	// activator and the biases, and other layers have the descriptions of the
+	// neurons (see RegisterNeuron)
+	activator string
+	neurons   []string
+	bias      []float64
+
+	centroids []float64 // in increasing order
+	indices   []int     // the index of the centroid of every clustered weight
+}
+
+// ClusterWeights compresses the net by clustering the weights of every layer
+// with k-means. The centroids start evenly spaced between the smallest and
+// largest weight of the layer. As with MarshalJSON, all of the neurons and
+// activators of the net must be registered.
+func ClusterWeights(n *Net, cfg ClusterConfig) (*ClusteredNet, error) {
+	k := cfg.Clusters
+	if k == 0 {
+		k = 16
+	}
+	if k < 0 || k > 256 {
+		return nil, errors.New("cluster weights: number of clusters must be between 1 and 256")
+	}
+	iterations := cfg.Iterations
+	if iterations == 0 {
+		iterations = 50
+	}
+	c := &ClusteredNet{
+		inputDim:  n.inputDim,
+		outputDim: n.outputDim,
+		layers:    make([]clusteredLayer, len(n.neurons)),
+	}
+	for i, neurons := range n.neurons {
+		layer := &c.layers[i]
+		var weights []float64
+		if a, ok := sumActivator(neurons); ok {
+			layer.activator = describeActivator(a)
+			if _, err := ActivatorByName(layer.activator); err != nil {
+				return nil, err
+			}
+			layer.bias = make([]float64, len(neurons))
+			for j, p := range n.parameters[i] {
+				weights = append(weights, p[:len(p)-1]...)
+				layer.bias[j] = p[len(p)-1]
+			}
+		} else {
+			layer.neurons = make([]string, len(neurons))
+			for j, neuron := range neurons {
+				layer.neurons[j] = describeNeuron(neuron)
+				if _, err := NeuronByName(layer.neurons[j]); err != nil {
+					return nil, err
+				}
+				weights = append(weights, n.parameters[i][j]...)
+			}
+		}
+		layer.centroids, layer.indices = kMeans1D(weights, k, iterations)
+	}
+	return c, nil
+}
+
+// kMeans1D clusters the values into at most k clusters with Lloyd's algorithm.
+// It returns the centroids in increasing order and the index of the centroid of
+// every value. In one dimension every cluster is an interval, so the order of
+// the centroids is kept by the iterations.
+func kMeans1D(values []float64, k, iterations int) (centroids []float64, indices []int) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	min, max := values[0], values[0]
+	for _, v := range values {
+		if v < min {
+			min = v
+		}
+		if v > max {
+			max = v
+		}
+	}
+	if min == max {
+		k = 1
+	}
+	centroids = make([]float64, k)
+	for i := range centroids {
+		centroids[i] = min + (float64(i)+0.5)*(max-min)/float64(k)
+	}
+	indices = make([]int, len(values))
+	for i := range indices {
+		indices[i] = -1
+	}
+	bounds := make([]float64, k-1)
+	sums := make([]float64, k)
+	counts := make([]int, k)
+	for iter := 0; iter < iterations; iter++ {
+		for i := range bounds {
+			bounds[i] = (centroids[i] + centroids[i+1]) / 2
+		}
+		changed := false
+		for i := range sums {
+			sums[i], counts[i] = 0, 0
+		}
+		for i, v := range values {
+			idx := sort.SearchFloat64s(bounds, v)
+			if idx != indices[i] {
+				indices[i] = idx
+				changed = true
+			}
+			sums[idx] += v
+			counts[idx]++
+		}
+		if !changed {
+			break
+		}
+		// Empty clusters keep their centroid, which stays between its neighbors
+		for i := range centroids {
+			if counts[i] > 0 {
+				centroids[i] = sums[i] / float64(counts[i])
+			}
+		}
+	}
+	return centroids, indices
+}
+
+// InputDim returns the number of inputs of the net
+func (c *ClusteredNet) InputDim() int {
+	return c.inputDim
+}
+
+// OutputDim returns the number of outputs of the net
+func (c *ClusteredNet) OutputDim() int {
+	return c.outputDim
+}
+
+// Net decompresses the net for inference, setting every weight to its
+// centroid
+func (c *ClusteredNet) Net() (*Net, error) {
+	neurons := make([][]Neuron, len(c.layers))
+	for i, layer := range c.layers {
+		if layer.activator != "" {
+			neuron, err := NeuronByName("Sum(" + layer.activator + ")")
+			if err != nil {
+				return nil, err
+			}
+			neurons[i] = make([]Neuron, len(layer.bias))
+			for j := range neurons[i] {
+				neurons[i][j] = neuron
+			}
+			continue
+		}
+		neurons[i] = make([]Neuron, len(layer.neurons))
+		for j, name := range layer.neurons {
+			neuron, err := NeuronByName(name)
+			if err != nil {
+				return nil, err
+			}
+			neurons[i][j] = neuron
+		}
+	}
+	if len(neurons) != 0 && len(neurons[len(neurons)-1]) != c.outputDim {
+		return nil, dimensionError("clustered net", ErrOutputDim, c.outputDim, len(neurons[len(neurons)-1]))
+	}
+	trainer, err := NewTrainer(c.inputDim, c.outputDim, neurons)
+	if err != nil {
+		return nil, err
+	}
+	net := trainer.Net
+	for i, layer := range c.layers {
+		var nWeights int
+		for _, p := range net.parameters[i] {
+			nWeights += len(p)
+		}
+		if layer.activator != "" {
+			nWeights -= len(layer.bias)
+		}
+		if len(layer.indices) != nWeights {
+			return nil, errors.New("clustered net: layer " + strconv.Itoa(i) + " has " +
+				strconv.Itoa(len(layer.indices)) + " weights, expected " + strconv.Itoa(nWeights))
+		}
+		var idx int
+		for j, p := range net.parameters[i] {
+			w := p
+			if layer.activator != "" {
+				w = p[:len(p)-1]
+				p[len(p)-1] = layer.bias[j]
+			}
+			for k := range w {
+				w[k] = layer.centroids[layer.indices[idx]]
+				idx++
+			}
+		}
+	}
+	// The parameters share memory with the layers, so the layers are up to date
+	return net, nil
+}
+
+// jsonClusteredNet is the JSON form of a clustered net. The centroid indices
+// of a layer are packed into the fewest bits which can hold the number of
+// centroids, least significant bit first, and stored as base64.
+type jsonClusteredNet struct {
+	InputDim  int                  `json:"inputDim"`
+	OutputDim int                  `json:"outputDim"`
+	Layers    []jsonClusteredLayer `json:"layers"`
+}
+
+type jsonClusteredLayer struct {
+	Activator string    `json:"activator,omitempty"`
+	Bias      []float64 `json:"bias,omitempty"`
+	Neurons   []string  `json:"neurons,omitempty"`
+
+	Centroids  []float64 `json:"centroids"`
+	NumWeights int       `json:"numWeights"`
+	Indices    []byte    `json:"indices"`
+}
+
+// MarshalJSON encodes the clustered net as JSON
+func (c *ClusteredNet) MarshalJSON() ([]byte, error) {
+	j := jsonClusteredNet{
+		InputDim:  c.inputDim,
+		OutputDim: c.outputDim,
+		Layers:    make([]jsonClusteredLayer, len(c.layers)),
+	}
+	for i, layer := range c.layers {
+		j.Layers[i] = jsonClusteredLayer{
+			Activator:  layer.activator,
+			Bias:       layer.bias,
+			Neurons:    layer.neurons,
+			Centroids:  layer.centroids,
+			NumWeights: len(layer.indices),
+			Indices:    packIndices(layer.indices, indexBits(len(layer.centroids))),
+		}
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes a clustered net encoded by MarshalJSON, replacing the
+// receiver
+func (c *ClusteredNet) UnmarshalJSON(data []byte) error {
+	var j jsonClusteredNet
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if j.InputDim <= 0 || j.OutputDim <= 0 {
+		return errors.New("clustered net: non-positive dimension")
+	}
+	layers := make([]clusteredLayer, len(j.Layers))
+	for i, jl := range j.Layers {
+		if (jl.Activator == "") == (len(jl.Neurons) == 0) {
+			return errors.New("clustered net: layer " + strconv.Itoa(i) + " must have either an activator or neurons")
+		}
+		for k := 1; k < len(jl.Centroids); k++ {
+			if jl.Centroids[k] < jl.Centroids[k-1] {
+				return errors.New("clustered net: layer " + strconv.Itoa(i) + " has unsorted centroids")
+			}
+		}
+		indices, err := unpackIndices(jl.Indices, jl.NumWeights, len(jl.Centroids))
+		if err != nil {
+			return errors.New("clustered net: layer " + strconv.Itoa(i) + ": " + err.Error())
+		}
+		layers[i] = clusteredLayer{
+			activator: jl.Activator,
+			bias:      jl.Bias,
+			neurons:   jl.Neurons,
+			centroids: jl.Centroids,
+			indices:   indices,
+		}
+	}
+	*c = ClusteredNet{
+		inputDim:  j.InputDim,
+		outputDim: j.OutputDim,
+		layers:    layers,
+	}
+	return nil
+}
+
+// indexBits returns the number of bits needed for an index of k centroids
+func indexBits(k int) int {
+	if k <= 1 {
+		return 0
+	}
+	return bits.Len(uint(k - 1))
+}
+
+// packIndices packs the indices with nBits bits each, least significant bit
+// first
+func packIndices(indices []int, nBits int) []byte {
+	packed := make([]byte, (len(indices)*nBits+7)/8)
+	var pos int
+	for _, idx := range indices {
+		for b := 0; b < nBits; b++ {
+			if idx&(1<<uint(b)) != 0 {
+				packed[pos/8] |= 1 << uint(pos%8)
+			}
+			pos++
+		}
+	}
+	return packed
+}
+
+// unpackIndices unpacks n indices of k centroids packed by packIndices
+func unpackIndices(packed []byte, n, k int) ([]int, error) {
+	if n < 0 {
+		return nil, errors.New("negative number of weights")
+	}
+	if n > 0 && k == 0 {
+		return nil, errors.New("weights with no centroids")
+	}
+	nBits := indexBits(k)
+	if len(packed) != (n*nBits+7)/8 {
+		return nil, errors.New("wrong length of packed indices")
+	}
+	indices := make([]int, n)
+	var pos int
+	for i := range indices {
+		var idx int
+		for b := 0; b < nBits; b++ {
+			if packed[pos/8]&(1<<uint(pos%8)) != 0 {
+				idx |= 1 << uint(b)
+			}
+			pos++
+		}
+		if idx >= k {
+			return nil, errors.New("centroid index out of range")
+		}
+		indices[i] = idx
+	}
+	return indices, nil
+}
+
+// ReadClusteredNet reads a clustered net encoded as JSON by MarshalJSON
+func ReadClusteredNet(r io.Reader) (*ClusteredNet, error) {
+	c := &ClusteredNet{}
+	if err := json.NewDecoder(r).Decode(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// WriteClusteredNet writes the clustered net as JSON (see MarshalJSON)
+func WriteClusteredNet(w io.Writer, c *ClusteredNet) error {
+	return json.NewEncoder(w).Encode(c)
+}
@@ -0,0 +1,173 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestKMeans1D(t *testing.T) {
+	values := []float64{0.1, 5, 0, 5.2, -0.1, 4.8, 10}
+	centroids, indices := kMeans1D(values, 3, 50)
+	if !floatsEqualApprox(centroids, []float64{0, 5, 10}, 1e-14) {
+		t.Errorf("Wrong centroids %v", centroids)
+	}
+	if !equalInts(indices, []int{0, 1, 0, 1, 0, 1, 2}) {
+		t.Errorf("Wrong indices %v", indices)
+	}
+	centroids, indices = kMeans1D([]float64{2, 2, 2}, 4, 50)
+	if !floatsEqual(centroids, []float64{2}) || !equalInts(indices, []int{0, 0, 0}) {
+		t.Errorf("Wrong clusters of equal values %v, %v", centroids, indices)
+	}
+}
+
+func TestPackIndices(t *testing.T) {
+	for _, k := range []int{1, 2, 3, 16, 17, 256} {
+		indices := make([]int, 37)
+		for i := range indices {
+			indices[i] = rand.Intn(k)
+		}
+		packed := packIndices(indices, indexBits(k))
+		got, err := unpackIndices(packed, len(indices), k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equalInts(got, indices) {
+			t.Errorf("k = %v: wrong unpacked indices", k)
+		}
+	}
+	if _, err := unpackIndices([]byte{0xff}, 2, 3); err == nil {
+		t.Errorf("Expected index out of range error")
+	}
+	if _, err := unpackIndices([]byte{0, 0}, 2, 3); err == nil {
+		t.Errorf("Expected length error")
+	}
+}
+
+func TestClusterWeights(t *testing.T) {
+	s, err := NewSimpleTrainer(4, 2, 2, 30, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetRand(rand.New(rand.NewSource(1)))
+	s.RandomizeParameters()
+	net := s.Net
+
+	c, err := ClusterWeights(net, ClusterConfig{Clusters: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.InputDim() != 4 || c.OutputDim() != 2 {
+		t.Errorf("Wrong dimensions")
+	}
+	dec, err := c.Net()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !SameArchitecture(net, dec) {
+		t.Fatalf("Architecture changed")
+	}
+	for i, layer := range dec.parameters {
+		weights := make(map[float64]bool)
+		for j, p := range layer {
+			for _, w := range p[:len(p)-1] {
+				weights[w] = true
+			}
+			if bias := net.parameters[i][j]; p[len(p)-1] != bias[len(bias)-1] {
+				t.Errorf("Layer %v: bias %v not kept", i, j)
+			}
+		}
+		if len(weights) > 32 {
+			t.Errorf("Layer %v: %v distinct weights", i, len(weights))
+		}
+	}
+	inputs := RandomMat(50, 4, rand.NormFloat64)
+	want, _ := net.PredictBatch(inputs, nil)
+	got, _ := dec.PredictBatch(inputs, nil)
+	zeros := make(SosMatrix, len(inputs))
+	for i := range zeros {
+		zeros[i] = make([]float64, 2)
+	}
+	scale := MeanSquaredError(want, zeros)
+	mse := MeanSquaredError(got, want)
+	if mse > scale/100 {
+		t.Errorf("Clustered predictions differ by %v, output scale %v", mse, scale)
+	}
+	coarse, err := ClusterWeights(net, ClusterConfig{Clusters: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	coarseNet, _ := coarse.Net()
+	coarsePred, _ := coarseNet.PredictBatch(inputs, nil)
+	if coarseMSE := MeanSquaredError(coarsePred, want); coarseMSE < mse {
+		t.Errorf("Fewer clusters more accurate. %v with 2, %v with 32", coarseMSE, mse)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteClusteredNet(&buf, c); err != nil {
+		t.Fatal(err)
+	}
+	size := buf.Len()
+	read, err := ReadClusteredNet(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readNet, err := read.Net()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Equal(readNet, dec) {
+		t.Errorf("Clustered net changed by the round trip")
+	}
+	buf.Reset()
+	if err := WriteNet(&buf, net); err != nil {
+		t.Fatal(err)
+	}
+	if size > buf.Len()/3 {
+		t.Errorf("Clustered net not compressed. Size %v, uncompressed %v", size, buf.Len())
+	}
+
+	if _, err := ClusterWeights(net, ClusterConfig{Clusters: 257}); err == nil {
+		t.Errorf("Expected error for too many clusters")
+	}
+}
+
+func TestClusteredNetErrors(t *testing.T) {
+	s, err := NewSimpleTrainer(2, 1, 1, 3, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := ClusterWeights(s.Net, ClusterConfig{Clusters: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var j jsonClusteredNet
+	if err := json.Unmarshal(data, &j); err != nil {
+		t.Fatal(err)
+	}
+	j.Layers[0].NumWeights--
+	j.Layers[0].Indices = packIndices(make([]int, j.Layers[0].NumWeights), indexBits(len(j.Layers[0].Centroids)))
+	bad, _ := json.Marshal(j)
+	var cn ClusteredNet
+	if err := json.Unmarshal(bad, &cn); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cn.Net(); err == nil || !strings.Contains(err.Error(), "weights") {
+		t.Errorf("Expected number of weights error, found %v", err)
+	}
+	j.Layers[0].Neurons = []string{"Constant"}
+	bad, _ = json.Marshal(j)
+	if err := json.Unmarshal(bad, &cn); err == nil {
+		t.Errorf("Expected error for a layer with an activator and neurons")
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/btracey/netbench/benchmark"
+)
+
+func runBench(args []string) (err error) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	format := fs.String("format", "text", "output format: text, json or csv")
+	out := fs.String("o", "", "write the results to this file instead of stdout")
+	benchtime := fs.String("benchtime", "1s", "run each benchmark for this long, or Nx for N iterations")
+	benches := fs.String("bench", "PredictBatch", "comma-separated benchmarks to run: PredictBatch, PredictLatency, BatchGradient, SGDEpoch")
+	count := fs.Int("count", 1, "run each benchmark this many times")
+	var sweep benchmark.Sweep
+	fs.Var((*intList)(&sweep.InputDims), "inputs", "comma-separated input dimensions to sweep over")
+	fs.Var((*intList)(&sweep.NumLayers), "layers", "comma-separated numbers of hidden layers to sweep over")
+	fs.Var((*intList)(&sweep.NumNeurons), "neurons", "comma-separated numbers of neurons per layer to sweep over")
+	fs.Var((*intList)(&sweep.NumSamples), "samples", "comma-separated numbers of samples to sweep over")
+	fs.IntVar(&sweep.MaxParameters, "maxparams", 0, "skip swept nets with more parameters than this")
+	fs.IntVar(&sweep.MaxWork, "maxwork", 0, "skip swept configurations where parameters times samples exceeds this")
+	cpuprofile := fs.String("cpuprofile", "", "write a CPU profile of each configuration to this file name with the configuration name added")
+	memprofile := fs.String("memprofile", "", "write a memory profile after each configuration to this file name with the configuration name added")
+	scaling := fs.Int("scaling", 0, "if positive, run each configuration with GOMAXPROCS = 1, 2, 4, ... up to this value and report the parallel scaling")
+	seed := fs.Int64("seed", 0, "seed of the random nets and inputs; if zero, each configuration uses a fixed seed derived from its name")
+	fs.Parse(args)
+
+	// Run the standard configurations unless a sweep is given
+	configs := benchmark.Standard
+	if len(sweep.InputDims) != 0 || len(sweep.NumLayers) != 0 || len(sweep.NumNeurons) != 0 || len(sweep.NumSamples) != 0 {
+		if len(sweep.InputDims) == 0 || len(sweep.NumLayers) == 0 || len(sweep.NumNeurons) == 0 || len(sweep.NumSamples) == 0 {
+			return errors.New("a sweep needs all of -inputs, -layers, -neurons and -samples")
+		}
+		configs = sweep.Configs()
+	}
+
+	// testing.Benchmark reads its run time from the testing flags
+	testing.Init()
+	if err := flag.Set("test.benchtime", *benchtime); err != nil {
+		return err
+	}
+
+	if *seed != 0 {
+		seeded := make([]benchmark.Config, len(configs))
+		for i, c := range configs {
+			c.Seed = *seed
+			seeded[i] = c
+		}
+		configs = seeded
+	}
+
+	var runs []string
+	for _, name := range strings.Split(*benches, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := benchmark.Benchmarks[name]; !ok {
+			return errors.New("unknown benchmark " + name)
+		}
+		runs = append(runs, name)
+	}
+
+	var write func(io.Writer, []benchmark.Result) error
+	switch *format {
+	case "text":
+		write = benchmark.WriteText
+	case "json":
+		write = benchmark.WriteJSON
+	case "csv":
+		write = benchmark.WriteCSV
+	default:
+		return errors.New("unknown format " + *format)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		var f *os.File
+		f, err = os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer closeOutput(f, &err)
+		w = f
+	}
+
+	prof := &profiler{cpu: *cpuprofile, mem: *memprofile}
+	var results []benchmark.Result
+	for i := 0; i < *count; i++ {
+		if *count > 1 {
+			prof.run = i + 1
+		}
+		for _, bench := range runs {
+			fn := func(c benchmark.Config) (benchmark.Result, error) {
+				name := bench + "_" + c.Name()
+				if *scaling > 0 {
+					name += "-" + strconv.Itoa(runtime.GOMAXPROCS(0))
+				}
+				if err := prof.start(name); err != nil {
+					return benchmark.Result{}, err
+				}
+				r, err := benchmark.Benchmarks[bench](c)
+				if err := prof.stop(name); err != nil {
+					return benchmark.Result{}, err
+				}
+				if err != nil {
+					return benchmark.Result{}, err
+				}
+				if *out != "" || *scaling > 0 {
+					// Show progress when the results go elsewhere or are summarized
+					benchmark.WriteText(os.Stderr, []benchmark.Result{r})
+				}
+				return r, nil
+			}
+			for _, c := range configs {
+				var rs []benchmark.Result
+				var err error
+				if *scaling > 0 {
+					rs, err = benchmark.RunScaling(c, fn, benchmark.ProcCounts(*scaling))
+				} else {
+					var r benchmark.Result
+					r, err = fn(c)
+					rs = []benchmark.Result{r}
+				}
+				if err != nil {
+					return err
+				}
+				results = append(results, rs...)
+			}
+		}
+	}
+	if *scaling > 0 && *format == "text" {
+		return benchmark.WriteScaling(w, benchmark.Scaling(results))
+	}
+	return write(w, results)
+}
+
+// intList is a flag.Value for a comma-separated list of integers
+type intList []int
+
+func (l *intList) String() string {
+	var s []string
+	for _, v := range *l {
+		s = append(s, strconv.Itoa(v))
+	}
+	return strings.Join(s, ",")
+}
+
+func (l *intList) Set(s string) error {
+	*l = nil
+	for _, f := range strings.Split(s, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil {
+			return err
+		}
+		*l = append(*l, v)
+	}
+	return nil
+}
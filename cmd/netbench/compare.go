@@ -0,0 +1,58 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/btracey/netbench/benchmark"
+)
+
+// runCompare compares two result files written by netbench bench -format json.
+// It returns an error, and so exits with a non-zero status, if any benchmark
+// regressed by more than the threshold.
+func runCompare(args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	threshold := fs.Float64("threshold", 0.05, "relative slowdown above which a significant change is a regression")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return errors.New("usage: netbench compare [flags] old.json new.json")
+	}
+	old, err := readResults(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	new, err := readResults(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	deltas := benchmark.Compare(old, new)
+	if err := benchmark.WriteDeltas(os.Stdout, deltas, *threshold); err != nil {
+		return err
+	}
+	var nRegressions int
+	for _, d := range deltas {
+		if d.Regression(*threshold) {
+			nRegressions++
+		}
+	}
+	if nRegressions > 0 {
+		return fmt.Errorf("%d regressions beyond %.1f%%", nRegressions, 100**threshold)
+	}
+	return nil
+}
+
+func readResults(name string) ([]benchmark.Result, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return benchmark.ReadJSON(f)
+}
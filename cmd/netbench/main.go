@@ -0,0 +1,69 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+// Command netbench runs the neural net benchmarks and related tools.
+//
+// Usage:
+//
+//	netbench bench [flags]
+//	netbench compare [flags] old.json new.json
+//	netbench components [-plugin file.so]...
+//	netbench predict -model model.json [-in data.csv] [-out preds.csv] [flags]
+//	netbench train -data data.csv -targets cols -model model.json [flags]
+//
+// Run netbench <command> -h for the flags of each command.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type command struct {
+	name  string
+	short string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{name: "bench", short: "run the prediction benchmarks", run: runBench},
+	{name: "compare", short: "compare two sets of JSON benchmark results", run: runCompare},
+	{name: "components", short: "list the registered neurons and activators", run: runComponents},
+	{name: "predict", short: "predict the rows of a CSV file with a saved model", run: runPredict},
+	{name: "train", short: "train a net on a CSV data set and save the model", run: runTrain},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: netbench <command> [flags]")
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "\t%-10s %s\n", c.name, c.short)
+	}
+	os.Exit(2)
+}
+
+// closeOutput closes the output file f and stores the error from closing it
+// in *err if *err is nil, so that a failure to write the last of the output is
+// reported. It is deferred by the commands which write to files.
+func closeOutput(f *os.File, err *error) {
+	if cerr := f.Close(); *err == nil {
+		*err = cerr
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			if err := c.run(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, "netbench "+c.name+":", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	usage()
+}
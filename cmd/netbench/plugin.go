@@ -0,0 +1,49 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"plugin"
+	"strings"
+
+	nnet "github.com/btracey/netbench"
+)
+
+// pluginList is a flag.Value which loads a Go plugin each time the flag is
+// given. Plugins make their neurons and activators available by calling
+// nnet.RegisterNeuron and nnet.RegisterActivator in an init function, which
+// runs when the plugin is loaded.
+type pluginList []string
+
+func (l *pluginList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *pluginList) Set(path string) error {
+	if _, err := plugin.Open(path); err != nil {
+		return err
+	}
+	*l = append(*l, path)
+	return nil
+}
+
+func runComponents(args []string) error {
+	fs := flag.NewFlagSet("components", flag.ExitOnError)
+	var plugins pluginList
+	fs.Var(&plugins, "plugin", "load the neurons and activators registered by this Go plugin; may be repeated")
+	fs.Parse(args)
+
+	fmt.Println("neurons:")
+	for _, name := range nnet.NeuronNames() {
+		fmt.Println("\t" + name)
+	}
+	fmt.Println("activators:")
+	for _, name := range nnet.ActivatorNames() {
+		fmt.Println("\t" + name)
+	}
+	return nil
+}
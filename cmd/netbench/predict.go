@@ -0,0 +1,135 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	nnet "github.com/btracey/netbench"
+)
+
+// runPredict predicts the rows of a CSV file with a model written by
+// nnet.WriteNet, writing one row of outputs per row of inputs. The rows are
+// read and predicted in chunks, so files larger than memory can be predicted.
+func runPredict(args []string) (err error) {
+	fs := flag.NewFlagSet("predict", flag.ExitOnError)
+	model := fs.String("model", "", "model file written by nnet.WriteNet")
+	in := fs.String("in", "", "CSV file of inputs, one sample per row; if empty, stdin is read")
+	out := fs.String("out", "", "write the predictions as CSV to this file instead of stdout")
+	header := fs.Bool("header", false, "the first row of the inputs is a header, which is skipped")
+	chunk := fs.Int("chunk", 10000, "number of rows predicted at a time")
+	var plugins pluginList
+	fs.Var(&plugins, "plugin", "load the neurons and activators registered by this Go plugin; may be repeated")
+	fs.Parse(args)
+	if *model == "" {
+		return errors.New("no -model given")
+	}
+	if *chunk <= 0 {
+		return errors.New("non-positive -chunk")
+	}
+
+	net, err := readNet(*model)
+	if err != nil {
+		return err
+	}
+	r := io.Reader(os.Stdin)
+	if *in != "" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		var f *os.File
+		f, err = os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer closeOutput(f, &err)
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	if err := predictCSV(net, r, bw, *header, *chunk); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func readNet(name string) (*nnet.Net, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return nnet.ReadNet(f)
+}
+
+// predictCSV streams the CSV inputs in r through the predictor in chunks of
+// rows, writing the predictions as CSV to w
+func predictCSV(p nnet.Predictor, r io.Reader, w io.Writer, header bool, chunk int) error {
+	cr := csv.NewReader(nnet.Decompress(r))
+	cr.TrimLeadingSpace = true
+	cr.FieldsPerRecord = p.InputDim()
+	cw := csv.NewWriter(w)
+	if header {
+		if _, err := cr.Read(); err != nil {
+			return err
+		}
+	}
+	inputs := make(nnet.SosMatrix, 0, chunk)
+	outputs := make(nnet.SosMatrix, chunk)
+	for i := range outputs {
+		outputs[i] = make([]float64, p.OutputDim())
+	}
+	record := make([]string, p.OutputDim())
+	for done := false; !done; {
+		inputs = inputs[:0]
+		for len(inputs) < chunk {
+			fields, err := cr.Read()
+			if err == io.EOF {
+				done = true
+				break
+			}
+			if err != nil {
+				return err
+			}
+			input := make([]float64, len(fields))
+			for j, f := range fields {
+				input[j], err = strconv.ParseFloat(strings.TrimSpace(f), 64)
+				if err != nil {
+					line, _ := cr.FieldPos(j)
+					return errors.New("line " + strconv.Itoa(line) + ": " + err.Error())
+				}
+			}
+			inputs = append(inputs, input)
+		}
+		if len(inputs) == 0 {
+			break
+		}
+		if _, err := p.PredictBatch(inputs, outputs[:len(inputs)]); err != nil {
+			return err
+		}
+		for _, output := range outputs[:len(inputs)] {
+			for j, v := range output {
+				record[j] = strconv.FormatFloat(v, 'g', -1, 64)
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,79 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+)
+
+// profiler writes CPU and memory profiles for each benchmark configuration.
+// The name of each profile file is the flag value with the name of the
+// configuration inserted before the extension, so -cpuprofile cpu.pprof gives
+// files such as cpu_PredictBatch_10_1_5_100000.pprof.
+type profiler struct {
+	cpu string // CPU profile file name pattern, or empty
+	mem string // Memory profile file name pattern, or empty
+	run int    // Run number to add to the file names if non-zero
+
+	cpuFile *os.File
+}
+
+// start starts the CPU profile of the named configuration
+func (p *profiler) start(name string) error {
+	if p.cpu == "" {
+		return nil
+	}
+	f, err := os.Create(p.fileName(p.cpu, name))
+	if err != nil {
+		return err
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		return err
+	}
+	p.cpuFile = f
+	return nil
+}
+
+// stop stops the CPU profile and writes the memory profile of the named
+// configuration. The memory profile contains the allocations since the start
+// of the program, so use pprof -base with the profile of the previous
+// configuration to see the allocations of a single configuration.
+func (p *profiler) stop(name string) error {
+	if p.cpuFile != nil {
+		pprof.StopCPUProfile()
+		err := p.cpuFile.Close()
+		p.cpuFile = nil
+		if err != nil {
+			return err
+		}
+	}
+	if p.mem == "" {
+		return nil
+	}
+	f, err := os.Create(p.fileName(p.mem, name))
+	if err != nil {
+		return err
+	}
+	runtime.GC()
+	if err := pprof.Lookup("allocs").WriteTo(f, 0); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (p *profiler) fileName(pattern, name string) string {
+	if p.run != 0 {
+		name += "_" + strconv.Itoa(p.run)
+	}
+	ext := filepath.Ext(pattern)
+	return strings.TrimSuffix(pattern, ext) + "_" + name + ext
+}
@@ -0,0 +1,209 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	nnet "github.com/btracey/netbench"
+)
+
+// runTrain trains a net on a CSV data set and writes the model, which can be
+// used with netbench predict, and optionally the training history
+func runTrain(args []string) error {
+	fs := flag.NewFlagSet("train", flag.ExitOnError)
+	data := fs.String("data", "", "CSV data set, one sample per row")
+	header := fs.Bool("header", false, "the first row of the data is a header with the column names")
+	targets := fs.String("targets", "", "comma-separated target columns, by name with -header and by zero-based index otherwise; the other columns are the inputs")
+	model := fs.String("model", "", "write the trained model to this file")
+	history := fs.String("history", "", "write the loss after every epoch as CSV to this file")
+	layers := intList{10}
+	fs.Var(&layers, "layers", "comma-separated numbers of neurons in each hidden layer")
+	activator := fs.String("activator", "Tanh", "activator of the hidden neurons")
+	output := fs.String("output", "Linear", "activator of the output neurons")
+	loss := fs.String("loss", "squared", "loss: squared, crossentropy or focal")
+	smoothing := fs.Float64("smoothing", 0, "label smoothing of the crossentropy loss")
+	gamma := fs.Float64("gamma", 2, "focusing parameter of the focal loss")
+	optimizer := fs.String("optimizer", "adam", "optimizer: sgd, adam or adamw")
+	rate := fs.Float64("rate", 0.001, "learning rate")
+	momentum := fs.Float64("momentum", 0, "momentum of the sgd optimizer")
+	decay := fs.Float64("decay", 0, "weight decay")
+	epochs := fs.Int("epochs", 10, "number of passes over the training data")
+	batch := fs.Int("batch", 32, "number of samples per mini-batch")
+	validation := fs.Float64("validation", 0, "fraction of the samples held out to compute the validation loss")
+	seed := fs.Int64("seed", 1, "seed of the initial parameters, the validation split and the order of the samples")
+	var plugins pluginList
+	fs.Var(&plugins, "plugin", "load the neurons and activators registered by this Go plugin; may be repeated")
+	fs.Parse(args)
+	if *data == "" || *targets == "" || *model == "" {
+		return errors.New("-data, -targets and -model are required")
+	}
+	if *validation < 0 || *validation >= 1 {
+		return errors.New("-validation must be in [0, 1)")
+	}
+
+	cfg := nnet.CSVConfig{Header: *header}
+	for _, t := range strings.Split(*targets, ",") {
+		t = strings.TrimSpace(t)
+		if *header {
+			cfg.TargetNames = append(cfg.TargetNames, t)
+			continue
+		}
+		c, err := strconv.Atoi(t)
+		if err != nil {
+			return errors.New("target column " + t + " is not an index; use -header to select columns by name")
+		}
+		cfg.TargetColumns = append(cfg.TargetColumns, c)
+	}
+	inputs, outputs, err := readCSV(*data, cfg)
+	if err != nil {
+		return err
+	}
+
+	trainer, err := newTrainer(len(inputs[0]), len(outputs[0]), layers, *activator, *output)
+	if err != nil {
+		return err
+	}
+	rnd := rand.New(rand.NewSource(*seed))
+	trainer.SetRand(rnd)
+	trainer.RandomizeParameters()
+	switch *loss {
+	case "squared":
+		trainer.SetLoss(nnet.SquaredDistance{})
+	case "crossentropy":
+		trainer.SetLoss(nnet.CrossEntropy{Smoothing: *smoothing})
+	case "focal":
+		trainer.SetLoss(nnet.Focal{Gamma: *gamma})
+	default:
+		return errors.New("unknown loss " + *loss)
+	}
+	switch *optimizer {
+	case "sgd":
+		trainer.SetOptimizer(&nnet.SGD{Rate: *rate, Momentum: *momentum, WeightDecay: *decay})
+	case "adam":
+		trainer.SetOptimizer(&nnet.Adam{Rate: *rate, WeightDecay: *decay})
+	case "adamw":
+		trainer.SetOptimizer(&nnet.AdamW{Rate: *rate, WeightDecay: *decay})
+	default:
+		return errors.New("unknown optimizer " + *optimizer)
+	}
+
+	trainInputs, trainTargets := nnet.Shuffle(inputs, outputs, rnd)
+	var valInputs, valTargets nnet.RowMatrix
+	if nVal := int(*validation * float64(len(inputs))); nVal > 0 {
+		valInputs, valTargets, trainInputs, trainTargets = nnet.Split(trainInputs, trainTargets, nVal)
+	}
+
+	var hist [][]string
+	start := time.Now()
+	for epoch := 0; epoch < *epochs; epoch++ {
+		trainLoss, err := trainer.TrainEpoch(trainInputs, trainTargets, *batch, nil)
+		if err != nil {
+			return err
+		}
+		row := []string{
+			strconv.Itoa(epoch + 1),
+			strconv.FormatFloat(trainLoss, 'g', -1, 64),
+			"",
+			strconv.FormatFloat(time.Since(start).Seconds(), 'f', 3, 64),
+		}
+		msg := fmt.Sprintf("epoch %d: train loss %.6g", epoch+1, trainLoss)
+		if valInputs != nil {
+			losses, err := trainer.SampleLosses(valInputs, valTargets, nil)
+			if err != nil {
+				return err
+			}
+			var valLoss float64
+			for _, l := range losses {
+				valLoss += l
+			}
+			valLoss /= float64(len(losses))
+			row[2] = strconv.FormatFloat(valLoss, 'g', -1, 64)
+			msg += fmt.Sprintf(", validation loss %.6g", valLoss)
+		}
+		fmt.Fprintln(os.Stderr, msg)
+		hist = append(hist, row)
+	}
+
+	if err := writeNet(*model, trainer.Net); err != nil {
+		return err
+	}
+	if *history != "" {
+		return writeHistory(*history, hist)
+	}
+	return nil
+}
+
+// newTrainer returns a net with hidden layers of the given sizes of sum
+// neurons with the named activators
+func newTrainer(inputDim, outputDim int, layers []int, activator, output string) (*nnet.Trainer, error) {
+	hidden, err := nnet.ActivatorByName(activator)
+	if err != nil {
+		return nil, err
+	}
+	final, err := nnet.ActivatorByName(output)
+	if err != nil {
+		return nil, err
+	}
+	neurons := make([][]nnet.Neuron, len(layers)+1)
+	for i, n := range layers {
+		if n <= 0 {
+			return nil, errors.New("non-positive layer size")
+		}
+		neurons[i] = make([]nnet.Neuron, n)
+		for j := range neurons[i] {
+			neurons[i][j] = nnet.SumNeuron{Activator: hidden}
+		}
+	}
+	neurons[len(layers)] = make([]nnet.Neuron, outputDim)
+	for j := range neurons[len(layers)] {
+		neurons[len(layers)][j] = nnet.SumNeuron{Activator: final}
+	}
+	return nnet.NewTrainer(inputDim, outputDim, neurons)
+}
+
+func readCSV(name string, cfg nnet.CSVConfig) (inputs, targets nnet.SosMatrix, err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	return nnet.ReadCSV(f, cfg)
+}
+
+func writeNet(name string, n *nnet.Net) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := nnet.WriteNet(f, n); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeHistory(name string, rows [][]string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"epoch", "train_loss", "validation_loss", "seconds"})
+	w.WriteAll(rows)
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -98,10 +98,10 @@ func testPredictAndBatch(t *testing.T, p Predictor, inputs, trueOutputs RowMatri
 
 		out1, err := p.Predict(input, nil)
 		if err != nil {
-			t.Errorf(name + ": Error predicting with nil output")
+			t.Errorf("%v: Error predicting with nil output", name)
 			return
 		}
-		if !Equal(input, inputCpy) {
+		if !floatsEqual(input, inputCpy) {
 			t.Errorf("%v: input changed with nil input for row %v", name, i)
 			break
 		}
@@ -115,16 +115,16 @@ func testPredictAndBatch(t *testing.T, p Predictor, inputs, trueOutputs RowMatri
 			t.Errorf("%v: error predicting with non-nil input for row %v", name, i)
 			break
 		}
-		if !Equal(input, inputCpy) {
+		if !floatsEqual(input, inputCpy) {
 			t.Errorf("%v: input changed with non-nil input for row %v", name, i)
 			break
 		}
 
-		if !Equal(out1, out2) {
-			t.Errorf(name + ": different answers with nil and non-nil predict ")
+		if !floatsEqual(out1, out2) {
+			t.Errorf("%v: different answers with nil and non-nil predict ", name)
 			break
 		}
-		if !EqualApprox(out1, trueOut, 1e-14) {
+		if !floatsEqualApprox(out1, trueOut, 1e-14) {
 			t.Errorf("%v: predicted output doesn't match for row %v. Expected %v, found %v", name, i, trueOut, out1)
 			break
 		}
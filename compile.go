@@ -0,0 +1,100 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+// Compile returns a function which predicts the output at the input, as
+// Predict does, specialized for the current architecture of the net. It is the
+// lowest-latency way to predict a single sample. The layers of SumNeurons with
+// a common Linear, Tanh, Sigmoid or ReLU activator are compiled into closures
+// which compute the activations directly, with no interface calls, and the
+// temporary memory is allocated once by Compile. Other layers are called
+// through the Layer interface.
+//
+// The function has its own copy of the parameters, so later changes to the
+// net do not affect it. It does not check for non-finite values (see
+// Trainer.SetValidateFinite), and it panics if input or output have the wrong
+// length. Because the temporary memory is shared between calls, the function
+// is not safe for concurrent use; compile once per goroutine instead.
+func (n *Net) Compile() func(input, output []float64) {
+	net := n.Clone()
+	inputDim, outputDim := net.inputDim, net.outputDim
+	steps := make([]func(input, output []float64), len(net.layers))
+	sizes := make([]int, len(net.layers))
+	var maxLayer int
+	for i, layer := range net.layers {
+		steps[i] = compileLayer(layer)
+		sizes[i] = layer.NumOutputs()
+		if sizes[i] > maxLayer {
+			maxLayer = sizes[i]
+		}
+	}
+	last := len(steps) - 1
+	buffers := [2][]float64{make([]float64, maxLayer), make([]float64, maxLayer)}
+	return func(input, output []float64) {
+		if len(input) != inputDim || len(output) != outputDim {
+			panic("nnet: compiled net input or output length mismatch")
+		}
+		for i, step := range steps[:last] {
+			tmp := buffers[i%2][:sizes[i]]
+			step(input, tmp)
+			input = tmp
+		}
+		steps[last](input, output)
+	}
+}
+
+// compileLayer returns the forward pass of the layer. The layer must not be
+// modified afterwards.
+func compileLayer(layer Layer) func(input, output []float64) {
+	l, ok := layer.(*sumLayer)
+	if !ok || l.sparse != nil {
+		return layer.Forward
+	}
+	nInputs, nOutputs := l.nInputs, l.nOutputs
+	stride := nInputs + 1
+	params := l.params
+	switch l.kernel {
+	case linearKernel:
+		return func(input, output []float64) {
+			input = input[:nInputs]
+			for i := 0; i < nOutputs; i++ {
+				output[i] = Linear{}.Activate(weightedSum(params[i*stride:(i+1)*stride], input))
+			}
+		}
+	case tanhKernel:
+		return func(input, output []float64) {
+			input = input[:nInputs]
+			for i := 0; i < nOutputs; i++ {
+				output[i] = Tanh{}.Activate(weightedSum(params[i*stride:(i+1)*stride], input))
+			}
+		}
+	case sigmoidKernel:
+		return func(input, output []float64) {
+			input = input[:nInputs]
+			for i := 0; i < nOutputs; i++ {
+				output[i] = Sigmoid{}.Activate(weightedSum(params[i*stride:(i+1)*stride], input))
+			}
+		}
+	case reluKernel:
+		return func(input, output []float64) {
+			input = input[:nInputs]
+			for i := 0; i < nOutputs; i++ {
+				output[i] = ReLU{}.Activate(weightedSum(params[i*stride:(i+1)*stride], input))
+			}
+		}
+	}
+	return layer.Forward
+}
+
+// weightedSum returns the weighted sum of the inputs plus the bias for a row of
+// a sumLayer, which holds the weights followed by the bias, in the same order
+// as sumLayer.combination
+func weightedSum(row, input []float64) float64 {
+	var combination float64
+	for k, v := range input {
+		combination += row[k] * v
+	}
+	return combination + row[len(input)]
+}
@@ -0,0 +1,110 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for test, neurons := range [][][]Neuron{
+		{{LinearNeuron}},
+		{
+			{TanhNeuron, TanhNeuron, TanhNeuron, TanhNeuron},
+			{SumNeuron{Activator: ReLU{}}, SumNeuron{Activator: ReLU{}}, SumNeuron{Activator: ReLU{}}},
+			{SigmoidNeuron, SigmoidNeuron},
+			{LinearNeuron, LinearNeuron},
+		},
+		{
+			{LinearTanhNeuron, LinearTanhNeuron, LinearTanhNeuron},
+			{TanhNeuron, SigmoidNeuron},
+			{LinearNeuron, ConstantNeuron{}},
+		},
+	} {
+		outputDim := len(neurons[len(neurons)-1])
+		s, err := NewTrainer(3, outputDim, neurons)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.SetRand(rnd)
+		s.RandomizeParameters()
+		f := s.Compile()
+		got := make([]float64, outputDim)
+		for i := 0; i < 10; i++ {
+			input := []float64{rnd.NormFloat64(), rnd.NormFloat64(), rnd.NormFloat64()}
+			want, _ := s.Predict(input, nil)
+			f(input, got)
+			if !floatsEqual(got, want) {
+				t.Errorf("Case %v: compiled prediction %v, expected %v", test, got, want)
+			}
+		}
+
+		// Changing the net does not change the compiled function
+		input := []float64{1, 2, 3}
+		f(input, got)
+		before := append([]float64(nil), got...)
+		params := s.Parameters(nil)
+		for i := range params {
+			params[i]++
+		}
+		s.SetParameters(params)
+		f(input, got)
+		if !floatsEqual(got, before) {
+			t.Errorf("Case %v: compiled function changed by the net", test)
+		}
+	}
+}
+
+func TestCompilePanics(t *testing.T) {
+	s, err := NewSimpleTrainer(3, 1, 1, 2, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := s.Compile()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected panic for wrong input length")
+		}
+	}()
+	f(make([]float64, 2), make([]float64, 1))
+}
+
+func BenchmarkPredict_10_2_50(b *testing.B) {
+	benchmarkPredictSingle(b, false)
+}
+
+func BenchmarkCompiled_10_2_50(b *testing.B) {
+	benchmarkPredictSingle(b, true)
+}
+
+// benchmarkPredictSingle benchmarks the prediction of a single sample with a
+// net of 10 inputs and 2 hidden layers of 50 neurons, through Predict or the
+// compiled function
+func benchmarkPredictSingle(b *testing.B, compiled bool) {
+	s, err := NewSimpleTrainer(10, 1, 2, 50, Linear{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(1))
+	s.SetRand(rnd)
+	s.RandomizeParameters()
+	input := make([]float64, 10)
+	for i := range input {
+		input[i] = rnd.NormFloat64()
+	}
+	output := make([]float64, 1)
+	f := s.Compile()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if compiled {
+			f(input, output)
+		} else {
+			s.Predict(input, output)
+		}
+	}
+}
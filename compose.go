@@ -0,0 +1,245 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import "errors"
+
+// Composite is a predictor built from several branch predictors whose outputs
+// are concatenated and used as the input to a shared top predictor. This allows
+// heterogeneous inputs (for example, an embedding and a set of numeric features)
+// to be processed by separate sub-nets before being combined.
+//
+// As a Predictor, the input to the composite is the concatenation of the inputs
+// to each of the branches in order. PredictMulti and PredictBatchMulti accept the
+// branch inputs separately.
+type Composite struct {
+	branches  []Predictor
+	top       Predictor
+	inputDim  int
+	outputDim int
+	grainSize int
+}
+
+// NewComposite creates a composite predictor from the top predictor and the
+// branches. The input dimension of top must equal the sum of the output
+// dimensions of the branches.
+func NewComposite(top Predictor, branches ...Predictor) (*Composite, error) {
+	if len(branches) == 0 {
+		return nil, errors.New("composite: no branches given")
+	}
+	var inputDim, branchOutputDim int
+	for _, b := range branches {
+		inputDim += b.InputDim()
+		branchOutputDim += b.OutputDim()
+	}
+	if branchOutputDim != top.InputDim() {
+		return nil, dimensionError("composite", ErrInputDim, top.InputDim(), branchOutputDim)
+	}
+	all := append([]Predictor{top}, branches...)
+	return &Composite{
+		branches:  branches,
+		top:       top,
+		inputDim:  inputDim,
+		outputDim: top.OutputDim(),
+		grainSize: combinedGrainSize(all),
+	}, nil
+}
+
+// InputDim returns the total number of inputs of all the branches
+func (c *Composite) InputDim() int {
+	return c.inputDim
+}
+
+// OutputDim returns the number of outputs of the top predictor
+func (c *Composite) OutputDim() int {
+	return c.outputDim
+}
+
+// GrainSize returns the number of samples per parallel work unit
+func (c *Composite) GrainSize() int {
+	return c.grainSize
+}
+
+// NumBranches returns the number of branches of the composite
+func (c *Composite) NumBranches() int {
+	return len(c.branches)
+}
+
+// Predict predicts the output where input is the concatenation of the inputs
+// to all of the branches
+func (c *Composite) Predict(input, output []float64) ([]float64, error) {
+	if len(input) != c.inputDim {
+		return nil, dimensionError("", ErrInputDim, c.inputDim, len(input))
+	}
+	output, err := c.checkOutput(output)
+	if err != nil {
+		return nil, err
+	}
+	return c.newPredictor().Predict(input, output)
+}
+
+// PredictMulti predicts the output where inputs[i] is the input to branch i
+func (c *Composite) PredictMulti(inputs [][]float64, output []float64) ([]float64, error) {
+	if len(inputs) != len(c.branches) {
+		return nil, errors.New("composite: wrong number of branch inputs")
+	}
+	for i, b := range c.branches {
+		if len(inputs[i]) != b.InputDim() {
+			return nil, dimensionError("", ErrInputDim, b.InputDim(), len(inputs[i]))
+		}
+	}
+	output, err := c.checkOutput(output)
+	if err != nil {
+		return nil, err
+	}
+	return c.newPredictor().predictMulti(inputs, output)
+}
+
+func (c *Composite) checkOutput(output []float64) ([]float64, error) {
+	if output == nil {
+		return make([]float64, c.outputDim), nil
+	}
+	if len(output) != c.outputDim {
+		return nil, dimensionError("", ErrOutputDim, c.outputDim, len(output))
+	}
+	return output, nil
+}
+
+// PredictBatch predicts the output for every row of inputs, where each row is
+// the concatenation of the inputs to all of the branches
+func (c *Composite) PredictBatch(inputs RowMatrix, outputs MutableRowMatrix) (MutableRowMatrix, error) {
+	return BatchPredict(compositeBatchPredictor{c}, inputs, outputs, c.inputDim, c.outputDim, c.grainSize)
+}
+
+// PredictBatchMulti predicts the output for every row, where inputs[i] contains
+// the inputs to branch i. If outputs is nil, a new matrix is allocated.
+func (c *Composite) PredictBatchMulti(inputs []RowMatrix, outputs MutableRowMatrix) (MutableRowMatrix, error) {
+	if len(inputs) != len(c.branches) {
+		return outputs, errors.New("composite: wrong number of branch inputs")
+	}
+	nSamples, _ := inputs[0].Dims()
+	for i, b := range c.branches {
+		r, dim := inputs[i].Dims()
+		if dim != b.InputDim() {
+			return outputs, dimensionError("predict batch", ErrInputDim, b.InputDim(), dim)
+		}
+		if r != nSamples {
+			return outputs, dimensionError("predict batch", ErrRows, nSamples, r)
+		}
+	}
+	if outputs == nil {
+		s := make(SosMatrix, nSamples)
+		for i := range s {
+			s[i] = make([]float64, c.outputDim)
+		}
+		outputs = s
+	} else {
+		r, dim := outputs.Dims()
+		if dim != c.outputDim {
+			return outputs, dimensionError("predict batch", ErrOutputDim, c.outputDim, dim)
+		}
+		if r != nSamples {
+			return outputs, dimensionError("predict batch", ErrRows, nSamples, r)
+		}
+	}
+
+	var errs rowErrors
+	f := func(start, end int) {
+		p := c.newPredictor()
+		branchInputs := make([][]float64, len(c.branches))
+		for i, b := range c.branches {
+			branchInputs[i] = make([]float64, b.InputDim())
+		}
+		output := make([]float64, c.outputDim)
+		for r := start; r < end; r++ {
+			for i := range branchInputs {
+				inputs[i].Row(branchInputs[i], r)
+			}
+			if _, err := p.predictMulti(branchInputs, output); err != nil {
+				errs.add(r, err)
+			}
+			outputs.SetRow(r, output)
+		}
+	}
+	ParallelFor(nSamples, c.grainSize, f)
+	return outputs, errs.err()
+}
+
+func (c *Composite) newPredictor() *compositePredictor {
+	p := &compositePredictor{
+		composite: c,
+		branches:  make([]Predictor, len(c.branches)),
+		top:       newPredictorFrom(c.top),
+		inputs:    make([][]float64, len(c.branches)),
+		outputs:   make([][]float64, len(c.branches)),
+		combined:  make([]float64, c.top.InputDim()),
+	}
+	var idx int
+	for i, b := range c.branches {
+		p.branches[i] = newPredictorFrom(b)
+		p.outputs[i] = p.combined[idx : idx+b.OutputDim()]
+		idx += b.OutputDim()
+	}
+	return p
+}
+
+// newPredictorFrom returns a predictor with its own temporary memory if p is
+// a BatchPredictor, and p itself otherwise
+func newPredictorFrom(p Predictor) Predictor {
+	if b, ok := p.(BatchPredictor); ok {
+		return b.NewPredictor()
+	}
+	return p
+}
+
+// compositeBatchPredictor implements BatchPredictor for the composite
+type compositeBatchPredictor struct {
+	*Composite
+}
+
+func (b compositeBatchPredictor) NewPredictor() Predictor {
+	return b.newPredictor()
+}
+
+// compositePredictor contains the temporary memory needed for a prediction.
+// The outputs of the branches are views into the combined input of the top.
+type compositePredictor struct {
+	composite *Composite
+	branches  []Predictor
+	top       Predictor
+	inputs    [][]float64
+	outputs   [][]float64
+	combined  []float64
+}
+
+func (p *compositePredictor) Predict(input, output []float64) ([]float64, error) {
+	var idx int
+	for i, b := range p.branches {
+		p.inputs[i] = input[idx : idx+b.InputDim()]
+		idx += b.InputDim()
+	}
+	return p.predictMulti(p.inputs, output)
+}
+
+func (p *compositePredictor) predictMulti(inputs [][]float64, output []float64) ([]float64, error) {
+	for i, b := range p.branches {
+		if _, err := b.Predict(inputs[i], p.outputs[i]); err != nil {
+			return output, err
+		}
+	}
+	return p.top.Predict(p.combined, output)
+}
+
+func (p *compositePredictor) PredictBatch(inputs RowMatrix, outputs MutableRowMatrix) (MutableRowMatrix, error) {
+	return p.composite.PredictBatch(inputs, outputs)
+}
+
+func (p *compositePredictor) InputDim() int {
+	return p.composite.inputDim
+}
+
+func (p *compositePredictor) OutputDim() int {
+	return p.composite.outputDim
+}
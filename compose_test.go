@@ -0,0 +1,69 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestComposite(t *testing.T) {
+	newNet := func(inputDim, outputDim int) *Trainer {
+		n, err := NewSimpleTrainer(inputDim, outputDim, 1, 4, Linear{})
+		if err != nil {
+			panic(err)
+		}
+		n.RandomizeParameters()
+		return n
+	}
+	a := newNet(3, 2)
+	b := newNet(5, 4)
+	top := newNet(6, 2)
+	if _, err := NewComposite(top, a); err == nil {
+		t.Errorf("No error with mismatched top input dimension")
+	}
+	c, err := NewComposite(top, a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testInputOutputDim(t, c, 8, 2, "composite")
+
+	nSamples := 20
+	inputs := RandomMat(nSamples, 8, rand.NormFloat64)
+	aInputs := make(SosMatrix, nSamples)
+	bInputs := make(SosMatrix, nSamples)
+	trueOutputs := make(SosMatrix, nSamples)
+	for i, input := range inputs {
+		aInputs[i] = input[:3]
+		bInputs[i] = input[3:]
+		aOut, _ := a.Predict(aInputs[i], nil)
+		bOut, _ := b.Predict(bInputs[i], nil)
+		trueOutputs[i], _ = top.Predict(append(aOut, bOut...), nil)
+
+		multi, err := c.PredictMulti([][]float64{aInputs[i], bInputs[i]}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !floatsEqualApprox(multi, trueOutputs[i], 1e-14) {
+			t.Errorf("PredictMulti mismatch for row %v", i)
+		}
+	}
+	testPredictAndBatch(t, c, inputs, trueOutputs, "composite")
+
+	for _, outputs := range []func() (MutableRowMatrix, error){
+		func() (MutableRowMatrix, error) { return c.PredictBatch(inputs, nil) },
+		func() (MutableRowMatrix, error) { return c.PredictBatchMulti([]RowMatrix{aInputs, bInputs}, nil) },
+	} {
+		out, err := outputs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range trueOutputs {
+			if !floatsEqualApprox(out.(SosMatrix)[i], trueOutputs[i], 1e-14) {
+				t.Errorf("batch mismatch for row %v", i)
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// ErrZstd is returned when reading zstd-compressed data, which is detected but
+// not supported as it is not in the standard library
+var ErrZstd = errors.New("zstd compressed data is not supported")
+
+// Decompress returns a reader of the uncompressed contents of r. The format is
+// detected from the first bytes of the data: gzip data is decompressed, and
+// uncompressed data is returned unchanged. The data loaders (ReadCSV,
+// NewCSVStream and NewJSONLStream) decompress their input automatically.
+//
+// The detection happens on the first call to Read, where any error in the
+// compressed header is also returned. zstd data is detected, and reading it
+// returns ErrZstd.
+func Decompress(r io.Reader) io.Reader {
+	if d, ok := r.(*decompressor); ok {
+		return d
+	}
+	return &decompressor{br: bufio.NewReader(r)}
+}
+
+type decompressor struct {
+	br  *bufio.Reader
+	r   io.Reader
+	err error
+}
+
+func (d *decompressor) Read(p []byte) (int, error) {
+	if d.r == nil && d.err == nil {
+		d.r, d.err = d.sniff()
+	}
+	if d.err != nil {
+		return 0, d.err
+	}
+	return d.r.Read(p)
+}
+
+func (d *decompressor) sniff() (io.Reader, error) {
+	magic, err := d.br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(d.br)
+	case bytes.HasPrefix(magic, zstdMagic):
+		return nil, ErrZstd
+	}
+	return d.br, nil
+}
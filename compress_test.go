@@ -0,0 +1,55 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDecompress(t *testing.T) {
+	const data = "x,y\n1,2\n3,4\n"
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(data))
+	w.Close()
+
+	for _, test := range []struct {
+		name string
+		r    io.Reader
+	}{
+		{"plain", strings.NewReader(data)},
+		{"gzip", bytes.NewReader(gz.Bytes())},
+	} {
+		b, err := io.ReadAll(Decompress(test.r))
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.name, err)
+			continue
+		}
+		if string(b) != data {
+			t.Errorf("%v: wrong data. Expected %q, found %q", test.name, data, b)
+		}
+	}
+
+	inputs, targets, err := ReadCSV(bytes.NewReader(gz.Bytes()), CSVConfig{Header: true, TargetNames: []string{"y"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sosEqual(inputs, SosMatrix{{1}, {3}}) || !sosEqual(targets, SosMatrix{{2}, {4}}) {
+		t.Errorf("Wrong data from gzip CSV")
+	}
+
+	if b, err := io.ReadAll(Decompress(strings.NewReader(""))); err != nil || len(b) != 0 {
+		t.Errorf("Wrong result for empty data: %q, %v", b, err)
+	}
+	zstd := []byte{0x28, 0xb5, 0x2f, 0xfd, 0, 0}
+	if _, err := io.ReadAll(Decompress(bytes.NewReader(zstd))); !errors.Is(err, ErrZstd) {
+		t.Errorf("Expected ErrZstd, found %v", err)
+	}
+}
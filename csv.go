@@ -0,0 +1,187 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// CSVConfig describes how ReadCSV reads a data set. Columns can be selected
+// either by name, which requires a header row, or by zero-based index, but not
+// both. If no feature columns are given, all of the columns which are not
+// targets are features.
+type CSVConfig struct {
+	Comma  rune // The field delimiter. If zero, ',' is used
+	Header bool // Whether the first row contains the column names
+
+	FeatureNames   []string
+	TargetNames    []string
+	FeatureColumns []int
+	TargetColumns  []int
+}
+
+// ReadCSV reads a data set from r where each row is a sample, returning the
+// features as the inputs and the targets as the outputs. All of the selected
+// values must parse as floating point numbers, and every row must have the
+// same number of fields. The data may be gzip compressed (see Decompress).
+func ReadCSV(r io.Reader, cfg CSVConfig) (inputs, targets SosMatrix, err error) {
+	dec := newCSVDecoder(r, cfg)
+	for {
+		input, target, err := dec.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		inputs = append(inputs, input)
+		targets = append(targets, target)
+	}
+	if len(inputs) == 0 {
+		return nil, nil, errors.New("csv: no data rows")
+	}
+	return inputs, targets, nil
+}
+
+// csvDecoder reads the samples of a CSV data set one at a time
+type csvDecoder struct {
+	cr  *csv.Reader
+	cfg CSVConfig
+
+	header   []string
+	started  bool
+	features []int
+	targets  []int
+	line     int
+}
+
+func newCSVDecoder(r io.Reader, cfg CSVConfig) *csvDecoder {
+	cr := csv.NewReader(Decompress(r))
+	if cfg.Comma != 0 {
+		cr.Comma = cfg.Comma
+	}
+	cr.TrimLeadingSpace = true
+	return &csvDecoder{cr: cr, cfg: cfg}
+}
+
+// next returns the features and targets of the next sample, or io.EOF if there
+// are no more samples
+func (d *csvDecoder) next() (input, target []float64, err error) {
+	if !d.started {
+		d.started = true
+		if d.cfg.Header {
+			d.line++
+			d.header, err = d.cr.Read()
+			if err != nil {
+				if err == io.EOF {
+					return nil, nil, errors.New("csv: missing header")
+				}
+				return nil, nil, err
+			}
+		}
+	}
+	record, err := d.cr.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+	d.line++
+	if d.features == nil {
+		// The number of columns is known from the first record
+		d.features, d.targets, err = d.cfg.columns(d.header, len(record))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	input, err = parseFields(record, d.features, d.line)
+	if err != nil {
+		return nil, nil, err
+	}
+	target, err = parseFields(record, d.targets, d.line)
+	if err != nil {
+		return nil, nil, err
+	}
+	return input, target, nil
+}
+
+// columns returns the indices of the feature and target columns
+func (cfg CSVConfig) columns(header []string, nColumns int) (features, targets []int, err error) {
+	byName := len(cfg.FeatureNames) != 0 || len(cfg.TargetNames) != 0
+	byIndex := len(cfg.FeatureColumns) != 0 || len(cfg.TargetColumns) != 0
+	if byName && byIndex {
+		return nil, nil, errors.New("csv: columns selected by both name and index")
+	}
+	if byName {
+		if header == nil {
+			return nil, nil, errors.New("csv: columns selected by name without a header")
+		}
+		if features, err = columnIndices(header, cfg.FeatureNames); err != nil {
+			return nil, nil, err
+		}
+		if targets, err = columnIndices(header, cfg.TargetNames); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		features = append(features, cfg.FeatureColumns...)
+		targets = append(targets, cfg.TargetColumns...)
+	}
+	if len(targets) == 0 {
+		return nil, nil, errors.New("csv: no target columns")
+	}
+	for _, c := range append(features, targets...) {
+		if c < 0 || c >= nColumns {
+			return nil, nil, errors.New("csv: column " + strconv.Itoa(c) + " out of range")
+		}
+	}
+	if len(features) == 0 {
+		isTarget := make(map[int]bool)
+		for _, c := range targets {
+			isTarget[c] = true
+		}
+		for c := 0; c < nColumns; c++ {
+			if !isTarget[c] {
+				features = append(features, c)
+			}
+		}
+		if len(features) == 0 {
+			return nil, nil, errors.New("csv: no feature columns")
+		}
+	}
+	return features, targets, nil
+}
+
+// columnIndices returns the index in the header of each of the names
+func columnIndices(header, names []string) ([]int, error) {
+	indices := make([]int, len(names))
+	for i, name := range names {
+		indices[i] = -1
+		for j, h := range header {
+			if strings.TrimSpace(h) == name {
+				indices[i] = j
+				break
+			}
+		}
+		if indices[i] == -1 {
+			return nil, errors.New("csv: no column named " + strconv.Quote(name))
+		}
+	}
+	return indices, nil
+}
+
+// parseFields parses the fields of the record in the given columns
+func parseFields(record []string, columns []int, line int) ([]float64, error) {
+	row := make([]float64, len(columns))
+	for i, c := range columns {
+		v, err := strconv.ParseFloat(strings.TrimSpace(record[c]), 64)
+		if err != nil {
+			return nil, errors.New("csv: line " + strconv.Itoa(line) + ", column " + strconv.Itoa(c) + ": invalid number " + strconv.Quote(record[c]))
+		}
+		row[i] = v
+	}
+	return row, nil
+}
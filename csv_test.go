@@ -0,0 +1,91 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadCSV(t *testing.T) {
+	const withHeader = `a, b, c, y
+1, 2, 3, 4
+5, 6, 7, 8
+`
+	const semicolons = "1;2;3\n4;5;6\n"
+	for _, test := range []struct {
+		name    string
+		data    string
+		cfg     CSVConfig
+		inputs  SosMatrix
+		targets SosMatrix
+	}{
+		{
+			name:    "names",
+			data:    withHeader,
+			cfg:     CSVConfig{Header: true, FeatureNames: []string{"c", "a"}, TargetNames: []string{"y"}},
+			inputs:  SosMatrix{{3, 1}, {7, 5}},
+			targets: SosMatrix{{4}, {8}},
+		},
+		{
+			name:    "default features",
+			data:    withHeader,
+			cfg:     CSVConfig{Header: true, TargetNames: []string{"b", "y"}},
+			inputs:  SosMatrix{{1, 3}, {5, 7}},
+			targets: SosMatrix{{2, 4}, {6, 8}},
+		},
+		{
+			name:    "indices",
+			data:    semicolons,
+			cfg:     CSVConfig{Comma: ';', FeatureColumns: []int{1}, TargetColumns: []int{0}},
+			inputs:  SosMatrix{{2}, {5}},
+			targets: SosMatrix{{1}, {4}},
+		},
+	} {
+		inputs, targets, err := ReadCSV(strings.NewReader(test.data), test.cfg)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !sosEqual(inputs, test.inputs) {
+			t.Errorf("%v: wrong inputs. Expected %v, found %v", test.name, test.inputs, inputs)
+		}
+		if !sosEqual(targets, test.targets) {
+			t.Errorf("%v: wrong targets. Expected %v, found %v", test.name, test.targets, targets)
+		}
+	}
+
+	for _, test := range []struct {
+		name string
+		data string
+		cfg  CSVConfig
+	}{
+		{"no targets", withHeader, CSVConfig{Header: true}},
+		{"unknown name", withHeader, CSVConfig{Header: true, TargetNames: []string{"z"}}},
+		{"names without header", semicolons, CSVConfig{Comma: ';', TargetNames: []string{"a"}}},
+		{"names and indices", withHeader, CSVConfig{Header: true, TargetNames: []string{"y"}, FeatureColumns: []int{0}}},
+		{"index out of range", semicolons, CSVConfig{Comma: ';', TargetColumns: []int{3}}},
+		{"invalid number", "1,x\n", CSVConfig{TargetColumns: []int{0}}},
+		{"ragged", "1,2\n3\n", CSVConfig{TargetColumns: []int{0}}},
+		{"empty", "a,b\n", CSVConfig{Header: true, TargetColumns: []int{0}}},
+	} {
+		_, _, err := ReadCSV(strings.NewReader(test.data), test.cfg)
+		if err == nil {
+			t.Errorf("%v: expected error", test.name)
+		}
+	}
+}
+
+func sosEqual(a, b SosMatrix) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !floatsEqual(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
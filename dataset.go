@@ -0,0 +1,159 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// Dataset is a source of mini-batches of samples
+type Dataset interface {
+	// Next returns the inputs and targets of the next mini-batch, where row i
+	// of targets is the target for row i of inputs. Next returns io.EOF when
+	// there are no more samples.
+	Next() (inputs, targets RowMatrix, err error)
+}
+
+// Stream is a Dataset which reads the samples lazily from an io.Reader, so
+// only one mini-batch is in memory at a time. The last mini-batch may be
+// smaller than the batch size.
+type Stream struct {
+	dec       sampleDecoder
+	batchSize int
+	err       error
+}
+
+// sampleDecoder reads samples one at a time, returning io.EOF at the end
+type sampleDecoder interface {
+	next() (input, target []float64, err error)
+}
+
+// NewCSVStream returns a stream of mini-batches of batchSize samples read from
+// CSV data. See ReadCSV for the format of the data.
+func NewCSVStream(r io.Reader, cfg CSVConfig, batchSize int) *Stream {
+	return newStream(newCSVDecoder(r, cfg), batchSize)
+}
+
+// JSONLConfig describes the format of JSON lines data, where each line is
+// a JSON object with the input and target of one sample as arrays of numbers,
+// for example
+//
+//	{"input": [1, 2, 3], "target": [4]}
+type JSONLConfig struct {
+	InputField  string // If empty, "input" is used
+	TargetField string // If empty, "target" is used
+}
+
+// NewJSONLStream returns a stream of mini-batches of batchSize samples read
+// from JSON lines data. Blank lines are ignored.
+func NewJSONLStream(r io.Reader, cfg JSONLConfig, batchSize int) *Stream {
+	if cfg.InputField == "" {
+		cfg.InputField = "input"
+	}
+	if cfg.TargetField == "" {
+		cfg.TargetField = "target"
+	}
+	return newStream(&jsonlDecoder{scanner: newLineScanner(Decompress(r)), cfg: cfg}, batchSize)
+}
+
+// maxLineSize is the length of the longest line of JSON lines data which can
+// be read
+const maxLineSize = 64 << 20
+
+// newLineScanner returns a scanner over the lines of r which accepts lines of
+// up to maxLineSize bytes, rather than the bufio default of 64 KiB
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineSize)
+	return scanner
+}
+
+func newStream(dec sampleDecoder, batchSize int) *Stream {
+	if batchSize <= 0 {
+		panic("nnet: non-positive batch size")
+	}
+	return &Stream{dec: dec, batchSize: batchSize}
+}
+
+// Next returns the next mini-batch. The inputs and targets are newly allocated
+// SosMatrix values, so they may be kept after the next call. Once Next has
+// returned an error, it returns the same error on all later calls.
+func (s *Stream) Next() (inputs, targets RowMatrix, err error) {
+	if s.err != nil {
+		return nil, nil, s.err
+	}
+	in := make(SosMatrix, 0, s.batchSize)
+	out := make(SosMatrix, 0, s.batchSize)
+	for len(in) < s.batchSize {
+		input, target, err := s.dec.next()
+		if err == io.EOF {
+			s.err = io.EOF
+			break
+		}
+		if err != nil {
+			s.err = err
+			return nil, nil, err
+		}
+		if len(in) > 0 && (len(input) != len(in[0]) || len(target) != len(out[0])) {
+			s.err = errors.New("dataset: samples of different sizes")
+			return nil, nil, s.err
+		}
+		in = append(in, input)
+		out = append(out, target)
+	}
+	if len(in) == 0 {
+		return nil, nil, io.EOF
+	}
+	return in, out, nil
+}
+
+// jsonlDecoder reads the samples of JSON lines data one at a time
+type jsonlDecoder struct {
+	scanner *bufio.Scanner
+	cfg     JSONLConfig
+	line    int
+}
+
+func (d *jsonlDecoder) next() (input, target []float64, err error) {
+	for d.scanner.Scan() {
+		d.line++
+		b := d.scanner.Bytes()
+		if len(bytes.TrimSpace(b)) == 0 {
+			continue
+		}
+		var record map[string]json.RawMessage
+		if err := json.Unmarshal(b, &record); err != nil {
+			return nil, nil, errors.New("jsonl: line " + strconv.Itoa(d.line) + ": " + err.Error())
+		}
+		if input, err = d.field(record, d.cfg.InputField); err != nil {
+			return nil, nil, err
+		}
+		if target, err = d.field(record, d.cfg.TargetField); err != nil {
+			return nil, nil, err
+		}
+		return input, target, nil
+	}
+	if err := d.scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return nil, nil, io.EOF
+}
+
+func (d *jsonlDecoder) field(record map[string]json.RawMessage, name string) ([]float64, error) {
+	raw, ok := record[name]
+	if !ok {
+		return nil, errors.New("jsonl: line " + strconv.Itoa(d.line) + ": missing field " + strconv.Quote(name))
+	}
+	var v []float64
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, errors.New("jsonl: line " + strconv.Itoa(d.line) + ": field " + strconv.Quote(name) + ": " + err.Error())
+	}
+	return v, nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStream(t *testing.T) {
+	const csvData = "x,y\n1,2\n3,4\n5,6\n7,8\n9,10\n"
+	const jsonlData = `{"input": [1], "target": [2]}
+{"input": [3], "target": [4]}
+
+{"input": [5], "target": [6]}
+{"input": [7], "target": [8]}
+{"input": [9], "target": [10]}
+`
+	for _, test := range []struct {
+		name   string
+		stream *Stream
+	}{
+		{"csv", NewCSVStream(strings.NewReader(csvData), CSVConfig{Header: true, TargetNames: []string{"y"}}, 2)},
+		{"jsonl", NewJSONLStream(strings.NewReader(jsonlData), JSONLConfig{}, 2)},
+	} {
+		var sizes []int
+		var x float64 = 1
+		for {
+			inputs, targets, err := test.stream.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%v: unexpected error: %v", test.name, err)
+			}
+			r, _ := inputs.Dims()
+			sizes = append(sizes, r)
+			in := make([]float64, 1)
+			out := make([]float64, 1)
+			for i := 0; i < r; i++ {
+				inputs.Row(in, i)
+				targets.Row(out, i)
+				if in[0] != x || out[0] != x+1 {
+					t.Errorf("%v: wrong sample. Expected %v, %v, found %v, %v", test.name, x, x+1, in[0], out[0])
+				}
+				x += 2
+			}
+		}
+		if !equalInts(sizes, []int{2, 2, 1}) {
+			t.Errorf("%v: wrong batch sizes. Expected [2 2 1], found %v", test.name, sizes)
+		}
+		if _, _, err := test.stream.Next(); err != io.EOF {
+			t.Errorf("%v: expected io.EOF after the end, found %v", test.name, err)
+		}
+	}
+
+	for _, test := range []struct {
+		name   string
+		stream *Stream
+	}{
+		{"bad json", NewJSONLStream(strings.NewReader("{\"input\": [1]\n"), JSONLConfig{}, 2)},
+		{"missing field", NewJSONLStream(strings.NewReader(`{"in": [1], "target": [2]}`), JSONLConfig{}, 2)},
+		{"different sizes", NewJSONLStream(strings.NewReader("{\"input\": [1], \"target\": [2]}\n{\"input\": [1, 2], \"target\": [2]}\n"), JSONLConfig{}, 2)},
+		{"bad csv", NewCSVStream(strings.NewReader("1,x\n"), CSVConfig{TargetColumns: []int{0}}, 2)},
+	} {
+		_, _, err := test.stream.Next()
+		if err == nil || err == io.EOF {
+			t.Errorf("%v: expected error, found %v", test.name, err)
+		}
+	}
+
+	// Lines longer than the default scanner buffer are read
+	long := `{"input": [1],` + strings.Repeat(" ", 100000) + `"target": [2]}`
+	inputs, _, err := NewJSONLStream(strings.NewReader(long), JSONLConfig{}, 2).Next()
+	if err != nil {
+		t.Fatalf("Long line: unexpected error: %v", err)
+	}
+	if r, _ := inputs.Dims(); r != 1 {
+		t.Errorf("Long line: expected 1 sample, found %v", r)
+	}
+}
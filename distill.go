@@ -0,0 +1,117 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"errors"
+	"math"
+)
+
+// DistillationLoss is the loss for distilling a classifier, where both the
+// prediction and the truth are logits, the truth being those of the teacher.
+// The loss is the Kullback-Leibler divergence from the teacher's to the
+// student's class probabilities, both softened by dividing the logits by the
+// Temperature, and multiplied by Temperature^2 so that the size of the gradient
+// does not depend on the temperature. As for CrossEntropy, a single output is
+// a binary classifier with sigmoid probabilities. If Temperature is zero, 1 is
+// used.
+type DistillationLoss struct {
+	Temperature float64
+}
+
+func (d DistillationLoss) LossDeriv(prediction, truth, dLossDPred []float64) float64 {
+	temp := d.Temperature
+	if temp == 0 {
+		temp = 1
+	}
+	if len(prediction) == 1 {
+		z, zt := prediction[0]/temp, truth[0]/temp
+		p, pt := Sigmoid{}.Activate(z), Sigmoid{}.Activate(zt)
+		// KL divergence of the Bernoulli distributions in terms of the logits
+		kl := pt*(softplus(-z)-softplus(-zt)) + (1-pt)*(softplus(z)-softplus(zt))
+		dLossDPred[0] = temp * (p - pt)
+		return temp * temp * kl
+	}
+	logSum := logSumExp(prediction, temp)
+	logSumTeacher := logSumExp(truth, temp)
+	var kl float64
+	for i, z := range prediction {
+		logP := z/temp - logSum
+		logPt := truth[i]/temp - logSumTeacher
+		pt := math.Exp(logPt)
+		kl += pt * (logPt - logP)
+		dLossDPred[i] = temp * (math.Exp(logP) - pt)
+	}
+	return temp * temp * kl
+}
+
+func (DistillationLoss) String() string {
+	return "DistillationLoss"
+}
+
+// logSumExp returns log(sum_i exp(x[i] / temp)) without overflow
+func logSumExp(x []float64, temp float64) float64 {
+	max := math.Inf(-1)
+	for _, v := range x {
+		max = math.Max(max, v/temp)
+	}
+	var sum float64
+	for _, v := range x {
+		sum += math.Exp(v/temp - max)
+	}
+	return max + math.Log(sum)
+}
+
+// Distiller trains a student net to reproduce the outputs of a teacher, which
+// is typically a larger and slower net, on unlabeled inputs.
+type Distiller struct {
+	// Temperature is the temperature of the DistillationLoss used for
+	// classifiers whose outputs are logits. If zero, the student's own loss
+	// is used with the outputs of the teacher as targets, as for regression.
+	Temperature float64
+
+	Epochs    int // If zero, 10 is used
+	BatchSize int // If zero, 32 is used
+}
+
+// Distill trains the student for the given number of epochs with TrainEpoch
+// on the inputs, with the targets predicted by the teacher in parallel with
+// PredictBatch. Distill returns the mean loss of the last epoch.
+func (d Distiller) Distill(student *Trainer, teacher Predictor, inputs RowMatrix) (float64, error) {
+	if teacher.InputDim() != student.InputDim() {
+		return 0, dimensionError("distill", ErrInputDim, student.InputDim(), teacher.InputDim())
+	}
+	if teacher.OutputDim() != student.OutputDim() {
+		return 0, dimensionError("distill", ErrOutputDim, student.OutputDim(), teacher.OutputDim())
+	}
+	epochs := d.Epochs
+	if epochs == 0 {
+		epochs = 10
+	}
+	batchSize := d.BatchSize
+	if batchSize == 0 {
+		batchSize = 32
+	}
+	if epochs < 0 || batchSize < 0 {
+		return 0, errors.New("distill: negative epochs or batch size")
+	}
+	targets, err := teacher.PredictBatch(inputs, nil)
+	if err != nil {
+		return 0, err
+	}
+	if d.Temperature != 0 {
+		loss := student.loss
+		defer student.SetLoss(loss)
+		student.SetLoss(DistillationLoss{Temperature: d.Temperature})
+	}
+	var loss float64
+	for i := 0; i < epochs; i++ {
+		loss, err = student.TrainEpoch(inputs, targets, batchSize, nil)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return loss, nil
+}
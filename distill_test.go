@@ -0,0 +1,77 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestDistillationLoss(t *testing.T) {
+	for _, logits := range [][]float64{{0.3, -1, 2}, {0.7}} {
+		deriv := make([]float64, len(logits))
+		if l := (DistillationLoss{Temperature: 2}).LossDeriv(logits, logits, deriv); math.Abs(l) > 1e-14 {
+			t.Errorf("Non-zero loss %v for identical logits", l)
+		}
+	}
+	for _, temp := range []float64{0, 1, 3} {
+		loss := DistillationLoss{Temperature: temp}
+		testLossDeriv(t, "DistillationLoss", loss, []float64{0.3, -1, 2}, []float64{1, 0.5, -2})
+		testLossDeriv(t, "DistillationLoss binary", loss, []float64{0.7}, []float64{-1.5})
+	}
+	// At a temperature of one with a confident teacher the loss approaches the
+	// cross entropy
+	deriv := make([]float64, 3)
+	prediction := []float64{0.3, -1, 2}
+	d := DistillationLoss{}.LossDeriv(prediction, []float64{0, 100, 0}, deriv)
+	ce := CrossEntropy{}.LossDeriv(prediction, []float64{0, 1, 0}, deriv)
+	if math.Abs(d-ce) > 1e-10 {
+		t.Errorf("Distillation loss %v differs from cross entropy %v", d, ce)
+	}
+}
+
+func TestDistill(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	teacher, err := NewSimpleTrainer(2, 3, 2, 30, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	teacher.SetRand(rnd)
+	teacher.RandomizeParameters()
+	inputs := RandomMat(300, 2, rnd.NormFloat64)
+
+	for _, temp := range []float64{0, 2} {
+		student, err := NewSimpleTrainer(2, 3, 1, 10, Linear{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		student.SetRand(rand.New(rand.NewSource(2)))
+		student.RandomizeParameters()
+		student.SetOptimizer(&Adam{Rate: 0.01})
+		d := Distiller{Temperature: temp, Epochs: 1}
+		first, err := d.Distill(student, teacher, inputs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.Epochs = 30
+		last, err := d.Distill(student, teacher, inputs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last > first/4 {
+			t.Errorf("Temperature %v: loss not reduced enough. First %v, last %v", temp, first, last)
+		}
+		if _, ok := student.Loss().(SquaredDistance); !ok {
+			t.Errorf("Temperature %v: student loss not restored", temp)
+		}
+	}
+
+	student, _ := NewSimpleTrainer(3, 3, 1, 10, Linear{})
+	if _, err := (Distiller{}).Distill(student, teacher, inputs); !errors.Is(err, ErrInputDim) {
+		t.Errorf("Expected ErrInputDim, found %v", err)
+	}
+}
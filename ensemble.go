@@ -0,0 +1,262 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"errors"
+	"math"
+)
+
+// Ensemble is a collection of predictors with the same input and output
+// dimensions. The prediction of the ensemble is the mean of the predictions
+// of its members, and the spread of the member predictions can be used
+// as an estimate of the predictive uncertainty.
+type Ensemble struct {
+	members   []Predictor
+	inputDim  int
+	outputDim int
+	grainSize int
+}
+
+// NewEnsemble creates an ensemble from the given predictors. All of the members
+// must have the same input and output dimensions
+func NewEnsemble(members ...Predictor) (*Ensemble, error) {
+	if len(members) == 0 {
+		return nil, errors.New("ensemble: no members given")
+	}
+	inputDim := members[0].InputDim()
+	outputDim := members[0].OutputDim()
+	for _, m := range members[1:] {
+		if m.InputDim() != inputDim {
+			return nil, dimensionError("ensemble", ErrInputDim, inputDim, m.InputDim())
+		}
+		if m.OutputDim() != outputDim {
+			return nil, dimensionError("ensemble", ErrOutputDim, outputDim, m.OutputDim())
+		}
+	}
+	e := &Ensemble{
+		members:   members,
+		inputDim:  inputDim,
+		outputDim: outputDim,
+		grainSize: combinedGrainSize(members),
+	}
+	return e, nil
+}
+
+// InputDim returns the number of inputs expected by the ensemble
+func (e *Ensemble) InputDim() int {
+	return e.inputDim
+}
+
+// OutputDim returns the number of outputs of the ensemble
+func (e *Ensemble) OutputDim() int {
+	return e.outputDim
+}
+
+// NumMembers returns the number of predictors in the ensemble
+func (e *Ensemble) NumMembers() int {
+	return len(e.members)
+}
+
+// GrainSize returns the number of samples per parallel work unit
+func (e *Ensemble) GrainSize() int {
+	return e.grainSize
+}
+
+// Predict returns the mean of the predictions of the members
+func (e *Ensemble) Predict(input, output []float64) ([]float64, error) {
+	if len(input) != e.inputDim {
+		return nil, dimensionError("", ErrInputDim, e.inputDim, len(input))
+	}
+	if output == nil {
+		output = make([]float64, e.outputDim)
+	} else {
+		if len(output) != e.outputDim {
+			return nil, dimensionError("", ErrOutputDim, e.outputDim, len(output))
+		}
+	}
+	p := e.newPredictor()
+	return p.Predict(input, output)
+}
+
+// PredictBatch predicts the mean of the members at every row of inputs
+func (e *Ensemble) PredictBatch(inputs RowMatrix, outputs MutableRowMatrix) (MutableRowMatrix, error) {
+	return BatchPredict(ensembleBatchPredictor{e}, inputs, outputs, e.inputDim, e.outputDim, e.grainSize)
+}
+
+// PredictMeanVariance computes the mean and the variance of the member predictions
+// at every row of inputs. If means or variances is nil, new matrices are allocated.
+// The variance is the population variance of the member predictions, so an ensemble
+// with a single member has zero variance.
+func (e *Ensemble) PredictMeanVariance(inputs RowMatrix, means, variances MutableRowMatrix) (MutableRowMatrix, MutableRowMatrix, error) {
+	nSamples, dimInputs := inputs.Dims()
+	if dimInputs != e.inputDim {
+		return means, variances, dimensionError("ensemble", ErrInputDim, e.inputDim, dimInputs)
+	}
+	var err error
+	means, err = e.checkOrAlloc(means, nSamples)
+	if err != nil {
+		return means, variances, err
+	}
+	variances, err = e.checkOrAlloc(variances, nSamples)
+	if err != nil {
+		return means, variances, err
+	}
+
+	var errs rowErrors
+	f := func(start, end int) {
+		p := e.newPredictor()
+		input := make([]float64, e.inputDim)
+		mean := make([]float64, e.outputDim)
+		variance := make([]float64, e.outputDim)
+		for i := start; i < end; i++ {
+			inputs.Row(input, i)
+			if err := p.meanVariance(input, mean, variance); err != nil {
+				errs.add(i, err)
+			}
+			means.SetRow(i, mean)
+			variances.SetRow(i, variance)
+		}
+	}
+	ParallelFor(nSamples, e.grainSize, f)
+	return means, variances, errs.err()
+}
+
+// PredictInterval computes a symmetric prediction interval mean ± z * std at every
+// row of inputs, where std is the standard deviation of the member predictions.
+// For example, z = 1.96 gives an approximate 95% interval if the member predictions
+// are normally distributed. If lower or upper is nil, new matrices are allocated.
+func (e *Ensemble) PredictInterval(inputs RowMatrix, z float64, lower, upper MutableRowMatrix) (MutableRowMatrix, MutableRowMatrix, error) {
+	if z < 0 {
+		return lower, upper, errors.New("ensemble: negative z")
+	}
+	means, variances, err := e.PredictMeanVariance(inputs, lower, upper)
+	if err != nil {
+		return lower, upper, err
+	}
+	// The means and variances were stored in lower and upper respectively, so
+	// transform them in place.
+	nSamples, _ := inputs.Dims()
+	mean := make([]float64, e.outputDim)
+	variance := make([]float64, e.outputDim)
+	for i := 0; i < nSamples; i++ {
+		means.Row(mean, i)
+		variances.Row(variance, i)
+		for j := range mean {
+			w := z * math.Sqrt(variance[j])
+			mean[j], variance[j] = mean[j]-w, mean[j]+w
+		}
+		means.SetRow(i, mean)
+		variances.SetRow(i, variance)
+	}
+	return means, variances, nil
+}
+
+func (e *Ensemble) checkOrAlloc(m MutableRowMatrix, nSamples int) (MutableRowMatrix, error) {
+	if m == nil {
+		s := make(SosMatrix, nSamples)
+		for i := range s {
+			s[i] = make([]float64, e.outputDim)
+		}
+		return s, nil
+	}
+	r, c := m.Dims()
+	if c != e.outputDim {
+		return m, dimensionError("ensemble", ErrOutputDim, e.outputDim, c)
+	}
+	if r != nSamples {
+		return m, dimensionError("ensemble", ErrRows, nSamples, r)
+	}
+	return m, nil
+}
+
+// newPredictor creates the temporary memory needed for a prediction. If the
+// members have their own batch predictors, those are used to avoid allocations
+// on every call.
+func (e *Ensemble) newPredictor() *ensemblePredictor {
+	members := make([]Predictor, len(e.members))
+	for i, m := range e.members {
+		members[i] = newPredictorFrom(m)
+	}
+	return &ensemblePredictor{
+		ensemble: e,
+		members:  members,
+		tmp:      make([]float64, e.outputDim),
+	}
+}
+
+// ensembleBatchPredictor implements BatchPredictor for the ensemble
+type ensembleBatchPredictor struct {
+	*Ensemble
+}
+
+func (b ensembleBatchPredictor) NewPredictor() Predictor {
+	return b.newPredictor()
+}
+
+// ensemblePredictor contains the temporary memory needed to combine the
+// predictions of the members
+type ensemblePredictor struct {
+	ensemble *Ensemble
+	members  []Predictor
+	tmp      []float64
+}
+
+func (p *ensemblePredictor) Predict(input, output []float64) ([]float64, error) {
+	for j := range output {
+		output[j] = 0
+	}
+	for _, m := range p.members {
+		_, err := m.Predict(input, p.tmp)
+		if err != nil {
+			return output, err
+		}
+		for j, v := range p.tmp {
+			output[j] += v
+		}
+	}
+	n := float64(len(p.members))
+	for j := range output {
+		output[j] /= n
+	}
+	return output, nil
+}
+
+// meanVariance computes the mean and variance of the member predictions using
+// Welford's algorithm
+func (p *ensemblePredictor) meanVariance(input, mean, variance []float64) error {
+	for j := range mean {
+		mean[j] = 0
+		variance[j] = 0
+	}
+	for k, m := range p.members {
+		_, err := m.Predict(input, p.tmp)
+		if err != nil {
+			return err
+		}
+		for j, v := range p.tmp {
+			delta := v - mean[j]
+			mean[j] += delta / float64(k+1)
+			variance[j] += delta * (v - mean[j])
+		}
+	}
+	n := float64(len(p.members))
+	for j := range variance {
+		variance[j] /= n
+	}
+	return nil
+}
+
+func (p *ensemblePredictor) PredictBatch(inputs RowMatrix, outputs MutableRowMatrix) (MutableRowMatrix, error) {
+	return p.ensemble.PredictBatch(inputs, outputs)
+}
+
+func (p *ensemblePredictor) InputDim() int {
+	return p.ensemble.inputDim
+}
+
+func (p *ensemblePredictor) OutputDim() int {
+	return p.ensemble.outputDim
+}
@@ -0,0 +1,100 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func newTestEnsemble(nMembers int) *Ensemble {
+	members := make([]Predictor, nMembers)
+	for i := range members {
+		n, err := NewSimpleTrainer(4, 3, 1, 5, Linear{})
+		if err != nil {
+			panic(err)
+		}
+		n.RandomizeParameters()
+		members[i] = n.Predictor()
+	}
+	e, err := NewEnsemble(members...)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+func TestEnsemblePredict(t *testing.T) {
+	e := newTestEnsemble(3)
+	testInputOutputDim(t, e, 4, 3, "ensemble")
+
+	nSamples := 20
+	inputs := RandomMat(nSamples, 4, rand.NormFloat64)
+	trueOutputs := RandomMat(nSamples, 3, rand.NormFloat64)
+	for i := 0; i < nSamples; i++ {
+		out := trueOutputs.RowView(i)
+		for j := range out {
+			out[j] = 0
+		}
+		for _, m := range e.members {
+			o, _ := m.Predict(inputs.RowView(i), nil)
+			for j := range out {
+				out[j] += o[j] / float64(len(e.members))
+			}
+		}
+	}
+	testPredictAndBatch(t, e, inputs, trueOutputs, "ensemble")
+
+	outputs, err := e.PredictBatch(inputs, nil)
+	if err != nil {
+		t.Fatalf("ensemble: error in PredictBatch: %v", err)
+	}
+	for i := 0; i < nSamples; i++ {
+		if !floatsEqualApprox(outputs.(SosMatrix)[i], trueOutputs[i], 1e-14) {
+			t.Errorf("ensemble: batch mismatch for row %v", i)
+		}
+	}
+}
+
+func TestEnsembleMeanVariance(t *testing.T) {
+	e := newTestEnsemble(4)
+	nSamples := 50
+	inputs := RandomMat(nSamples, 4, rand.NormFloat64)
+	means, variances, err := e.PredictMeanVariance(inputs, nil, nil)
+	if err != nil {
+		t.Fatalf("ensemble: error in PredictMeanVariance: %v", err)
+	}
+	lower, upper, err := e.PredictInterval(inputs, 2, nil, nil)
+	if err != nil {
+		t.Fatalf("ensemble: error in PredictInterval: %v", err)
+	}
+	for i := 0; i < nSamples; i++ {
+		mean := make([]float64, 3)
+		sumSq := make([]float64, 3)
+		for _, m := range e.members {
+			o, _ := m.Predict(inputs[i], nil)
+			for j, v := range o {
+				mean[j] += v
+				sumSq[j] += v * v
+			}
+		}
+		for j := range mean {
+			n := float64(len(e.members))
+			mean[j] /= n
+			variance := sumSq[j]/n - mean[j]*mean[j]
+			if !EqualWithinAbsOrRel(means.At(i, j), mean[j], 1e-12, 1e-12) {
+				t.Errorf("ensemble: mean mismatch at (%v, %v). Expected %v, found %v", i, j, mean[j], means.At(i, j))
+			}
+			if !EqualWithinAbsOrRel(variances.At(i, j), variance, 1e-10, 1e-10) {
+				t.Errorf("ensemble: variance mismatch at (%v, %v). Expected %v, found %v", i, j, variance, variances.At(i, j))
+			}
+			w := 2 * math.Sqrt(variances.At(i, j))
+			if !EqualWithinAbsOrRel(lower.At(i, j), mean[j]-w, 1e-10, 1e-10) || !EqualWithinAbsOrRel(upper.At(i, j), mean[j]+w, 1e-10, 1e-10) {
+				t.Errorf("ensemble: interval mismatch at (%v, %v)", i, j)
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math"
+	"reflect"
+)
+
+// Equal returns true if the two nets have the same architecture (input and
+// output dimensions, layer sizes and neuron definitions) and identical parameters.
+func Equal(a, b *Net) bool {
+	return equalNets(a, b, func(x, y float64) bool { return x == y })
+}
+
+// EqualApprox returns true if the two nets have the same architecture and
+// all of the parameters are equal to within an absolute or relative tolerance
+// of tol.
+func EqualApprox(a, b *Net, tol float64) bool {
+	return equalNets(a, b, func(x, y float64) bool {
+		if x == y {
+			return true
+		}
+		diff := math.Abs(x - y)
+		return diff <= tol || diff <= tol*math.Max(math.Abs(x), math.Abs(y))
+	})
+}
+
+// SameArchitecture returns true if the two nets have the same input and output
+// dimensions, layer sizes and neuron definitions
+func SameArchitecture(a, b *Net) bool {
+	if a.inputDim != b.inputDim || a.outputDim != b.outputDim {
+		return false
+	}
+	if len(a.neurons) != len(b.neurons) {
+		return false
+	}
+	for i, layer := range a.neurons {
+		if len(layer) != len(b.neurons[i]) {
+			return false
+		}
+		for j, neuron := range layer {
+			if !reflect.DeepEqual(neuron, b.neurons[i][j]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func equalNets(a, b *Net, eq func(x, y float64) bool) bool {
+	if !SameArchitecture(a, b) {
+		return false
+	}
+	for i, layer := range a.parameters {
+		for j, p := range layer {
+			q := b.parameters[i][j]
+			if len(p) != len(q) {
+				return false
+			}
+			for k, v := range p {
+				if !eq(v, q[k]) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
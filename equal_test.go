@@ -0,0 +1,37 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import "testing"
+
+func TestEqualNets(t *testing.T) {
+	for i, test := range netIniters {
+		a := testNets[i].Net
+		b := a.Clone()
+		if !Equal(a, b) {
+			t.Errorf("%v: clone not equal to original", test.name)
+		}
+		b.parameters[0][0][0] += 1e-10
+		if Equal(a, b) {
+			t.Errorf("%v: nets with different parameters are equal", test.name)
+		}
+		if !EqualApprox(a, b, 1e-8) {
+			t.Errorf("%v: nets not approximately equal", test.name)
+		}
+		if EqualApprox(a, b, 1e-14) {
+			t.Errorf("%v: nets approximately equal with a tight tolerance", test.name)
+		}
+		for j, other := range testNets {
+			if j != i && SameArchitecture(a, other.Net) {
+				t.Errorf("%v: different architectures reported as the same", test.name)
+			}
+		}
+	}
+	b := testNets[0].Clone()
+	b.neurons[0][0] = SigmoidNeuron
+	if SameArchitecture(testNets[0].Net, b.Net) {
+		t.Errorf("Nets with different neurons reported as the same")
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"errors"
+	"math"
+	"strconv"
+)
+
+// Sentinel errors for the kinds of dimension mismatch. Errors returned by the
+// package for mismatched sizes are *DimensionError values which wrap one of
+// these, so callers can test for the kind with errors.Is.
+var (
+	ErrInputDim     = errors.New("input dimension mismatch")
+	ErrOutputDim    = errors.New("output dimension mismatch")
+	ErrRows         = errors.New("rows mismatch")
+	ErrParameterDim = errors.New("parameter dimension mismatch")
+)
+
+// Sentinel errors for non-finite values found when finite validation is on
+// (see Trainer.SetValidateFinite). These are wrapped by *NonFiniteError.
+var (
+	ErrNonFiniteInput  = errors.New("non-finite input")
+	ErrNonFiniteOutput = errors.New("non-finite output")
+)
+
+// DimensionError is returned when the size of an argument doesn't match the
+// size expected by the predictor or function.
+type DimensionError struct {
+	Op       string // The operation that failed, for example "predict batch". May be empty
+	Err      error  // The kind of mismatch. One of ErrInputDim, ErrOutputDim, ErrRows or ErrParameterDim
+	Expected int
+	Actual   int
+}
+
+func (e *DimensionError) Error() string {
+	s := e.Err.Error() + ": expected " + strconv.Itoa(e.Expected) + ", found " + strconv.Itoa(e.Actual)
+	if e.Op == "" {
+		return s
+	}
+	return e.Op + ": " + s
+}
+
+// Unwrap returns the kind of mismatch
+func (e *DimensionError) Unwrap() error {
+	return e.Err
+}
+
+func dimensionError(op string, err error, expected, actual int) error {
+	return &DimensionError{
+		Op:       op,
+		Err:      err,
+		Expected: expected,
+		Actual:   actual,
+	}
+}
+
+// NonFiniteError is returned when an input or output contains a NaN or an Inf
+// and finite validation is on.
+type NonFiniteError struct {
+	Err   error // ErrNonFiniteInput or ErrNonFiniteOutput
+	Index int   // Index of the first non-finite element
+	Value float64
+}
+
+func (e *NonFiniteError) Error() string {
+	return e.Err.Error() + " at index " + strconv.Itoa(e.Index) + ": " + strconv.FormatFloat(e.Value, 'g', -1, 64)
+}
+
+// Unwrap returns the kind of non-finite value
+func (e *NonFiniteError) Unwrap() error {
+	return e.Err
+}
+
+// checkFinite returns a *NonFiniteError of the given kind if any element of x
+// is a NaN or an Inf
+func checkFinite(x []float64, kind error) error {
+	for i, v := range x {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return &NonFiniteError{Err: kind, Index: i, Value: v}
+		}
+	}
+	return nil
+}
+
+// RowError is the error from predicting a single row of a batch
+type RowError struct {
+	Row int
+	Err error
+}
+
+func (e RowError) Error() string {
+	return "row " + strconv.Itoa(e.Row) + ": " + e.Err.Error()
+}
+
+// BatchError is returned by batch prediction when the prediction of one or
+// more rows fails. The remaining rows are still predicted.
+type BatchError struct {
+	Errors []RowError // The failed rows in increasing order
+}
+
+func (e *BatchError) Error() string {
+	s := "predict batch: " + e.Errors[0].Error()
+	if len(e.Errors) > 1 {
+		s += " (and " + strconv.Itoa(len(e.Errors)-1) + " more rows)"
+	}
+	return s
+}
+
+// Unwrap returns the errors of the individual rows
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, r := range e.Errors {
+		errs[i] = r.Err
+	}
+	return errs
+}
+
+// Rows returns the indices of the rows which failed
+func (e *BatchError) Rows() []int {
+	rows := make([]int, len(e.Errors))
+	for i, r := range e.Errors {
+		rows[i] = r.Row
+	}
+	return rows
+}
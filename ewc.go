@@ -0,0 +1,99 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+// EWC is elastic weight consolidation, which anchors the parameters that were
+// important for an old task while training on a new one, to avoid forgetting
+// the old task. The penalty added to the loss is
+//
+//	Lambda / 2 * sum_i Fisher[i] * (parameters[i] - Anchor[i])^2
+//
+// where Fisher is the diagonal of the Fisher information on the old task and
+// Anchor are the parameters after training on the old task.
+type EWC struct {
+	Lambda float64
+	Fisher []float64
+	Anchor []float64
+}
+
+// NewEWC returns the EWC penalty for the task given by inputs and targets,
+// anchored at the current parameters of the trainer. It should be called after
+// training on the task and set on the trainer with SetEWC before training on
+// the next task.
+func NewEWC(s *Trainer, inputs, targets RowMatrix, lambda float64) (*EWC, error) {
+	fisher, err := s.FisherDiagonal(inputs, targets, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &EWC{
+		Lambda: lambda,
+		Fisher: fisher,
+		Anchor: s.Parameters(nil),
+	}, nil
+}
+
+// Penalty returns the penalty at the parameters and adds its gradient to grad
+// if grad is not nil
+func (e *EWC) Penalty(parameters, grad []float64) float64 {
+	var penalty float64
+	for i, p := range parameters {
+		d := p - e.Anchor[i]
+		penalty += e.Fisher[i] * d * d
+		if grad != nil {
+			grad[i] += e.Lambda * e.Fisher[i] * d
+		}
+	}
+	return e.Lambda / 2 * penalty
+}
+
+// FisherDiagonal computes the diagonal of the empirical Fisher information of
+// the parameters, the mean over the samples of the squared gradient of the loss
+// of each sample. If fisher is nil, new memory is allocated.
+func (s *Trainer) FisherDiagonal(inputs, targets RowMatrix, fisher []float64) ([]float64, error) {
+	if err := s.checkTargets("fisher", inputs, targets); err != nil {
+		return nil, err
+	}
+	if fisher == nil {
+		fisher = make([]float64, s.totalNumParameters)
+	}
+	if len(fisher) != s.totalNumParameters {
+		return nil, dimensionError("fisher", ErrParameterDim, s.totalNumParameters, len(fisher))
+	}
+	for i := range fisher {
+		fisher[i] = 0
+	}
+	predictions, err := s.PredictBatch(inputs, nil)
+	if err != nil {
+		return nil, err
+	}
+	lossFunc := s.Loss()
+	input := make([]float64, s.inputDim)
+	target := make([]float64, s.targetDim())
+	dLoss := make([]float64, s.outputDim)
+	grad := make([]float64, s.totalNumParameters)
+	for i, prediction := range predictions.(SosMatrix) {
+		input = inputs.Row(input, i)
+		target = targets.Row(target, i)
+		lossFunc.LossDeriv(prediction, target, dLoss)
+		if _, err := s.ParameterGradient(input, dLoss, grad); err != nil {
+			return nil, err
+		}
+		for j, g := range grad {
+			fisher[j] += g * g
+		}
+	}
+	n := float64(len(predictions.(SosMatrix)))
+	for i := range fisher {
+		fisher[i] /= n
+	}
+	return fisher, nil
+}
+
+// SetEWC sets the elastic weight consolidation penalty whose gradient is added
+// by PartialFit. The losses returned by PartialFit do not include the penalty.
+// If e is nil, which is the default, there is no penalty.
+func (s *Trainer) SetEWC(e *EWC) {
+	s.ewc = e
+}
@@ -0,0 +1,103 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestFisherDiagonal(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	trainer, err := NewSimpleTrainer(2, 2, 1, 4, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trainer.SetRand(rnd)
+	trainer.RandomizeParameters()
+	inputs := RandomMat(5, 2, rnd.NormFloat64)
+	targets := RandomMat(5, 2, rnd.NormFloat64)
+	fisher, err := trainer.FisherDiagonal(inputs, targets, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := make([]float64, len(fisher))
+	for i := range inputs {
+		_, grad, err := trainer.lossGradient(inputs[i:i+1], targets[i:i+1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j, g := range grad {
+			want[j] += g * g / 5
+		}
+	}
+	if !floatsEqualApprox(fisher, want, 1e-12) {
+		t.Errorf("Wrong Fisher diagonal. Expected %v, found %v", want, fisher)
+	}
+
+	e := &EWC{Lambda: 3, Fisher: fisher, Anchor: trainer.Parameters(nil)}
+	params := RandomMat(1, len(fisher), rnd.NormFloat64)[0]
+	grad := make([]float64, len(fisher))
+	e.Penalty(params, grad)
+	const h = 1e-6
+	for i := range params {
+		p := append([]float64(nil), params...)
+		p[i] += h
+		plus := e.Penalty(p, nil)
+		p[i] -= 2 * h
+		minus := e.Penalty(p, nil)
+		if fd := (plus - minus) / (2 * h); math.Abs(fd-grad[i]) > 1e-6 {
+			t.Errorf("Wrong penalty derivative %v. Finite difference %v, found %v", i, fd, grad[i])
+		}
+	}
+}
+
+func TestEWC(t *testing.T) {
+	// Two tasks on disjoint parts of the input space
+	rnd := rand.New(rand.NewSource(1))
+	task := func(lo float64) (SosMatrix, SosMatrix) {
+		inputs := make(SosMatrix, 100)
+		targets := make(SosMatrix, 100)
+		for i := range inputs {
+			x := lo + 2*rnd.Float64()
+			inputs[i] = []float64{x}
+			targets[i] = []float64{math.Sin(2 * x)}
+		}
+		return inputs, targets
+	}
+	inputsA, targetsA := task(-2)
+	inputsB, targetsB := task(0)
+
+	trainer, err := NewSimpleTrainer(1, 1, 1, 20, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trainer.SetRand(rnd)
+	trainer.RandomizeParameters()
+	trainer.SetOptimizer(&Adam{Rate: 0.01})
+	for i := 0; i < 1000; i++ {
+		trainer.PartialFit(inputsA, targetsA)
+	}
+	lossA, _, _ := trainer.lossGradient(inputsA, targetsA)
+
+	ewc, err := NewEWC(trainer, inputsA, targetsA, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var forgotten [2]float64
+	for i, e := range []*EWC{nil, ewc} {
+		tr := trainer.Clone()
+		tr.SetOptimizer(&Adam{Rate: 0.01})
+		tr.SetEWC(e)
+		for j := 0; j < 500; j++ {
+			tr.PartialFit(inputsB, targetsB)
+		}
+		forgotten[i], _, _ = tr.lossGradient(inputsA, targetsA)
+	}
+	if forgotten[1] > forgotten[0]/2 {
+		t.Errorf("EWC did not reduce forgetting. Loss on the old task %v initially, %v without EWC, %v with EWC", lossA, forgotten[0], forgotten[1])
+	}
+}
@@ -6,7 +6,7 @@ package nnet
 
 import "math"
 
-func Equal(s, t []float64) bool {
+func floatsEqual(s, t []float64) bool {
 	if len(s) != len(t) {
 		return false
 	}
@@ -18,10 +18,10 @@ func Equal(s, t []float64) bool {
 	return true
 }
 
-// EqualsApprox returns true if the slices have equal lengths and
+// floatsEqualApprox returns true if the slices have equal lengths and
 // all element pairs have an absolute tolerance less than tol or a
 // relative tolerance less than tol.
-func EqualApprox(s1, s2 []float64, tol float64) bool {
+func floatsEqualApprox(s1, s2 []float64, tol float64) bool {
 	if len(s1) != len(s2) {
 		return false
 	}
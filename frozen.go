@@ -0,0 +1,140 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"context"
+	"sync"
+)
+
+// FrozenNet is an immutable feed-forward net produced by Trainer.Freeze. It has
+// its own copy of the layers and parameters which are never modified, so it is
+// safe to call Predict and PredictBatch concurrently from many goroutines. The
+// temporary memory needed for prediction is sized once when the net is frozen
+// and is reused across calls. Layers of SumNeurons where most of the weights
+// are zero, such as after pruning, skip the zero weights.
+type FrozenNet struct {
+	inputDim  int
+	outputDim int
+	grainSize int
+	maxLayer  int // the largest number of neurons in a layer
+	validate  bool
+	partition Partition
+
+	layers []Layer
+
+	scratch sync.Pool // *predictor
+}
+
+// Freeze returns an immutable copy of the current state of the net. Later
+// changes to the trainer do not affect the frozen net.
+func (s *Trainer) Freeze() *FrozenNet {
+	net := s.Net.Clone()
+	return newFrozenNet(net, net.layers)
+}
+
+// newFrozenNet returns a frozen net with the settings of n and the given
+// layers, which must not be modified afterwards
+func newFrozenNet(n *Net, layers []Layer) *FrozenNet {
+	sparsifyLayers(layers)
+	maxLayer := 0
+	for _, layer := range layers {
+		if layer.NumOutputs() > maxLayer {
+			maxLayer = layer.NumOutputs()
+		}
+	}
+	f := &FrozenNet{
+		inputDim:  n.inputDim,
+		outputDim: n.outputDim,
+		grainSize: n.grainSize,
+		maxLayer:  maxLayer,
+		validate:  n.validate,
+		partition: n.partition,
+		layers:    layers,
+	}
+	f.scratch.New = func() interface{} {
+		p := f.newPredictor()
+		return &p
+	}
+	return f
+}
+
+// InputDim returns the number of inputs expected by the net
+func (f *FrozenNet) InputDim() int {
+	return f.inputDim
+}
+
+// OutputDim returns the number of outputs of the net
+func (f *FrozenNet) OutputDim() int {
+	return f.outputDim
+}
+
+// GrainSize returns the number of samples per parallel work unit
+func (f *FrozenNet) GrainSize() int {
+	return f.grainSize
+}
+
+// Predict predicts the output at the input. It is safe to call concurrently.
+func (f *FrozenNet) Predict(input, output []float64) ([]float64, error) {
+	if len(input) != f.inputDim {
+		return nil, dimensionError("", ErrInputDim, f.inputDim, len(input))
+	}
+	if output == nil {
+		output = make([]float64, f.outputDim)
+	} else {
+		if len(output) != f.outputDim {
+			return nil, dimensionError("", ErrOutputDim, f.outputDim, len(output))
+		}
+	}
+	p := f.scratch.Get().(*predictor)
+	_, err := p.Predict(input, output)
+	f.scratch.Put(p)
+	return output, err
+}
+
+// PredictBatch predicts the output at every row of inputs in parallel. It is
+// safe to call concurrently.
+func (f *FrozenNet) PredictBatch(inputs RowMatrix, outputs MutableRowMatrix) (MutableRowMatrix, error) {
+	return f.PredictBatchCtx(context.Background(), inputs, outputs)
+}
+
+// PredictCtx is like Predict but returns the context's error without predicting
+// if the context is already done.
+func (f *FrozenNet) PredictCtx(ctx context.Context, input, output []float64) ([]float64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return f.Predict(input, output)
+}
+
+// PredictBatchCtx is like PredictBatch but honors the cancellation and deadline
+// of the context. See Net.PredictBatchCtx.
+func (f *FrozenNet) PredictBatchCtx(ctx context.Context, inputs RowMatrix, outputs MutableRowMatrix) (MutableRowMatrix, error) {
+	if nSamples, _ := inputs.Dims(); !f.validate && layerParallel(f.layers, f.grainSize, nSamples) {
+		return predictBatchLayers(ctx, f.layers, inputs, outputs, f.inputDim, f.outputDim)
+	}
+	return BatchPredictCtx(ctx, f, inputs, outputs, f.inputDim, f.outputDim, f.grainSize)
+}
+
+// Partition returns how PredictBatch divides the samples among the workers
+func (f *FrozenNet) Partition() Partition {
+	return f.partition
+}
+
+// NewPredictor returns a predictor with its own temporary memory
+func (f *FrozenNet) NewPredictor() Predictor {
+	return f.newPredictor()
+}
+
+func (f *FrozenNet) newPredictor() predictor {
+	return predictor{
+		layers:        f.layers,
+		tmpOutput:     make([]float64, f.maxLayer),
+		prevTmpOutput: make([]float64, f.maxLayer),
+		inputDim:      f.inputDim,
+		outputDim:     f.outputDim,
+		validate:      f.validate,
+	}
+}
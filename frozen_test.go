@@ -0,0 +1,43 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestFrozenNet(t *testing.T) {
+	for i, test := range netIniters {
+		s := testNets[i].Clone()
+		f := s.Freeze()
+		testInputOutputDim(t, f, test.inputDim, test.outputDim, test.name)
+
+		nSamples := 50
+		inputs := RandomMat(nSamples, test.inputDim, rand.NormFloat64)
+		trueOutputs, _ := s.PredictBatch(inputs, nil)
+		s.RandomizeParameters()
+
+		testPredictAndBatch(t, f, inputs, trueOutputs, test.name)
+
+		// Predict concurrently and check against the true outputs
+		var wg sync.WaitGroup
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				outputs, _ := f.PredictBatch(inputs, nil)
+				for r := 0; r < nSamples; r++ {
+					out, _ := f.Predict(inputs[r], nil)
+					if !floatsEqual(out, outputs.(SosMatrix)[r]) || !floatsEqual(out, trueOutputs.(SosMatrix)[r]) {
+						t.Errorf("%v: concurrent prediction mismatch for row %v", test.name, r)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
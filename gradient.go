@@ -0,0 +1,206 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import "errors"
+
+// ParameterGradient computes the gradient of a scalar objective with respect to the
+// parameters of the net at the given input, where dObjDOutput is the derivative of
+// the objective with respect to each of the outputs of the net. The gradient with
+// respect to the parameters of frozen layers (see Trainer.SetLayerTrainable) is
+// zero. The gradient is stored into deriv using the flat parameter ordering (see
+// Net.Parameters). If deriv is nil, a new slice is allocated.
+func (n *Net) ParameterGradient(input, dObjDOutput, deriv []float64) ([]float64, error) {
+	if len(input) != n.inputDim {
+		return nil, dimensionError("", ErrInputDim, n.inputDim, len(input))
+	}
+	if len(dObjDOutput) != n.outputDim {
+		return nil, dimensionError("", ErrOutputDim, n.outputDim, len(dObjDOutput))
+	}
+	if deriv == nil {
+		deriv = make([]float64, n.totalNumParameters)
+	} else {
+		if len(deriv) != n.totalNumParameters {
+			return nil, dimensionError("", ErrParameterDim, n.totalNumParameters, len(deriv))
+		}
+	}
+	mem := newGradMemory(n.layers)
+	backprop(input, dObjDOutput, n.layers, mem, n.gradientViews(deriv), nil)
+	return deriv, nil
+}
+
+// BatchParameterGradient computes the sum over the rows of inputs of the parameter
+// gradient (see ParameterGradient), where row i of dObjDOutputs is the derivative of
+// the objective with respect to the outputs of the net for row i of inputs. The
+// rows are processed in parallel. If deriv is nil, a new slice is allocated.
+//
+// The order in which the per-row gradients are added depends on the scheduling of
+// the parallel loop unless the net is deterministic (see Trainer.SetDeterministic).
+func (n *Net) BatchParameterGradient(inputs, dObjDOutputs RowMatrix, deriv []float64) ([]float64, error) {
+	nSamples, inputDim := inputs.Dims()
+	if inputDim != n.inputDim {
+		return nil, dimensionError("", ErrInputDim, n.inputDim, inputDim)
+	}
+	nOutputSamples, outputDim := dObjDOutputs.Dims()
+	if outputDim != n.outputDim {
+		return nil, dimensionError("", ErrOutputDim, n.outputDim, outputDim)
+	}
+	if nOutputSamples != nSamples {
+		return nil, dimensionError("", ErrRows, nSamples, nOutputSamples)
+	}
+	if deriv == nil {
+		deriv = make([]float64, n.totalNumParameters)
+	} else {
+		if len(deriv) != n.totalNumParameters {
+			return nil, dimensionError("", ErrParameterDim, n.totalNumParameters, len(deriv))
+		}
+	}
+	f := func(start, end int, sum []float64) {
+		mem := newGradMemory(n.layers)
+		input := make([]float64, inputDim)
+		dObjDOutput := make([]float64, outputDim)
+		tmp := make([]float64, len(sum))
+		dParams := n.gradientViews(tmp)
+		for i := start; i < end; i++ {
+			inputs.Row(input, i)
+			dObjDOutputs.Row(dObjDOutput, i)
+			backprop(input, dObjDOutput, n.layers, mem, dParams, nil)
+			for k, v := range tmp {
+				sum[k] += v
+			}
+		}
+	}
+	parallelSum(nSamples, n.grainSize, n.deterministic, deriv, f)
+	return deriv, nil
+}
+
+// gradientViews returns views of deriv for storing the parameter gradient of
+// each layer with backprop. The gradient of frozen layers is zero and is not
+// computed, so those elements of deriv are zeroed and their views are nil.
+func (n *Net) gradientViews(deriv []float64) [][]float64 {
+	dParams := make([][]float64, len(n.layers))
+	var idx int
+	for i, layer := range n.layers {
+		nParams := layer.NumParameters()
+		d := deriv[idx : idx+nParams : idx+nParams]
+		idx += nParams
+		if n.frozen[i] {
+			for k := range d {
+				d[k] = 0
+			}
+			continue
+		}
+		dParams[i] = d
+	}
+	return dParams
+}
+
+// OutputParameterGradient computes the derivative of the output with the given
+// index with respect to the parameters of the net at the given input. The
+// derivative is stored into deriv using the flat parameter ordering (see
+// Net.Parameters). If deriv is nil, a new slice is allocated.
+func (n *Net) OutputParameterGradient(input []float64, output int, deriv []float64) ([]float64, error) {
+	if output < 0 || output >= n.outputDim {
+		return nil, errors.New("output index out of range")
+	}
+	dObjDOutput := make([]float64, n.outputDim)
+	dObjDOutput[output] = 1
+	return n.ParameterGradient(input, dObjDOutput, deriv)
+}
+
+// gradMemory is the temporary memory needed to compute derivatives with backprop
+type gradMemory struct {
+	caches   [][]float64 // values saved by the forward pass of each cachedLayer
+	outputs  [][]float64 // output of each layer
+	dOutputs [][]float64 // derivative of the objective with respect to the output of each layer
+}
+
+func newGradMemory(layers []Layer) *gradMemory {
+	mem := &gradMemory{
+		caches:   make([][]float64, len(layers)),
+		outputs:  make([][]float64, len(layers)),
+		dOutputs: make([][]float64, len(layers)),
+	}
+	for i, layer := range layers {
+		if c, ok := layer.(cachedLayer); ok {
+			mem.caches[i] = make([]float64, c.cacheSize())
+		}
+		mem.outputs[i] = make([]float64, layer.NumOutputs())
+		mem.dOutputs[i] = make([]float64, layer.NumOutputs())
+	}
+	return mem
+}
+
+// forward computes the outputs of the net and stores the output of every layer
+// into mem
+func forward(input []float64, layers []Layer, mem *gradMemory) {
+	layerInput := input
+	for i, layer := range layers {
+		if c, ok := layer.(cachedLayer); ok {
+			c.forwardCached(layerInput, mem.outputs[i], mem.caches[i])
+		} else {
+			layer.Forward(layerInput, mem.outputs[i])
+		}
+		layerInput = mem.outputs[i]
+	}
+}
+
+// backprop computes the derivative of an objective with respect to the parameters
+// and the inputs of the net given the derivative of the objective with respect to
+// the outputs of the net. The derivatives are stored in dParams and dInput. Either
+// may be nil, in which case that derivative is not computed. The derivative with
+// respect to the parameters of layer i is also not computed if dParams[i] is nil.
+func backprop(input, dObjDOutput []float64, layers []Layer, mem *gradMemory, dParams [][]float64, dInput []float64) {
+	forward(input, layers, mem)
+
+	nLayers := len(layers)
+	copy(mem.dOutputs[nLayers-1], dObjDOutput)
+	for i := nLayers - 1; i >= 0; i-- {
+		layerInput := input
+		if i != 0 {
+			layerInput = mem.outputs[i-1]
+		}
+		// The derivative with respect to the inputs of this layer is the derivative
+		// with respect to the outputs of the previous layer
+		var dLayerInput []float64
+		if i != 0 {
+			dLayerInput = mem.dOutputs[i-1]
+		} else {
+			dLayerInput = dInput
+		}
+		var dParam []float64
+		if dParams != nil {
+			dParam = dParams[i]
+		}
+		if c, ok := layers[i].(cachedLayer); ok {
+			c.backwardCached(layerInput, mem.outputs[i], mem.caches[i], mem.dOutputs[i], dParam, dLayerInput)
+			continue
+		}
+		layers[i].Backward(layerInput, mem.outputs[i], mem.dOutputs[i], dParam, dLayerInput)
+	}
+}
+
+// InputGradient computes the gradient of a scalar objective with respect to the
+// inputs of the net, where dObjDOutput is the derivative of the objective with
+// respect to each of the outputs of the net. If deriv is nil, a new slice is
+// allocated.
+func (n *Net) InputGradient(input, dObjDOutput, deriv []float64) ([]float64, error) {
+	if len(input) != n.inputDim {
+		return nil, dimensionError("", ErrInputDim, n.inputDim, len(input))
+	}
+	if len(dObjDOutput) != n.outputDim {
+		return nil, dimensionError("", ErrOutputDim, n.outputDim, len(dObjDOutput))
+	}
+	if deriv == nil {
+		deriv = make([]float64, n.inputDim)
+	} else {
+		if len(deriv) != n.inputDim {
+			return nil, dimensionError("", ErrInputDim, n.inputDim, len(deriv))
+		}
+	}
+	mem := newGradMemory(n.layers)
+	backprop(input, dObjDOutput, n.layers, mem, nil, deriv)
+	return deriv, nil
+}
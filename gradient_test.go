@@ -0,0 +1,167 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+func TestParameterGradient(t *testing.T) {
+	for i, test := range netIniters {
+		n := testNets[i].Net
+		input := make([]float64, test.inputDim)
+		for j := range input {
+			input[j] = rand.NormFloat64()
+		}
+		dObjDOutput := make([]float64, test.outputDim)
+		for j := range dObjDOutput {
+			dObjDOutput[j] = rand.NormFloat64()
+		}
+		deriv, err := n.ParameterGradient(input, dObjDOutput, nil)
+		if err != nil {
+			t.Fatalf("%v: error computing gradient: %v", test.name, err)
+		}
+
+		// Compare with a central finite difference of the objective
+		objective := func() float64 {
+			out, _ := n.Predict(input, nil)
+			var obj float64
+			for j, v := range out {
+				obj += dObjDOutput[j] * v
+			}
+			return obj
+		}
+		var idx int
+		for _, layer := range n.parameters {
+			for _, params := range layer {
+				for k := range params {
+					orig := params[k]
+					params[k] = orig + fdStep
+					plus := objective()
+					params[k] = orig - fdStep
+					minus := objective()
+					params[k] = orig
+					fd := (plus - minus) / (2 * fdStep)
+					if !EqualWithinAbsOrRel(fd, deriv[idx], fdTol, fdTol) {
+						t.Errorf("%v: gradient mismatch at parameter %v. Finite difference %v, found %v", test.name, idx, fd, deriv[idx])
+					}
+					idx++
+				}
+			}
+		}
+
+		// OutputParameterGradient is the gradient with a unit vector
+		outDeriv, err := n.OutputParameterGradient(input, test.outputDim-1, nil)
+		if err != nil {
+			t.Fatalf("%v: error computing output gradient: %v", test.name, err)
+		}
+		unit := make([]float64, test.outputDim)
+		unit[test.outputDim-1] = 1
+		deriv, _ = n.ParameterGradient(input, unit, deriv)
+		if !floatsEqual(outDeriv, deriv) {
+			t.Errorf("%v: output gradient doesn't match gradient with unit vector", test.name)
+		}
+	}
+}
+
+func TestInputGradient(t *testing.T) {
+	for i, test := range netIniters {
+		n := testNets[i].Net
+		input := make([]float64, test.inputDim)
+		for j := range input {
+			input[j] = rand.NormFloat64()
+		}
+		dObjDOutput := make([]float64, test.outputDim)
+		for j := range dObjDOutput {
+			dObjDOutput[j] = rand.NormFloat64()
+		}
+		deriv, err := n.InputGradient(input, dObjDOutput, nil)
+		if err != nil {
+			t.Fatalf("%v: error computing gradient: %v", test.name, err)
+		}
+		objective := func() float64 {
+			out, _ := n.Predict(input, nil)
+			var obj float64
+			for j, v := range out {
+				obj += dObjDOutput[j] * v
+			}
+			return obj
+		}
+		for k := range input {
+			orig := input[k]
+			input[k] = orig + fdStep
+			plus := objective()
+			input[k] = orig - fdStep
+			minus := objective()
+			input[k] = orig
+			fd := (plus - minus) / (2 * fdStep)
+			if !EqualWithinAbsOrRel(fd, deriv[k], fdTol, fdTol) {
+				t.Errorf("%v: gradient mismatch at input %v. Finite difference %v, found %v", test.name, k, fd, deriv[k])
+			}
+		}
+	}
+}
+
+func TestFrozenLayerGradient(t *testing.T) {
+	s := testNets[1].Clone()
+	input := RandomMat(1, s.InputDim(), rand.NormFloat64)[0]
+	dObjDOutput := RandomMat(1, s.OutputDim(), rand.NormFloat64)[0]
+	full, _ := s.ParameterGradient(input, dObjDOutput, nil)
+
+	s.SetLayerTrainable(0, false)
+	if s.LayerTrainable(0) || !s.LayerTrainable(1) {
+		t.Errorf("Wrong trainable status")
+	}
+	frozen := make([]float64, len(full))
+	for i := range frozen {
+		frozen[i] = rand.NormFloat64()
+	}
+	s.ParameterGradient(input, dObjDOutput, frozen)
+	nFrozen := s.LayerNumParameters(0)
+	for i, v := range frozen[:nFrozen] {
+		if v != 0 {
+			t.Errorf("Non-zero gradient %v for frozen parameter %v", v, i)
+		}
+	}
+	if !floatsEqual(frozen[nFrozen:], full[nFrozen:]) {
+		t.Errorf("Freezing a layer changed the gradient of trainable layers")
+	}
+}
+
+func TestBatchParameterGradient(t *testing.T) {
+	for i, test := range netIniters {
+		s := testNets[i].Clone()
+		nSamples := 103
+		inputs := RandomMat(nSamples, test.inputDim, rand.NormFloat64)
+		dObjDOutputs := RandomMat(nSamples, test.outputDim, rand.NormFloat64)
+		want := make([]float64, s.TotalNumParameters())
+		for r := 0; r < nSamples; r++ {
+			d, _ := s.ParameterGradient(inputs[r], dObjDOutputs[r], nil)
+			for k, v := range d {
+				want[k] += v
+			}
+		}
+		s.HackGrainSize(4)
+		got, err := s.BatchParameterGradient(inputs, dObjDOutputs, nil)
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		if !floatsEqualApprox(got, want, 1e-12) {
+			t.Errorf("%v: batch gradient doesn't match sum of gradients", test.name)
+		}
+
+		s.SetDeterministic(true)
+		procs := runtime.GOMAXPROCS(1)
+		serial, _ := s.BatchParameterGradient(inputs, dObjDOutputs, nil)
+		runtime.GOMAXPROCS(4)
+		parallel, _ := s.BatchParameterGradient(inputs, dObjDOutputs, nil)
+		runtime.GOMAXPROCS(procs)
+		if !floatsEqual(serial, parallel) {
+			t.Errorf("%v: deterministic gradient depends on GOMAXPROCS", test.name)
+		}
+	}
+}
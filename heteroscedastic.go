@@ -0,0 +1,139 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"errors"
+	"math"
+)
+
+// GaussianNLL is the negative log-likelihood of the truth under independent
+// Gaussian distributions, for regression where the net predicts its own
+// uncertainty. For k targets the prediction has 2k elements: the k means
+// followed by the logarithms of the k variances. The loss is the mean over the
+// targets of
+//
+//	0.5 * (log(2π) + logVar + (truth - mean)^2 / exp(logVar))
+//
+// so noisy samples can be explained by a large variance instead of pulling the
+// mean towards them.
+type GaussianNLL struct{}
+
+func (GaussianNLL) LossDeriv(prediction, truth, dLossDPred []float64) float64 {
+	k := len(truth)
+	if len(prediction) != 2*k {
+		panic(dimensionError("gaussian nll", ErrOutputDim, 2*k, len(prediction)))
+	}
+	n := float64(k)
+	var loss float64
+	for i, t := range truth {
+		mean, logVar := prediction[i], prediction[k+i]
+		diff := t - mean
+		invVar := math.Exp(-logVar)
+		loss += 0.5 * (math.Log(2*math.Pi) + logVar + diff*diff*invVar)
+		dLossDPred[i] = -diff * invVar / n
+		dLossDPred[k+i] = 0.5 * (1 - diff*diff*invVar) / n
+	}
+	return loss / n
+}
+
+// TargetDim returns half the number of outputs, as there is a mean and a
+// log-variance per target
+func (GaussianNLL) TargetDim(outputDim int) int {
+	return outputDim / 2
+}
+
+// CheckTargets returns an error if the number of outputs is odd, so the outputs
+// cannot be split into means and log-variances
+func (GaussianNLL) CheckTargets(outputDim int, targets RowMatrix) error {
+	if outputDim%2 != 0 {
+		return errors.New("gaussian nll: odd number of outputs")
+	}
+	return nil
+}
+
+func (GaussianNLL) String() string {
+	return "GaussianNLL"
+}
+
+// HeteroscedasticTrainer is a regression net which predicts both the mean and
+// the variance of every target, so the uncertainty of the prediction can vary
+// with the input. The output layer has the means of the targets followed by
+// the logarithms of their variances, and the net is trained with GaussianNLL,
+// so the targets given to PartialFit and TrainEpoch have TargetDim columns.
+type HeteroscedasticTrainer struct {
+	*Trainer
+	targetDim int
+}
+
+// NewHeteroscedasticTrainer constructs a net with nHiddenLayers layers of tanh
+// neurons and linear outputs for the mean and log-variance of each of the
+// targetDim targets
+func NewHeteroscedasticTrainer(inputDim, targetDim, nHiddenLayers, nNeuronsPerLayer int) (*HeteroscedasticTrainer, error) {
+	if targetDim <= 0 {
+		return nil, errors.New("non-positive target dimension")
+	}
+	trainer, err := NewSimpleTrainer(inputDim, 2*targetDim, nHiddenLayers, nNeuronsPerLayer, Linear{})
+	if err != nil {
+		return nil, err
+	}
+	trainer.SetLoss(GaussianNLL{})
+	return &HeteroscedasticTrainer{
+		Trainer:   trainer,
+		targetDim: targetDim,
+	}, nil
+}
+
+// TargetDim returns the number of targets
+func (h *HeteroscedasticTrainer) TargetDim() int {
+	return h.targetDim
+}
+
+// PredictDistribution predicts the mean and the standard deviation of every
+// target at every row of inputs. If means or stds is nil, new matrices are
+// allocated.
+func (h *HeteroscedasticTrainer) PredictDistribution(inputs RowMatrix, means, stds MutableRowMatrix) (MutableRowMatrix, MutableRowMatrix, error) {
+	nSamples, _ := inputs.Dims()
+	var err error
+	means, err = h.checkOrAlloc(means, nSamples)
+	if err != nil {
+		return means, stds, err
+	}
+	stds, err = h.checkOrAlloc(stds, nSamples)
+	if err != nil {
+		return means, stds, err
+	}
+	outputs, err := h.PredictBatch(inputs, nil)
+	if err != nil {
+		return means, stds, err
+	}
+	std := make([]float64, h.targetDim)
+	for i, row := range outputs.(SosMatrix) {
+		for j, logVar := range row[h.targetDim:] {
+			std[j] = math.Exp(0.5 * logVar)
+		}
+		means.SetRow(i, row[:h.targetDim])
+		stds.SetRow(i, std)
+	}
+	return means, stds, nil
+}
+
+func (h *HeteroscedasticTrainer) checkOrAlloc(m MutableRowMatrix, nSamples int) (MutableRowMatrix, error) {
+	if m == nil {
+		s := make(SosMatrix, nSamples)
+		for i := range s {
+			s[i] = make([]float64, h.targetDim)
+		}
+		return s, nil
+	}
+	r, c := m.Dims()
+	if c != h.targetDim {
+		return m, dimensionError("predict distribution", ErrOutputDim, h.targetDim, c)
+	}
+	if r != nSamples {
+		return m, dimensionError("predict distribution", ErrRows, nSamples, r)
+	}
+	return m, nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestGaussianNLL(t *testing.T) {
+	deriv := make([]float64, 2)
+	// Unit variance gives half the squared error plus a constant
+	loss := GaussianNLL{}.LossDeriv([]float64{1, 0}, []float64{3}, deriv)
+	if want := 0.5*math.Log(2*math.Pi) + 2; math.Abs(loss-want) > 1e-14 {
+		t.Errorf("Wrong loss. Expected %v, found %v", want, loss)
+	}
+	testLossDeriv(t, "GaussianNLL", GaussianNLL{}, []float64{1, -2, 0.5, -0.3}, []float64{0.3, 1})
+	testLossDeriv(t, "GaussianNLL", GaussianNLL{}, []float64{0.2, 1.5}, []float64{-1})
+	if d := (GaussianNLL{}).TargetDim(6); d != 3 {
+		t.Errorf("Wrong target dimension %v", d)
+	}
+
+	// An odd number of outputs is an error rather than a panic while training
+	s, err := NewSimpleTrainer(1, 3, 1, 4, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetLoss(GaussianNLL{})
+	if _, err := s.PartialFit(SosMatrix{{1}, {2}}, SosMatrix{{1}, {2}}); err == nil {
+		t.Errorf("Expected odd output dimension error")
+	}
+}
+
+func TestHeteroscedasticTrainer(t *testing.T) {
+	h, err := NewHeteroscedasticTrainer(1, 1, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.OutputDim() != 2 || h.TargetDim() != 1 {
+		t.Errorf("Wrong dimensions")
+	}
+	rnd := rand.New(rand.NewSource(1))
+	h.SetRand(rnd)
+	h.RandomizeParameters()
+	h.SetOptimizer(&Adam{Rate: 0.01})
+
+	// The noise grows with the distance from zero
+	nSamples := 500
+	inputs := make(SosMatrix, nSamples)
+	targets := make(SosMatrix, nSamples)
+	for i := range inputs {
+		x := 2*rnd.Float64() - 1
+		inputs[i] = []float64{x}
+		targets[i] = []float64{x + (0.05+0.5*math.Abs(x))*rnd.NormFloat64()}
+	}
+	if _, err := h.TrainEpoch(inputs, SosMatrix{{1, 2}}, 10, nil); err == nil {
+		t.Errorf("Expected target dimension error")
+	}
+	for i := 0; i < 100; i++ {
+		if _, err := h.TrainEpoch(inputs, targets, 20, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	means, stds, err := h.PredictDistribution(SosMatrix{{-0.9}, {0}, {0.9}}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, s := means.(SosMatrix), stds.(SosMatrix)
+	for i, x := range []float64{-0.9, 0, 0.9} {
+		if math.Abs(m[i][0]-x) > 0.2 {
+			t.Errorf("Wrong mean at %v: %v", x, m[i][0])
+		}
+	}
+	if s[1][0] >= s[0][0]/2 || s[1][0] >= s[2][0]/2 {
+		t.Errorf("Standard deviation doesn't grow with the noise: %v", s)
+	}
+	if _, _, err := h.PredictDistribution(SosMatrix{{0}}, SosMatrix{{0, 0}}, nil); err == nil {
+		t.Errorf("Expected dimension error")
+	}
+}
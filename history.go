@@ -0,0 +1,169 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// History records the progress of training at the end of every epoch of
+// TrainEpoch so that training curves can be plotted. Element i of each slice
+// is the value once Epochs[i] epochs have been completed. History can be
+// marshaled to JSON directly, or written as CSV with WriteCSV. Create a History
+// with NewHistory and record into it with Trainer.SetHistory.
+type History struct {
+	Epochs    []int     `json:"epochs"`
+	TrainLoss []float64 `json:"trainLoss"` // Mean mini-batch loss over the epoch
+
+	// ValidationLoss is the mean loss over the validation data, or nil if
+	// there is no validation data
+	ValidationLoss []float64 `json:"validationLoss,omitempty"`
+
+	// LearningRate is the learning rate of the optimizer at the last step of
+	// the epoch
+	LearningRate []float64 `json:"learningRate"`
+
+	// GradientNorm is the mean over the mini-batches of the epoch of the
+	// Euclidean norm of the gradient of the loss, before any penalty or noise
+	// is added
+	GradientNorm []float64 `json:"gradientNorm"`
+
+	// Metrics holds the value of each metric by name
+	Metrics map[string][]float64 `json:"metrics,omitempty"`
+
+	validInputs  RowMatrix
+	validTargets RowMatrix
+	metrics      map[string]Metric
+
+	// Running sum of the gradient norms of the current epoch
+	gradNormSum float64
+	nGradNorms  int
+}
+
+// NewHistory returns a new History. If validInputs and validTargets are not
+// nil, the loss on the validation data is recorded at every epoch. The metrics
+// are evaluated on the validation data, or on the training data if there is
+// none.
+func NewHistory(validInputs, validTargets RowMatrix, metrics map[string]Metric) *History {
+	h := &History{
+		validInputs:  validInputs,
+		validTargets: validTargets,
+		metrics:      metrics,
+	}
+	if len(metrics) != 0 {
+		h.Metrics = make(map[string][]float64, len(metrics))
+	}
+	return h
+}
+
+// Len returns the number of epochs recorded
+func (h *History) Len() int {
+	return len(h.Epochs)
+}
+
+// observeGradient adds the norm of a mini-batch gradient to the current epoch
+func (h *History) observeGradient(grad []float64) {
+	var sum float64
+	for _, v := range grad {
+		sum += v * v
+	}
+	h.gradNormSum += math.Sqrt(sum)
+	h.nGradNorms++
+}
+
+// record appends the values at the end of an epoch
+func (h *History) record(s *Trainer, epoch int, trainLoss float64, inputs, targets RowMatrix) error {
+	if h.validInputs != nil && h.validTargets != nil {
+		losses, err := s.SampleLosses(h.validInputs, h.validTargets, nil)
+		if err != nil {
+			return err
+		}
+		var loss float64
+		for _, l := range losses {
+			loss += l
+		}
+		h.ValidationLoss = append(h.ValidationLoss, loss/float64(len(losses)))
+		inputs, targets = h.validInputs, h.validTargets
+	}
+	if len(h.metrics) != 0 {
+		predictions, err := s.PredictBatch(inputs, nil)
+		if err != nil {
+			return err
+		}
+		for name, metric := range h.metrics {
+			h.Metrics[name] = append(h.Metrics[name], metric(predictions, targets))
+		}
+	}
+	var gradNorm float64
+	if h.nGradNorms != 0 {
+		gradNorm = h.gradNormSum / float64(h.nGradNorms)
+	}
+	h.gradNormSum, h.nGradNorms = 0, 0
+
+	h.Epochs = append(h.Epochs, epoch)
+	h.TrainLoss = append(h.TrainLoss, trainLoss)
+	h.LearningRate = append(h.LearningRate, s.Optimizer().LearningRate())
+	h.GradientNorm = append(h.GradientNorm, gradNorm)
+	return nil
+}
+
+// WriteCSV writes the history to w as CSV with a header row and one row per
+// epoch. The columns are epoch, trainLoss, validationLoss if there is
+// validation data, learningRate, gradientNorm, and then the metrics sorted by
+// name.
+func (h *History) WriteCSV(w io.Writer) error {
+	names := make([]string, 0, len(h.Metrics))
+	for name := range h.Metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	header := []string{"epoch", "trainLoss"}
+	if h.ValidationLoss != nil {
+		header = append(header, "validationLoss")
+	}
+	header = append(header, "learningRate", "gradientNorm")
+	header = append(header, names...)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	format := func(v float64) string {
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	row := make([]string, len(header))
+	for i, epoch := range h.Epochs {
+		row = append(row[:0], strconv.Itoa(epoch), format(h.TrainLoss[i]))
+		if h.ValidationLoss != nil {
+			row = append(row, format(h.ValidationLoss[i]))
+		}
+		row = append(row, format(h.LearningRate[i]), format(h.GradientNorm[i]))
+		for _, name := range names {
+			row = append(row, format(h.Metrics[name][i]))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// SetHistory sets the history into which TrainEpoch records the progress of
+// training at the end of every epoch. If h is nil, no history is recorded. The
+// history is not copied by Clone.
+func (s *Trainer) SetHistory(h *History) {
+	s.history = h
+}
+
+// History returns the history set by SetHistory
+func (s *Trainer) History() *History {
+	return s.history
+}
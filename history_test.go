@@ -0,0 +1,122 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	s, err := NewSimpleTrainer(2, 1, 1, 4, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetRand(rand.New(rand.NewSource(1)))
+	s.RandomizeParameters()
+	s.SetSchedule(LinearDecay{Start: 0.1, End: 0.01, Steps: 10})
+	rnd := rand.New(rand.NewSource(2))
+	inputs := RandomMat(40, 2, rnd.NormFloat64)
+	targets := make(SosMatrix, len(inputs))
+	for i, x := range inputs {
+		targets[i] = []float64{x[0] - 2*x[1]}
+	}
+	validInputs := RandomMat(10, 2, rnd.NormFloat64)
+	validTargets := make(SosMatrix, len(validInputs))
+	for i, x := range validInputs {
+		validTargets[i] = []float64{x[0] - 2*x[1]}
+	}
+
+	h := NewHistory(validInputs, validTargets, map[string]Metric{"mse": MeanSquaredError, "mae": MeanAbsoluteError})
+	s.SetHistory(h)
+	nEpochs := 5
+	for i := 0; i < nEpochs; i++ {
+		loss, err := s.TrainEpoch(inputs, targets, 10, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.TrainLoss[i] != loss {
+			t.Errorf("Epoch %v: wrong train loss. Expected %v, found %v", i, loss, h.TrainLoss[i])
+		}
+		if h.Epochs[i] != i+1 {
+			t.Errorf("Wrong epoch. Expected %v, found %v", i+1, h.Epochs[i])
+		}
+		if h.LearningRate[i] != s.Optimizer().LearningRate() {
+			t.Errorf("Epoch %v: wrong learning rate %v", i, h.LearningRate[i])
+		}
+		if h.GradientNorm[i] <= 0 {
+			t.Errorf("Epoch %v: non-positive gradient norm %v", i, h.GradientNorm[i])
+		}
+		predictions, _ := s.PredictBatch(validInputs, nil)
+		mse := MeanSquaredError(predictions, validTargets)
+		if math.Abs(h.Metrics["mse"][i]-mse) > 1e-14 {
+			t.Errorf("Epoch %v: wrong mse. Expected %v, found %v", i, mse, h.Metrics["mse"][i])
+		}
+		// With one output, SquaredDistance is the squared error
+		if math.Abs(h.ValidationLoss[i]-mse) > 1e-14 {
+			t.Errorf("Epoch %v: wrong validation loss. Expected %v, found %v", i, mse, h.ValidationLoss[i])
+		}
+	}
+	if h.Len() != nEpochs || h.LearningRate[0] == h.LearningRate[nEpochs-1] {
+		t.Errorf("Wrong history %v", h)
+	}
+
+	b, err := json.Marshal(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded History
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !floatsEqual(decoded.TrainLoss, h.TrainLoss) || !floatsEqual(decoded.Metrics["mae"], h.Metrics["mae"]) {
+		t.Errorf("History changed by JSON encoding")
+	}
+
+	var buf bytes.Buffer
+	if err := h.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := []string{"epoch", "trainLoss", "validationLoss", "learningRate", "gradientNorm", "mae", "mse"}
+	if len(records) != nEpochs+1 || len(records[0]) != len(header) {
+		t.Fatalf("Wrong CSV shape %v", records)
+	}
+	for j, name := range header {
+		if records[0][j] != name {
+			t.Errorf("Wrong column %v. Expected %v, found %v", j, name, records[0][j])
+		}
+	}
+	if records[3][0] != "3" {
+		t.Errorf("Wrong epoch column %v", records[3][0])
+	}
+
+	// Without validation data there is no validation loss, and the metrics are
+	// evaluated on the training data
+	h = NewHistory(nil, nil, map[string]Metric{"mse": MeanSquaredError})
+	s.SetHistory(h)
+	if _, err := s.TrainEpoch(inputs, targets, 10, nil); err != nil {
+		t.Fatal(err)
+	}
+	predictions, _ := s.PredictBatch(inputs, nil)
+	if h.ValidationLoss != nil || math.Abs(h.Metrics["mse"][0]-MeanSquaredError(predictions, targets)) > 1e-14 {
+		t.Errorf("Wrong history without validation data")
+	}
+	buf.Reset()
+	if err := h.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	records, _ = csv.NewReader(&buf).ReadAll()
+	if len(records[0]) != 5 {
+		t.Errorf("Wrong CSV columns %v", records[0])
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import "math"
+
+// A Hook transforms or validates a vector in place. A Hook which returns an
+// error stops the prediction of that input.
+type Hook func(x []float64) error
+
+// Hooked is a predictor which runs pre-hooks on every input before it is given
+// to the wrapped predictor and post-hooks on every output, for example to
+// validate inputs or to clamp, round or denormalize outputs. The hooks run in
+// order. The pre-hooks operate on a copy of the input, so the caller's input
+// is never modified. Hooks must not change the length of the vector, and must
+// be safe to call concurrently as batches are predicted in parallel.
+//
+// In PredictBatch, an error from a hook fails only its row, and the error is
+// reported as a *BatchError.
+type Hooked struct {
+	predictor Predictor
+	pre       []Hook
+	post      []Hook
+	grainSize int
+}
+
+// NewHooked wraps the predictor with the pre-hooks and post-hooks
+func NewHooked(p Predictor, pre, post []Hook) *Hooked {
+	return &Hooked{
+		predictor: p,
+		pre:       pre,
+		post:      post,
+		grainSize: combinedGrainSize([]Predictor{p}),
+	}
+}
+
+// InputDim returns the number of inputs of the wrapped predictor
+func (h *Hooked) InputDim() int {
+	return h.predictor.InputDim()
+}
+
+// OutputDim returns the number of outputs of the wrapped predictor
+func (h *Hooked) OutputDim() int {
+	return h.predictor.OutputDim()
+}
+
+// GrainSize returns the number of samples per parallel work unit
+func (h *Hooked) GrainSize() int {
+	return h.grainSize
+}
+
+// Predict runs the pre-hooks on a copy of the input, predicts the output with
+// the wrapped predictor and runs the post-hooks on the output
+func (h *Hooked) Predict(input, output []float64) ([]float64, error) {
+	if len(input) != h.InputDim() {
+		return nil, dimensionError("", ErrInputDim, h.InputDim(), len(input))
+	}
+	if output == nil {
+		output = make([]float64, h.OutputDim())
+	}
+	if len(output) != h.OutputDim() {
+		return nil, dimensionError("", ErrOutputDim, h.OutputDim(), len(output))
+	}
+	return h.newPredictor().Predict(input, output)
+}
+
+// PredictBatch predicts the output for every row of inputs, running the hooks
+// on every row
+func (h *Hooked) PredictBatch(inputs RowMatrix, outputs MutableRowMatrix) (MutableRowMatrix, error) {
+	return BatchPredict(hookedBatchPredictor{h}, inputs, outputs, h.InputDim(), h.OutputDim(), h.grainSize)
+}
+
+func (h *Hooked) newPredictor() *hookedPredictor {
+	return &hookedPredictor{
+		hooked:    h,
+		predictor: newPredictorFrom(h.predictor),
+		input:     make([]float64, h.InputDim()),
+	}
+}
+
+// hookedBatchPredictor implements BatchPredictor for Hooked
+type hookedBatchPredictor struct {
+	*Hooked
+}
+
+func (b hookedBatchPredictor) NewPredictor() Predictor {
+	return b.newPredictor()
+}
+
+// hookedPredictor contains the temporary memory needed for a prediction
+type hookedPredictor struct {
+	hooked    *Hooked
+	predictor Predictor
+	input     []float64
+}
+
+func (p *hookedPredictor) Predict(input, output []float64) ([]float64, error) {
+	copy(p.input, input)
+	for _, hook := range p.hooked.pre {
+		if err := hook(p.input); err != nil {
+			return output, err
+		}
+	}
+	output, err := p.predictor.Predict(p.input, output)
+	if err != nil {
+		return output, err
+	}
+	for _, hook := range p.hooked.post {
+		if err := hook(output); err != nil {
+			return output, err
+		}
+	}
+	return output, nil
+}
+
+func (p *hookedPredictor) PredictBatch(inputs RowMatrix, outputs MutableRowMatrix) (MutableRowMatrix, error) {
+	return p.hooked.PredictBatch(inputs, outputs)
+}
+
+func (p *hookedPredictor) InputDim() int {
+	return p.hooked.InputDim()
+}
+
+func (p *hookedPredictor) OutputDim() int {
+	return p.hooked.OutputDim()
+}
+
+// Clamp returns a hook which limits every element to [min, max]
+func Clamp(min, max float64) Hook {
+	return func(x []float64) error {
+		for i, v := range x {
+			x[i] = math.Max(min, math.Min(max, v))
+		}
+		return nil
+	}
+}
+
+// Round returns a hook which rounds every element to the nearest integer
+func Round() Hook {
+	return func(x []float64) error {
+		for i, v := range x {
+			x[i] = math.Round(v)
+		}
+		return nil
+	}
+}
+
+// Denormalize returns a hook which undoes standardization, setting element i
+// to x[i]*scale[i] + shift[i], for example to map the outputs of a net trained
+// on standardized targets back to the original units
+func Denormalize(scale, shift []float64) Hook {
+	return func(x []float64) error {
+		if len(x) != len(scale) || len(x) != len(shift) {
+			return dimensionError("denormalize", ErrOutputDim, len(scale), len(x))
+		}
+		for i := range x {
+			x[i] = x[i]*scale[i] + shift[i]
+		}
+		return nil
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestHooked(t *testing.T) {
+	s, err := NewSimpleTrainer(3, 2, 1, 4, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.RandomizeParameters()
+	s.HackGrainSize(3)
+	double := func(x []float64) error {
+		for i := range x {
+			x[i] *= 2
+		}
+		return nil
+	}
+	positive := func(x []float64) error {
+		if x[0] < 0 {
+			return errNegative
+		}
+		return nil
+	}
+	scale, shift := []float64{10, 0.5}, []float64{1, -1}
+	h := NewHooked(s, []Hook{positive, double}, []Hook{Denormalize(scale, shift), Clamp(-2, 2)})
+	if h.InputDim() != 3 || h.OutputDim() != 2 {
+		t.Errorf("Wrong dimensions")
+	}
+	var _ Predictor = h
+
+	nSamples := 20
+	inputs := RandomMat(nSamples, 3, rand.NormFloat64)
+	want := make(SosMatrix, nSamples)
+	for i, input := range inputs {
+		doubled := make([]float64, len(input))
+		for j, v := range input {
+			doubled[j] = 2 * v
+		}
+		want[i], _ = s.Predict(doubled, nil)
+		for j := range want[i] {
+			want[i][j] = math.Max(-2, math.Min(2, want[i][j]*scale[j]+shift[j]))
+		}
+	}
+
+	orig := make(SosMatrix, nSamples)
+	for i := range inputs {
+		orig[i] = append([]float64(nil), inputs[i]...)
+	}
+	for i, input := range inputs {
+		output, err := h.Predict(input, nil)
+		if input[0] < 0 {
+			if err != errNegative {
+				t.Errorf("Row %v: expected hook error, found %v", i, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !floatsEqualApprox(output, want[i], 1e-14) {
+			t.Errorf("Wrong prediction %v. Expected %v, found %v", i, want[i], output)
+		}
+	}
+
+	outputs, err := h.PredictBatch(inputs, nil)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || !errors.Is(err, errNegative) {
+		t.Fatalf("Wrong batch error %v", err)
+	}
+	var failed []int
+	for i, input := range inputs {
+		if input[0] < 0 {
+			failed = append(failed, i)
+			continue
+		}
+		if !floatsEqualApprox(outputs.(SosMatrix)[i], want[i], 1e-14) {
+			t.Errorf("Wrong batch prediction %v. Expected %v, found %v", i, want[i], outputs.(SosMatrix)[i])
+		}
+	}
+	if !equalInts(batchErr.Rows(), failed) {
+		t.Errorf("Wrong failed rows. Expected %v, found %v", failed, batchErr.Rows())
+	}
+	for i := range inputs {
+		if !floatsEqual(inputs[i], orig[i]) {
+			t.Errorf("Input %v modified by hooks", i)
+		}
+	}
+
+	if _, err := h.Predict(make([]float64, 2), nil); err == nil {
+		t.Errorf("Expected input dimension error")
+	}
+}
+
+func TestRound(t *testing.T) {
+	x := []float64{-1.5, 0.4, 2.6}
+	if err := Round()(x); err != nil || !floatsEqual(x, []float64{-2, 0, 3}) {
+		t.Errorf("Wrong rounding %v", x)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// FeatureImportance is the importance of a single input feature as measured by
+// PermutationImportance
+type FeatureImportance struct {
+	Feature    int     // Index of the input feature
+	Importance float64 // Mean increase in the metric when the feature is permuted
+	Std        float64 // Standard deviation of the increase over the repeats
+}
+
+// PermutationImportance measures the importance of each input feature by randomly
+// permuting the values of that feature across the rows of inputs and measuring
+// the increase in the metric with respect to the targets. The permutation is
+// repeated nRepeats times for each feature. The permutations are drawn from
+// rnd, or from the global source of math/rand if rnd is nil. The returned
+// importances are sorted from most to least important.
+func PermutationImportance(p Predictor, inputs, targets RowMatrix, metric Metric, nRepeats int, rnd *rand.Rand) ([]FeatureImportance, error) {
+	if nRepeats <= 0 {
+		return nil, errors.New("importance: non-positive number of repeats")
+	}
+	nSamples, inputDim := inputs.Dims()
+	if inputDim != p.InputDim() {
+		return nil, dimensionError("importance", ErrInputDim, p.InputDim(), inputDim)
+	}
+	nTargets, outputDim := targets.Dims()
+	if outputDim != p.OutputDim() {
+		return nil, dimensionError("importance", ErrOutputDim, p.OutputDim(), outputDim)
+	}
+	if nTargets != nSamples {
+		return nil, dimensionError("importance", ErrRows, nSamples, nTargets)
+	}
+
+	// Copy the inputs so that a column can be permuted without modifying the
+	// original data
+	permuted := make(SosMatrix, nSamples)
+	for i := range permuted {
+		permuted[i] = inputs.Row(nil, i)
+	}
+	outputs := make(SosMatrix, nSamples)
+	for i := range outputs {
+		outputs[i] = make([]float64, outputDim)
+	}
+
+	if _, err := p.PredictBatch(permuted, outputs); err != nil {
+		return nil, err
+	}
+	base := metric(outputs, targets)
+
+	rnd = orGlobal(rnd)
+	importances := make([]FeatureImportance, inputDim)
+	column := make([]float64, nSamples)
+	increases := make([]float64, nRepeats)
+	for j := 0; j < inputDim; j++ {
+		for i := range column {
+			column[i] = permuted[i][j]
+		}
+		for r := range increases {
+			perm := rnd.Perm(nSamples)
+			for i, k := range perm {
+				permuted[i][j] = column[k]
+			}
+			if _, err := p.PredictBatch(permuted, outputs); err != nil {
+				return nil, err
+			}
+			increases[r] = metric(outputs, targets) - base
+		}
+		for i := range column {
+			permuted[i][j] = column[i]
+		}
+
+		var mean float64
+		for _, v := range increases {
+			mean += v
+		}
+		mean /= float64(nRepeats)
+		var variance float64
+		for _, v := range increases {
+			variance += (v - mean) * (v - mean)
+		}
+		variance /= float64(nRepeats)
+		importances[j] = FeatureImportance{
+			Feature:    j,
+			Importance: mean,
+			Std:        math.Sqrt(variance),
+		}
+	}
+	sort.Stable(byImportance(importances))
+	return importances, nil
+}
+
+// byImportance sorts feature importances from most to least important
+type byImportance []FeatureImportance
+
+func (b byImportance) Len() int           { return len(b) }
+func (b byImportance) Less(i, j int) bool { return b[i].Importance > b[j].Importance }
+func (b byImportance) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
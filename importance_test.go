@@ -0,0 +1,51 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestPermutationImportance(t *testing.T) {
+	trainer, err := NewSimpleTrainer(3, 1, 0, 1, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(trainer.parameters[0][0], []float64{0, 1, 3, 0.5})
+
+	nSamples := 200
+	inputs := RandomMat(nSamples, 3, rand.NormFloat64)
+	targets, err := trainer.PredictBatch(inputs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	importances, err := PermutationImportance(trainer, inputs, targets, MeanSquaredError, 3, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, feature := range []int{2, 1, 0} {
+		if importances[i].Feature != feature {
+			t.Errorf("Wrong feature order. Expected feature %v at rank %v, found %v", feature, i, importances[i].Feature)
+		}
+	}
+	if importances[2].Importance != 0 {
+		t.Errorf("Unused feature has non-zero importance %v", importances[2].Importance)
+	}
+
+	// The same source gives the same importances, and nil uses the global source
+	again, err := PermutationImportance(trainer, inputs, targets, MeanSquaredError, 3, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range importances {
+		if again[i] != importances[i] {
+			t.Errorf("Importances not reproducible: %v, %v", importances[i], again[i])
+		}
+	}
+	if _, err := PermutationImportance(trainer, inputs, targets, MeanSquaredError, 3, nil); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Initializer sets the initial weights of a layer of SumNeurons. weights[j]
+// contains the input weights of neuron j (not including the bias), so
+// len(weights) is the fan-out of the layer and each weights[j] has length
+// equal to the fan-in. The biases are set to zero by the caller. Random numbers
+// should be drawn from rnd so that initialization is reproducible.
+//
+// Initializers are only used for layers where all of the neurons are
+// SumNeurons. Other layers are initialized with Neuron.Randomize.
+type Initializer interface {
+	Initialize(weights [][]float64, rnd *rand.Rand)
+}
+
+// Xavier is the Glorot uniform initialization, where the weights are drawn
+// from U(-b, b) with b = sqrt(6 / (fanIn + fanOut)). This is a good choice for
+// Tanh and Sigmoid activators.
+type Xavier struct{}
+
+func (Xavier) Initialize(weights [][]float64, rnd *rand.Rand) {
+	fanIn, fanOut := len(weights[0]), len(weights)
+	Uniform{Bound: math.Sqrt(6 / float64(fanIn+fanOut))}.Initialize(weights, rnd)
+}
+
+func (Xavier) String() string {
+	return "Xavier"
+}
+
+// He is the He normal initialization, where the weights are drawn from
+// N(0, 2 / fanIn). This is a good choice for rectified linear activators.
+type He struct{}
+
+func (He) Initialize(weights [][]float64, rnd *rand.Rand) {
+	std := math.Sqrt(2 / float64(len(weights[0])))
+	for _, w := range weights {
+		for k := range w {
+			w[k] = rnd.NormFloat64() * std
+		}
+	}
+}
+
+func (He) String() string {
+	return "He"
+}
+
+// Uniform draws the weights from U(-Bound, Bound)
+type Uniform struct {
+	Bound float64
+}
+
+func (u Uniform) Initialize(weights [][]float64, rnd *rand.Rand) {
+	for _, w := range weights {
+		for k := range w {
+			w[k] = (2*rnd.Float64() - 1) * u.Bound
+		}
+	}
+}
+
+func (Uniform) String() string {
+	return "Uniform"
+}
+
+// Orthogonal sets the weight matrix to a random (semi-)orthogonal matrix scaled
+// by Gain. If the fan-out is at most the fan-in the rows of the weight matrix
+// are orthonormal, otherwise the columns are. A Gain of zero is treated as one.
+type Orthogonal struct {
+	Gain float64
+}
+
+func (o Orthogonal) Initialize(weights [][]float64, rnd *rand.Rand) {
+	gain := o.Gain
+	if gain == 0 {
+		gain = 1
+	}
+	fanIn, fanOut := len(weights[0]), len(weights)
+	// Orthonormalize the shorter dimension with modified Gram-Schmidt. vecs
+	// are the vectors to be orthonormalized.
+	nVecs, vecLen := fanOut, fanIn
+	transpose := fanOut > fanIn
+	if transpose {
+		nVecs, vecLen = fanIn, fanOut
+	}
+	vecs := make([][]float64, nVecs)
+	for i := range vecs {
+		vecs[i] = make([]float64, vecLen)
+		for {
+			for k := range vecs[i] {
+				vecs[i][k] = rnd.NormFloat64()
+			}
+			for _, prev := range vecs[:i] {
+				var dot float64
+				for k, v := range prev {
+					dot += v * vecs[i][k]
+				}
+				for k, v := range prev {
+					vecs[i][k] -= dot * v
+				}
+			}
+			var norm float64
+			for _, v := range vecs[i] {
+				norm += v * v
+			}
+			norm = math.Sqrt(norm)
+			// The random vector is almost surely linearly independent of the
+			// previous ones, but draw again if it isn't.
+			if norm > 1e-8 {
+				for k := range vecs[i] {
+					vecs[i][k] /= norm
+				}
+				break
+			}
+		}
+	}
+	for j, w := range weights {
+		for k := range w {
+			if transpose {
+				w[k] = gain * vecs[k][j]
+			} else {
+				w[k] = gain * vecs[j][k]
+			}
+		}
+	}
+}
+
+func (Orthogonal) String() string {
+	return "Orthogonal"
+}
+
+// SetInitializer sets the initializer used by RandomizeParameters for all of
+// the layers without a layer-specific initializer. If init is nil, the neurons
+// are initialized using Neuron.Randomize.
+func (s *Trainer) SetInitializer(init Initializer) {
+	s.initializer = init
+}
+
+// SetLayerInitializer sets the initializer used by RandomizeParameters for the
+// given layer, overriding the net-wide initializer. If init is nil, the
+// net-wide initializer is used.
+func (s *Trainer) SetLayerInitializer(layer int, init Initializer) {
+	s.layerInitializers[layer] = init
+}
+
+// randomizeLayer randomizes the parameters of the given layer
+func (s *Trainer) randomizeLayer(layer int) {
+	init := s.layerInitializers[layer]
+	if init == nil {
+		init = s.initializer
+	}
+	if init != nil && isSumLayer(s.neurons[layer]) {
+		weights := make([][]float64, len(s.parameters[layer]))
+		for j, p := range s.parameters[layer] {
+			// The last parameter of a SumNeuron is the bias
+			weights[j] = p[:len(p)-1]
+			p[len(p)-1] = 0
+		}
+		init.Initialize(weights, s.rand())
+		return
+	}
+	for j, neuron := range s.neurons[layer] {
+		s.randomizeNeuron(neuron, s.parameters[layer][j])
+	}
+}
+
+// randomizeNeuron sets the parameters of the neuron to a random initial
+// condition, using the trainer's source of randomness if the neuron supports it
+func (s *Trainer) randomizeNeuron(neuron Neuron, parameters []float64) {
+	if r, ok := neuron.(RandomizerFrom); ok {
+		r.RandomizeFrom(parameters, s.rand())
+		return
+	}
+	neuron.Randomize(parameters)
+}
+
+// isSumLayer returns true if all of the neurons in the layer are SumNeurons
+func isSumLayer(neurons []Neuron) bool {
+	for _, neuron := range neurons {
+		if _, ok := neuron.(SumNeuron); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,74 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestOrthogonal(t *testing.T) {
+	for _, dims := range [][2]int{{3, 5}, {5, 3}, {4, 4}} {
+		fanOut, fanIn := dims[0], dims[1]
+		weights := RandomMat(fanOut, fanIn, func() float64 { return 0 })
+		Orthogonal{Gain: 2}.Initialize(weights, rand.New(rand.NewSource(1)))
+		// Check W W^T = 4 I or W^T W = 4 I, whichever is smaller
+		n, m := fanOut, fanIn
+		at := func(i, k int) float64 { return weights[i][k] }
+		if fanOut > fanIn {
+			n, m = fanIn, fanOut
+			at = func(i, k int) float64 { return weights[k][i] }
+		}
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				var dot float64
+				for k := 0; k < m; k++ {
+					dot += at(i, k) * at(j, k)
+				}
+				want := 0.0
+				if i == j {
+					want = 4
+				}
+				if math.Abs(dot-want) > 1e-12 {
+					t.Errorf("%v x %v: not orthogonal at (%v, %v): %v", fanOut, fanIn, i, j, dot)
+				}
+			}
+		}
+	}
+}
+
+func TestInitializers(t *testing.T) {
+	s, err := NewSimpleTrainer(20, 3, 1, 30, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetInitializer(Uniform{Bound: 0.1})
+	s.SetLayerInitializer(1, Uniform{Bound: 0.01})
+	s.RandomizeParameters()
+	for i, bound := range []float64{0.1, 0.01} {
+		for _, p := range s.parameters[i] {
+			if p[len(p)-1] != 0 {
+				t.Errorf("Layer %v: bias not zero", i)
+			}
+			for _, v := range p[:len(p)-1] {
+				if math.Abs(v) > bound {
+					t.Errorf("Layer %v: weight %v out of bounds", i, v)
+				}
+			}
+		}
+	}
+	bound := math.Sqrt(6.0 / (20 + 30))
+	s.SetInitializer(Xavier{})
+	s.SetLayerInitializer(1, nil)
+	s.RandomizeParameters()
+	for _, p := range s.parameters[0] {
+		for _, v := range p[:len(p)-1] {
+			if math.Abs(v) > bound {
+				t.Errorf("Xavier weight %v out of bounds", v)
+			}
+		}
+	}
+}
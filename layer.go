@@ -0,0 +1,367 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Layer is one layer of a feed-forward net. The net computes its predictions and
+// gradients a layer at a time through this interface, so a layer is free to
+// evaluate all of its outputs at once rather than one neuron at a time.
+//
+// A layer has no state other than its parameters, so Forward and Backward may be
+// called concurrently. The parameters of a layer are in the flat parameter
+// ordering of the net (see Net.Parameters) restricted to the layer.
+type Layer interface {
+	NumOutputs() int
+	NumParameters() int
+
+	// Forward computes the outputs of the layer at the input and stores them
+	// in output.
+	Forward(input, output []float64)
+
+	// Backward computes the derivatives of an objective given dOutput, the
+	// derivative of the objective with respect to the outputs of the layer,
+	// where output was computed by Forward at the input. The derivative with
+	// respect to the parameters of the layer is stored into dParams, and the
+	// derivative with respect to the input is stored into dInput. Either may be
+	// nil, in which case that derivative is not computed.
+	Backward(input, output, dOutput, dParams, dInput []float64)
+}
+
+// NeuronLayer is a Layer made of individual neurons, where Parameters[i] are
+// the parameters of Neurons[i]. The slices are used directly, so the layer sees
+// changes to the parameters.
+type NeuronLayer struct {
+	Neurons    []Neuron
+	Parameters [][]float64
+}
+
+func (l NeuronLayer) NumOutputs() int {
+	return len(l.Neurons)
+}
+
+func (l NeuronLayer) NumParameters() int {
+	var n int
+	for _, p := range l.Parameters {
+		n += len(p)
+	}
+	return n
+}
+
+func (l NeuronLayer) Forward(input, output []float64) {
+	for i, neuron := range l.Neurons {
+		combination := neuron.Combine(l.Parameters[i], input)
+		output[i] = neuron.Activate(combination)
+	}
+}
+
+func (l NeuronLayer) forwardRange(input, output []float64, start, end int) {
+	for i := start; i < end; i++ {
+		neuron := l.Neurons[i]
+		output[i] = neuron.Activate(neuron.Combine(l.Parameters[i], input))
+	}
+}
+
+func (l NeuronLayer) Backward(input, output, dOutput, dParams, dInput []float64) {
+	combinations := make([]float64, len(l.Neurons))
+	for i, neuron := range l.Neurons {
+		combinations[i] = neuron.Combine(l.Parameters[i], input)
+	}
+	l.backwardCached(input, output, combinations, dOutput, dParams, dInput)
+}
+
+func (l NeuronLayer) cacheSize() int {
+	return len(l.Neurons)
+}
+
+// forwardCached is Forward, also storing the combination of each neuron in cache
+func (l NeuronLayer) forwardCached(input, output, cache []float64) {
+	for i, neuron := range l.Neurons {
+		combination := neuron.Combine(l.Parameters[i], input)
+		cache[i] = combination
+		output[i] = neuron.Activate(combination)
+	}
+}
+
+func (l NeuronLayer) backwardCached(input, output, cache, dOutput, dParams, dInput []float64) {
+	for k := range dInput {
+		dInput[k] = 0
+	}
+	// The derivative of the combination with respect to the input is only
+	// needed as temporary memory for neurons other than SumNeuron, whose
+	// derivative is its weights
+	var dCombDInput []float64
+	var idx int
+	for i, neuron := range l.Neurons {
+		params := l.Parameters[i]
+		combination := cache[i]
+		delta := dOutput[i] * neuron.DActivateDCombination(combination, output[i])
+
+		if dParams != nil {
+			dParam := dParams[idx : idx+len(params)]
+			neuron.DCombineDParameters(params, input, combination, dParam)
+			for k := range dParam {
+				dParam[k] *= delta
+			}
+		}
+		idx += len(params)
+		if dInput == nil {
+			continue
+		}
+		if _, ok := neuron.(SumNeuron); ok {
+			for k := range dInput {
+				dInput[k] += delta * params[k]
+			}
+			continue
+		}
+		if dCombDInput == nil {
+			dCombDInput = make([]float64, len(input))
+		}
+		neuron.DCombineDInput(params, input, combination, dCombDInput)
+		for k, v := range dCombDInput {
+			dInput[k] += delta * v
+		}
+	}
+}
+
+// cachedLayer is a Layer that can save intermediate values of Forward for use
+// by Backward, such as the combinations of a NeuronLayer. backprop uses the
+// cached methods when a layer implements them to avoid recomputing the values.
+type cachedLayer interface {
+	Layer
+	cacheSize() int
+	forwardCached(input, output, cache []float64)
+	backwardCached(input, output, cache, dOutput, dParams, dInput []float64)
+}
+
+// Layer returns layer i of the net, where layer 0 is connected to the input
+func (n *Net) Layer(i int) Layer {
+	return n.layers[i]
+}
+
+// updateLayers rebuilds the layers of the net from its neurons and parameters,
+// along with the pool of temporary memory for Predict, which is sized by the
+// layers. The parameters of layers of SumNeurons with a common activator are
+// moved into contiguous memory (see sumLayer).
+func (n *Net) updateLayers() {
+	n.layers = make([]Layer, len(n.neurons))
+	for i, neurons := range n.neurons {
+		n.layers[i] = newLayer(neurons, n.parameters[i])
+	}
+	layers := n.layers
+	n.scratch = &sync.Pool{New: func() interface{} {
+		prevOutput, output := newPredictMemory(layers)
+		return &predictMemory{prevOutput: prevOutput, output: output}
+	}}
+}
+
+// newLayer returns the layer of the neurons with the given parameters, which
+// are moved into contiguous memory for a sumLayer
+func newLayer(neurons []Neuron, parameters [][]float64) Layer {
+	if a, ok := sumActivator(neurons); ok {
+		return newSumLayer(a, parameters)
+	}
+	return NeuronLayer{Neurons: neurons, Parameters: parameters}
+}
+
+// sumActivator returns the activator of the neurons if they are all SumNeurons
+// with the same activator
+func sumActivator(neurons []Neuron) (Activator, bool) {
+	first, ok := neurons[0].(SumNeuron)
+	if !ok || first.Activator == nil {
+		return nil, false
+	}
+	for _, neuron := range neurons[1:] {
+		s, ok := neuron.(SumNeuron)
+		if !ok || !reflect.DeepEqual(s.Activator, first.Activator) {
+			return nil, false
+		}
+	}
+	return first.Activator, true
+}
+
+// sumLayer is a layer of SumNeurons which all have the same activator. The
+// parameters are stored as a single row-major matrix with one row per neuron,
+// holding the weights of the neuron followed by its bias, so the forward pass
+// is a matrix-vector product over contiguous memory. This is the flat parameter
+// ordering of the layer, and the parameters of each neuron are a view of its
+// row.
+type sumLayer struct {
+	activator Activator
+	kernel    kernel
+	nInputs   int
+	nOutputs  int
+	params    []float64
+	sparse    *sparseWeights // the non-zero weights, nil if the layer is dense
+}
+
+// kernel identifies the activators for which sumLayer has a fused kernel,
+// where the activation is computed directly from the weighted sum without
+// calling the Activator interface
+type kernel int
+
+const (
+	genericKernel kernel = iota
+	linearKernel
+	tanhKernel
+	sigmoidKernel
+	reluKernel
+)
+
+func activatorKernel(a Activator) kernel {
+	switch a.(type) {
+	case Linear:
+		return linearKernel
+	case Tanh:
+		return tanhKernel
+	case Sigmoid:
+		return sigmoidKernel
+	case ReLU:
+		return reluKernel
+	}
+	return genericKernel
+}
+
+// newSumLayer copies the parameters of the neurons into a new matrix and
+// replaces parameters[i] with a view of row i
+func newSumLayer(a Activator, parameters [][]float64) *sumLayer {
+	nInputs := len(parameters[0]) - 1
+	stride := nInputs + 1
+	l := &sumLayer{
+		activator: a,
+		kernel:    activatorKernel(a),
+		nInputs:   nInputs,
+		nOutputs:  len(parameters),
+		params:    make([]float64, len(parameters)*stride),
+	}
+	for i, p := range parameters {
+		row := l.params[i*stride : (i+1)*stride : (i+1)*stride]
+		copy(row, p)
+		parameters[i] = row
+	}
+	return l
+}
+
+func (l *sumLayer) NumOutputs() int {
+	return l.nOutputs
+}
+
+func (l *sumLayer) NumParameters() int {
+	return len(l.params)
+}
+
+// combination returns the weighted sum of the inputs plus the bias for neuron i,
+// in the same order as SumNeuron.Combine
+func (l *sumLayer) combination(i int, input []float64) float64 {
+	stride := l.nInputs + 1
+	if l.sparse != nil {
+		return l.sparse.combination(i, input) + l.params[i*stride+l.nInputs]
+	}
+	row := l.params[i*stride : (i+1)*stride]
+	var combination float64
+	for k, v := range input[:l.nInputs] {
+		combination += row[k] * v
+	}
+	return combination + row[l.nInputs]
+}
+
+func (l *sumLayer) Forward(input, output []float64) {
+	l.forward(input, output, nil, 0, l.nOutputs)
+}
+
+func (l *sumLayer) forwardRange(input, output []float64, start, end int) {
+	l.forward(input, output, nil, start, end)
+}
+
+// forward computes the outputs of the neurons in [start, end), and stores their
+// combinations into cache if it is not nil. The common activators are called
+// directly, so the weighted sum is activated without an interface call.
+func (l *sumLayer) forward(input, output, cache []float64, start, end int) {
+	switch l.kernel {
+	case linearKernel:
+		for i := start; i < end; i++ {
+			combination := l.combination(i, input)
+			if cache != nil {
+				cache[i] = combination
+			}
+			output[i] = Linear{}.Activate(combination)
+		}
+	case tanhKernel:
+		for i := start; i < end; i++ {
+			combination := l.combination(i, input)
+			if cache != nil {
+				cache[i] = combination
+			}
+			output[i] = Tanh{}.Activate(combination)
+		}
+	case sigmoidKernel:
+		for i := start; i < end; i++ {
+			combination := l.combination(i, input)
+			if cache != nil {
+				cache[i] = combination
+			}
+			output[i] = Sigmoid{}.Activate(combination)
+		}
+	case reluKernel:
+		for i := start; i < end; i++ {
+			combination := l.combination(i, input)
+			if cache != nil {
+				cache[i] = combination
+			}
+			output[i] = ReLU{}.Activate(combination)
+		}
+	default:
+		for i := start; i < end; i++ {
+			combination := l.combination(i, input)
+			if cache != nil {
+				cache[i] = combination
+			}
+			output[i] = l.activator.Activate(combination)
+		}
+	}
+}
+
+func (l *sumLayer) Backward(input, output, dOutput, dParams, dInput []float64) {
+	combinations := make([]float64, l.nOutputs)
+	for i := range combinations {
+		combinations[i] = l.combination(i, input)
+	}
+	l.backwardCached(input, output, combinations, dOutput, dParams, dInput)
+}
+
+func (l *sumLayer) cacheSize() int {
+	return l.nOutputs
+}
+
+func (l *sumLayer) forwardCached(input, output, cache []float64) {
+	l.forward(input, output, cache, 0, l.nOutputs)
+}
+
+func (l *sumLayer) backwardCached(input, output, cache, dOutput, dParams, dInput []float64) {
+	for k := range dInput {
+		dInput[k] = 0
+	}
+	stride := l.nInputs + 1
+	input = input[:l.nInputs]
+	for i, combination := range cache[:l.nOutputs] {
+		delta := dOutput[i] * l.activator.DActivateDCombination(combination, output[i])
+		if dParams != nil {
+			dRow := dParams[i*stride : (i+1)*stride]
+			for k, v := range input {
+				dRow[k] = delta * v
+			}
+			dRow[l.nInputs] = delta
+		}
+		if dInput != nil {
+			row := l.params[i*stride : (i+1)*stride]
+			for k := range dInput {
+				dInput[k] += delta * row[k]
+			}
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestNeuronLayer(t *testing.T) {
+	for i, test := range netIniters {
+		n := testNets[i].Net
+		nInputs := test.inputDim
+		for j := 0; j < n.NumLayers(); j++ {
+			layer := n.Layer(j)
+			if layer.NumParameters() != n.LayerNumParameters(j) {
+				t.Errorf("%v: layer %v has %v parameters, want %v", test.name, j, layer.NumParameters(), n.LayerNumParameters(j))
+			}
+			if layer.NumOutputs() != n.LayerSizes()[j] {
+				t.Errorf("%v: layer %v has %v outputs, want %v", test.name, j, layer.NumOutputs(), n.LayerSizes()[j])
+			}
+
+			input := RandomMat(1, nInputs, rand.NormFloat64)[0]
+			output := make([]float64, layer.NumOutputs())
+			layer.Forward(input, output)
+			for k, neuron := range n.neurons[j] {
+				want := neuron.Activate(neuron.Combine(n.parameters[j][k], input))
+				if output[k] != want {
+					t.Errorf("%v: layer %v output %v is %v, want %v", test.name, j, k, output[k], want)
+				}
+			}
+
+			// Backward recomputes the values that backprop caches, so the
+			// derivatives must be the same
+			dOutput := RandomMat(1, layer.NumOutputs(), rand.NormFloat64)[0]
+			dParams := make([]float64, layer.NumParameters())
+			dInput := make([]float64, nInputs)
+			layer.Backward(input, output, dOutput, dParams, dInput)
+
+			c := layer.(cachedLayer)
+			cache := make([]float64, c.cacheSize())
+			c.forwardCached(input, output, cache)
+			wantParams := make([]float64, layer.NumParameters())
+			wantInput := make([]float64, nInputs)
+			c.backwardCached(input, output, cache, dOutput, wantParams, wantInput)
+			if !floatsEqual(dParams, wantParams) {
+				t.Errorf("%v: layer %v parameter derivative mismatch", test.name, j)
+			}
+			if !floatsEqual(dInput, wantInput) {
+				t.Errorf("%v: layer %v input derivative mismatch", test.name, j)
+			}
+
+			// Either derivative may be omitted
+			layer.Backward(input, output, dOutput, nil, dInput)
+			if !floatsEqual(dInput, wantInput) {
+				t.Errorf("%v: layer %v input derivative changed without parameter derivative", test.name, j)
+			}
+			layer.Backward(input, output, dOutput, dParams, nil)
+			if !floatsEqual(dParams, wantParams) {
+				t.Errorf("%v: layer %v parameter derivative changed without input derivative", test.name, j)
+			}
+			nInputs = layer.NumOutputs()
+		}
+	}
+}
+
+func TestLayersAfterSurgery(t *testing.T) {
+	s, err := NewSimpleTrainer(3, 2, 2, 4, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.RandomizeParameters()
+	if err := s.ResizeLayer(0, 6); err != nil {
+		t.Fatal(err)
+	}
+	if s.Layer(0).NumOutputs() != 6 {
+		t.Errorf("layer has %v outputs after resize, want 6", s.Layer(0).NumOutputs())
+	}
+	if s.Layer(1).NumParameters() != s.LayerNumParameters(1) {
+		t.Errorf("next layer has %v parameters after resize, want %v", s.Layer(1).NumParameters(), s.LayerNumParameters(1))
+	}
+	if _, err := s.Predict([]float64{1, 2, 3}, nil); err != nil {
+		t.Errorf("predict after resize: %v", err)
+	}
+	if err := s.RemoveLayer(1); err != nil {
+		t.Fatal(err)
+	}
+	if s.NumLayers() != 2 || s.Layer(1).NumOutputs() != 2 {
+		t.Errorf("layers not updated after removing a layer")
+	}
+}
+
+func TestSumLayer(t *testing.T) {
+	s, err := NewTrainer(3, 2, [][]Neuron{
+		{TanhNeuron, TanhNeuron, TanhNeuron},
+		{TanhNeuron, SigmoidNeuron},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Layer(0).(*sumLayer); !ok {
+		t.Errorf("Layer of SumNeurons with one activator is %T, not a weight matrix", s.Layer(0))
+	}
+	if _, ok := s.Layer(1).(NeuronLayer); !ok {
+		t.Errorf("Layer with mixed activators is %T, not a NeuronLayer", s.Layer(1))
+	}
+
+	// The parameters of the neurons are views of the matrix, so setting them
+	// changes the layer
+	params := make([]float64, s.TotalNumParameters())
+	for i := range params {
+		params[i] = float64(i)
+	}
+	s.SetParameters(params)
+	l := s.Layer(0).(*sumLayer)
+	if !floatsEqual(l.params, params[:len(l.params)]) {
+		t.Errorf("Weight matrix doesn't match the parameters. Found %v", l.params)
+	}
+	for j, p := range s.parameters[0] {
+		if &p[0] != &l.params[j*4] {
+			t.Errorf("Parameters of neuron %v are not a view of the weight matrix", j)
+		}
+	}
+	clone := s.Clone()
+	if &clone.Layer(0).(*sumLayer).params[0] == &l.params[0] {
+		t.Errorf("Clone shares the weight matrix")
+	}
+}
+
+func TestSumLayerKernels(t *testing.T) {
+	for _, a := range []Activator{Linear{}, Tanh{}, Sigmoid{}, ReLU{}, LinearTanh{}} {
+		neurons := make([]Neuron, 7)
+		for i := range neurons {
+			neurons[i] = SumNeuron{Activator: a}
+		}
+		parameters := make([][]float64, len(neurons))
+		for i := range parameters {
+			parameters[i] = RandomMat(1, 6, rand.NormFloat64)[0]
+		}
+		generic := NeuronLayer{Neurons: neurons, Parameters: parameters}
+		input := RandomMat(1, 5, rand.NormFloat64)[0]
+		want := make([]float64, len(neurons))
+		generic.Forward(input, want)
+
+		l := newSumLayer(a, parameters)
+		if (l.kernel == genericKernel) != (a == Activator(LinearTanh{})) {
+			t.Errorf("%v: wrong kernel %v", a, l.kernel)
+		}
+		output := make([]float64, len(neurons))
+		l.Forward(input, output)
+		if !floatsEqual(output, want) {
+			t.Errorf("%v: Forward mismatch. Expected %v, found %v", a, want, output)
+		}
+		cache := make([]float64, l.cacheSize())
+		l.forwardCached(input, output, cache)
+		if !floatsEqual(output, want) {
+			t.Errorf("%v: forwardCached mismatch. Expected %v, found %v", a, want, output)
+		}
+		for i, neuron := range neurons {
+			if c := neuron.Combine(parameters[i], input); cache[i] != c {
+				t.Errorf("%v: cached combination %v is %v, want %v", a, i, cache[i], c)
+			}
+		}
+		output = make([]float64, len(neurons))
+		l.forwardRange(input, output, 2, 5)
+		for i := range output {
+			if (i >= 2 && i < 5 && output[i] != want[i]) || ((i < 2 || i >= 5) && output[i] != 0) {
+				t.Errorf("%v: forwardRange wrote %v at %v", a, output[i], i)
+			}
+		}
+	}
+}
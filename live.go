@@ -0,0 +1,149 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+// LiveNet serves predictions from snapshots of a trainer's parameters so that
+// a model can keep serving while it is fine-tuned online. The trainer publishes
+// a new snapshot with Publish whenever its parameters should go live, such as
+// after every epoch. Each snapshot is an immutable FrozenNet, so predictions
+// which have started finish with the snapshot they started with, and later
+// predictions use the new one.
+//
+// Publishing is copy-on-write at the granularity of layers: a layer whose
+// neurons and parameters are unchanged since the last snapshot is shared with
+// it rather than copied, so publishing is cheap when only some of the layers
+// are trained, as is common when fine-tuning.
+//
+// Predict, PredictBatch and Snapshot are safe to call concurrently with each
+// other and with Publish. Publish reads the trainer, so it must not be called
+// concurrently with training, and is typically called from the goroutine which
+// trains.
+type LiveNet struct {
+	trainer *Trainer
+
+	mux      sync.RWMutex
+	snapshot *FrozenNet
+	version  int
+
+	// The neurons and parameters of the layers of the last snapshot, used to
+	// find the unchanged layers
+	neurons    [][]Neuron
+	parameters [][][]float64
+}
+
+// NewLiveNet returns a live net serving the trainer, and publishes the
+// current parameters of the trainer as the first snapshot.
+func NewLiveNet(s *Trainer) *LiveNet {
+	l := &LiveNet{trainer: s}
+	l.Publish()
+	return l
+}
+
+// Publish takes a snapshot of the current parameters of the trainer and makes
+// it serve the predictions. It returns the version of the snapshot, which
+// starts at 1 and increases by one with every call.
+func (l *LiveNet) Publish() int {
+	s := l.trainer
+	nLayers := len(s.neurons)
+	layers := make([]Layer, nLayers)
+	neurons := make([][]Neuron, nLayers)
+	parameters := make([][][]float64, nLayers)
+	for i := range layers {
+		if i < len(l.neurons) && l.layerUnchanged(i) {
+			layers[i] = l.snapshot.layers[i]
+			neurons[i] = l.neurons[i]
+			parameters[i] = l.parameters[i]
+			continue
+		}
+		neurons[i] = append([]Neuron(nil), s.neurons[i]...)
+		parameters[i] = newPerParameterMemory(s.parameters[i : i+1])[0]
+		for j, p := range s.parameters[i] {
+			copy(parameters[i][j], p)
+		}
+		layers[i] = newLayer(neurons[i], parameters[i])
+	}
+	snapshot := newFrozenNet(s.Net, layers)
+
+	// Only the publishing goroutine modifies neurons and parameters
+	l.neurons, l.parameters = neurons, parameters
+	l.mux.Lock()
+	l.snapshot = snapshot
+	l.version++
+	version := l.version
+	l.mux.Unlock()
+	return version
+}
+
+// layerUnchanged returns whether layer i of the trainer has the same neurons
+// and parameters as layer i of the last snapshot
+func (l *LiveNet) layerUnchanged(i int) bool {
+	neurons, parameters := l.trainer.neurons[i], l.trainer.parameters[i]
+	if len(neurons) != len(l.neurons[i]) {
+		return false
+	}
+	for j, neuron := range neurons {
+		if !reflect.DeepEqual(neuron, l.neurons[i][j]) {
+			return false
+		}
+	}
+	for j, p := range parameters {
+		q := l.parameters[i][j]
+		if len(p) != len(q) {
+			return false
+		}
+		for k, v := range p {
+			if v != q[k] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Snapshot returns the snapshot currently serving the predictions and its
+// version
+func (l *LiveNet) Snapshot() (*FrozenNet, int) {
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+	return l.snapshot, l.version
+}
+
+// InputDim returns the number of inputs expected by the current snapshot
+func (l *LiveNet) InputDim() int {
+	f, _ := l.Snapshot()
+	return f.InputDim()
+}
+
+// OutputDim returns the number of outputs of the current snapshot
+func (l *LiveNet) OutputDim() int {
+	f, _ := l.Snapshot()
+	return f.OutputDim()
+}
+
+// Predict predicts the output at the input with the current snapshot
+func (l *LiveNet) Predict(input, output []float64) ([]float64, error) {
+	f, _ := l.Snapshot()
+	return f.Predict(input, output)
+}
+
+// PredictBatch predicts the output at every row of inputs with the current
+// snapshot. All of the rows are predicted with the same snapshot.
+func (l *LiveNet) PredictBatch(inputs RowMatrix, outputs MutableRowMatrix) (MutableRowMatrix, error) {
+	f, _ := l.Snapshot()
+	return f.PredictBatch(inputs, outputs)
+}
+
+// PredictBatchCtx is like PredictBatch but honors the cancellation and deadline
+// of the context
+func (l *LiveNet) PredictBatchCtx(ctx context.Context, inputs RowMatrix, outputs MutableRowMatrix) (MutableRowMatrix, error) {
+	f, _ := l.Snapshot()
+	return f.PredictBatchCtx(ctx, inputs, outputs)
+}
@@ -0,0 +1,125 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestLiveNet(t *testing.T) {
+	s, err := NewSimpleTrainer(3, 2, 2, 5, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetRand(rand.New(rand.NewSource(1)))
+	s.RandomizeParameters()
+	s.SetOptimizer(&SGD{Rate: 0.01})
+	s.SetLayerTrainable(0, false)
+	s.SetLayerTrainable(1, false)
+
+	l := NewLiveNet(s)
+	first, version := l.Snapshot()
+	if version != 1 || l.InputDim() != 3 || l.OutputDim() != 2 {
+		t.Errorf("Wrong first snapshot")
+	}
+	inputs := RandomMat(20, 3, rand.NormFloat64)
+	targets := RandomMat(20, 2, rand.NormFloat64)
+	want, _ := s.PredictBatch(inputs, nil)
+
+	// Training does not change the served predictions until they are published
+	if _, err := s.PartialFit(inputs, targets); err != nil {
+		t.Fatal(err)
+	}
+	got, err := l.PredictBatch(inputs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sosEqual(got.(SosMatrix), want.(SosMatrix)) {
+		t.Errorf("Unpublished training changed the predictions")
+	}
+	if l.Publish() != 2 {
+		t.Errorf("Wrong version")
+	}
+	want, _ = s.PredictBatch(inputs, nil)
+	got, _ = l.PredictBatch(inputs, nil)
+	if !sosEqual(got.(SosMatrix), want.(SosMatrix)) {
+		t.Errorf("Published predictions differ from the trainer")
+	}
+	out, err := l.Predict(inputs.RowView(0), nil)
+	if err != nil || !floatsEqual(out, want.(SosMatrix)[0]) {
+		t.Errorf("Wrong prediction")
+	}
+
+	// The frozen layers are shared with the previous snapshot, and the trained
+	// layer is copied
+	second, _ := l.Snapshot()
+	for i := 0; i < 2; i++ {
+		if second.layers[i] != first.layers[i] {
+			t.Errorf("Unchanged layer %v not shared", i)
+		}
+	}
+	if second.layers[2] == first.layers[2] {
+		t.Errorf("Trained layer shared")
+	}
+	old, _ := first.PredictBatch(inputs, nil)
+	if sosEqual(old.(SosMatrix), want.(SosMatrix)) {
+		t.Errorf("Old snapshot modified")
+	}
+
+	// Modifying the parameters directly is also picked up
+	params := s.Parameters(nil)
+	params[0] += 1
+	s.SetParameters(params)
+	l.Publish()
+	third, _ := l.Snapshot()
+	if third.layers[0] == second.layers[0] || third.layers[1] != second.layers[1] {
+		t.Errorf("Wrong layers shared after setting the parameters")
+	}
+}
+
+func TestLiveNetConcurrent(t *testing.T) {
+	s, err := NewSimpleTrainer(3, 2, 1, 5, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetRand(rand.New(rand.NewSource(2)))
+	s.RandomizeParameters()
+	inputs := RandomMat(50, 3, rand.NormFloat64)
+	targets := RandomMat(50, 2, rand.NormFloat64)
+	l := NewLiveNet(s)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				f, _ := l.Snapshot()
+				want, _ := f.PredictBatch(inputs, nil)
+				got, err := f.PredictBatch(inputs, nil)
+				if err != nil || !sosEqual(got.(SosMatrix), want.(SosMatrix)) {
+					t.Errorf("Snapshot changed during prediction")
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		if _, err := s.TrainEpoch(inputs, targets, 10, nil); err != nil {
+			t.Fatal(err)
+		}
+		l.Publish()
+	}
+	close(done)
+	wg.Wait()
+}
@@ -0,0 +1,228 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import "math"
+
+// Losser is a loss function used for training. The loss of a mini-batch is the
+// mean of the losses of its samples.
+type Losser interface {
+	// LossDeriv returns the loss of the prediction given the true value, and
+	// stores the derivative of the loss with respect to each element of the
+	// prediction in dLossDPred.
+	LossDeriv(prediction, truth, dLossDPred []float64) float64
+}
+
+// A TargetDimer is a Losser whose true values have a different number of
+// elements than the prediction, for example because the prediction contains
+// both an estimate and its uncertainty. Trainers using such a loss expect
+// targets with TargetDim columns. Losses which are not TargetDimers expect
+// targets with the output dimension of the net.
+type TargetDimer interface {
+	// TargetDim returns the number of elements of the truth given the number
+	// of outputs of the net
+	TargetDim(outputDim int) int
+}
+
+// A TargetChecker is a Losser which only accepts some true values, for example
+// category indices. Trainers check the targets before training on them, so
+// invalid targets are reported as an error instead of a panic in LossDeriv.
+type TargetChecker interface {
+	// CheckTargets returns an error if the rows of targets are not valid true
+	// values for a net with outputDim outputs
+	CheckTargets(outputDim int, targets RowMatrix) error
+}
+
+// SquaredDistance is the mean over the outputs of the squared difference
+// between the prediction and the truth. If Weights is not nil, the squared
+// difference of output i is multiplied by Weights[i], so that some outputs
+// can be prioritized over others. Weights must then have one element per
+// output.
+type SquaredDistance struct {
+	Weights []float64
+}
+
+func (s SquaredDistance) LossDeriv(prediction, truth, dLossDPred []float64) float64 {
+	if s.Weights != nil && len(s.Weights) != len(prediction) {
+		panic(dimensionError("squared distance", ErrOutputDim, len(prediction), len(s.Weights)))
+	}
+	var loss float64
+	n := float64(len(prediction))
+	for i, p := range prediction {
+		diff := p - truth[i]
+		w := 1.0
+		if s.Weights != nil {
+			w = s.Weights[i]
+		}
+		loss += w * diff * diff
+		dLossDPred[i] = 2 * w * diff / n
+	}
+	return loss / n
+}
+
+func (SquaredDistance) String() string {
+	return "SquaredDistance"
+}
+
+// CrossEntropy is the cross-entropy loss for classification, where the
+// predictions are logits, that is, the outputs of a net with a Linear output
+// layer. With more than one output the probabilities are the softmax of the
+// logits and the truth is a distribution over the classes, typically one-hot.
+// With a single output the probability of the positive class is the sigmoid of
+// the logit and the truth is in [0, 1].
+//
+// Smoothing, in [0, 1), mixes the truth with the uniform distribution over
+// the classes as (1 - Smoothing) * truth + Smoothing / k, where k is the
+// number of classes (two for a single output). This discourages the net from
+// becoming overconfident.
+type CrossEntropy struct {
+	Smoothing float64
+}
+
+func (c CrossEntropy) LossDeriv(prediction, truth, dLossDPred []float64) float64 {
+	if len(prediction) == 1 {
+		t := (1-c.Smoothing)*truth[0] + c.Smoothing/2
+		z := prediction[0]
+		// log(sigmoid(z)) = -softplus(-z) and log(1-sigmoid(z)) = -softplus(z)
+		dLossDPred[0] = Sigmoid{}.Activate(z) - t
+		return t*softplus(-z) + (1-t)*softplus(z)
+	}
+	max := prediction[0]
+	for _, z := range prediction {
+		if z > max {
+			max = z
+		}
+	}
+	var sum float64
+	for _, z := range prediction {
+		sum += math.Exp(z - max)
+	}
+	logSum := max + math.Log(sum)
+	uniform := c.Smoothing / float64(len(prediction))
+	var totalTruth, loss float64
+	for i, z := range prediction {
+		t := (1-c.Smoothing)*truth[i] + uniform
+		totalTruth += t
+		loss -= t * (z - logSum)
+	}
+	for i, z := range prediction {
+		t := (1-c.Smoothing)*truth[i] + uniform
+		dLossDPred[i] = math.Exp(z-logSum)*totalTruth - t
+	}
+	return loss
+}
+
+func (CrossEntropy) String() string {
+	return "CrossEntropy"
+}
+
+// softplus returns log(1 + exp(x)) without overflow
+func softplus(x float64) float64 {
+	return math.Max(x, 0) + math.Log1p(math.Exp(-math.Abs(x)))
+}
+
+// Focal is the focal loss for classification with heavily imbalanced classes,
+// which down-weights the loss of well classified samples by a factor of
+// (1 - p)^Gamma, where p is the predicted probability of the true class. The
+// predictions are logits and the truth is as for CrossEntropy, which is the
+// special case Gamma = 0.
+//
+// With a single output, the loss of the positive class is weighted by Alpha
+// and that of the negative class by 1 - Alpha. With several outputs, the loss
+// is scaled by Alpha. If Alpha is zero no weighting is applied.
+type Focal struct {
+	Gamma float64
+	Alpha float64
+}
+
+func (f Focal) LossDeriv(prediction, truth, dLossDPred []float64) float64 {
+	if len(prediction) == 1 {
+		aPos, aNeg := 1.0, 1.0
+		if f.Alpha != 0 {
+			aPos, aNeg = f.Alpha, 1-f.Alpha
+		}
+		z, t := prediction[0], truth[0]
+		p := Sigmoid{}.Activate(z)
+		logP, logQ := -softplus(-z), -softplus(z)
+		wPos := aPos * t * math.Pow(1-p, f.Gamma)
+		wNeg := aNeg * (1 - t) * math.Pow(p, f.Gamma)
+		dLossDPred[0] = -wPos*((1-p)-f.Gamma*p*logP) - wNeg*(f.Gamma*(1-p)*logQ-p)
+		return -wPos*logP - wNeg*logQ
+	}
+	scale := 1.0
+	if f.Alpha != 0 {
+		scale = f.Alpha
+	}
+	max := prediction[0]
+	for _, z := range prediction {
+		if z > max {
+			max = z
+		}
+	}
+	var sum float64
+	for _, z := range prediction {
+		sum += math.Exp(z - max)
+	}
+	logSum := max + math.Log(sum)
+	// dLossDPred temporarily holds the derivative with respect to each
+	// probability times the probability
+	var loss, total float64
+	for i, z := range prediction {
+		logP := z - logSum
+		q := 1 - math.Exp(logP)
+		w := scale * truth[i] * math.Pow(q, f.Gamma)
+		loss -= w * logP
+		var g float64
+		if q > 0 {
+			g = -w * (1 - f.Gamma*(1-q)*logP/q)
+		}
+		dLossDPred[i] = g
+		total += g
+	}
+	for i, z := range prediction {
+		dLossDPred[i] -= math.Exp(z-logSum) * total
+	}
+	return loss
+}
+
+func (Focal) String() string {
+	return "Focal"
+}
+
+// LossTask applies a loss to the range [Start, End) of the output vector
+type LossTask struct {
+	Start, End int
+	Loss       Losser
+	Weight     float64 // If zero, 1 is used
+}
+
+// CompositeLoss is the weighted sum of losses applied to different ranges of
+// the output vector, for training nets with several tasks such as the heads
+// of a MultiHeadTrainer. Outputs not covered by any of the tasks do not
+// contribute to the loss.
+type CompositeLoss []LossTask
+
+func (c CompositeLoss) LossDeriv(prediction, truth, dLossDPred []float64) float64 {
+	for i := range dLossDPred {
+		dLossDPred[i] = 0
+	}
+	var loss float64
+	for _, task := range c {
+		w := task.Weight
+		if w == 0 {
+			w = 1
+		}
+		d := dLossDPred[task.Start:task.End]
+		loss += w * task.Loss.LossDeriv(prediction[task.Start:task.End], truth[task.Start:task.End], d)
+		for i := range d {
+			d[i] *= w
+		}
+	}
+	return loss
+}
+
+func (CompositeLoss) String() string {
+	return "CompositeLoss"
+}
@@ -0,0 +1,110 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import "errors"
+
+// LotteryTicket searches for a sparse subnetwork which trains as well as the
+// full net (a "winning ticket") by iterative magnitude pruning. Every round
+// trains the net, records its validation score, prunes a fraction of the
+// remaining weights with the smallest trained magnitude (see
+// Trainer.PruneMagnitude), and rewinds the weights which are left to their
+// initial values before the next round.
+type LotteryTicket struct {
+	Rounds        int     // Number of pruning rounds. Must be positive
+	PruneFraction float64 // Fraction of the remaining weights pruned per round. If zero, 0.2 is used
+	Epochs        int     // Number of epochs of training per round. If zero, 10 is used
+	BatchSize     int     // Number of samples per mini-batch. If zero, 32 is used
+
+	// Metric scores the predictions on the validation data. If nil,
+	// MeanSquaredError is used.
+	Metric Metric
+
+	// NewOptimizer returns the optimizer for every round, so that optimizer
+	// state such as momentum is not carried across the rewind. If nil, the
+	// optimizer of the trainer is used throughout.
+	NewOptimizer func() Optimizer
+}
+
+// LotteryRound is the result of a round of LotteryTicket
+type LotteryRound struct {
+	Sparsity  float64 // Fraction of the prunable weights pruned while training
+	TrainLoss float64 // Mean training loss of the last epoch of the round
+	Score     float64 // Metric on the validation data after training
+}
+
+// Run performs the search on the trainer, starting from its current
+// parameters and prune mask, which are the initial weights the rounds rewind
+// to. The rewind also resets the step and epoch counts, so learning rate
+// schedules restart. The net is trained Rounds+1 times: once before the first
+// pruning and once after every pruning, and Run returns the result of each
+// training in order, tracking the score against the sparsity. Afterwards the
+// trainer holds the sparsest net, trained, with its prune mask set.
+func (l LotteryTicket) Run(s *Trainer, inputs, targets, validInputs, validTargets RowMatrix) ([]LotteryRound, error) {
+	if l.Rounds <= 0 {
+		return nil, errors.New("lottery ticket: non-positive number of rounds")
+	}
+	fraction := l.PruneFraction
+	if fraction == 0 {
+		fraction = 0.2
+	}
+	if fraction < 0 || fraction >= 1 {
+		return nil, errors.New("lottery ticket: prune fraction not in [0, 1)")
+	}
+	epochs := l.Epochs
+	if epochs == 0 {
+		epochs = 10
+	}
+	batchSize := l.BatchSize
+	if batchSize == 0 {
+		batchSize = 32
+	}
+	metric := l.Metric
+	if metric == nil {
+		metric = MeanSquaredError
+	}
+	if err := s.checkTargets("lottery ticket", inputs, targets); err != nil {
+		return nil, err
+	}
+	if err := s.checkTargets("lottery ticket", validInputs, validTargets); err != nil {
+		return nil, err
+	}
+
+	initial := s.Parameters(nil)
+	steps, epochsDone := s.steps, s.epochs
+	weights := s.prunableWeights()
+	rounds := make([]LotteryRound, 0, l.Rounds+1)
+	for round := 0; ; round++ {
+		if l.NewOptimizer != nil {
+			s.SetOptimizer(l.NewOptimizer())
+		}
+		var loss float64
+		for e := 0; e < epochs; e++ {
+			var err error
+			loss, err = s.TrainEpoch(inputs, targets, batchSize, nil)
+			if err != nil {
+				return nil, err
+			}
+		}
+		predictions, err := s.PredictBatch(validInputs, nil)
+		if err != nil {
+			return nil, err
+		}
+		rounds = append(rounds, LotteryRound{
+			Sparsity:  s.sparsity(weights),
+			TrainLoss: loss,
+			Score:     metric(predictions, validTargets),
+		})
+		if round == l.Rounds {
+			return rounds, nil
+		}
+		if _, err := s.PruneMagnitude(fraction); err != nil {
+			return nil, err
+		}
+		s.SetParameters(initial)
+		s.applyPruneMask()
+		s.steps, s.epochs = steps, epochsDone
+	}
+}
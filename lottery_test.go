@@ -0,0 +1,74 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestLotteryTicket(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	inputs, targets := Friedman1(300, 0, rnd)
+	validInputs, validTargets := Friedman1(100, 0, rnd)
+	s, err := NewSimpleTrainer(10, 1, 1, 20, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetRand(rnd)
+	s.RandomizeParameters()
+	initial := s.Parameters(nil)
+
+	l := LotteryTicket{
+		Rounds:        3,
+		PruneFraction: 0.5,
+		Epochs:        50,
+		BatchSize:     20,
+		NewOptimizer:  func() Optimizer { return &Adam{Rate: 0.02} },
+	}
+	rounds, err := l.Run(s, inputs, targets, validInputs, validTargets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rounds) != 4 {
+		t.Fatalf("Wrong number of rounds %v", len(rounds))
+	}
+	for i, r := range rounds {
+		if want := 1 - math.Pow(0.5, float64(i)); math.Abs(r.Sparsity-want) > 0.01 {
+			t.Errorf("Round %v: wrong sparsity. Expected %v, found %v", i, want, r.Sparsity)
+		}
+		if r.Score > rounds[0].Score*2 {
+			t.Errorf("Round %v: score %v much worse than dense %v", i, r.Score, rounds[0].Score)
+		}
+	}
+	if s.epochs != 50 {
+		t.Errorf("Epochs not rewound, found %v", s.epochs)
+	}
+
+	// The kept weights were trained from their initial values and the pruned
+	// weights are zero
+	mask := s.PruneMask()
+	params := s.Parameters(nil)
+	var moved int
+	for i, pruned := range mask {
+		if pruned && params[i] != 0 {
+			t.Errorf("Pruned parameter %v is not zero", i)
+		}
+		if !pruned && params[i] != initial[i] {
+			moved++
+		}
+	}
+	if moved == 0 {
+		t.Errorf("Last round not trained")
+	}
+
+	if _, err := (LotteryTicket{}).Run(s, inputs, targets, validInputs, validTargets); err == nil {
+		t.Errorf("Expected rounds error")
+	}
+	if _, err := l.Run(s, inputs, targets, validInputs, validTargets[:3]); err == nil {
+		t.Errorf("Expected rows error")
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"errors"
+	"math"
+)
+
+// LRFinder finds a good starting learning rate with a learning rate range
+// test. Starting from a copy of the trainer, it takes one optimizer step per
+// mini-batch while increasing the learning rate exponentially from MinRate to
+// MaxRate, and records the loss at each rate. The test stops early once the
+// loss diverges.
+type LRFinder struct {
+	MinRate   float64 // If zero, 1e-7 is used
+	MaxRate   float64 // If zero, 10 is used
+	Steps     int     // Number of mini-batches. If zero, 200 is used
+	BatchSize int     // Number of samples per mini-batch. If zero, 32 is used
+
+	// Smoothing is the factor of the exponential moving average of the loss,
+	// in [0, 1). If zero, 0.98 is used.
+	Smoothing float64
+
+	// Optimizer is the optimizer used for the test. Its learning rate is set
+	// at every step. If nil, SGD without momentum is used.
+	Optimizer Optimizer
+}
+
+// LRFinderResult is the result of a learning rate range test
+type LRFinderResult struct {
+	Rates  []float64 // The learning rate at every step
+	Losses []float64 // The smoothed loss at every step
+
+	// Suggested is the learning rate where the smoothed loss decreases the
+	// fastest with respect to the logarithm of the rate, before the loss
+	// reaches its minimum
+	Suggested float64
+}
+
+// Find runs the range test on a copy of the trainer (see Trainer.Clone), so
+// the trainer itself is not modified apart from seeding the copy's source of
+// randomness. The mini-batches are drawn from inputs and targets in a random
+// order using the copy's source of randomness, cycling through the data as
+// many times as needed.
+func (f LRFinder) Find(s *Trainer, inputs, targets RowMatrix) (LRFinderResult, error) {
+	minRate, maxRate := f.MinRate, f.MaxRate
+	if minRate == 0 {
+		minRate = 1e-7
+	}
+	if maxRate == 0 {
+		maxRate = 10
+	}
+	if minRate <= 0 || maxRate <= minRate {
+		return LRFinderResult{}, errors.New("lr finder: need 0 < MinRate < MaxRate")
+	}
+	steps := f.Steps
+	if steps == 0 {
+		steps = 200
+	}
+	batchSize := f.BatchSize
+	if batchSize == 0 {
+		batchSize = 32
+	}
+	beta := f.Smoothing
+	if beta == 0 {
+		beta = 0.98
+	}
+	if err := s.checkTargets("lr finder", inputs, targets); err != nil {
+		return LRFinderResult{}, err
+	}
+	nSamples, _ := inputs.Dims()
+	if batchSize > nSamples {
+		batchSize = nSamples
+	}
+
+	// The rate is swept by the finder, so a schedule of the trainer must not
+	// set it
+	trainer := s.Clone()
+	trainer.SetSchedule(nil)
+	opt := f.Optimizer
+	if opt == nil {
+		opt = &SGD{}
+	}
+	trainer.SetOptimizer(opt)
+	rnd := trainer.rand()
+
+	var result LRFinderResult
+	ratio := math.Pow(maxRate/minRate, 1/math.Max(float64(steps-1), 1))
+	rate := minRate
+	var perm []int
+	var avg, best float64
+	for i := 0; i < steps; i++ {
+		if len(perm) < batchSize {
+			perm = rnd.Perm(nSamples)
+		}
+		rows := perm[:batchSize]
+		perm = perm[batchSize:]
+
+		opt.SetLearningRate(rate)
+		loss, err := trainer.PartialFit(SelectRows(inputs, rows), SelectRows(targets, rows))
+		if err != nil {
+			return result, err
+		}
+		// Bias-corrected exponential moving average
+		avg = beta*avg + (1-beta)*loss
+		smoothed := avg / (1 - math.Pow(beta, float64(i+1)))
+		if i == 0 || smoothed < best {
+			best = smoothed
+		}
+		if math.IsNaN(smoothed) || math.IsInf(smoothed, 0) {
+			break
+		}
+		result.Rates = append(result.Rates, rate)
+		result.Losses = append(result.Losses, smoothed)
+		if smoothed > 4*best {
+			// The loss has diverged
+			break
+		}
+		rate *= ratio
+	}
+	result.Suggested = suggestRate(result.Rates, result.Losses)
+	return result, nil
+}
+
+// suggestRate returns the rate with the steepest decrease in loss before the
+// minimum of the loss. The slopes are computed over a window of several steps
+// to reduce the effect of the noise in the losses, and the first steps, where
+// the moving average is still settling, are skipped.
+func suggestRate(rates, losses []float64) float64 {
+	if len(rates) == 0 {
+		return 0
+	}
+	end := 0
+	for i, l := range losses {
+		if l < losses[end] {
+			end = i
+		}
+	}
+	w := len(rates) / 20
+	if w < 1 {
+		w = 1
+	}
+	start := len(rates)/10 + w
+	if start >= end-w {
+		return rates[end]
+	}
+	best := start
+	bestSlope := math.Inf(1)
+	for i := start; i < end-w; i++ {
+		slope := (losses[i+w] - losses[i-w]) / (math.Log(rates[i+w]) - math.Log(rates[i-w]))
+		if slope < bestSlope {
+			bestSlope = slope
+			best = i
+		}
+	}
+	return rates[best]
+}
@@ -0,0 +1,83 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestLRFinder(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	inputs, targets := NoisySine(500, 0.05, rnd)
+	trainer, err := NewSimpleTrainer(1, 1, 1, 10, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trainer.SetRand(rnd)
+	trainer.RandomizeParameters()
+	before := trainer.Parameters(nil)
+
+	result, err := LRFinder{MinRate: 1e-5, MaxRate: 100, Steps: 300}.Find(trainer, inputs, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !floatsEqual(before, trainer.Parameters(nil)) {
+		t.Errorf("Trainer modified by the range test")
+	}
+	if trainer.Steps() != 0 {
+		t.Errorf("Steps taken by the trainer")
+	}
+	if len(result.Rates) == 0 || len(result.Rates) != len(result.Losses) {
+		t.Fatalf("Wrong result lengths %v and %v", len(result.Rates), len(result.Losses))
+	}
+	if len(result.Rates) == 300 {
+		t.Errorf("Loss did not diverge at large learning rates")
+	}
+	for i := 1; i < len(result.Rates); i++ {
+		if result.Rates[i] <= result.Rates[i-1] {
+			t.Fatalf("Rates not increasing")
+		}
+	}
+	if math.Abs(result.Rates[0]-1e-5) > 1e-15 {
+		t.Errorf("Wrong first rate %v", result.Rates[0])
+	}
+	// The suggestion should be a usable rate for this problem
+	if result.Suggested < 1e-3 || result.Suggested > 10 {
+		t.Errorf("Unreasonable suggested rate %v", result.Suggested)
+	}
+
+	if _, err := (LRFinder{MinRate: 1, MaxRate: 0.1}).Find(trainer, inputs, targets); err == nil {
+		t.Errorf("Expected error for MinRate > MaxRate")
+	}
+	if _, err := (LRFinder{}).Find(trainer, inputs, targets[:10]); !errors.Is(err, ErrRows) {
+		t.Errorf("Expected rows error, found %v", err)
+	}
+
+	// The batches are drawn from the copy's source of randomness, which takes
+	// a single seed from the trainer's
+	trainer.SetRand(rand.New(rand.NewSource(2)))
+	if _, err := (LRFinder{Steps: 20}).Find(trainer, inputs, targets); err != nil {
+		t.Fatal(err)
+	}
+	want := rand.New(rand.NewSource(2))
+	want.Int63()
+	if trainer.rand().Int63() != want.Int63() {
+		t.Errorf("Batches drawn from the trainer's source of randomness")
+	}
+
+	// The swept rates are used even if the trainer has a schedule
+	trainer.SetSchedule(LinearDecay{Start: 0.01, Steps: 100})
+	opt := &recordingOptimizer{}
+	result, err = LRFinder{MinRate: 1e-4, MaxRate: 1e-2, Steps: 5, Optimizer: opt}.Find(trainer, inputs, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !floatsEqual(opt.rates, result.Rates) {
+		t.Errorf("Steps taken at the wrong rates. Expected %v, found %v", result.Rates, opt.rates)
+	}
+}
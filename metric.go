@@ -0,0 +1,38 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+// Metric is a scalar measure of the error of a set of predictions with
+// respect to the true values. Smaller values are better.
+type Metric func(predictions, truth RowMatrix) float64
+
+// MeanSquaredError is the squared error averaged over all of the samples and outputs
+func MeanSquaredError(predictions, truth RowMatrix) float64 {
+	r, c := predictions.Dims()
+	var sum float64
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			diff := predictions.At(i, j) - truth.At(i, j)
+			sum += diff * diff
+		}
+	}
+	return sum / float64(r*c)
+}
+
+// MeanAbsoluteError is the absolute error averaged over all of the samples and outputs
+func MeanAbsoluteError(predictions, truth RowMatrix) float64 {
+	r, c := predictions.Dims()
+	var sum float64
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			diff := predictions.At(i, j) - truth.At(i, j)
+			if diff < 0 {
+				diff = -diff
+			}
+			sum += diff
+		}
+	}
+	return sum / float64(r*c)
+}
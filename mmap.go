@@ -0,0 +1,144 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"unsafe"
+)
+
+// MmapMatrix is a matrix backed by a memory-mapped file of float64 values, so
+// data sets larger than memory can be used without reading them in, and the
+// pages of the file are shared with the operating system's file cache instead
+// of being copied. The file contains the values of the matrix in row-major
+// order in the native byte order of the machine, with no header, as written by
+// WriteFloat64s.
+//
+// MmapMatrix implements RowMatrix and RowViewer, and MutableRowMatrix if it was
+// opened for writing. Using the matrix after Close panics.
+type MmapMatrix struct {
+	raw      []byte
+	data     []float64
+	rows     int
+	cols     int
+	writable bool
+}
+
+// OpenMmapMatrix memory-maps the named file as a matrix with the given number
+// of columns. The size of the file must be a multiple of the size of a row. If
+// writable is true, changes to the matrix are written to the file.
+func OpenMmapMatrix(name string, cols int, writable bool) (*MmapMatrix, error) {
+	if cols <= 0 {
+		return nil, errors.New("mmap: non-positive number of columns")
+	}
+	flag := os.O_RDONLY
+	if writable {
+		flag = os.O_RDWR
+	}
+	f, err := os.OpenFile(name, flag, 0)
+	if err != nil {
+		return nil, err
+	}
+	// The mapping stays valid after the file is closed
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := fi.Size()
+	rowSize := int64(cols) * 8
+	if size == 0 || size%rowSize != 0 {
+		return nil, errors.New("mmap: file size " + strconv.FormatInt(size, 10) + " is not a positive multiple of the row size " + strconv.FormatInt(rowSize, 10))
+	}
+	raw, err := mmap(f, int(size), writable)
+	if err != nil {
+		return nil, err
+	}
+	return &MmapMatrix{
+		raw:      raw,
+		data:     unsafe.Slice((*float64)(unsafe.Pointer(&raw[0])), len(raw)/8),
+		rows:     int(size / rowSize),
+		cols:     cols,
+		writable: writable,
+	}, nil
+}
+
+// Close unmaps the file
+func (m *MmapMatrix) Close() error {
+	if m.raw == nil {
+		return nil
+	}
+	err := munmap(m.raw)
+	m.raw = nil
+	m.data = nil
+	return err
+}
+
+func (m *MmapMatrix) Dims() (r, c int) {
+	return m.rows, m.cols
+}
+
+func (m *MmapMatrix) At(i, j int) float64 {
+	return m.data[i*m.cols+j]
+}
+
+// Set sets the value of element (i, j). Set panics if the matrix is read-only.
+func (m *MmapMatrix) Set(i, j int, v float64) {
+	m.checkWritable()
+	m.data[i*m.cols+j] = v
+}
+
+// RowView returns a view of row r backed by the file. The view must not be
+// modified if the matrix is read-only.
+func (m *MmapMatrix) RowView(r int) []float64 {
+	return m.data[r*m.cols : (r+1)*m.cols : (r+1)*m.cols]
+}
+
+func (m *MmapMatrix) Row(d []float64, i int) []float64 {
+	if len(d) < m.cols {
+		d = make([]float64, m.cols)
+	} else {
+		d = d[:m.cols]
+	}
+	copy(d, m.RowView(i))
+	return d
+}
+
+// SetRow sets row i to d. SetRow panics if the matrix is read-only.
+func (m *MmapMatrix) SetRow(i int, d []float64) int {
+	m.checkWritable()
+	return copy(m.RowView(i), d)
+}
+
+func (m *MmapMatrix) checkWritable() {
+	if !m.writable {
+		panic("nnet: write to read-only mmap matrix")
+	}
+}
+
+// WriteFloat64s writes the values of the matrix to w in the format read by
+// OpenMmapMatrix
+func WriteFloat64s(w io.Writer, m RowMatrix) error {
+	r, c := m.Dims()
+	bw := bufio.NewWriter(w)
+	row := make([]float64, c)
+	var buf [8]byte
+	for i := 0; i < r; i++ {
+		row = m.Row(row, i)
+		for _, v := range row {
+			binary.NativeEndian.PutUint64(buf[:], math.Float64bits(v))
+			if _, err := bw.Write(buf[:]); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,20 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//go:build !unix
+
+package nnet
+
+import (
+	"errors"
+	"os"
+)
+
+func mmap(f *os.File, size int, writable bool) ([]byte, error) {
+	return nil, errors.New("mmap: not supported on this platform")
+}
+
+func munmap(b []byte) error {
+	return nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//go:build unix
+
+package nnet
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMmapMatrix(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	data := RandomMat(20, 3, rnd.NormFloat64)
+	name := filepath.Join(t.TempDir(), "data.f64")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFloat64s(f, data); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if _, err := OpenMmapMatrix(name, 7, false); err == nil {
+		t.Errorf("Expected error for a file size which is not a multiple of the row size")
+	}
+
+	m, err := OpenMmapMatrix(name, 3, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, c := m.Dims(); r != 20 || c != 3 {
+		t.Fatalf("Wrong dimensions. Expected 20×3, found %v×%v", r, c)
+	}
+	row := make([]float64, 3)
+	for i := range data {
+		if !floatsEqual(m.Row(row, i), data[i]) || !floatsEqual(m.RowView(i), data[i]) {
+			t.Errorf("Wrong row %v. Expected %v, found %v", i, data[i], m.RowView(i))
+		}
+	}
+
+	// Predictions from the mapped file match predictions from memory
+	trainer, err := NewSimpleTrainer(3, 2, 1, 4, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trainer.SetRand(rnd)
+	trainer.RandomizeParameters()
+	want, err := trainer.PredictBatch(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := trainer.PredictBatch(m, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range data {
+		if !floatsEqual(got.(SosMatrix)[i], want.(SosMatrix)[i]) {
+			t.Errorf("Wrong prediction for row %v", i)
+		}
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected panic writing to a read-only matrix")
+			}
+		}()
+		m.Set(0, 0, 1)
+	}()
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Writes to a writable matrix are stored in the file
+	w, err := OpenMmapMatrix(name, 3, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetRow(5, []float64{1, 2, 3})
+	w.Set(6, 1, 4)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	m, err = OpenMmapMatrix(name, 3, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if !floatsEqual(m.RowView(5), []float64{1, 2, 3}) || m.At(6, 1) != 4 {
+		t.Errorf("Writes not stored in the file")
+	}
+}
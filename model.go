@@ -0,0 +1,168 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// jsonNet is the JSON form of a net. A layer of SumNeurons with a common
+// activator is stored as the name of the activator, a weight matrix with one row
+// per neuron, and a bias vector. Other layers store the neurons by their
+// descriptions (see RegisterNeuron) and the parameters per neuron. Nets written
+// before the weight matrix form was added store every layer per neuron, and
+// are still decoded.
+type jsonNet struct {
+	InputDim  int         `json:"inputDim"`
+	OutputDim int         `json:"outputDim"`
+	Layers    []jsonLayer `json:"layers"`
+}
+
+type jsonLayer struct {
+	Neurons    []string    `json:"neurons,omitempty"`
+	Parameters [][]float64 `json:"parameters,omitempty"`
+
+	Activator string      `json:"activator,omitempty"`
+	Weights   [][]float64 `json:"weights,omitempty"`
+	Bias      []float64   `json:"bias,omitempty"`
+}
+
+// MarshalJSON encodes the structure and parameters of the net as JSON. All of
+// the neurons and activators of the net must be registered (see
+// RegisterNeuron and RegisterActivator) so that the net can be decoded.
+func (n *Net) MarshalJSON() ([]byte, error) {
+	j := jsonNet{
+		InputDim:  n.inputDim,
+		OutputDim: n.outputDim,
+		Layers:    make([]jsonLayer, len(n.neurons)),
+	}
+	for i, layer := range n.neurons {
+		if l, ok := n.layers[i].(*sumLayer); ok {
+			jl, err := l.marshalLayer()
+			if err != nil {
+				return nil, err
+			}
+			j.Layers[i] = jl
+			continue
+		}
+		names := make([]string, len(layer))
+		for k, neuron := range layer {
+			names[k] = describeNeuron(neuron)
+			if _, err := NeuronByName(names[k]); err != nil {
+				return nil, err
+			}
+		}
+		j.Layers[i] = jsonLayer{Neurons: names, Parameters: n.parameters[i]}
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes a net encoded by MarshalJSON, replacing the receiver.
+// The neurons are constructed from the registered neurons and activators.
+func (n *Net) UnmarshalJSON(data []byte) error {
+	var j jsonNet
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if j.InputDim <= 0 || j.OutputDim <= 0 {
+		return errors.New("net: non-positive dimension")
+	}
+	neurons := make([][]Neuron, len(j.Layers))
+	for i := range j.Layers {
+		layer := &j.Layers[i]
+		if layer.Activator != "" {
+			if err := layer.unmarshalSumLayer(i); err != nil {
+				return err
+			}
+		}
+		neurons[i] = make([]Neuron, len(layer.Neurons))
+		for k, name := range layer.Neurons {
+			neuron, err := NeuronByName(name)
+			if err != nil {
+				return err
+			}
+			neurons[i][k] = neuron
+		}
+	}
+	if len(neurons) != 0 && len(neurons[len(neurons)-1]) != j.OutputDim {
+		return dimensionError("net", ErrOutputDim, j.OutputDim, len(neurons[len(neurons)-1]))
+	}
+	trainer, err := NewTrainer(j.InputDim, j.OutputDim, neurons)
+	if err != nil {
+		return err
+	}
+	net := trainer.Net
+	for i, layer := range j.Layers {
+		if len(layer.Parameters) != len(net.parameters[i]) {
+			return errors.New("net: layer " + strconv.Itoa(i) + " has parameters for " +
+				strconv.Itoa(len(layer.Parameters)) + " neurons, expected " + strconv.Itoa(len(net.parameters[i])))
+		}
+		for k, p := range layer.Parameters {
+			if len(p) != len(net.parameters[i][k]) {
+				return dimensionError("net", ErrParameterDim, len(net.parameters[i][k]), len(p))
+			}
+			copy(net.parameters[i][k], p)
+		}
+	}
+	*n = *net
+	return nil
+}
+
+// marshalLayer returns the weight matrix form of the layer
+func (l *sumLayer) marshalLayer() (jsonLayer, error) {
+	name := describeActivator(l.activator)
+	if _, err := ActivatorByName(name); err != nil {
+		return jsonLayer{}, err
+	}
+	stride := l.nInputs + 1
+	j := jsonLayer{
+		Activator: name,
+		Weights:   make([][]float64, l.nOutputs),
+		Bias:      make([]float64, l.nOutputs),
+	}
+	for i := range j.Weights {
+		row := l.params[i*stride : (i+1)*stride]
+		j.Weights[i] = row[:l.nInputs]
+		j.Bias[i] = row[l.nInputs]
+	}
+	return j, nil
+}
+
+// unmarshalSumLayer converts the weight matrix form of layer i into neuron
+// descriptions and parameters per neuron
+func (j *jsonLayer) unmarshalSumLayer(i int) error {
+	if len(j.Neurons) != 0 || len(j.Parameters) != 0 {
+		return errors.New("net: layer " + strconv.Itoa(i) + " has both an activator and neurons")
+	}
+	if len(j.Bias) != len(j.Weights) {
+		return errors.New("net: layer " + strconv.Itoa(i) + " has " + strconv.Itoa(len(j.Bias)) +
+			" biases for " + strconv.Itoa(len(j.Weights)) + " neurons")
+	}
+	neuron := "Sum(" + j.Activator + ")"
+	j.Neurons = make([]string, len(j.Weights))
+	j.Parameters = make([][]float64, len(j.Weights))
+	for k, w := range j.Weights {
+		j.Neurons[k] = neuron
+		j.Parameters[k] = append(w[:len(w):len(w)], j.Bias[k])
+	}
+	return nil
+}
+
+// ReadNet reads a net encoded as JSON by MarshalJSON
+func ReadNet(r io.Reader) (*Net, error) {
+	n := &Net{}
+	if err := json.NewDecoder(r).Decode(n); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// WriteNet writes the net as JSON (see MarshalJSON)
+func WriteNet(w io.Writer, n *Net) error {
+	return json.NewEncoder(w).Encode(n)
+}
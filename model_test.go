@@ -0,0 +1,134 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+type unregisteredNeuron struct {
+	SumNeuron
+}
+
+func TestNetJSON(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	trainer, err := NewTrainer(3, 2, [][]Neuron{
+		{TanhNeuron, SigmoidNeuron, LinearTanhNeuron, TanhNeuron},
+		{LinearNeuron, SigmoidNeuron},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trainer.SetRand(rnd)
+	trainer.RandomizeParameters()
+
+	var buf bytes.Buffer
+	if err := WriteNet(&buf, trainer.Net); err != nil {
+		t.Fatal(err)
+	}
+	net, err := ReadNet(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !floatsEqual(net.Parameters(nil), trainer.Parameters(nil)) {
+		t.Errorf("Parameters changed by encoding")
+	}
+	for i := 0; i < trainer.NumLayers(); i++ {
+		if net.LayerDescription(i) != trainer.LayerDescription(i) {
+			t.Errorf("Layer %v changed by encoding. Expected %v, found %v", i, trainer.LayerDescription(i), net.LayerDescription(i))
+		}
+	}
+	if net.GrainSize() != trainer.GrainSize() {
+		t.Errorf("Wrong grain size %v", net.GrainSize())
+	}
+	inputs := RandomMat(10, 3, rnd.NormFloat64)
+	want, _ := trainer.PredictBatch(inputs, nil)
+	got, err := net.PredictBatch(inputs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range inputs {
+		if !floatsEqual(got.(SosMatrix)[i], want.(SosMatrix)[i]) {
+			t.Errorf("Wrong prediction %v after decoding", i)
+		}
+	}
+
+	bad, _ := NewTrainer(1, 1, [][]Neuron{{unregisteredNeuron{TanhNeuron}}})
+	if err := WriteNet(&buf, bad.Net); err == nil {
+		t.Errorf("Expected error for unregistered neuron")
+	}
+	for _, s := range []string{
+		`{"inputDim": 1, "outputDim": 1, "layers": [{"neurons": ["Sum(Foo)"], "parameters": [[1, 2]]}]}`,
+		`{"inputDim": 1, "outputDim": 2, "layers": [{"neurons": ["Sum(Tanh)"], "parameters": [[1, 2]]}]}`,
+		`{"inputDim": 1, "outputDim": 1, "layers": [{"neurons": ["Sum(Tanh)"], "parameters": []}]}`,
+		`{"inputDim": 0, "outputDim": 1, "layers": []}`,
+	} {
+		if _, err := ReadNet(strings.NewReader(s)); err == nil {
+			t.Errorf("Expected error for %v", s)
+		}
+	}
+	s := `{"inputDim": 1, "outputDim": 1, "layers": [{"neurons": ["Sum(Tanh)"], "parameters": [[1, 2, 3]]}]}`
+	if _, err := ReadNet(strings.NewReader(s)); !errors.Is(err, ErrParameterDim) {
+		t.Errorf("Expected ErrParameterDim, found %v", err)
+	}
+}
+
+func TestNetJSONWeightMatrix(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	trainer, err := NewSimpleTrainer(3, 2, 1, 4, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trainer.SetRand(rnd)
+	trainer.RandomizeParameters()
+
+	var buf bytes.Buffer
+	if err := WriteNet(&buf, trainer.Net); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"weights"`) || strings.Contains(buf.String(), `"neurons"`) {
+		t.Errorf("Expected weight matrix layers, found %v", buf.String())
+	}
+	net, err := ReadNet(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !floatsEqual(net.Parameters(nil), trainer.Parameters(nil)) {
+		t.Errorf("Parameters changed by encoding")
+	}
+
+	// Nets written per neuron decode to the same net
+	old := `{"inputDim": 2, "outputDim": 2, "layers": [{"neurons": ["Sum(Tanh)", "Sum(Tanh)"], "parameters": [[1, 2, 3], [4, 5, 6]]}]}`
+	cur := `{"inputDim": 2, "outputDim": 2, "layers": [{"activator": "Tanh", "weights": [[1, 2], [4, 5]], "bias": [3, 6]}]}`
+	oldNet, err := ReadNet(strings.NewReader(old))
+	if err != nil {
+		t.Fatal(err)
+	}
+	curNet, err := ReadNet(strings.NewReader(cur))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !floatsEqual(oldNet.Parameters(nil), curNet.Parameters(nil)) {
+		t.Errorf("Per neuron and weight matrix forms differ. Found %v and %v", oldNet.Parameters(nil), curNet.Parameters(nil))
+	}
+	if _, ok := oldNet.Layer(0).(*sumLayer); !ok {
+		t.Errorf("Per neuron layer not migrated to a weight matrix")
+	}
+
+	for _, s := range []string{
+		`{"inputDim": 2, "outputDim": 1, "layers": [{"activator": "Foo", "weights": [[1, 2]], "bias": [3]}]}`,
+		`{"inputDim": 2, "outputDim": 1, "layers": [{"activator": "Tanh", "weights": [[1, 2]], "bias": [3, 4]}]}`,
+		`{"inputDim": 2, "outputDim": 1, "layers": [{"activator": "Tanh", "weights": [[1, 2]], "bias": [3], "neurons": ["Sum(Tanh)"]}]}`,
+		`{"inputDim": 2, "outputDim": 1, "layers": [{"activator": "Tanh", "weights": [[1]], "bias": [3]}]}`,
+	} {
+		if _, err := ReadNet(strings.NewReader(s)); err == nil {
+			t.Errorf("Expected error for %v", s)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import "errors"
+
+// Head describes one output head of a multi-head net
+type Head struct {
+	Size      int       // Number of outputs of the head
+	Activator Activator // Activator of the output neurons of the head
+}
+
+// MultiHeadTrainer is a net with several output heads sharing the same hidden
+// layers. The output layer of the net is the concatenation of the heads, so
+// the output of head i is a contiguous range of the output vector.
+type MultiHeadTrainer struct {
+	*Trainer
+	heads []Head
+	start []int // start index of each head in the output vector
+}
+
+// NewMultiHeadTrainer constructs a multi-head net with nHiddenLayers shared layers
+// of tanh neurons followed by the output heads.
+func NewMultiHeadTrainer(inputDim, nHiddenLayers, nNeuronsPerLayer int, heads []Head) (*MultiHeadTrainer, error) {
+	if len(heads) == 0 {
+		return nil, errors.New("net: no heads given")
+	}
+	if nNeuronsPerLayer <= 0 {
+		return nil, errors.New("non-positive number of neurons per layer")
+	}
+	start := make([]int, len(heads))
+	var outputDim int
+	for i, h := range heads {
+		if h.Size <= 0 {
+			return nil, errors.New("net: head with no outputs")
+		}
+		start[i] = outputDim
+		outputDim += h.Size
+	}
+
+	neurons := make([][]Neuron, nHiddenLayers+1)
+	for i := 0; i < nHiddenLayers; i++ {
+		neurons[i] = make([]Neuron, nNeuronsPerLayer)
+		for j := range neurons[i] {
+			neurons[i][j] = TanhNeuron
+		}
+	}
+	for _, h := range heads {
+		for j := 0; j < h.Size; j++ {
+			neurons[nHiddenLayers] = append(neurons[nHiddenLayers], SumNeuron{Activator: h.Activator})
+		}
+	}
+	trainer, err := NewTrainer(inputDim, outputDim, neurons)
+	if err != nil {
+		return nil, err
+	}
+	h := make([]Head, len(heads))
+	copy(h, heads)
+	return &MultiHeadTrainer{
+		Trainer: trainer,
+		heads:   h,
+		start:   start,
+	}, nil
+}
+
+// NumHeads returns the number of output heads
+func (m *MultiHeadTrainer) NumHeads() int {
+	return len(m.heads)
+}
+
+// Head returns the definition of the given head
+func (m *MultiHeadTrainer) Head(i int) Head {
+	return m.heads[i]
+}
+
+// HeadRange returns the range of the output vector [start, end) which
+// contains the outputs of the given head
+func (m *MultiHeadTrainer) HeadRange(i int) (start, end int) {
+	return m.start[i], m.start[i] + m.heads[i].Size
+}
+
+// SplitHeads returns views of the output vector for each of the heads
+func (m *MultiHeadTrainer) SplitHeads(output []float64) [][]float64 {
+	views := make([][]float64, len(m.heads))
+	for i := range m.heads {
+		start, end := m.HeadRange(i)
+		views[i] = output[start:end:end]
+	}
+	return views
+}
+
+// PredictHeads predicts the outputs of all of the heads at the input. If
+// outputs is nil, new memory is allocated, otherwise outputs[i] must have
+// the size of head i.
+func (m *MultiHeadTrainer) PredictHeads(input []float64, outputs [][]float64) ([][]float64, error) {
+	if outputs == nil {
+		output, err := m.Predict(input, nil)
+		if err != nil {
+			return nil, err
+		}
+		return m.SplitHeads(output), nil
+	}
+	if len(outputs) != len(m.heads) {
+		return nil, errors.New("net: wrong number of head outputs")
+	}
+	for i, h := range m.heads {
+		if len(outputs[i]) != h.Size {
+			return nil, dimensionError("", ErrOutputDim, h.Size, len(outputs[i]))
+		}
+	}
+	output, err := m.Predict(input, nil)
+	if err != nil {
+		return nil, err
+	}
+	for i, view := range m.SplitHeads(output) {
+		copy(outputs[i], view)
+	}
+	return outputs, nil
+}
+
+// CompositeLoss returns the loss applying losses[i] to the outputs of head i,
+// weighted by weights[i]. If weights is nil all of the heads have a weight of
+// one.
+func (m *MultiHeadTrainer) CompositeLoss(losses []Losser, weights []float64) (CompositeLoss, error) {
+	if len(losses) != len(m.heads) {
+		return nil, errors.New("net: wrong number of head losses")
+	}
+	if weights != nil && len(weights) != len(m.heads) {
+		return nil, errors.New("net: wrong number of head weights")
+	}
+	c := make(CompositeLoss, len(m.heads))
+	for i, loss := range losses {
+		start, end := m.HeadRange(i)
+		c[i] = LossTask{Start: start, End: end, Loss: loss, Weight: 1}
+		if weights != nil {
+			c[i].Weight = weights[i]
+		}
+	}
+	return c, nil
+}
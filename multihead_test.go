@@ -0,0 +1,98 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestMultiHead(t *testing.T) {
+	heads := []Head{{Size: 2, Activator: Linear{}}, {Size: 3, Activator: Sigmoid{}}}
+	m, err := NewMultiHeadTrainer(4, 2, 5, heads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.RandomizeParameters()
+	testInputOutputDim(t, m, 4, 5, "multihead")
+	if m.LayerDescription(2) != "Sum(Linear), Sum(Sigmoid)" {
+		t.Errorf("Wrong output layer: %v", m.LayerDescription(2))
+	}
+	if start, end := m.HeadRange(1); start != 2 || end != 5 {
+		t.Errorf("Wrong head range. Expected [2, 5), found [%v, %v)", start, end)
+	}
+
+	input := RandomMat(1, 4, rand.NormFloat64)[0]
+	output, _ := m.Predict(input, nil)
+	outputs, err := m.PredictHeads(input, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := [][]float64{make([]float64, 2), make([]float64, 3)}
+	if _, err := m.PredictHeads(input, dst); err != nil {
+		t.Fatal(err)
+	}
+	for i := range heads {
+		start, end := m.HeadRange(i)
+		if !floatsEqual(outputs[i], output[start:end]) || !floatsEqual(dst[i], output[start:end]) {
+			t.Errorf("Wrong output for head %v", i)
+		}
+	}
+}
+
+func TestMultiHeadCompositeLoss(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	heads := []Head{{Size: 1, Activator: Linear{}}, {Size: 2, Activator: Linear{}}}
+	m, err := NewMultiHeadTrainer(2, 1, 10, heads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.CompositeLoss([]Losser{SquaredDistance{}}, nil); err == nil {
+		t.Errorf("Expected error for wrong number of losses")
+	}
+	if _, err := m.CompositeLoss([]Losser{SquaredDistance{}, CrossEntropy{}}, []float64{1}); err == nil {
+		t.Errorf("Expected error for wrong number of weights")
+	}
+	loss, err := m.CompositeLoss([]Losser{SquaredDistance{}, CrossEntropy{}}, []float64{1, 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loss[1].Start != 1 || loss[1].End != 3 || loss[1].Weight != 0.5 {
+		t.Errorf("Wrong task for head 1: %+v", loss[1])
+	}
+
+	// Regress the sum of the inputs and classify its sign
+	inputs := RandomMat(200, 2, rnd.NormFloat64)
+	targets := make(SosMatrix, len(inputs))
+	for i, x := range inputs {
+		sum := x[0] + x[1]
+		targets[i] = []float64{sum, 0, 0}
+		if sum > 0 {
+			targets[i][1] = 1
+		} else {
+			targets[i][2] = 1
+		}
+	}
+	m.SetRand(rnd)
+	m.RandomizeParameters()
+	m.SetLoss(loss)
+	m.SetOptimizer(&Adam{Rate: 0.01})
+	first, err := m.PartialFit(inputs, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 300; i++ {
+		if _, err := m.PartialFit(inputs, targets); err != nil {
+			t.Fatal(err)
+		}
+	}
+	last, _, err := m.lossGradient(inputs, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last > first/4 {
+		t.Errorf("Loss not reduced enough. First %v, last %v", first, last)
+	}
+}
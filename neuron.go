@@ -14,6 +14,7 @@ var (
 	LinearTanhNeuron SumNeuron = SumNeuron{Activator: LinearTanh{}}
 	LinearNeuron     SumNeuron = SumNeuron{Activator: Linear{}}
 	SigmoidNeuron    SumNeuron = SumNeuron{Activator: Sigmoid{}}
+	ReLUNeuron       SumNeuron = SumNeuron{Activator: ReLU{}}
 )
 
 // Neuron doesn't provide own memory, just a definition. Net interfaces with parameters directly
@@ -32,6 +33,37 @@ type Neuron interface {
 	// Shouldn't need to add json.Marshaler because layer can do it
 }
 
+// A RandomizerFrom is a Neuron which can set a random initial condition using a
+// specific source of randomness rather than the global one in math/rand. Trainers
+// use RandomizeFrom when a neuron implements it so that initialization is
+// reproducible.
+type RandomizerFrom interface {
+	RandomizeFrom(parameters []float64, rnd *rand.Rand)
+}
+
+// A CostHint is a Neuron which knows its approximate computational cost. The
+// cost is used to choose the number of samples per parallel work unit (see
+// Net.GrainSize), so neurons which are much more or much less expensive than a
+// weighted sum, such as radial basis or maxout neurons, should implement it.
+// Neurons without a hint are assumed to cost two floating point operations per
+// parameter, as for the multiply and add of each weight of a SumNeuron.
+type CostHint interface {
+	// Cost returns the approximate number of floating point operations of
+	// one Combine and Activate with the given number of inputs
+	Cost(nInputs int) float64
+}
+
+// globalRand is a *rand.Rand which draws from the global source of math/rand,
+// so code can be written in terms of *rand.Rand but default to the same
+// behavior as the top-level functions of math/rand.
+var globalRand = rand.New(globalSource{})
+
+type globalSource struct{}
+
+func (globalSource) Int63() int64   { return rand.Int63() }
+func (globalSource) Uint64() uint64 { return rand.Uint64() }
+func (globalSource) Seed(int64)     { panic("nnet: global source cannot be seeded") }
+
 // A sum neuron takes a weighted sum of all the inputs and pipes them through an activator function
 type SumNeuron struct {
 	Activator
@@ -56,8 +88,14 @@ func (s SumNeuron) Combine(parameters []float64, inputs []float64) (combination
 
 // Randomize sets the parameters to a random initial condition
 func (s SumNeuron) Randomize(parameters []float64) {
+	s.RandomizeFrom(parameters, globalRand)
+}
+
+// RandomizeFrom sets the parameters to a random initial condition using the
+// given source of randomness
+func (s SumNeuron) RandomizeFrom(parameters []float64, rnd *rand.Rand) {
 	for i := range parameters {
-		parameters[i] = rand.NormFloat64() * math.Pow(float64(len(parameters)), -0.5)
+		parameters[i] = rnd.NormFloat64() * math.Pow(float64(len(parameters)), -0.5)
 	}
 }
 
@@ -80,3 +118,43 @@ func (s SumNeuron) DCombineDInput(params []float64, inputs []float64, combinatio
 	}
 	// This intentionally doesn't loop over all of the parameters, as the last parameter is the bias term
 }
+
+// ConstantNeuron outputs its single parameter regardless of its inputs. It is a
+// learned constant, such as the thresholds of an ordinal regression.
+type ConstantNeuron struct{}
+
+// NumParameters returns one, the value of the constant
+func (ConstantNeuron) NumParameters(nInputs int) int {
+	return 1
+}
+
+func (ConstantNeuron) Activate(combination float64) float64 {
+	return combination
+}
+
+func (ConstantNeuron) Combine(parameters, inputs []float64) float64 {
+	return parameters[0]
+}
+
+// Randomize sets the constant to zero
+func (ConstantNeuron) Randomize(parameters []float64) {
+	parameters[0] = 0
+}
+
+func (ConstantNeuron) DActivateDCombination(combination, output float64) float64 {
+	return 1
+}
+
+func (ConstantNeuron) DCombineDParameters(params, inputs []float64, combination float64, deriv []float64) {
+	deriv[0] = 1
+}
+
+func (ConstantNeuron) DCombineDInput(params, inputs []float64, combination float64, deriv []float64) {
+	for i := range deriv {
+		deriv[i] = 0
+	}
+}
+
+func (ConstantNeuron) String() string {
+	return "Constant"
+}
@@ -5,8 +5,13 @@
 package nnet
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"math"
+	"math/rand"
+	"strings"
+	"sync"
 )
 
 // Net is a simple feed-forward neural net
@@ -15,10 +20,17 @@ type Net struct {
 	outputDim          int
 	totalNumParameters int
 
-	grainSize int
+	grainSize     int
+	deterministic bool      // parallel reductions are done in a fixed order
+	partition     Partition // division of the samples of PredictBatch among the workers
+	validate      bool      // check that inputs and outputs are finite
 
 	neurons    [][]Neuron
 	parameters [][][]float64
+	frozen     []bool  // whether the parameters of each layer are fixed during training
+	layers     []Layer // the neurons of each layer, through which the net is evaluated
+
+	scratch *sync.Pool // *predictMemory for Predict, replaced when the layers change
 }
 
 // InputDim returns the number of inputs expected by the net
@@ -31,30 +43,122 @@ func (n *Net) OutputDim() int {
 	return n.outputDim
 }
 
+// Predict predicts the output at the input and stores it into output. If output
+// is nil, a new slice is allocated. The temporary memory is pooled, so Predict
+// doesn't allocate when output is given, and it is safe to call concurrently.
 func (n *Net) Predict(input []float64, output []float64) ([]float64, error) {
 	if len(input) != n.inputDim {
-		return nil, errors.New("input dimension mismatch")
+		return nil, dimensionError("", ErrInputDim, n.inputDim, len(input))
 	}
 	if output == nil {
 		output = make([]float64, n.outputDim)
 	} else {
 		if len(output) != n.outputDim {
-			return nil, errors.New("output dimension mismatch")
+			return nil, dimensionError("", ErrOutputDim, n.outputDim, len(output))
+		}
+	}
+	if n.validate {
+		if err := checkFinite(input, ErrNonFiniteInput); err != nil {
+			return nil, err
+		}
+	}
+	mem := n.scratch.Get().(*predictMemory)
+	predict(input, n.layers, mem.prevOutput, mem.output, output)
+	n.scratch.Put(mem)
+	if n.validate {
+		return output, checkFinite(output, ErrNonFiniteOutput)
+	}
+	return output, nil
+}
+
+// PredictOutputs predicts only the outputs with the given indices, storing output
+// indices[i] into output[i]. The hidden layers are evaluated as usual, but only
+// the requested neurons of the final layer are computed, which saves work when
+// the final layer is wide. If output is nil, a new slice is allocated.
+func (n *Net) PredictOutputs(input []float64, indices []int, output []float64) ([]float64, error) {
+	if len(input) != n.inputDim {
+		return nil, dimensionError("", ErrInputDim, n.inputDim, len(input))
+	}
+	if output == nil {
+		output = make([]float64, len(indices))
+	} else {
+		if len(output) != len(indices) {
+			return nil, dimensionError("", ErrOutputDim, len(indices), len(output))
+		}
+	}
+	for _, idx := range indices {
+		if idx < 0 || idx >= n.outputDim {
+			return nil, errors.New("output index out of range")
 		}
 	}
-	prevOutput, tmpOutput := newPredictMemory(n.neurons)
-	predict(input, n.neurons, n.parameters, prevOutput, tmpOutput, output)
+	if n.validate {
+		if err := checkFinite(input, ErrNonFiniteInput); err != nil {
+			return nil, err
+		}
+	}
+	nLayers := len(n.neurons)
+	layerInput := input
+	if nLayers > 1 {
+		// Predict the last hidden layer as if it were the output of the net
+		prevOutput, tmpOutput := newPredictMemory(n.layers[:nLayers-1])
+		layerInput = make([]float64, n.layers[nLayers-2].NumOutputs())
+		predict(input, n.layers[:nLayers-1], prevOutput, tmpOutput, layerInput)
+	}
+	final := n.neurons[nLayers-1]
+	params := n.parameters[nLayers-1]
+	for i, idx := range indices {
+		combination := final[idx].Combine(params[idx], layerInput)
+		output[i] = final[idx].Activate(combination)
+	}
+	if n.validate {
+		return output, checkFinite(output, ErrNonFiniteOutput)
+	}
 	return output, nil
 }
 
 func (n *Net) PredictBatch(inputs RowMatrix, outputs MutableRowMatrix) (MutableRowMatrix, error) {
-	batch := batchPredictor{
-		neurons:    n.neurons,
-		parameters: n.parameters,
-		inputDim:   n.InputDim(),
-		outputDim:  n.OutputDim(),
+	return n.PredictBatchCtx(context.Background(), inputs, outputs)
+}
+
+// PredictCtx is like Predict but returns the context's error without predicting
+// if the context is already done.
+func (n *Net) PredictCtx(ctx context.Context, input []float64, output []float64) ([]float64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return n.Predict(input, output)
+}
+
+// PredictBatchCtx is like PredictBatch but honors the cancellation and deadline of
+// the context. The context is checked before each chunk of rows is predicted.
+// If the context is done, its error is returned and outputs is only partially
+// computed.
+//
+// The samples are usually divided among the workers. When there are too few
+// samples to keep the workers busy but the layers are wide, the neurons of each
+// layer are divided among the workers instead, unless the net validates its
+// inputs and outputs (see SetValidateFinite).
+func (n *Net) PredictBatchCtx(ctx context.Context, inputs RowMatrix, outputs MutableRowMatrix) (MutableRowMatrix, error) {
+	if nSamples, _ := inputs.Dims(); !n.validate && layerParallel(n.layers, n.grainSize, nSamples) {
+		return predictBatchLayers(ctx, n.layers, inputs, outputs, n.inputDim, n.outputDim)
+	}
+	return BatchPredictCtx(ctx, n.batchPredictor(), inputs, outputs, n.inputDim, n.outputDim, n.grainSize)
+}
+
+// PredictBatchChunked is like PredictBatchCtx but calls onChunk with the range of
+// rows [start, end) as each chunk of outputs is complete. See BatchPredictChunked.
+func (n *Net) PredictBatchChunked(ctx context.Context, inputs RowMatrix, outputs MutableRowMatrix, onChunk func(start, end int)) (MutableRowMatrix, error) {
+	return BatchPredictChunked(ctx, n.batchPredictor(), inputs, outputs, n.inputDim, n.outputDim, n.grainSize, onChunk)
+}
+
+func (n *Net) batchPredictor() batchPredictor {
+	return batchPredictor{
+		layers:    n.layers,
+		inputDim:  n.InputDim(),
+		outputDim: n.OutputDim(),
+		validate:  n.validate,
+		partition: n.partition,
 	}
-	return BatchPredict(batch, inputs, outputs, n.inputDim, n.outputDim, n.grainSize)
 }
 
 func (n *Net) HackGrainSize(g int) {
@@ -71,12 +175,22 @@ func (n *Net) setGrainSize() {
 	neuronOverhead := 70 // WAG, relative to one parameter
 	layerOverhead := 200 // relative to one parameter
 
-	var nNeurons int
+	// The cost of a neuron is its number of parameters unless it has a cost
+	// hint, which is in floating point operations at two per parameter
+	var nOps float64
+	nLayerInputs := n.inputDim
 	for _, layer := range n.neurons {
-		nNeurons += len(layer)
+		for _, neuron := range layer {
+			if c, ok := neuron.(CostHint); ok {
+				nOps += c.Cost(nLayerInputs) / 2
+			} else {
+				nOps += float64(neuron.NumParameters(nLayerInputs))
+			}
+		}
+		nOps += float64(len(layer) * neuronOverhead)
+		nLayerInputs = len(layer)
 	}
-
-	nOps := n.totalNumParameters + nNeurons*neuronOverhead + layerOverhead*len(n.neurons)
+	nOps += float64(layerOverhead * len(n.neurons))
 
 	// We want each batch to take around 100µs
 	// https://groups.google.com/forum/#!searchin/golang-nuts/Data$20parallelism$20with$20go$20routines/golang-nuts/-9LdBZoAIrk/2ayBvi0U0mQJ
@@ -86,7 +200,7 @@ func (n *Net) setGrainSize() {
 	// dependent, but maybe not relative to the overhead of the parallel loop
 	c := 0.7
 
-	grainSize := int(math.Ceil(100000 / (c * float64(nOps))))
+	grainSize := int(math.Ceil(100000 / (c * nOps)))
 	if grainSize < 1 {
 		grainSize = 1 // This shouldn't happen, but maybe for a REALLY large net. Better safe than sorry
 	}
@@ -98,43 +212,126 @@ func (n *Net) GrainSize() int {
 	return n.grainSize
 }
 
+// TotalNumParameters returns the total number of parameters in the net
+func (n *Net) TotalNumParameters() int {
+	return n.totalNumParameters
+}
+
+// NumLayers returns the number of layers in the net including the output layer
+func (n *Net) NumLayers() int {
+	return len(n.neurons)
+}
+
+// LayerSizes returns the number of neurons in each layer of the net. The
+// last element is the output dimension.
+func (n *Net) LayerSizes() []int {
+	sizes := make([]int, len(n.neurons))
+	for i, layer := range n.neurons {
+		sizes[i] = len(layer)
+	}
+	return sizes
+}
+
+// LayerNumParameters returns the number of parameters in the given layer
+func (n *Net) LayerNumParameters(layer int) int {
+	var nParams int
+	for _, p := range n.parameters[layer] {
+		nParams += len(p)
+	}
+	return nParams
+}
+
+// Neuron returns the definition of the given neuron in the given layer
+func (n *Net) Neuron(layer, neuron int) Neuron {
+	return n.neurons[layer][neuron]
+}
+
+// LayerDescription returns a short description of the neurons in the given
+// layer, for example "Sum(Tanh)". If the neurons in the layer are not all the
+// same, the descriptions are separated by commas.
+func (n *Net) LayerDescription(layer int) string {
+	var descs []string
+	seen := make(map[string]bool)
+	for _, neuron := range n.neurons[layer] {
+		d := describeNeuron(neuron)
+		if !seen[d] {
+			seen[d] = true
+			descs = append(descs, d)
+		}
+	}
+	return strings.Join(descs, ", ")
+}
+
+// describeNeuron returns a short description of a neuron. Neurons which
+// implement fmt.Stringer describe themselves.
+func describeNeuron(neuron Neuron) string {
+	switch t := neuron.(type) {
+	case fmt.Stringer:
+		return t.String()
+	case SumNeuron:
+		return "Sum(" + describeActivator(t.Activator) + ")"
+	default:
+		return fmt.Sprintf("%T", neuron)
+	}
+}
+
+func describeActivator(a Activator) string {
+	if s, ok := a.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", a)
+}
+
 // batchPredictor is a type which implements BatchPredictor so that
 // the predictions can be computed in parallel
 type batchPredictor struct {
-	neurons    [][]Neuron
-	parameters [][][]float64
-	inputDim   int
-	outputDim  int
+	layers    []Layer
+	inputDim  int
+	outputDim int
+	validate  bool
+	partition Partition
+}
+
+func (b batchPredictor) Partition() Partition {
+	return b.partition
 }
 
 // NewPredictor generates the necessary temporary memory and returns a struct to allow
 // for concurrent prediction
 func (b batchPredictor) NewPredictor() Predictor {
-	prevOutput, tmpOutput := newPredictMemory(b.neurons)
+	prevOutput, tmpOutput := newPredictMemory(b.layers)
 	return predictor{
-		neurons:       b.neurons,
-		parameters:    b.parameters,
+		layers:        b.layers,
 		tmpOutput:     tmpOutput,
 		prevTmpOutput: prevOutput,
 		inputDim:      b.inputDim,
 		outputDim:     b.outputDim,
+		validate:      b.validate,
 	}
 }
 
 // predictor is a struct that contains temporary memory to be reused during
 // sucessive calls to predict
 type predictor struct {
-	neurons       [][]Neuron
-	parameters    [][][]float64
+	layers        []Layer
 	tmpOutput     []float64
 	prevTmpOutput []float64
 
 	inputDim  int
 	outputDim int
+	validate  bool
 }
 
 func (p predictor) Predict(input, output []float64) ([]float64, error) {
-	predict(input, p.neurons, p.parameters, p.prevTmpOutput, p.tmpOutput, output)
+	if p.validate {
+		if err := checkFinite(input, ErrNonFiniteInput); err != nil {
+			return output, err
+		}
+	}
+	predict(input, p.layers, p.prevTmpOutput, p.tmpOutput, output)
+	if p.validate {
+		return output, checkFinite(output, ErrNonFiniteOutput)
+	}
 	return output, nil
 }
 
@@ -152,11 +349,18 @@ func (p predictor) OutputDim() int {
 	return p.outputDim
 }
 
-func newPredictMemory(neurons [][]Neuron) (prevOutput, output []float64) {
-	// find the largest layer in terms of number of neurons
-	max := len(neurons[0])
-	for i := 1; i < len(neurons); i++ {
-		l := len(neurons[i])
+// predictMemory is the temporary memory of predict, pooled by Net.Predict so
+// that single predictions don't allocate
+type predictMemory struct {
+	prevOutput []float64
+	output     []float64
+}
+
+func newPredictMemory(layers []Layer) (prevOutput, output []float64) {
+	// find the largest layer in terms of number of outputs
+	max := layers[0].NumOutputs()
+	for i := 1; i < len(layers); i++ {
+		l := layers[i].NumOutputs()
 		if l > max {
 			max = l
 		}
@@ -165,39 +369,52 @@ func newPredictMemory(neurons [][]Neuron) (prevOutput, output []float64) {
 }
 
 // predict predicts the output from the net. prevOutput and output are
-func predict(input []float64, neurons [][]Neuron, parameters [][][]float64, prevTmpOutput, tmpOutput []float64, output []float64) {
-	nLayers := len(neurons)
+func predict(input []float64, layers []Layer, prevTmpOutput, tmpOutput []float64, output []float64) {
+	nLayers := len(layers)
 
 	if nLayers == 1 {
-		processLayer(input, neurons[0], parameters[0], output)
+		layers[0].Forward(input, output)
 		return
 	}
 
 	// first layer uses the real input as the input
-	tmpOutput = tmpOutput[:len(neurons[0])]
-	processLayer(input, neurons[0], parameters[0], tmpOutput)
+	tmpOutput = tmpOutput[:layers[0].NumOutputs()]
+	layers[0].Forward(input, tmpOutput)
 
 	// Middle layers use the previous output as input
 	for i := 1; i < nLayers-1; i++ {
 		// swap the pointers for temporary outputs, and make the new output the correct size
 		prevTmpOutput, tmpOutput = tmpOutput, prevTmpOutput
-		tmpOutput = tmpOutput[:len(neurons[i])]
-		processLayer(prevTmpOutput, neurons[i], parameters[i], tmpOutput)
+		tmpOutput = tmpOutput[:layers[i].NumOutputs()]
+		layers[i].Forward(prevTmpOutput, tmpOutput)
 	}
 	// The final layer is the actual output
-	processLayer(tmpOutput, neurons[nLayers-1], parameters[nLayers-1], output)
-}
-
-func processLayer(input []float64, neurons []Neuron, parameters [][]float64, output []float64) {
-	for i, neuron := range neurons {
-		combination := neuron.Combine(parameters[i], input)
-		output[i] = neuron.Activate(combination)
-	}
+	layers[nLayers-1].Forward(tmpOutput, output)
 }
 
 // Trainer is a wrapper for the feed-forward net for training
 type Trainer struct {
 	*Net
+
+	initializer       Initializer   // net-wide initializer
+	layerInitializers []Initializer // layer-specific initializers
+	rnd               *rand.Rand    // source of randomness, global if nil
+
+	loss      Losser    // loss minimized by PartialFit, SquaredDistance if nil
+	optimizer Optimizer // optimizer used by PartialFit, created on first use if nil
+	schedule  Schedule  // learning rate schedule, none if nil
+	steps     int       // number of optimizer steps taken
+	epochs    int       // number of epochs completed by TrainEpoch
+
+	gradientNoise GradientNoise // noise added to the gradient, none if Eta is zero
+	ewc           *EWC          // elastic weight consolidation penalty, none if nil
+	history       *History      // progress recorded by TrainEpoch, none if nil
+	tied          []tiedLayers  // layers whose weights are tied, see AutoencoderTrainer
+	pruned        []bool        // flat mask of the pruned parameters, none if nil
+
+	// Temporary memory for training
+	params []float64
+	grad   []float64
 }
 
 // NewSimpleTrainer constructs a trainable feed-forward neural net with the specified sizes and
@@ -266,9 +483,14 @@ func NewTrainer(inputDim, outputDim int, neurons [][]Neuron) (*Trainer, error) {
 		totalNumParameters: totalNumParameters,
 		neurons:            neurons,
 		parameters:         parameters,
+		frozen:             make([]bool, nLayers),
 	}
+	net.updateLayers()
 	net.setGrainSize()
-	return &Trainer{net}, nil
+	return &Trainer{
+		Net:               net,
+		layerInitializers: make([]Initializer, nLayers),
+	}, nil
 }
 
 func newPerParameterMemory(params [][][]float64) [][][]float64 {
@@ -282,24 +504,192 @@ func newPerParameterMemory(params [][][]float64) [][][]float64 {
 	return n
 }
 
-func newPerNeuronMemory(n [][]Neuron) [][]float64 {
-	sos := make([][]float64, len(n))
-	for i, layer := range n {
-		sos[i] = make([]float64, len(layer))
+// Predictor returns a copy of the net so that the trainer can continue to be
+// modified after releasing the predictor
+func (s *Trainer) Predictor() Predictor {
+	return s.Net.Clone()
+}
+
+// Clone returns a deep copy of the trainer. The optimizer is not copied as it
+// holds state about the training of this trainer, so the clone uses the default
+// optimizer until one is set. If a source of randomness was set with SetRand,
+// the clone gets its own *rand.Rand seeded from it, so the clone and the
+// original may be used from different goroutines and the clone draws the same
+// values on every run.
+func (s *Trainer) Clone() *Trainer {
+	layerInitializers := make([]Initializer, len(s.layerInitializers))
+	copy(layerInitializers, s.layerInitializers)
+	var rnd *rand.Rand
+	if s.rnd != nil {
+		rnd = rand.New(rand.NewSource(s.rnd.Int63()))
+	}
+	return &Trainer{
+		Net:               s.Net.Clone(),
+		initializer:       s.initializer,
+		layerInitializers: layerInitializers,
+		rnd:               rnd,
+		loss:              s.loss,
+		schedule:          s.schedule,
+		steps:             s.steps,
+		epochs:            s.epochs,
+		gradientNoise:     s.gradientNoise,
+		ewc:               s.ewc,
+		tied:              s.tied,
+		pruned:            s.PruneMask(),
 	}
-	return sos
 }
 
-// TODO: Replace this with a copy so can modify the trainer after releasing the
-// predictor
-func (s *Trainer) Predictor() Predictor {
-	return s.Net
+// Clone returns a deep copy of the net. The neurons are assumed to be
+// stateless values (like SumNeuron), so the neuron definitions are copied
+// but the parameters are newly allocated.
+func (n *Net) Clone() *Net {
+	neurons := make([][]Neuron, len(n.neurons))
+	for i, layer := range n.neurons {
+		neurons[i] = make([]Neuron, len(layer))
+		copy(neurons[i], layer)
+	}
+	parameters := newPerParameterMemory(n.parameters)
+	for i, layer := range n.parameters {
+		for j, p := range layer {
+			copy(parameters[i][j], p)
+		}
+	}
+	frozen := make([]bool, len(n.frozen))
+	copy(frozen, n.frozen)
+	net := &Net{
+		inputDim:           n.inputDim,
+		outputDim:          n.outputDim,
+		totalNumParameters: n.totalNumParameters,
+		grainSize:          n.grainSize,
+		deterministic:      n.deterministic,
+		partition:          n.partition,
+		validate:           n.validate,
+		neurons:            neurons,
+		parameters:         parameters,
+		frozen:             frozen,
+	}
+	net.updateLayers()
+	return net
 }
 
-func (s *Trainer) RandomizeParameters() {
-	for i, layer := range s.neurons {
-		for j, neuron := range layer {
-			neuron.Randomize(s.parameters[i][j])
+// Parameters copies the parameters of the net into dst as a single flat vector
+// and returns it. If dst is nil, a new slice is allocated, otherwise dst must
+// have a length equal to the total number of parameters.
+//
+// The ordering is by layer, then by neuron within the layer, then by parameter
+// within the neuron, i.e. the flat index increases fastest in the parameter index
+// of the neuron. Layer 0 is the layer connected to the input. For a SumNeuron, the
+// parameters are the weights of the inputs followed by the bias. This ordering is
+// stable and is shared by all the methods that use a flat parameter vector.
+func (n *Net) Parameters(dst []float64) []float64 {
+	if dst == nil {
+		dst = make([]float64, n.totalNumParameters)
+	}
+	if len(dst) != n.totalNumParameters {
+		panic("nnet: parameter length mismatch")
+	}
+	var idx int
+	for _, layer := range n.parameters {
+		for _, p := range layer {
+			idx += copy(dst[idx:], p)
+		}
+	}
+	return dst
+}
+
+// SetParameters sets the parameters of the net from a flat vector with the
+// ordering described in Net.Parameters. The length of src must equal the total
+// number of parameters.
+func (s *Trainer) SetParameters(src []float64) {
+	if len(src) != s.totalNumParameters {
+		panic("nnet: parameter length mismatch")
+	}
+	var idx int
+	for _, layer := range s.parameters {
+		for _, p := range layer {
+			idx += copy(p, src[idx:])
 		}
 	}
 }
+
+// SetLayerTrainable sets whether the parameters of the given layer are modified
+// during training. The parameter gradient of a frozen layer is not computed and
+// is reported as zero, so only the trainable layers are fine-tuned. This is
+// useful for transfer learning, where the early layers of a trained net are kept
+// fixed and only the final layer(s) are retrained. All layers are trainable by
+// default.
+func (s *Trainer) SetLayerTrainable(layer int, trainable bool) {
+	s.frozen[layer] = !trainable
+}
+
+// LayerTrainable returns whether the parameters of the given layer are modified
+// during training
+func (n *Net) LayerTrainable(layer int) bool {
+	return !n.frozen[layer]
+}
+
+// SetDeterministic sets whether parallel accumulations over samples, such as
+// batch gradients, are reduced in a fixed order. With determinism on, results are
+// bit-for-bit reproducible regardless of GOMAXPROCS at the cost of some extra
+// memory. Determinism is off by default.
+func (s *Trainer) SetDeterministic(deterministic bool) {
+	s.deterministic = deterministic
+}
+
+// Deterministic returns whether parallel accumulations are reduced in a fixed order
+func (n *Net) Deterministic() bool {
+	return n.deterministic
+}
+
+// SetPartition sets how PredictBatch divides the chunks of samples among the
+// workers (see Partition). Stealing is a good choice when the cost of
+// predicting varies between samples. The default is Dynamic.
+func (s *Trainer) SetPartition(part Partition) {
+	s.partition = part
+}
+
+// Partition returns how PredictBatch divides the samples among the workers
+func (n *Net) Partition() Partition {
+	return n.partition
+}
+
+// SetValidateFinite sets whether Predict and PredictBatch check for NaN and Inf
+// values. With validation on, an input containing a non-finite value is rejected
+// with an error wrapping ErrNonFiniteInput, and a non-finite output is reported
+// with an error wrapping ErrNonFiniteOutput (the output is still returned). In
+// PredictBatch these errors are reported per row in a *BatchError. Validation
+// is off by default.
+func (s *Trainer) SetValidateFinite(validate bool) {
+	s.validate = validate
+}
+
+// ValidateFinite returns whether predictions check for NaN and Inf values
+func (n *Net) ValidateFinite() bool {
+	return n.validate
+}
+
+// SetRand sets the source of randomness used by the trainer. If rnd is nil, the
+// global source in math/rand is used, which is the default. A *rand.Rand is not
+// safe for concurrent use, so rnd should not be shared between trainers used
+// from different goroutines.
+func (s *Trainer) SetRand(rnd *rand.Rand) {
+	s.rnd = rnd
+}
+
+func (s *Trainer) rand() *rand.Rand {
+	if s.rnd == nil {
+		return globalRand
+	}
+	return s.rnd
+}
+
+// RandomizeParameters sets the parameters of the net to a random initial condition.
+// Layers are initialized using the initializers set with SetInitializer and
+// SetLayerInitializer, or using Neuron.Randomize if there is no initializer.
+func (s *Trainer) RandomizeParameters() {
+	for i := range s.neurons {
+		s.randomizeLayer(i)
+	}
+	s.tieParameters()
+	s.applyPruneMask()
+}
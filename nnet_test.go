@@ -5,6 +5,9 @@
 package nnet
 
 import (
+	"context"
+	"errors"
+	"math"
 	"math/rand"
 	"strconv"
 	"testing"
@@ -88,12 +91,347 @@ func TestPublicPredictAndBatch(t *testing.T) {
 			trueOutputs := RandomMat(nSamples, test.outputDim, rand.NormFloat64)
 
 			for j := 0; j < nSamples; j++ {
-				tmp1, tmp2 := newPredictMemory(n.neurons)
-				predict(inputs.RowView(j), n.neurons, n.parameters, tmp1, tmp2, trueOutputs.RowView(j))
-				//predict(inputs.RowView(j), s.features, s.b, s.featureWeights, trueOutputs.RowView(j))
+				tmp1, tmp2 := newPredictMemory(n.layers)
+				predict(inputs.RowView(j), n.layers, tmp1, tmp2, trueOutputs.RowView(j))
 			}
 
 			testPredictAndBatch(t, n, inputs, trueOutputs, test.name)
 		}
 	}
 }
+
+func TestClone(t *testing.T) {
+	for i, test := range netIniters {
+		trainer := testNets[i].Clone()
+		p := trainer.Predictor()
+		input := RandomMat(1, test.inputDim, rand.NormFloat64)[0]
+		before, _ := p.Predict(input, nil)
+		trueOut, _ := testNets[i].Predict(input, nil)
+		if !floatsEqual(before, trueOut) {
+			t.Errorf("%v: clone predicts differently than the original", test.name)
+		}
+		trainer.RandomizeParameters()
+		after, _ := p.Predict(input, nil)
+		if !floatsEqual(before, after) {
+			t.Errorf("%v: modifying the trainer changed the released predictor", test.name)
+		}
+		orig, _ := testNets[i].Predict(input, nil)
+		if !floatsEqual(orig, trueOut) {
+			t.Errorf("%v: modifying the clone changed the original", test.name)
+		}
+	}
+
+	// The clone has its own source of randomness, seeded from the original
+	var draws [2]float64
+	for i := range draws {
+		s := testNets[0].Clone()
+		s.SetRand(rand.New(rand.NewSource(1)))
+		c := s.Clone()
+		if c.rnd == s.rnd {
+			t.Fatalf("Clone shares the source of randomness")
+		}
+		draws[i] = c.rand().Float64()
+	}
+	if draws[0] != draws[1] {
+		t.Errorf("Clone randomness not reproducible: %v, %v", draws[0], draws[1])
+	}
+}
+
+func TestParameters(t *testing.T) {
+	for i, test := range netIniters {
+		trainer := testNets[i].Clone()
+		params := trainer.Parameters(nil)
+		var idx int
+		for _, layer := range trainer.parameters {
+			for _, p := range layer {
+				for _, v := range p {
+					if params[idx] != v {
+						t.Errorf("%v: parameter mismatch at index %v", test.name, idx)
+					}
+					idx++
+				}
+			}
+		}
+		if idx != len(params) {
+			t.Errorf("%v: wrong number of parameters. Expected %v, found %v", test.name, idx, len(params))
+		}
+
+		for j := range params {
+			params[j] = rand.NormFloat64()
+		}
+		trainer.SetParameters(params)
+		if !floatsEqual(params, trainer.Parameters(make([]float64, len(params)))) {
+			t.Errorf("%v: parameters not set", test.name)
+		}
+		if !panics(func() { trainer.SetParameters(params[1:]) }) {
+			t.Errorf("%v: no panic with wrong parameter length", test.name)
+		}
+	}
+}
+
+func TestIntrospection(t *testing.T) {
+	for i, test := range netIniters {
+		n := testNets[i]
+		if n.NumLayers() != test.nHiddenLayers+1 {
+			t.Errorf("%v: wrong number of layers", test.name)
+		}
+		sizes := n.LayerSizes()
+		var nParams int
+		nInputs := test.inputDim
+		for j, size := range sizes {
+			trueSize := test.nNeuronsPerLayer
+			trueDesc := "Sum(Tanh)"
+			if j == len(sizes)-1 {
+				trueSize = test.outputDim
+				trueDesc = "Sum(Linear)"
+			}
+			if size != trueSize {
+				t.Errorf("%v: wrong size of layer %v. Expected %v, found %v", test.name, j, trueSize, size)
+			}
+			if desc := n.LayerDescription(j); desc != trueDesc {
+				t.Errorf("%v: wrong description of layer %v. Expected %v, found %v", test.name, j, trueDesc, desc)
+			}
+			layerParams := size * (nInputs + 1)
+			if n.LayerNumParameters(j) != layerParams {
+				t.Errorf("%v: wrong number of parameters in layer %v", test.name, j)
+			}
+			nParams += layerParams
+			nInputs = size
+		}
+		if n.TotalNumParameters() != nParams {
+			t.Errorf("%v: wrong total number of parameters. Expected %v, found %v", test.name, nParams, n.TotalNumParameters())
+		}
+	}
+}
+
+func TestSetRand(t *testing.T) {
+	newParams := func(init Initializer) []float64 {
+		s, err := NewSimpleTrainer(4, 2, 2, 5, Linear{})
+		if err != nil {
+			panic(err)
+		}
+		s.SetInitializer(init)
+		s.SetRand(rand.New(rand.NewSource(42)))
+		s.RandomizeParameters()
+		return s.Parameters(nil)
+	}
+	for _, init := range []Initializer{nil, Xavier{}, He{}, Orthogonal{}} {
+		if !floatsEqual(newParams(init), newParams(init)) {
+			t.Errorf("Parameters with the same seed differ for initializer %v", init)
+		}
+	}
+}
+
+func TestPredictCtx(t *testing.T) {
+	n := testNets[1]
+	nSamples := 1000
+	inputs := RandomMat(nSamples, n.InputDim(), rand.NormFloat64)
+	want, _ := n.PredictBatch(inputs, nil)
+	got, err := n.PredictBatchCtx(context.Background(), inputs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < nSamples; i++ {
+		if !floatsEqual(got.(SosMatrix)[i], want.(SosMatrix)[i]) {
+			t.Errorf("Mismatch with background context for row %v", i)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := n.PredictCtx(ctx, inputs[0], nil); err != context.Canceled {
+		t.Errorf("Wrong error for cancelled Predict: %v", err)
+	}
+	outputs := RandomMat(nSamples, n.OutputDim(), func() float64 { return 0 })
+	if _, err := n.PredictBatchCtx(ctx, inputs, outputs); err != context.Canceled {
+		t.Errorf("Wrong error for cancelled PredictBatch: %v", err)
+	}
+	for i := range outputs {
+		for _, v := range outputs[i] {
+			if v != 0 {
+				t.Fatalf("Rows predicted after cancellation")
+			}
+		}
+	}
+}
+
+func TestDimensionErrors(t *testing.T) {
+	n := testNets[0]
+	_, err := n.Predict(make([]float64, n.InputDim()+1), nil)
+	if !errors.Is(err, ErrInputDim) {
+		t.Errorf("Predict: wrong error kind: %v", err)
+	}
+	var dimErr *DimensionError
+	if !errors.As(err, &dimErr) || dimErr.Expected != n.InputDim() || dimErr.Actual != n.InputDim()+1 {
+		t.Errorf("Predict: wrong dimensions in error: %v", err)
+	}
+
+	inputs := RandomMat(3, n.InputDim(), rand.NormFloat64)
+	outputs := RandomMat(4, n.OutputDim(), rand.NormFloat64)
+	_, err = n.PredictBatch(inputs, outputs)
+	if !errors.Is(err, ErrRows) {
+		t.Errorf("PredictBatch: wrong error kind: %v", err)
+	}
+	if err.Error() != "predict batch: rows mismatch: expected 3, found 4" {
+		t.Errorf("PredictBatch: wrong error message: %v", err)
+	}
+}
+
+func TestValidateFinite(t *testing.T) {
+	s := testNets[2].Clone()
+	input := RandomMat(1, s.InputDim(), rand.NormFloat64)[0]
+	input[1] = math.NaN()
+	if _, err := s.Predict(input, nil); err != nil {
+		t.Errorf("Error without validation: %v", err)
+	}
+	s.SetValidateFinite(true)
+	_, err := s.Predict(input, nil)
+	var nfErr *NonFiniteError
+	if !errors.Is(err, ErrNonFiniteInput) || !errors.As(err, &nfErr) || nfErr.Index != 1 {
+		t.Errorf("Wrong error for NaN input: %v", err)
+	}
+
+	inputs := RandomMat(10, s.InputDim(), rand.NormFloat64)
+	inputs[4][0] = math.Inf(1)
+	_, err = s.Freeze().PredictBatch(inputs, nil)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || !equalInts(batchErr.Rows(), []int{4}) || !errors.Is(err, ErrNonFiniteInput) {
+		t.Errorf("Wrong error for Inf input in batch: %v", err)
+	}
+
+	// Make the output infinite with a huge bias
+	out := s.parameters[len(s.parameters)-1][0]
+	out[len(out)-1] = math.Inf(1)
+	input[1] = 0
+	if _, err := s.Predict(input, nil); !errors.Is(err, ErrNonFiniteOutput) {
+		t.Errorf("Wrong error for Inf output: %v", err)
+	}
+}
+
+func TestPredictOutputs(t *testing.T) {
+	for i, test := range netIniters {
+		n := testNets[i]
+		input := RandomMat(1, test.inputDim, rand.NormFloat64)[0]
+		full, _ := n.Predict(input, nil)
+		indices := []int{test.outputDim - 1, 0}
+		got, err := n.PredictOutputs(input, indices, nil)
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		for j, idx := range indices {
+			if got[j] != full[idx] {
+				t.Errorf("%v: output %v mismatch. Expected %v, found %v", test.name, idx, full[idx], got[j])
+			}
+		}
+		if _, err := n.PredictOutputs(input, []int{test.outputDim}, nil); err == nil {
+			t.Errorf("%v: no error for out of range index", test.name)
+		}
+	}
+}
+
+func TestPredictBatchChunked(t *testing.T) {
+	n := testNets[1].Clone()
+	n.HackGrainSize(7)
+	nSamples := 100
+	inputs := RandomMat(nSamples, n.InputDim(), rand.NormFloat64)
+	want, _ := n.PredictBatch(inputs, nil)
+	seen := make([]bool, nSamples)
+	var outputs MutableRowMatrix = RandomMat(nSamples, n.OutputDim(), rand.NormFloat64)
+	outputs, err := n.PredictBatchChunked(context.Background(), inputs, outputs, func(start, end int) {
+		for i := start; i < end; i++ {
+			if seen[i] {
+				t.Errorf("Row %v reported twice", i)
+			}
+			seen[i] = true
+			if !floatsEqual(outputs.(SosMatrix)[i], want.(SosMatrix)[i]) {
+				t.Errorf("Row %v not complete when its chunk was reported", i)
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range seen {
+		if !s {
+			t.Errorf("Row %v never reported", i)
+		}
+	}
+}
+
+func TestPredictAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocations are not counted with the race detector")
+	}
+	for i, test := range netIniters {
+		n := testNets[i]
+		input := RandomMat(1, test.inputDim, rand.NormFloat64)[0]
+		output := make([]float64, test.outputDim)
+		allocs := testing.AllocsPerRun(100, func() {
+			if _, err := n.Predict(input, output); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if allocs != 0 {
+			t.Errorf("%v: Predict made %v allocations, want 0", test.name, allocs)
+		}
+	}
+
+	// The temporary memory must grow with the net
+	s, err := NewSimpleTrainer(2, 1, 1, 3, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.RandomizeParameters()
+	if _, err := s.Predict([]float64{1, 2}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ResizeLayer(0, 20); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Predict([]float64{1, 2}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := s.Clone().Predict([]float64{1, 2}, nil)
+	if !floatsEqual(got, want) {
+		t.Errorf("Wrong prediction after resize. Expected %v, found %v", want, got)
+	}
+}
+
+// hintedNeuron is a SumNeuron with a cost hint of cost floating point
+// operations per input
+type hintedNeuron struct {
+	SumNeuron
+	cost float64
+}
+
+func (h hintedNeuron) Cost(nInputs int) float64 {
+	return h.cost * float64(nInputs)
+}
+
+func TestCostHintGrainSize(t *testing.T) {
+	grainSize := func(neuron Neuron) int {
+		layers := [][]Neuron{make([]Neuron, 50), make([]Neuron, 1)}
+		for i := range layers[0] {
+			layers[0][i] = neuron
+		}
+		layers[1][0] = neuron
+		s, err := NewTrainer(200, 1, layers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s.GrainSize()
+	}
+	plain := grainSize(TanhNeuron)
+	// Two operations per input is about the cost of the parameters of a
+	// SumNeuron without a hint
+	if g := grainSize(hintedNeuron{TanhNeuron, 2}); g < plain*9/10 || g > plain*11/10 {
+		t.Errorf("Grain size with the cost of a SumNeuron is %v, expected about %v", g, plain)
+	}
+	if g := grainSize(hintedNeuron{TanhNeuron, 100}); g > plain/4 {
+		t.Errorf("Grain size of expensive neurons %v isn't smaller than %v", g, plain)
+	}
+	if g := grainSize(hintedNeuron{TanhNeuron, 0.1}); g <= plain {
+		t.Errorf("Grain size of cheap neurons %v isn't larger than %v", g, plain)
+	}
+}
@@ -0,0 +1,9 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//go:build !race
+
+package nnet
+
+const raceEnabled = false
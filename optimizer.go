@@ -0,0 +1,315 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+)
+
+// Optimizer updates the parameters of a net from the gradient of the loss.
+// Optimizers may keep state between steps, such as momentum, so an optimizer
+// should only be used with one trainer at a time. The state is reset if the
+// number of parameters changes.
+type Optimizer interface {
+	// Step updates the parameters in place given the gradient of the loss
+	// with respect to the parameters, both in the flat parameter ordering (see
+	// Net.Parameters).
+	Step(parameters, gradient []float64)
+
+	LearningRate() float64
+	SetLearningRate(float64)
+}
+
+// A StatefulOptimizer is an Optimizer whose state between steps can be saved
+// in a checkpoint (see WriteCheckpoint), so that training resumed from the
+// checkpoint continues as if it had not been interrupted. The state does not
+// include the settings of the optimizer, such as the learning rate.
+type StatefulOptimizer interface {
+	Optimizer
+
+	// OptimizerState returns the state of the optimizer encoded as JSON
+	OptimizerState() (json.RawMessage, error)
+
+	// SetOptimizerState replaces the state of the optimizer with one returned
+	// by OptimizerState
+	SetOptimizerState(json.RawMessage) error
+}
+
+// SGD is stochastic gradient descent with optional momentum. The update is
+//
+//	v = Momentum * v - Rate * gradient
+//	parameters += v
+//
+// so a Momentum of zero gives plain gradient descent. WeightDecay, if non-zero,
+// adds WeightDecay times the parameters to the gradient.
+type SGD struct {
+	Rate        float64
+	Momentum    float64
+	WeightDecay float64
+
+	velocity []float64
+}
+
+func (s *SGD) Step(parameters, gradient []float64) {
+	if s.Momentum == 0 {
+		for i, g := range gradient {
+			parameters[i] -= s.Rate * (g + s.WeightDecay*parameters[i])
+		}
+		return
+	}
+	if len(s.velocity) != len(parameters) {
+		s.velocity = make([]float64, len(parameters))
+	}
+	for i, g := range gradient {
+		s.velocity[i] = s.Momentum*s.velocity[i] - s.Rate*(g+s.WeightDecay*parameters[i])
+		parameters[i] += s.velocity[i]
+	}
+}
+
+func (s *SGD) LearningRate() float64 {
+	return s.Rate
+}
+
+func (s *SGD) SetLearningRate(rate float64) {
+	s.Rate = rate
+}
+
+type jsonSGDState struct {
+	Velocity []float64 `json:"velocity,omitempty"`
+}
+
+// OptimizerState returns the momentum of the parameters
+func (s *SGD) OptimizerState() (json.RawMessage, error) {
+	return json.Marshal(jsonSGDState{Velocity: s.velocity})
+}
+
+func (s *SGD) SetOptimizerState(state json.RawMessage) error {
+	var j jsonSGDState
+	if err := json.Unmarshal(state, &j); err != nil {
+		return err
+	}
+	s.velocity = j.Velocity
+	return nil
+}
+
+// Adam is the Adam optimizer. WeightDecay, if non-zero, adds WeightDecay times
+// the parameters to the gradient, which is equivalent to an L2 penalty in the
+// loss. The zero values of Beta1, Beta2 and Epsilon are replaced by the
+// defaults 0.9, 0.999 and 1e-8.
+type Adam struct {
+	Rate        float64
+	Beta1       float64
+	Beta2       float64
+	Epsilon     float64
+	WeightDecay float64
+
+	adamState
+}
+
+func (a *Adam) Step(parameters, gradient []float64) {
+	a.step(parameters, gradient, a.Rate, a.Beta1, a.Beta2, a.Epsilon, a.WeightDecay, false)
+}
+
+func (a *Adam) LearningRate() float64 {
+	return a.Rate
+}
+
+func (a *Adam) SetLearningRate(rate float64) {
+	a.Rate = rate
+}
+
+// OptimizerState returns the moment estimates and the number of steps
+func (a *Adam) OptimizerState() (json.RawMessage, error) {
+	return a.marshalState()
+}
+
+func (a *Adam) SetOptimizerState(state json.RawMessage) error {
+	return a.unmarshalState(state)
+}
+
+// AdamW is the Adam optimizer with decoupled weight decay, where the
+// parameters are shrunk by Rate * WeightDecay times their value at every step
+// instead of the decay being added to the gradient. Unlike with Adam, the decay
+// is not rescaled by the adaptive step sizes, so all of the parameters decay
+// at the same rate. The zero values of Beta1, Beta2 and Epsilon are replaced by
+// the defaults 0.9, 0.999 and 1e-8.
+type AdamW struct {
+	Rate        float64
+	Beta1       float64
+	Beta2       float64
+	Epsilon     float64
+	WeightDecay float64
+
+	adamState
+}
+
+func (a *AdamW) Step(parameters, gradient []float64) {
+	a.step(parameters, gradient, a.Rate, a.Beta1, a.Beta2, a.Epsilon, a.WeightDecay, true)
+}
+
+func (a *AdamW) LearningRate() float64 {
+	return a.Rate
+}
+
+func (a *AdamW) SetLearningRate(rate float64) {
+	a.Rate = rate
+}
+
+// OptimizerState returns the moment estimates and the number of steps
+func (a *AdamW) OptimizerState() (json.RawMessage, error) {
+	return a.marshalState()
+}
+
+func (a *AdamW) SetOptimizerState(state json.RawMessage) error {
+	return a.unmarshalState(state)
+}
+
+// adamState holds the moment estimates of Adam and AdamW
+type adamState struct {
+	m []float64 // First moment
+	v []float64 // Second moment
+	t int       // Number of steps
+}
+
+type jsonAdamState struct {
+	M []float64 `json:"m,omitempty"`
+	V []float64 `json:"v,omitempty"`
+	T int       `json:"t"`
+}
+
+func (s *adamState) marshalState() (json.RawMessage, error) {
+	return json.Marshal(jsonAdamState{M: s.m, V: s.v, T: s.t})
+}
+
+func (s *adamState) unmarshalState(state json.RawMessage) error {
+	var j jsonAdamState
+	if err := json.Unmarshal(state, &j); err != nil {
+		return err
+	}
+	if len(j.M) != len(j.V) {
+		return errors.New("adam: moments of different lengths")
+	}
+	if j.T < 0 {
+		return errors.New("adam: negative number of steps")
+	}
+	s.m, s.v, s.t = j.M, j.V, j.T
+	return nil
+}
+
+func (s *adamState) step(parameters, gradient []float64, rate, beta1, beta2, eps, decay float64, decoupled bool) {
+	if beta1 == 0 {
+		beta1 = 0.9
+	}
+	if beta2 == 0 {
+		beta2 = 0.999
+	}
+	if eps == 0 {
+		eps = 1e-8
+	}
+	if len(s.m) != len(parameters) {
+		s.m = make([]float64, len(parameters))
+		s.v = make([]float64, len(parameters))
+		s.t = 0
+	}
+	s.t++
+	c1 := 1 - math.Pow(beta1, float64(s.t))
+	c2 := 1 - math.Pow(beta2, float64(s.t))
+	for i, g := range gradient {
+		if decay != 0 && !decoupled {
+			g += decay * parameters[i]
+		}
+		s.m[i] = beta1*s.m[i] + (1-beta1)*g
+		s.v[i] = beta2*s.v[i] + (1-beta2)*g*g
+		update := (s.m[i] / c1) / (math.Sqrt(s.v[i]/c2) + eps)
+		if decoupled {
+			update += decay * parameters[i]
+		}
+		parameters[i] -= rate * update
+	}
+}
+
+// Lookahead wraps another optimizer. The inner optimizer takes K fast steps,
+// after which the slow parameters are moved the fraction Alpha of the way to
+// the fast parameters, and the fast parameters are reset to the slow ones.
+// The zero values of K and Alpha are replaced by the defaults 5 and 0.5. The
+// learning rate is that of the inner optimizer.
+type Lookahead struct {
+	Inner Optimizer
+	K     int
+	Alpha float64
+
+	slow  []float64
+	count int
+}
+
+func (l *Lookahead) Step(parameters, gradient []float64) {
+	k := l.K
+	if k == 0 {
+		k = 5
+	}
+	alpha := l.Alpha
+	if alpha == 0 {
+		alpha = 0.5
+	}
+	if len(l.slow) != len(parameters) {
+		l.slow = append(l.slow[:0], parameters...)
+		l.count = 0
+	}
+	l.Inner.Step(parameters, gradient)
+	l.count++
+	if l.count%k != 0 {
+		return
+	}
+	for i, p := range parameters {
+		l.slow[i] += alpha * (p - l.slow[i])
+		parameters[i] = l.slow[i]
+	}
+}
+
+func (l *Lookahead) LearningRate() float64 {
+	return l.Inner.LearningRate()
+}
+
+func (l *Lookahead) SetLearningRate(rate float64) {
+	l.Inner.SetLearningRate(rate)
+}
+
+type jsonLookaheadState struct {
+	Slow  []float64       `json:"slow,omitempty"`
+	Count int             `json:"count"`
+	Inner json.RawMessage `json:"inner"`
+}
+
+// OptimizerState returns the slow parameters, the number of steps and the
+// state of the inner optimizer, which must be a StatefulOptimizer
+func (l *Lookahead) OptimizerState() (json.RawMessage, error) {
+	inner, ok := l.Inner.(StatefulOptimizer)
+	if !ok {
+		return nil, errors.New("lookahead: inner optimizer has no state")
+	}
+	state, err := inner.OptimizerState()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonLookaheadState{Slow: l.slow, Count: l.count, Inner: state})
+}
+
+func (l *Lookahead) SetOptimizerState(state json.RawMessage) error {
+	inner, ok := l.Inner.(StatefulOptimizer)
+	if !ok {
+		return errors.New("lookahead: inner optimizer has no state")
+	}
+	var j jsonLookaheadState
+	if err := json.Unmarshal(state, &j); err != nil {
+		return err
+	}
+	if err := inner.SetOptimizerState(j.Inner); err != nil {
+		return err
+	}
+	l.slow, l.count = j.Slow, j.Count
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestAdam(t *testing.T) {
+	// The first step of Adam moves every parameter by the rate in the direction
+	// opposite the gradient
+	params := []float64{1, -1, 2}
+	(&Adam{Rate: 0.1}).Step(params, []float64{3, -0.01, 0})
+	if !floatsEqualApprox(params, []float64{0.9, -0.9, 2}, 1e-6) {
+		t.Errorf("Wrong first Adam step. Found %v", params)
+	}
+
+	// With a zero gradient, AdamW decays the parameters proportionally, while
+	// the coupled decay of Adam is normalized to a step of the rate
+	params = []float64{1, 10}
+	(&AdamW{Rate: 0.1, WeightDecay: 0.5}).Step(params, []float64{0, 0})
+	if !floatsEqualApprox(params, []float64{0.95, 9.5}, 1e-12) {
+		t.Errorf("Wrong AdamW decay. Found %v", params)
+	}
+	params = []float64{1, 10}
+	(&Adam{Rate: 0.1, WeightDecay: 0.5}).Step(params, []float64{0, 0})
+	if !floatsEqualApprox(params, []float64{0.9, 9.9}, 1e-6) {
+		t.Errorf("Wrong Adam decay. Found %v", params)
+	}
+}
+
+func TestOptimizersMinimize(t *testing.T) {
+	// Minimize a badly scaled quadratic
+	scales := []float64{1, 100}
+	for _, test := range []struct {
+		name string
+		opt  Optimizer
+	}{
+		{"SGD", &SGD{Rate: 0.005}},
+		{"Momentum", &SGD{Rate: 0.002, Momentum: 0.9}},
+		{"Adam", &Adam{Rate: 0.05}},
+		{"AdamW", &AdamW{Rate: 0.05, WeightDecay: 1e-4}},
+		{"Lookahead", &Lookahead{Inner: &Adam{Rate: 0.05}}},
+	} {
+		params := []float64{1, 1}
+		grad := make([]float64, 2)
+		for i := 0; i < 2000; i++ {
+			for j, s := range scales {
+				grad[j] = 2 * s * params[j]
+			}
+			test.opt.Step(params, grad)
+		}
+		if math.Abs(params[0]) > 1e-2 || math.Abs(params[1]) > 1e-2 {
+			t.Errorf("%v: did not converge. Found %v", test.name, params)
+		}
+	}
+}
+
+func TestPartialFitAdam(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	inputs, targets := NoisySine(200, 0.05, rnd)
+	trainer, err := NewSimpleTrainer(1, 1, 1, 10, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trainer.SetRand(rnd)
+	trainer.RandomizeParameters()
+	trainer.SetOptimizer(&AdamW{Rate: 0.01, WeightDecay: 1e-4})
+	first, err := trainer.PartialFit(inputs, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 300; i++ {
+		if _, err := trainer.PartialFit(inputs, targets); err != nil {
+			t.Fatal(err)
+		}
+	}
+	last, _, err := trainer.lossGradient(inputs, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last > first/10 {
+		t.Errorf("Loss not reduced enough. First %v, last %v", first, last)
+	}
+}
+
+func TestLookahead(t *testing.T) {
+	// With a constant gradient, the inner SGD moves by -rate*k over k steps,
+	// and the slow parameters move by alpha of that
+	l := &Lookahead{Inner: &SGD{Rate: 1}, K: 2, Alpha: 0.5}
+	params := []float64{0}
+	grad := []float64{1}
+	var found []float64
+	for i := 0; i < 4; i++ {
+		l.Step(params, grad)
+		found = append(found, params[0])
+	}
+	want := []float64{-1, -1, -2, -2}
+	if !floatsEqual(found, want) {
+		t.Errorf("Wrong lookahead steps. Expected %v, found %v", want, found)
+	}
+	l.SetLearningRate(0.5)
+	if l.LearningRate() != 0.5 || l.Inner.LearningRate() != 0.5 {
+		t.Errorf("Learning rate not forwarded to the inner optimizer")
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"errors"
+	"math"
+	"strconv"
+)
+
+// CumulativeLogit is the loss of an ordinal regression, where the targets are
+// ordered categories such as ratings rather than real values or unordered
+// classes. For k categories the prediction has k elements: a latent score f
+// followed by k-1 raw threshold values. The thresholds are
+//
+//	θ_1 = raw_1,  θ_j = θ_{j-1} + softplus(raw_j)
+//
+// so they are always increasing, and the probability that the category is at
+// most j is sigmoid(θ_{j+1} - f) for categories counted from zero. The truth
+// is the index of the category, and the loss is the negative log of its
+// probability.
+type CumulativeLogit struct{}
+
+func (CumulativeLogit) LossDeriv(prediction, truth, dLossDPred []float64) float64 {
+	nCategories := len(prediction)
+	k := int(truth[0])
+	if float64(k) != truth[0] || k < 0 || k >= nCategories {
+		panic("cumulative logit: category " + strconv.FormatFloat(truth[0], 'g', -1, 64) + " out of range")
+	}
+	f := prediction[0]
+	raw := prediction[1:]
+	thresholds := ordinalThresholds(raw, make([]float64, len(raw)))
+	dThresholds := dLossDPred[1:]
+	for i := range dThresholds {
+		dThresholds[i] = 0
+	}
+
+	// The category lies between the lower and upper thresholds, which are
+	// thresholds[k-1] and thresholds[k] if they exist
+	var loss, dF float64
+	switch {
+	case nCategories == 1:
+	case k == 0:
+		u := thresholds[0] - f
+		loss = softplus(-u)
+		du := -(1 - Sigmoid{}.Activate(u))
+		dThresholds[0] = du
+		dF = -du
+	case k == nCategories-1:
+		l := thresholds[k-1] - f
+		loss = softplus(l)
+		dl := Sigmoid{}.Activate(l)
+		dThresholds[k-1] = dl
+		dF = -dl
+	default:
+		u, l := thresholds[k]-f, thresholds[k-1]-f
+		su, sl := Sigmoid{}.Activate(u), Sigmoid{}.Activate(l)
+		p := math.Max(su-sl, math.SmallestNonzeroFloat64)
+		loss = -math.Log(p)
+		du := -su * (1 - su) / p
+		dl := sl * (1 - sl) / p
+		dThresholds[k] = du
+		dThresholds[k-1] = dl
+		dF = -du - dl
+	}
+	dLossDPred[0] = dF
+
+	// Chain rule from the thresholds to the raw values. Raw value j shifts all
+	// of the thresholds from j on.
+	var sum float64
+	for j := len(raw) - 1; j >= 0; j-- {
+		sum += dThresholds[j]
+		if j == 0 {
+			dThresholds[j] = sum
+		} else {
+			dThresholds[j] = sum * Sigmoid{}.Activate(raw[j])
+		}
+	}
+	return loss
+}
+
+// TargetDim returns one, as the truth is the index of the category
+func (CumulativeLogit) TargetDim(outputDim int) int {
+	return 1
+}
+
+// CheckTargets returns an error if a target is not the index of one of the
+// outputDim categories
+func (CumulativeLogit) CheckTargets(outputDim int, targets RowMatrix) error {
+	nTargets, _ := targets.Dims()
+	truth := make([]float64, 1)
+	for i := 0; i < nTargets; i++ {
+		truth = targets.Row(truth, i)
+		if k := int(truth[0]); float64(k) != truth[0] || k < 0 || k >= outputDim {
+			return errors.New("cumulative logit: row " + strconv.Itoa(i) + ": category " + strconv.FormatFloat(truth[0], 'g', -1, 64) + " out of range")
+		}
+	}
+	return nil
+}
+
+func (CumulativeLogit) String() string {
+	return "CumulativeLogit"
+}
+
+// ordinalThresholds computes the increasing thresholds from their raw values
+// and stores them into dst
+func ordinalThresholds(raw, dst []float64) []float64 {
+	for j, r := range raw {
+		if j == 0 {
+			dst[j] = r
+		} else {
+			dst[j] = dst[j-1] + softplus(r)
+		}
+	}
+	return dst
+}
+
+// ordinalProbabilities computes the probability of every category from the
+// prediction of a CumulativeLogit net and stores them into probs
+func ordinalProbabilities(prediction, probs []float64) {
+	f := prediction[0]
+	raw := prediction[1:]
+	prev := 0.0
+	var threshold float64
+	for j := range raw {
+		if j == 0 {
+			threshold = raw[j]
+		} else {
+			threshold += softplus(raw[j])
+		}
+		c := Sigmoid{}.Activate(threshold - f)
+		probs[j] = c - prev
+		prev = c
+	}
+	probs[len(probs)-1] = 1 - prev
+}
+
+// OrdinalTrainer is a net for ordinal regression. The output layer has a
+// linear neuron for the latent score followed by a ConstantNeuron for each
+// threshold between the categories, and the net is trained with
+// CumulativeLogit, so the targets given to PartialFit and TrainEpoch are the
+// indices of the categories.
+type OrdinalTrainer struct {
+	*Trainer
+	nCategories int
+}
+
+// NewOrdinalTrainer constructs an ordinal regression net for the given number
+// of categories with nHiddenLayers layers of tanh neurons
+func NewOrdinalTrainer(inputDim, nCategories, nHiddenLayers, nNeuronsPerLayer int) (*OrdinalTrainer, error) {
+	if nCategories < 2 {
+		return nil, errors.New("ordinal: need at least two categories")
+	}
+	if nNeuronsPerLayer <= 0 {
+		return nil, errors.New("non-positive number of neurons per layer")
+	}
+	neurons := make([][]Neuron, nHiddenLayers+1)
+	for i := 0; i < nHiddenLayers; i++ {
+		neurons[i] = make([]Neuron, nNeuronsPerLayer)
+		for j := range neurons[i] {
+			neurons[i][j] = TanhNeuron
+		}
+	}
+	output := []Neuron{LinearNeuron}
+	for j := 1; j < nCategories; j++ {
+		output = append(output, ConstantNeuron{})
+	}
+	neurons[nHiddenLayers] = output
+	trainer, err := NewTrainer(inputDim, nCategories, neurons)
+	if err != nil {
+		return nil, err
+	}
+	trainer.SetLoss(CumulativeLogit{})
+	return &OrdinalTrainer{
+		Trainer:     trainer,
+		nCategories: nCategories,
+	}, nil
+}
+
+// NumCategories returns the number of ordered categories
+func (o *OrdinalTrainer) NumCategories() int {
+	return o.nCategories
+}
+
+// Thresholds returns the increasing thresholds of the latent score between
+// the categories. If dst is nil, new memory is allocated.
+func (o *OrdinalTrainer) Thresholds(dst []float64) []float64 {
+	if dst == nil {
+		dst = make([]float64, o.nCategories-1)
+	}
+	if len(dst) != o.nCategories-1 {
+		panic(dimensionError("thresholds", ErrOutputDim, o.nCategories-1, len(dst)))
+	}
+	last := o.NumLayers() - 1
+	raw := make([]float64, o.nCategories-1)
+	for j := range raw {
+		raw[j] = o.parameters[last][j+1][0]
+	}
+	return ordinalThresholds(raw, dst)
+}
+
+// PredictProbabilities predicts the probability of every category at every row
+// of inputs. If probs is nil, a new matrix is allocated.
+func (o *OrdinalTrainer) PredictProbabilities(inputs RowMatrix, probs MutableRowMatrix) (MutableRowMatrix, error) {
+	nSamples, _ := inputs.Dims()
+	if probs == nil {
+		s := make(SosMatrix, nSamples)
+		for i := range s {
+			s[i] = make([]float64, o.nCategories)
+		}
+		probs = s
+	} else {
+		r, c := probs.Dims()
+		if c != o.nCategories {
+			return probs, dimensionError("ordinal", ErrOutputDim, o.nCategories, c)
+		}
+		if r != nSamples {
+			return probs, dimensionError("ordinal", ErrRows, nSamples, r)
+		}
+	}
+	outputs, err := o.PredictBatch(inputs, nil)
+	if err != nil {
+		return probs, err
+	}
+	p := make([]float64, o.nCategories)
+	for i, row := range outputs.(SosMatrix) {
+		ordinalProbabilities(row, p)
+		probs.SetRow(i, p)
+	}
+	return probs, nil
+}
+
+// PredictCategories predicts the most probable category at every row of
+// inputs. If categories is nil, new memory is allocated.
+func (o *OrdinalTrainer) PredictCategories(inputs RowMatrix, categories []int) ([]int, error) {
+	nSamples, _ := inputs.Dims()
+	if categories == nil {
+		categories = make([]int, nSamples)
+	}
+	if len(categories) != nSamples {
+		return categories, dimensionError("ordinal", ErrRows, nSamples, len(categories))
+	}
+	probs, err := o.PredictProbabilities(inputs, nil)
+	if err != nil {
+		return categories, err
+	}
+	for i, p := range probs.(SosMatrix) {
+		best := 0
+		for j, v := range p {
+			if v > p[best] {
+				best = j
+			}
+		}
+		categories[i] = best
+	}
+	return categories, nil
+}
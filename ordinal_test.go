@@ -0,0 +1,110 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestCumulativeLogit(t *testing.T) {
+	prediction := []float64{0.3, -1, 0.2, -0.5}
+	for k := 0; k < 4; k++ {
+		testLossDeriv(t, "CumulativeLogit", CumulativeLogit{}, prediction, []float64{float64(k)})
+	}
+	// The loss is the negative log of the probability of the category
+	probs := make([]float64, 4)
+	ordinalProbabilities(prediction, probs)
+	var sum float64
+	for k, p := range probs {
+		sum += p
+		loss := CumulativeLogit{}.LossDeriv(prediction, []float64{float64(k)}, make([]float64, 4))
+		if math.Abs(loss+math.Log(p)) > 1e-14 {
+			t.Errorf("Category %v: wrong loss. Expected %v, found %v", k, -math.Log(p), loss)
+		}
+	}
+	if math.Abs(sum-1) > 1e-14 {
+		t.Errorf("Probabilities sum to %v", sum)
+	}
+}
+
+func TestOrdinalTrainer(t *testing.T) {
+	o, err := NewOrdinalTrainer(2, 4, 1, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.OutputDim() != 4 || o.NumCategories() != 4 || o.LayerDescription(1) != "Sum(Linear), Constant" {
+		t.Errorf("Wrong net %v", o.LayerDescription(1))
+	}
+	rnd := rand.New(rand.NewSource(1))
+	o.SetRand(rnd)
+	o.RandomizeParameters()
+	o.SetOptimizer(&Adam{Rate: 0.02})
+
+	// The category is the latent value x0 + x1 cut at -1, 0 and 1
+	category := func(x []float64) int {
+		return sort.SearchFloat64s([]float64{-1, 0, 1}, x[0]+x[1])
+	}
+	nSamples := 400
+	inputs := RandomMat(nSamples, 2, rnd.NormFloat64)
+	targets := make(SosMatrix, nSamples)
+	for i, x := range inputs {
+		targets[i] = []float64{float64(category(x))}
+	}
+	for i := 0; i < 50; i++ {
+		if _, err := o.TrainEpoch(inputs, targets, 20, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if thresholds := o.Thresholds(nil); !sort.Float64sAreSorted(thresholds) {
+		t.Errorf("Thresholds not increasing: %v", thresholds)
+	}
+
+	test := RandomMat(200, 2, rnd.NormFloat64)
+	categories, err := o.PredictCategories(test, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var correct int
+	for i, x := range test {
+		if categories[i] == category(x) {
+			correct++
+		}
+	}
+	if correct < 160 {
+		t.Errorf("Only %v of 200 categories correct", correct)
+	}
+
+	// The thresholds are stored with the net
+	var buf bytes.Buffer
+	if err := WriteNet(&buf, o.Net); err != nil {
+		t.Fatal(err)
+	}
+	net, err := ReadNet(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, x := range test[:10] {
+		want, _ := o.Predict(x, nil)
+		got, _ := net.Predict(x, nil)
+		if !floatsEqual(got, want) {
+			t.Errorf("Prediction %v changed by encoding", i)
+		}
+	}
+
+	// Invalid categories are an error rather than a panic
+	for _, category := range []float64{-1, 4, 1.5, math.NaN()} {
+		targets[3] = []float64{category}
+		if _, err := o.PartialFit(inputs, targets); err == nil {
+			t.Errorf("Expected error for category %v", category)
+		}
+		if _, err := o.TrainEpoch(inputs, targets, 20, nil); err == nil {
+			t.Errorf("Expected error for category %v", category)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+)
+
+// ParameterGroup is a set of layers of a net trained by their own optimizer,
+// so that, for example, a pretrained trunk can be trained with a smaller
+// learning rate and weight decay than a new head.
+type ParameterGroup struct {
+	Layers    []int
+	Optimizer Optimizer
+}
+
+// GroupOptimizer is an optimizer which applies a different optimizer to each
+// group of layers of a net. The learning rate of the GroupOptimizer is that
+// of the default optimizer, which is used for the layers not in any group.
+// Setting the learning rate, for example with a Schedule, scales the rates of
+// all of the groups by the same factor, keeping their ratios to the default
+// rate.
+type GroupOptimizer struct {
+	groups []groupState
+	rate   float64
+}
+
+type groupState struct {
+	opt    Optimizer
+	ratio  float64  // ratio of the learning rate to that of the default
+	ranges [][2]int // ranges of the flat parameters in the group
+
+	params []float64
+	grad   []float64
+}
+
+// NewGroupOptimizer returns the optimizer for the parameters of the net
+// training the given groups of layers with their optimizers and the rest of
+// the layers with def. The learning rate of def must be positive. A layer can
+// be in at most one group.
+func NewGroupOptimizer(n *Net, def Optimizer, groups ...ParameterGroup) (*GroupOptimizer, error) {
+	rate := def.LearningRate()
+	if rate <= 0 {
+		return nil, errors.New("group optimizer: non-positive default learning rate")
+	}
+	owner := make([]int, n.NumLayers())
+	for i := range owner {
+		owner[i] = -1
+	}
+	for i, g := range groups {
+		for _, l := range g.Layers {
+			if l < 0 || l >= len(owner) {
+				return nil, errors.New("group optimizer: layer " + strconv.Itoa(l) + " out of range")
+			}
+			if owner[l] != -1 {
+				return nil, errors.New("group optimizer: layer " + strconv.Itoa(l) + " in more than one group")
+			}
+			owner[l] = i
+		}
+	}
+	g := &GroupOptimizer{
+		groups: make([]groupState, len(groups)+1),
+		rate:   rate,
+	}
+	g.groups[0] = groupState{opt: def, ratio: 1}
+	for i, group := range groups {
+		g.groups[i+1] = groupState{opt: group.Optimizer, ratio: group.Optimizer.LearningRate() / rate}
+	}
+	var start int
+	for l, o := range owner {
+		end := start + n.LayerNumParameters(l)
+		state := &g.groups[o+1]
+		state.ranges = append(state.ranges, [2]int{start, end})
+		start = end
+	}
+	return g, nil
+}
+
+func (g *GroupOptimizer) Step(parameters, gradient []float64) {
+	for i := range g.groups {
+		state := &g.groups[i]
+		if len(state.ranges) == 0 {
+			continue
+		}
+		state.params = state.params[:0]
+		state.grad = state.grad[:0]
+		for _, r := range state.ranges {
+			state.params = append(state.params, parameters[r[0]:r[1]]...)
+			state.grad = append(state.grad, gradient[r[0]:r[1]]...)
+		}
+		state.opt.Step(state.params, state.grad)
+		var idx int
+		for _, r := range state.ranges {
+			idx += copy(parameters[r[0]:r[1]], state.params[idx:])
+		}
+	}
+}
+
+func (g *GroupOptimizer) LearningRate() float64 {
+	return g.rate
+}
+
+func (g *GroupOptimizer) SetLearningRate(rate float64) {
+	g.rate = rate
+	for _, state := range g.groups {
+		state.opt.SetLearningRate(rate * state.ratio)
+	}
+}
+
+// OptimizerState returns the states of the optimizers of the groups, which
+// must all be StatefulOptimizers
+func (g *GroupOptimizer) OptimizerState() (json.RawMessage, error) {
+	states := make([]json.RawMessage, len(g.groups))
+	for i, state := range g.groups {
+		opt, ok := state.opt.(StatefulOptimizer)
+		if !ok {
+			return nil, errors.New("group optimizer: optimizer of group " + strconv.Itoa(i) + " has no state")
+		}
+		var err error
+		if states[i], err = opt.OptimizerState(); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(states)
+}
+
+func (g *GroupOptimizer) SetOptimizerState(state json.RawMessage) error {
+	var states []json.RawMessage
+	if err := json.Unmarshal(state, &states); err != nil {
+		return err
+	}
+	if len(states) != len(g.groups) {
+		return errors.New("group optimizer: state for " + strconv.Itoa(len(states)) + " groups, expected " + strconv.Itoa(len(g.groups)))
+	}
+	for i, state := range g.groups {
+		opt, ok := state.opt.(StatefulOptimizer)
+		if !ok {
+			return errors.New("group optimizer: optimizer of group " + strconv.Itoa(i) + " has no state")
+		}
+		if err := opt.SetOptimizerState(states[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
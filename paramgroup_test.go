@@ -0,0 +1,83 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import "testing"
+
+func TestGroupOptimizer(t *testing.T) {
+	trainer, err := NewSimpleTrainer(2, 1, 2, 3, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trainer.RandomizeParameters()
+	n0, n1 := trainer.LayerNumParameters(0), trainer.LayerNumParameters(1)
+
+	trunk := &SGD{Rate: 0.001, WeightDecay: 1}
+	head := &SGD{Rate: 0.1}
+	g, err := NewGroupOptimizer(trainer.Net, &SGD{Rate: 0.01},
+		ParameterGroup{Layers: []int{0}, Optimizer: trunk},
+		ParameterGroup{Layers: []int{2}, Optimizer: head},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.LearningRate() != 0.01 {
+		t.Errorf("Wrong learning rate %v", g.LearningRate())
+	}
+
+	check := func(rates []float64, decay float64) {
+		before := trainer.Parameters(nil)
+		params := trainer.Parameters(nil)
+		grad := make([]float64, len(params))
+		for i := range grad {
+			grad[i] = 1
+		}
+		g.Step(params, grad)
+		for i := range params {
+			rate, wd := rates[2], 0.0
+			if i < n0 {
+				rate, wd = rates[0], decay
+			} else if i < n0+n1 {
+				rate = rates[1]
+			}
+			want := before[i] - rate*(1+wd*before[i])
+			if !floatsEqualApprox([]float64{params[i]}, []float64{want}, 1e-12) {
+				t.Fatalf("Parameter %v: expected %v, found %v", i, want, params[i])
+			}
+		}
+	}
+	check([]float64{0.001, 0.01, 0.1}, 1)
+
+	// Setting the learning rate keeps the ratios between the groups
+	g.SetLearningRate(0.02)
+	if !floatsEqualApprox([]float64{g.LearningRate(), trunk.Rate, head.Rate}, []float64{0.02, 0.002, 0.2}, 1e-15) {
+		t.Errorf("Wrong learning rates %v, %v, %v", g.LearningRate(), trunk.Rate, head.Rate)
+	}
+	check([]float64{0.002, 0.02, 0.2}, 1)
+
+	if _, err := NewGroupOptimizer(trainer.Net, &SGD{Rate: 0.01}, ParameterGroup{Layers: []int{3}, Optimizer: head}); err == nil {
+		t.Errorf("Expected error for layer out of range")
+	}
+	if _, err := NewGroupOptimizer(trainer.Net, &SGD{Rate: 0.01},
+		ParameterGroup{Layers: []int{1}, Optimizer: head},
+		ParameterGroup{Layers: []int{1, 2}, Optimizer: trunk},
+	); err == nil {
+		t.Errorf("Expected error for layer in two groups")
+	}
+	if _, err := NewGroupOptimizer(trainer.Net, &SGD{}); err == nil {
+		t.Errorf("Expected error for zero default learning rate")
+	}
+
+	// The group optimizer works with the trainer's schedule
+	trainer.SetOptimizer(g)
+	trainer.SetSchedule(LinearDecay{Start: 0.05, End: 0, Steps: 10})
+	inputs := RandomMat(5, 2, func() float64 { return 1 })
+	if _, err := trainer.PartialFit(inputs, RandomMat(5, 1, func() float64 { return 0 })); err != nil {
+		t.Fatal(err)
+	}
+	if !floatsEqualApprox([]float64{trunk.Rate, head.Rate}, []float64{0.005, 0.5}, 1e-15) {
+		t.Errorf("Wrong scheduled rates %v and %v", trunk.Rate, head.Rate)
+	}
+}
@@ -5,32 +5,287 @@
 package nnet
 
 import (
+	"context"
+	"math"
 	"runtime"
+	"strconv"
 	"sync"
 	"sync/atomic"
 )
 
 // ParallelFor computes the function f in parallel
 func ParallelFor(n, grain int, f func(start, end int)) {
+	ParallelForCtx(context.Background(), n, grain, f)
+}
+
+// ParallelForCtx computes the function f in parallel, checking for cancellation of
+// the context before each chunk of grain items. If the context is done, the
+// remaining chunks are not started and the context's error is returned once the
+// chunks already in progress finish. If every chunk was computed before the
+// cancellation took effect, nil is returned. The chunks are divided among the workers
+// dynamically (see Partition).
+//
+// If only one goroutine can run at a time (GOMAXPROCS is one), or the package
+// is built for a single-threaded environment (see sequential), the chunks are
+// computed in order in the calling goroutine.
+func ParallelForCtx(ctx context.Context, n, grain int, f func(start, end int)) error {
+	return ParallelForPartition(ctx, n, grain, Dynamic, f)
+}
+
+// Partition is a strategy for dividing the chunks of a parallel loop among the
+// workers
+type Partition int
+
+const (
+	// Dynamic hands out the chunks in order from a shared counter, so workers
+	// which finish early take more chunks. This balances the load, but with
+	// small chunks and many workers the counter becomes contended.
+	Dynamic Partition = iota
+
+	// Contiguous gives each worker one contiguous range of chunks computed
+	// before the loop starts, so the workers share no state. This has the least
+	// overhead when the chunks are small and all take about the same time.
+	Contiguous
+
+	// Stealing starts with the ranges of Contiguous, but a worker which
+	// finishes its range takes half of the remaining chunks of another worker.
+	// This keeps the low overhead of Contiguous when the chunks take about the
+	// same time, and avoids waiting for stragglers when the cost of the items
+	// varies, such as with sparse inputs or models which exit early.
+	Stealing
+)
+
+func (p Partition) String() string {
+	switch p {
+	case Dynamic:
+		return "Dynamic"
+	case Contiguous:
+		return "Contiguous"
+	case Stealing:
+		return "Stealing"
+	}
+	return "Partition(" + strconv.Itoa(int(p)) + ")"
+}
+
+// ParallelForPartition is like ParallelForCtx but divides the chunks among the
+// workers according to part. With every partition, the chunks start at
+// multiples of grain.
+func ParallelForPartition(ctx context.Context, n, grain int, part Partition, f func(start, end int)) error {
 	P := runtime.GOMAXPROCS(0)
-	idx := uint64(0)
+	if sequential {
+		P = 1
+	}
+	atomic.StoreInt64(&statWorkers, int64(P))
+	atomic.StoreInt64(&statGrainSize, int64(grain))
+	// Count the items computed, so that a cancellation after the last chunk
+	// started is not reported as an error
+	var computed int64
+	g := f
+	f = func(start, end int) {
+		g(start, end)
+		atomic.AddInt64(&computed, int64(end-start))
+	}
+	if P == 1 {
+		for start := 0; start < n; start += grain {
+			if ctx.Err() != nil {
+				break
+			}
+			f(start, chunkEnd(start, grain, n))
+		}
+		return skippedErr(ctx, computed, n)
+	}
 	var wg sync.WaitGroup
-	wg.Add(P)
-	for p := 0; p < P; p++ {
-		go func() {
-			for {
-				start := int(atomic.AddUint64(&idx, uint64(grain))) - grain
-				if start >= n {
-					break
+	switch part {
+	default:
+		panic("nnet: unknown partition " + part.String())
+	case Dynamic:
+		idx := uint64(0)
+		wg.Add(P)
+		for p := 0; p < P; p++ {
+			go func() {
+				for {
+					if ctx.Err() != nil {
+						break
+					}
+					start := int(atomic.AddUint64(&idx, uint64(grain))) - grain
+					if start >= n {
+						break
+					}
+					f(start, chunkEnd(start, grain, n))
 				}
-				end := start + grain
-				if end > n {
-					end = n
+				wg.Done()
+			}()
+		}
+	case Contiguous:
+		nChunks := (n + grain - 1) / grain
+		if P > nChunks {
+			P = nChunks
+		}
+		wg.Add(P)
+		for p := 0; p < P; p++ {
+			first, last := p*nChunks/P, (p+1)*nChunks/P
+			go func() {
+				for c := first; c < last; c++ {
+					if ctx.Err() != nil {
+						break
+					}
+					start := c * grain
+					f(start, chunkEnd(start, grain, n))
 				}
-				f(start, end)
-			}
-			wg.Done()
-		}()
+				wg.Done()
+			}()
+		}
+	case Stealing:
+		nChunks := (n + grain - 1) / grain
+		if P > nChunks {
+			P = nChunks
+		}
+		ranges := make(chunkRanges, P)
+		for p := range ranges {
+			ranges[p].next, ranges[p].last = p*nChunks/P, (p+1)*nChunks/P
+		}
+		wg.Add(P)
+		for p := 0; p < P; p++ {
+			go func() {
+				for ctx.Err() == nil {
+					c, ok := ranges[p].take()
+					if !ok && !ranges.steal(p) {
+						break
+					}
+					if !ok {
+						continue
+					}
+					start := c * grain
+					f(start, chunkEnd(start, grain, n))
+				}
+				wg.Done()
+			}()
+		}
 	}
 	wg.Wait()
+	return skippedErr(ctx, atomic.LoadInt64(&computed), n)
+}
+
+// skippedErr returns the error of the context if fewer than n items were
+// computed
+func skippedErr(ctx context.Context, computed int64, n int) error {
+	if computed >= int64(n) {
+		return nil
+	}
+	return ctx.Err()
+}
+
+// chunkRange is the range of chunks [next, last) left to a worker of a loop
+// with the Stealing partition
+type chunkRange struct {
+	mux  sync.Mutex
+	next int
+	last int
+}
+
+// take removes the next chunk from the range
+func (r *chunkRange) take() (int, bool) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.next == r.last {
+		return 0, false
+	}
+	r.next++
+	return r.next - 1, true
+}
+
+type chunkRanges []chunkRange
+
+// steal moves half of the remaining chunks of another worker, rounded up, to the
+// empty range of worker p. It returns false if all of the ranges are empty.
+func (ranges chunkRanges) steal(p int) bool {
+	for k := 1; k < len(ranges); k++ {
+		victim := &ranges[(p+k)%len(ranges)]
+		victim.mux.Lock()
+		remaining := victim.last - victim.next
+		if remaining == 0 {
+			victim.mux.Unlock()
+			continue
+		}
+		mid := victim.last - (remaining+1)/2
+		first, last := mid, victim.last
+		victim.last = mid
+		victim.mux.Unlock()
+
+		r := &ranges[p]
+		r.mux.Lock()
+		r.next, r.last = first, last
+		r.mux.Unlock()
+		return true
+	}
+	return false
+}
+
+// chunkEnd returns the end of the chunk of grain items starting at start in a
+// loop over n items
+func chunkEnd(start, grain, n int) int {
+	end := start + grain
+	if end > n {
+		end = n
+	}
+	return end
+}
+
+// combinedGrainSize returns the grain size for a sample which is evaluated by
+// all of the predictors. If the predictors know their own grain size, the cost
+// of a sample is roughly the sum of their costs. Otherwise, assume each sample
+// is expensive.
+func combinedGrainSize(predictors []Predictor) int {
+	var invGrain float64
+	for _, p := range predictors {
+		g, ok := p.(interface {
+			GrainSize() int
+		})
+		if !ok {
+			return 1
+		}
+		invGrain += 1 / float64(g.GrainSize())
+	}
+	return int(math.Ceil(1 / invGrain))
+}
+
+// parallelSum computes the sum of vector-valued contributions from n items in
+// parallel and stores the result into sum. f adds the contributions of the items
+// in [start, end) to its sum argument, which is zero when f is called.
+//
+// If deterministic is true, the partial sum of each chunk of grain items is kept
+// and the partial sums are added in chunk order, so the result is bit-for-bit
+// identical regardless of GOMAXPROCS or scheduling. This uses memory proportional
+// to the number of chunks. Otherwise, the partial sums are added as the chunks
+// finish.
+func parallelSum(n, grain int, deterministic bool, sum []float64, f func(start, end int, sum []float64)) {
+	for i := range sum {
+		sum[i] = 0
+	}
+	if deterministic {
+		nChunks := (n + grain - 1) / grain
+		partials := make([][]float64, nChunks)
+		ParallelFor(n, grain, func(start, end int) {
+			partial := make([]float64, len(sum))
+			f(start, end, partial)
+			// Chunks start at multiples of grain
+			partials[start/grain] = partial
+		})
+		for _, partial := range partials {
+			for i, v := range partial {
+				sum[i] += v
+			}
+		}
+		return
+	}
+	var mux sync.Mutex
+	ParallelFor(n, grain, func(start, end int) {
+		partial := make([]float64, len(sum))
+		f(start, end, partial)
+		mux.Lock()
+		for i, v := range partial {
+			sum[i] += v
+		}
+		mux.Unlock()
+	})
 }
@@ -0,0 +1,11 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//go:build !js && !wasip1 && !nnet_sequential
+
+package nnet
+
+// sequential is false, so parallel loops use GOMAXPROCS workers. See
+// parfor_sequential.go.
+const sequential = false
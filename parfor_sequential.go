@@ -0,0 +1,13 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//go:build js || wasip1 || nnet_sequential
+
+package nnet
+
+// sequential is true when the package is built for a single-threaded
+// environment, such as GOOS=js or wasip1, or with the nnet_sequential build
+// tag. Parallel loops then run in the calling goroutine without spawning
+// workers.
+const sequential = true
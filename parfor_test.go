@@ -0,0 +1,243 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"context"
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParallelForSequential(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+	// With one worker the chunks are computed in order, so no synchronization
+	// is needed
+	var starts []int
+	covered := make([]int, 10)
+	ParallelFor(10, 3, func(start, end int) {
+		starts = append(starts, start)
+		for i := start; i < end; i++ {
+			covered[i]++
+		}
+	})
+	if !equalInts(starts, []int{0, 3, 6, 9}) {
+		t.Errorf("Wrong chunks %v", starts)
+	}
+	for i, c := range covered {
+		if c != 1 {
+			t.Errorf("Item %v computed %v times", i, c)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var n int
+	err := ParallelForCtx(ctx, 10, 3, func(start, end int) {
+		n++
+		cancel()
+	})
+	if err != context.Canceled || n != 1 {
+		t.Errorf("Expected one chunk and context.Canceled, found %v chunks and %v", n, err)
+	}
+
+	// Canceling during the last chunk skips nothing, so there is no error
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	err = ParallelForCtx(ctx, 10, 3, func(start, end int) {
+		if end == 10 {
+			cancel()
+		}
+	})
+	if err != nil {
+		t.Errorf("Expected no error when every chunk is computed, found %v", err)
+	}
+}
+
+func TestParallelForPartition(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	for _, part := range []Partition{Dynamic, Contiguous, Stealing} {
+		for _, test := range []struct{ n, grain int }{
+			{0, 3}, {1, 3}, {10, 3}, {100, 1}, {1000, 7},
+		} {
+			var mux sync.Mutex
+			covered := make([]int, test.n)
+			err := ParallelForPartition(context.Background(), test.n, test.grain, part, func(start, end int) {
+				if start%test.grain != 0 || end-start > test.grain {
+					t.Errorf("%v: wrong chunk [%v, %v) for grain %v", part, start, end, test.grain)
+				}
+				mux.Lock()
+				for i := start; i < end; i++ {
+					covered[i]++
+				}
+				mux.Unlock()
+			})
+			if err != nil {
+				t.Errorf("%v: unexpected error %v", part, err)
+			}
+			for i, c := range covered {
+				if c != 1 {
+					t.Errorf("%v: n = %v, grain = %v: item %v computed %v times", part, test.n, test.grain, i, c)
+				}
+			}
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := ParallelForPartition(ctx, 100, 1, part, func(start, end int) {
+			t.Errorf("%v: chunk computed after cancellation", part)
+		})
+		if err != context.Canceled {
+			t.Errorf("%v: expected context.Canceled, found %v", part, err)
+		}
+
+		ctx, cancel = context.WithCancel(context.Background())
+		var computed int64
+		err = ParallelForPartition(ctx, 100, 1, part, func(start, end int) {
+			if atomic.AddInt64(&computed, 1) == 100 {
+				cancel()
+			}
+		})
+		if err != nil {
+			t.Errorf("%v: unexpected error after computing every chunk: %v", part, err)
+		}
+	}
+}
+
+func TestParallelForStealing(t *testing.T) {
+	if sequential {
+		t.Skip("loops are sequential")
+	}
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	// The first chunk waits until all of the others are done, which only
+	// happens if the other workers take the rest of the range of its worker
+	n := 100
+	var done int64
+	allDone := make(chan struct{})
+	err := ParallelForPartition(context.Background(), n, 1, Stealing, func(start, end int) {
+		if start == 0 {
+			select {
+			case <-allDone:
+			case <-time.After(10 * time.Second):
+				t.Errorf("chunks of a blocked worker not stolen")
+			}
+			return
+		}
+		if atomic.AddInt64(&done, 1) == int64(n-1) {
+			close(allDone)
+		}
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPredictBatchPartition(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	s, err := NewSimpleTrainer(3, 2, 2, 4, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.RandomizeParameters()
+	s.HackGrainSize(2)
+	inputs := RandomMat(101, 3, rand.NormFloat64)
+	want, _ := s.PredictBatch(inputs, nil)
+	for _, part := range []Partition{Contiguous, Stealing} {
+		s.SetPartition(part)
+		if s.Partition() != part || s.Clone().Partition() != part || s.Freeze().Partition() != part {
+			t.Errorf("%v: partition not kept", part)
+		}
+		for _, p := range []Predictor{s, s.Freeze()} {
+			got, err := p.PredictBatch(inputs, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range inputs {
+				if !floatsEqual(got.(SosMatrix)[i], want.(SosMatrix)[i]) {
+					t.Errorf("%v: %T: wrong prediction %v", part, p, i)
+				}
+			}
+		}
+	}
+}
+
+// The ParallelFor benchmarks predict the samples of the smallest PredictBatch
+// benchmark, which has the most chunks and the least work per chunk, with each
+// partition
+func BenchmarkParallelFor_Dynamic_10_1_5_100000(b *testing.B) {
+	benchmarkParallelFor(b, Dynamic, 10, 1, 5, 100000)
+}
+
+func BenchmarkParallelFor_Contiguous_10_1_5_100000(b *testing.B) {
+	benchmarkParallelFor(b, Contiguous, 10, 1, 5, 100000)
+}
+
+func BenchmarkParallelFor_Stealing_10_1_5_100000(b *testing.B) {
+	benchmarkParallelFor(b, Stealing, 10, 1, 5, 100000)
+}
+
+// The Skewed benchmarks have items whose cost varies by a factor of 100, with
+// the expensive items at the start of the loop
+func BenchmarkParallelFor_Dynamic_Skewed(b *testing.B) {
+	benchmarkParallelForSkewed(b, Dynamic)
+}
+
+func BenchmarkParallelFor_Contiguous_Skewed(b *testing.B) {
+	benchmarkParallelForSkewed(b, Contiguous)
+}
+
+func BenchmarkParallelFor_Stealing_Skewed(b *testing.B) {
+	benchmarkParallelForSkewed(b, Stealing)
+}
+
+func benchmarkParallelForSkewed(b *testing.B, part Partition) {
+	n := 10000
+	sums := make([]float64, n)
+	f := func(start, end int) {
+		for i := start; i < end; i++ {
+			work := 10
+			if i < n/8 {
+				work = 1000
+			}
+			var sum float64
+			for k := 0; k < work; k++ {
+				sum += float64(k * i)
+			}
+			sums[i] = sum
+		}
+	}
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ParallelForPartition(ctx, n, 10, part, f)
+	}
+}
+
+func benchmarkParallelFor(b *testing.B, part Partition, inputDim, nLayers, nNeurons, nSamples int) {
+	trainer, err := NewSimpleTrainer(inputDim, 1, nLayers, nNeurons, Linear{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(1))
+	trainer.SetRand(rnd)
+	trainer.RandomizeParameters()
+	inputs := RandomMat(nSamples, inputDim, rnd.NormFloat64)
+	outputs := RandomMat(nSamples, 1, rnd.NormFloat64)
+	pool := newPredictorPool(trainer.batchPredictor(), inputDim, 1)
+	f := func(start, end int) {
+		p := pool.get()
+		for i := start; i < end; i++ {
+			p.Predict(inputs[i], outputs[i])
+		}
+		pool.put(p)
+	}
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ParallelForPartition(ctx, nSamples, trainer.GrainSize(), part, f)
+	}
+}
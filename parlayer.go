@@ -0,0 +1,134 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+)
+
+// Minimum time in nanoseconds spent predicting one layer of a batch for the
+// neurons of the layer to be divided among the workers. Below this, the barrier
+// between layers costs more than is gained.
+const layerParallelMinTime = 20e3
+
+// rangeLayer is a Layer which can compute a range of its outputs, so that the
+// neurons of the layer can be divided among the workers
+type rangeLayer interface {
+	Layer
+	forwardRange(input, output []float64, start, end int)
+}
+
+// layerParallel returns whether a batch of nSamples should be predicted by
+// dividing the neurons of each layer among the workers rather than dividing the
+// samples. Dividing the samples is better whenever there are enough chunks of
+// samples to keep the workers busy, but a few samples of a net with wide layers
+// leave workers idle.
+func layerParallel(layers []Layer, grainSize, nSamples int) bool {
+	P := runtime.GOMAXPROCS(0)
+	if sequential || P == 1 {
+		return false
+	}
+	if (nSamples+grainSize-1)/grainSize >= P {
+		return false
+	}
+	var widest int
+	for _, layer := range layers {
+		if _, ok := layer.(rangeLayer); !ok {
+			return false
+		}
+		if layer.NumOutputs() > widest {
+			widest = layer.NumOutputs()
+		}
+	}
+	// The grain size is chosen so that a chunk of samples takes about 100µs
+	// (see setGrainSize), which gives the time per sample
+	sampleTime := 100e3 / float64(grainSize)
+	layerTime := float64(nSamples) * sampleTime / float64(len(layers))
+	return widest >= 2*P && layerTime >= layerParallelMinTime
+}
+
+// predictBatchLayers predicts the batch a layer at a time. The neurons of each
+// layer are divided among the workers, which all finish the layer before any
+// start the next.
+func predictBatchLayers(ctx context.Context, layers []Layer, inputs RowMatrix, outputs MutableRowMatrix,
+	inputDim, outputDim int) (MutableRowMatrix, error) {
+
+	outputs, err := checkBatch(inputs, outputs, inputDim, outputDim)
+	if err != nil {
+		return outputs, err
+	}
+	nSamples, _ := inputs.Dims()
+	P := runtime.GOMAXPROCS(0)
+
+	// The input to the current layer, one row per sample
+	in := make([][]float64, nSamples)
+	if rv, ok := inputs.(RowViewer); ok {
+		for s := range in {
+			in[s] = rv.RowView(s)
+		}
+	} else {
+		for s := range in {
+			in[s] = inputs.Row(make([]float64, inputDim), s)
+		}
+	}
+
+	// The hidden layers alternate between two buffers
+	var maxWidth int
+	for _, layer := range layers[:len(layers)-1] {
+		if layer.NumOutputs() > maxWidth {
+			maxWidth = layer.NumOutputs()
+		}
+	}
+	var hidden [2][][]float64
+	for k := range hidden {
+		hidden[k] = make([][]float64, nSamples)
+		mem := make([]float64, nSamples*maxWidth)
+		for s := range hidden[k] {
+			hidden[k][s] = mem[s*maxWidth : (s+1)*maxWidth : (s+1)*maxWidth]
+		}
+	}
+
+	// The final layer is written directly into outputs if possible
+	final := make([][]float64, nSamples)
+	outputRVer, outputIsRowViewer := outputs.(RowViewer)
+	for s := range final {
+		if outputIsRowViewer {
+			final[s] = outputRVer.RowView(s)
+		} else {
+			final[s] = make([]float64, outputDim)
+		}
+	}
+
+	for i, layer := range layers {
+		width := layer.NumOutputs()
+		out := final
+		if i != len(layers)-1 {
+			out = hidden[i%2]
+			for s := range out {
+				out[s] = out[s][:width]
+			}
+		}
+		l := layer.(rangeLayer)
+		err := ParallelForCtx(ctx, width, (width+P-1)/P, func(start, end int) {
+			for s, input := range in {
+				l.forwardRange(input, out[s], start, end)
+			}
+		})
+		if err != nil {
+			return outputs, err
+		}
+		in = out
+	}
+	if !outputIsRowViewer {
+		for s, row := range final {
+			outputs.SetRow(s, row)
+		}
+	}
+	atomic.AddUint64(&statBatches, 1)
+	atomic.AddUint64(&statPredictions, uint64(nSamples))
+	return outputs, nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"context"
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+func TestLayerParallel(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	wide, err := NewSimpleTrainer(500, 3, 2, 400, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	narrow, err := NewSimpleTrainer(10, 1, 1, 5, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name     string
+		net      *Trainer
+		nSamples int
+		want     bool
+	}{
+		{"wide, few samples", wide, 2, !sequential},
+		{"wide, many samples", wide, 10000, false},
+		{"narrow, few samples", narrow, 2, false},
+	} {
+		if got := layerParallel(test.net.layers, test.net.GrainSize(), test.nSamples); got != test.want {
+			t.Errorf("%v: expected %v, found %v", test.name, test.want, got)
+		}
+	}
+	runtime.GOMAXPROCS(1)
+	if layerParallel(wide.layers, wide.GrainSize(), 2) {
+		t.Errorf("Layers divided with one worker")
+	}
+}
+
+func TestPredictBatchLayers(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	for _, neurons := range [][][]Neuron{
+		{{TanhNeuron}},
+		{{TanhNeuron, TanhNeuron, SigmoidNeuron, TanhNeuron, LinearNeuron}, {TanhNeuron, LinearNeuron}},
+		{make([]Neuron, 17), make([]Neuron, 9), {LinearNeuron, LinearNeuron, SigmoidNeuron}},
+	} {
+		for _, layer := range neurons {
+			for j := range layer {
+				if layer[j] == nil {
+					layer[j] = TanhNeuron
+				}
+			}
+		}
+		outputDim := len(neurons[len(neurons)-1])
+		s, err := NewTrainer(6, outputDim, neurons)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.RandomizeParameters()
+		inputs := RandomMat(5, 6, rand.NormFloat64)
+		want := make(SosMatrix, len(inputs))
+		for i := range want {
+			want[i], _ = s.Predict(inputs[i], nil)
+		}
+		for _, rowViewer := range []bool{true, false} {
+			var in RowMatrix = inputs
+			outputs := RandomMat(len(inputs), outputDim, rand.NormFloat64)
+			var out MutableRowMatrix = outputs
+			if !rowViewer {
+				in, out = copyMatrix{inputs}, copyMatrix{outputs}
+			}
+			if _, err := predictBatchLayers(context.Background(), s.layers, in, out, 6, outputDim); err != nil {
+				t.Fatal(err)
+			}
+			for i := range want {
+				if !floatsEqualApprox(outputs[i], want[i], 1e-14) {
+					t.Errorf("%v layers, row viewer %v: wrong prediction %v. Expected %v, found %v", len(neurons), rowViewer, i, want[i], outputs[i])
+				}
+			}
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := predictBatchLayers(ctx, s.layers, inputs, nil, 6, outputDim); err != context.Canceled {
+			t.Errorf("Expected context.Canceled, found %v", err)
+		}
+		if _, err := predictBatchLayers(context.Background(), s.layers, inputs, nil, 5, outputDim); err == nil {
+			t.Errorf("Expected dimension error")
+		}
+	}
+
+	// PredictBatch divides the layers of a wide net with few samples
+	s, err := NewSimpleTrainer(500, 3, 2, 400, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.RandomizeParameters()
+	inputs := RandomMat(2, 500, rand.NormFloat64)
+	for _, p := range []Predictor{s, s.Freeze()} {
+		got, err := p.PredictBatch(inputs, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range inputs {
+			want, _ := s.Predict(inputs[i], nil)
+			if !floatsEqualApprox(got.(SosMatrix)[i], want, 1e-14) {
+				t.Errorf("%T: wrong prediction %v", p, i)
+			}
+		}
+	}
+}
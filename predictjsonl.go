@@ -0,0 +1,129 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// JSONLSchema maps the fields of JSON records to the inputs and outputs of a
+// predictor for PredictJSONL
+type JSONLSchema struct {
+	// Inputs are the names of the fields holding the inputs in order. Each
+	// field must be a number.
+	Inputs []string
+
+	// Outputs are the names of the fields the outputs are written to in order
+	Outputs []string
+
+	// If Keep is true, the output records are the input records with the
+	// output fields added at the end. Otherwise they only contain the outputs.
+	Keep bool
+}
+
+// PredictJSONL reads JSON records from r, one per line, predicts the outputs
+// for each record, and writes a JSON record with the outputs to w for each
+// input record. Blank lines are skipped. The input may be gzip compressed (see
+// Decompress). PredictJSONL stops at the first invalid record and returns an
+// error with its line number, after writing the outputs of the records before
+// it.
+func PredictJSONL(p Predictor, r io.Reader, w io.Writer, schema JSONLSchema) error {
+	if len(schema.Inputs) != p.InputDim() {
+		return dimensionError("predict jsonl", ErrInputDim, p.InputDim(), len(schema.Inputs))
+	}
+	if len(schema.Outputs) != p.OutputDim() {
+		return dimensionError("predict jsonl", ErrOutputDim, p.OutputDim(), len(schema.Outputs))
+	}
+	// The names of the output fields don't change, so encode them once
+	outputKeys := make([][]byte, len(schema.Outputs))
+	for i, name := range schema.Outputs {
+		b, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+		outputKeys[i] = append(b, ':')
+	}
+
+	pred := newPredictorFrom(p)
+	input := make([]float64, p.InputDim())
+	output := make([]float64, p.OutputDim())
+	scanner := newLineScanner(Decompress(r))
+	bw := bufio.NewWriter(w)
+	var line int
+	var buf []byte
+	for scanner.Scan() {
+		line++
+		record := bytes.TrimSpace(scanner.Bytes())
+		if len(record) == 0 {
+			continue
+		}
+		// The records before the invalid one are written before the error is
+		// returned
+		lineErr := func(msg string) error {
+			if err := bw.Flush(); err != nil {
+				return err
+			}
+			return errors.New("predict jsonl: line " + strconv.Itoa(line) + ": " + msg)
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(record, &fields); err != nil {
+			return lineErr(err.Error())
+		}
+		for i, name := range schema.Inputs {
+			raw, ok := fields[name]
+			if !ok {
+				return lineErr("missing field " + strconv.Quote(name))
+			}
+			// A null leaves a float64 unchanged, so decode into a pointer
+			var v *float64
+			if err := json.Unmarshal(raw, &v); err != nil || v == nil {
+				return lineErr("field " + strconv.Quote(name) + " is not a number")
+			}
+			input[i] = *v
+		}
+		if _, err := pred.Predict(input, output); err != nil {
+			return lineErr(err.Error())
+		}
+
+		// Add the output fields to the record, keeping the input fields in
+		// their original order if they are kept
+		buf = buf[:0]
+		sep := false
+		if schema.Keep {
+			buf = append(buf, record[:len(record)-1]...)
+			sep = len(fields) != 0
+		} else {
+			buf = append(buf, '{')
+		}
+		for i, v := range output {
+			if sep {
+				buf = append(buf, ',')
+			}
+			sep = true
+			buf = append(buf, outputKeys[i]...)
+			b, err := json.Marshal(v)
+			if err != nil {
+				return lineErr("output " + strconv.Quote(schema.Outputs[i]) + ": " + err.Error())
+			}
+			buf = append(buf, b...)
+		}
+		buf = append(buf, '}', '\n')
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if ferr := bw.Flush(); ferr != nil {
+			return ferr
+		}
+		return err
+	}
+	return bw.Flush()
+}
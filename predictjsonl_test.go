@@ -0,0 +1,98 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestPredictJSONL(t *testing.T) {
+	trainer, err := NewSimpleTrainer(2, 2, 1, 3, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trainer.SetRand(rand.New(rand.NewSource(1)))
+	trainer.RandomizeParameters()
+
+	const data = `{"id": "a", "x": 1, "y": 2}
+
+{"y": -1, "x": 0.5, "id": "b"}
+`
+	schema := JSONLSchema{Inputs: []string{"x", "y"}, Outputs: []string{"p", "q"}}
+	for _, keep := range []bool{false, true} {
+		schema.Keep = keep
+		var out bytes.Buffer
+		if err := PredictJSONL(trainer, strings.NewReader(data), &out, schema); err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("Wrong number of output lines. Expected 2, found %v", len(lines))
+		}
+		for i, test := range []struct {
+			id    string
+			input []float64
+		}{
+			{"a", []float64{1, 2}},
+			{"b", []float64{0.5, -1}},
+		} {
+			var record map[string]interface{}
+			if err := json.Unmarshal([]byte(lines[i]), &record); err != nil {
+				t.Fatalf("Invalid output %q: %v", lines[i], err)
+			}
+			want, _ := trainer.Predict(test.input, nil)
+			if record["p"] != want[0] || record["q"] != want[1] {
+				t.Errorf("Wrong prediction for %v. Expected %v, found %v, %v", test.id, want, record["p"], record["q"])
+			}
+			if keep && record["id"] != test.id {
+				t.Errorf("Input field not kept for %v", test.id)
+			}
+			if !keep && len(record) != 2 {
+				t.Errorf("Extra fields in output %q", lines[i])
+			}
+		}
+	}
+
+	for _, test := range []struct {
+		name   string
+		data   string
+		schema JSONLSchema
+	}{
+		{"missing field", `{"x": 1}`, schema},
+		{"not a number", `{"x": 1, "y": "2"}`, schema},
+		{"null", "{\"x\": 1, \"y\": 2}\n{\"x\": 1, \"y\": null}", schema},
+		{"invalid json", `{"x": 1,`, schema},
+		{"wrong input dim", `{"x": 1, "y": 2}`, JSONLSchema{Inputs: []string{"x"}, Outputs: []string{"p", "q"}}},
+		{"wrong output dim", `{"x": 1, "y": 2}`, JSONLSchema{Inputs: []string{"x", "y"}, Outputs: []string{"p"}}},
+	} {
+		if err := PredictJSONL(trainer, strings.NewReader(test.data), &bytes.Buffer{}, test.schema); err == nil {
+			t.Errorf("%v: expected error", test.name)
+		}
+	}
+
+	// The records before an invalid one are written
+	var out bytes.Buffer
+	partial := "{\"x\": 1, \"y\": 2}\n{\"x\": 1}\n"
+	if err := PredictJSONL(trainer, strings.NewReader(partial), &out, schema); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected error on line 2, found %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 1 || lines[0] == "" {
+		t.Errorf("Valid record not written before the error. Output %q", out.String())
+	}
+
+	// Records longer than the default scanner buffer are read
+	out.Reset()
+	long := `{"id": "` + strings.Repeat("a", 100000) + `", "x": 1, "y": 2}`
+	if err := PredictJSONL(trainer, strings.NewReader(long), &out, schema); err != nil {
+		t.Errorf("Long record: unexpected error: %v", err)
+	}
+	if out.Len() == 0 {
+		t.Errorf("Long record not written")
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// SetPruneMask sets which parameters of the net are pruned, in the flat
+// parameter ordering (see Net.Parameters). Pruned parameters are set to zero
+// and are kept at zero by training. If mask is nil, no parameters are pruned,
+// which is the default. Changing the size of the layers with surgery clears the
+// mask.
+func (s *Trainer) SetPruneMask(mask []bool) error {
+	if mask == nil {
+		s.pruned = nil
+		return nil
+	}
+	if len(mask) != s.totalNumParameters {
+		return dimensionError("set prune mask", ErrParameterDim, s.totalNumParameters, len(mask))
+	}
+	s.pruned = append([]bool(nil), mask...)
+	s.applyPruneMask()
+	return nil
+}
+
+// PruneMask returns a copy of the prune mask of the net, or nil if no
+// parameters are pruned
+func (s *Trainer) PruneMask() []bool {
+	if s.pruned == nil {
+		return nil
+	}
+	return append([]bool(nil), s.pruned...)
+}
+
+// PruneMagnitude prunes the given fraction of the weights of the SumNeurons
+// of the net which are not yet pruned, choosing the weights with the smallest
+// magnitude over the whole net. Biases and the parameters of other neurons are
+// never pruned. It returns the sparsity of the net afterwards, the fraction of
+// the prunable weights which are pruned.
+func (s *Trainer) PruneMagnitude(fraction float64) (float64, error) {
+	if fraction < 0 || fraction > 1 {
+		return 0, errors.New("prune magnitude: fraction not in [0, 1]")
+	}
+	if s.pruned == nil {
+		s.pruned = make([]bool, s.totalNumParameters)
+	}
+	params := s.Parameters(nil)
+	weights := s.prunableWeights()
+	var remaining []int
+	for _, idx := range weights {
+		if !s.pruned[idx] {
+			remaining = append(remaining, idx)
+		}
+	}
+	sort.SliceStable(remaining, func(i, j int) bool {
+		return math.Abs(params[remaining[i]]) < math.Abs(params[remaining[j]])
+	})
+	nPrune := int(math.Round(fraction * float64(len(remaining))))
+	for _, idx := range remaining[:nPrune] {
+		s.pruned[idx] = true
+	}
+	s.applyPruneMask()
+	return s.sparsity(weights), nil
+}
+
+// prunableWeights returns the flat indices of the weights of the SumNeurons
+// of the net, which are the parameters which can be pruned
+func (s *Trainer) prunableWeights() []int {
+	var weights []int
+	var idx int
+	for i, layer := range s.parameters {
+		for j, p := range layer {
+			if _, ok := s.neurons[i][j].(SumNeuron); ok {
+				for k := range p[:len(p)-1] {
+					weights = append(weights, idx+k)
+				}
+			}
+			idx += len(p)
+		}
+	}
+	return weights
+}
+
+// sparsity returns the fraction of the given weights which are pruned
+func (s *Trainer) sparsity(weights []int) float64 {
+	if len(weights) == 0 || s.pruned == nil {
+		return 0
+	}
+	var n int
+	for _, idx := range weights {
+		if s.pruned[idx] {
+			n++
+		}
+	}
+	return float64(n) / float64(len(weights))
+}
+
+// applyPruneMask sets the pruned parameters to zero
+func (s *Trainer) applyPruneMask() {
+	if s.pruned == nil {
+		return
+	}
+	var idx int
+	for _, layer := range s.parameters {
+		for _, p := range layer {
+			for k := range p {
+				if s.pruned[idx] {
+					p[k] = 0
+				}
+				idx++
+			}
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestPruneMagnitude(t *testing.T) {
+	s, err := NewSimpleTrainer(4, 1, 1, 5, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(1))
+	s.SetRand(rnd)
+	s.RandomizeParameters()
+	if s.PruneMask() != nil {
+		t.Errorf("Prune mask set by default")
+	}
+	before := s.Parameters(nil)
+
+	// 4*5 + 5 weights, of which half, rounded up, are pruned
+	sparsity, err := s.PruneMagnitude(0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 13.0 / 25; math.Abs(sparsity-want) > 1e-14 {
+		t.Errorf("Wrong sparsity. Expected %v, found %v", want, sparsity)
+	}
+	mask := s.PruneMask()
+	params := s.Parameters(nil)
+	var maxPruned, minKept float64 = 0, math.Inf(1)
+	weights := s.prunableWeights()
+	isWeight := make(map[int]bool)
+	for _, idx := range weights {
+		isWeight[idx] = true
+		if mask[idx] {
+			maxPruned = math.Max(maxPruned, math.Abs(before[idx]))
+		} else {
+			minKept = math.Min(minKept, math.Abs(before[idx]))
+		}
+	}
+	if maxPruned > minKept {
+		t.Errorf("Larger weight pruned. Largest pruned %v, smallest kept %v", maxPruned, minKept)
+	}
+	for i, pruned := range mask {
+		if pruned && (!isWeight[i] || params[i] != 0) {
+			t.Errorf("Wrong pruned parameter %v", i)
+		}
+		if !pruned && params[i] != before[i] {
+			t.Errorf("Unpruned parameter %v changed", i)
+		}
+	}
+
+	// Training keeps the pruned weights at zero, and the clone keeps the mask
+	inputs := RandomMat(20, 4, rnd.NormFloat64)
+	targets := RandomMat(20, 1, rnd.NormFloat64)
+	c := s.Clone()
+	for _, tr := range []*Trainer{s, c} {
+		if _, err := tr.TrainEpoch(inputs, targets, 5, nil); err != nil {
+			t.Fatal(err)
+		}
+		params = tr.Parameters(nil)
+		for i, pruned := range mask {
+			if pruned && params[i] != 0 {
+				t.Errorf("Pruned parameter %v trained", i)
+			}
+		}
+	}
+	if _, err := c.PruneMagnitude(0.5); err != nil {
+		t.Fatal(err)
+	}
+	if !equalBools(s.PruneMask(), mask) {
+		t.Errorf("Clone shares the prune mask")
+	}
+
+	if err := s.SetPruneMask(make([]bool, 3)); err == nil {
+		t.Errorf("Expected mask length error")
+	}
+	if err := s.SetPruneMask(nil); err != nil || s.PruneMask() != nil {
+		t.Errorf("Mask not cleared")
+	}
+	if _, err := s.PruneMagnitude(1.5); err == nil {
+		t.Errorf("Expected fraction error")
+	}
+}
+
+func equalBools(a, b []bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, v := range a {
+		if v != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,11 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+//go:build race
+
+package nnet
+
+// The race detector randomly drops items put into a sync.Pool, so tests that
+// count allocations are skipped
+const raceEnabled = true
@@ -0,0 +1,121 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Registered activators and neurons by name, so that nets can be constructed
+// from descriptions such as those returned by Net.LayerDescription
+var (
+	registerMux          sync.RWMutex
+	registeredActivators = make(map[string]Activator)
+	registeredNeurons    = make(map[string]func(Activator) Neuron)
+)
+
+func init() {
+	RegisterActivator("Linear", Linear{})
+	RegisterActivator("Sigmoid", Sigmoid{})
+	RegisterActivator("Tanh", Tanh{})
+	RegisterActivator("LinearTanh", LinearTanh{})
+	RegisterActivator("ReLU", ReLU{})
+	RegisterNeuron("Sum", func(a Activator) Neuron { return SumNeuron{Activator: a} })
+	RegisterNeuron("Constant", func(Activator) Neuron { return ConstantNeuron{} })
+}
+
+// RegisterActivator makes an activator available by name. Activators are
+// assumed to be stateless values which can be shared. The name should be the
+// one returned by the String method of the activator, if it has one, so that
+// layer descriptions can be parsed back. Packages providing activators
+// typically register them in an init function. RegisterActivator panics if the
+// name is already registered.
+func RegisterActivator(name string, a Activator) {
+	registerMux.Lock()
+	defer registerMux.Unlock()
+	if _, ok := registeredActivators[name]; ok {
+		panic("nnet: activator " + name + " registered twice")
+	}
+	registeredActivators[name] = a
+}
+
+// RegisterNeuron makes a kind of neuron available by name. The function
+// returns the neuron with the given activator, which is nil for a neuron
+// described without one. The description of the neuron is name(activator), as
+// for "Sum(Tanh)", or just name. RegisterNeuron panics if the name is already
+// registered.
+func RegisterNeuron(name string, f func(Activator) Neuron) {
+	registerMux.Lock()
+	defer registerMux.Unlock()
+	if _, ok := registeredNeurons[name]; ok {
+		panic("nnet: neuron " + name + " registered twice")
+	}
+	registeredNeurons[name] = f
+}
+
+// ActivatorByName returns the registered activator with the given name
+func ActivatorByName(name string) (Activator, error) {
+	registerMux.RLock()
+	defer registerMux.RUnlock()
+	a, ok := registeredActivators[name]
+	if !ok {
+		return nil, errors.New("nnet: unknown activator " + name)
+	}
+	return a, nil
+}
+
+// NeuronByName returns the neuron with the given description, such as
+// "Sum(Tanh)", from the registered neurons and activators
+func NeuronByName(desc string) (Neuron, error) {
+	name, arg := desc, ""
+	if i := strings.IndexByte(desc, '('); i >= 0 {
+		if !strings.HasSuffix(desc, ")") {
+			return nil, errors.New("nnet: malformed neuron " + desc)
+		}
+		name, arg = desc[:i], desc[i+1:len(desc)-1]
+	}
+	registerMux.RLock()
+	f, ok := registeredNeurons[name]
+	registerMux.RUnlock()
+	if !ok {
+		return nil, errors.New("nnet: unknown neuron " + name)
+	}
+	var a Activator
+	if arg != "" {
+		var err error
+		a, err = ActivatorByName(arg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return f(a), nil
+}
+
+// ActivatorNames returns the names of the registered activators in sorted order
+func ActivatorNames() []string {
+	registerMux.RLock()
+	defer registerMux.RUnlock()
+	names := make([]string, 0, len(registeredActivators))
+	for name := range registeredActivators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NeuronNames returns the names of the registered neurons in sorted order
+func NeuronNames() []string {
+	registerMux.RLock()
+	defer registerMux.RUnlock()
+	names := make([]string, 0, len(registeredNeurons))
+	for name := range registeredNeurons {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,60 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import "testing"
+
+type doubleActivator struct{}
+
+func (doubleActivator) Activate(sum float64) float64                      { return 2 * sum }
+func (doubleActivator) DActivateDCombination(sum, output float64) float64 { return 2 }
+func (doubleActivator) String() string                                    { return "Double" }
+
+func TestRegister(t *testing.T) {
+	// The layer descriptions of the built in neurons can be parsed back
+	for _, neuron := range []Neuron{TanhNeuron, LinearTanhNeuron, LinearNeuron, SigmoidNeuron} {
+		desc := describeNeuron(neuron)
+		n, err := NeuronByName(desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != neuron {
+			t.Errorf("Wrong neuron for %v", desc)
+		}
+	}
+
+	RegisterActivator("Double", doubleActivator{})
+	n, err := NeuronByName("Sum(Double)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if describeNeuron(n) != "Sum(Double)" || n.Activate(3) != 6 {
+		t.Errorf("Wrong registered neuron %v", describeNeuron(n))
+	}
+	found := false
+	for _, name := range ActivatorNames() {
+		found = found || name == "Double"
+	}
+	if !found {
+		t.Errorf("Registered activator not listed in %v", ActivatorNames())
+	}
+	if names := NeuronNames(); len(names) < 2 || names[0] != "Constant" || names[1] != "Sum" {
+		t.Errorf("Wrong neuron names %v", names)
+	}
+
+	for _, desc := range []string{"Sum(Unknown)", "Unknown(Tanh)", "Sum(Tanh"} {
+		if _, err := NeuronByName(desc); err == nil {
+			t.Errorf("Expected error for %v", desc)
+		}
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected panic for duplicate registration")
+			}
+		}()
+		RegisterActivator("Tanh", Tanh{})
+	}()
+}
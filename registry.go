@@ -0,0 +1,186 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ModelRegistry holds named models, each with several versions of which one
+// is active and serves the predictions. The active version can be swapped
+// while predictions are in flight: predictions which have started finish with
+// the version they started with, and later predictions use the new version.
+// The predictors must be safe for concurrent use, such as a FrozenNet. All of
+// the methods of ModelRegistry are safe to call concurrently.
+type ModelRegistry struct {
+	mux    sync.RWMutex
+	models map[string]*registryModel
+}
+
+type registryModel struct {
+	versions map[string]Predictor
+	active   string
+	previous string // version active before the last Activate
+}
+
+// NewModelRegistry returns an empty registry
+func NewModelRegistry() *ModelRegistry {
+	return &ModelRegistry{models: make(map[string]*registryModel)}
+}
+
+// Register adds a version of the named model. All of the versions of a model
+// must have the same input and output dimensions. The first version of a model
+// becomes active, later versions are activated with Activate.
+func (r *ModelRegistry) Register(name, version string, p Predictor) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	m, ok := r.models[name]
+	if !ok {
+		r.models[name] = &registryModel{
+			versions: map[string]Predictor{version: p},
+			active:   version,
+		}
+		return nil
+	}
+	if _, ok := m.versions[version]; ok {
+		return errors.New("registry: version " + version + " of " + name + " already registered")
+	}
+	current := m.versions[m.active]
+	if p.InputDim() != current.InputDim() {
+		return dimensionError("registry", ErrInputDim, current.InputDim(), p.InputDim())
+	}
+	if p.OutputDim() != current.OutputDim() {
+		return dimensionError("registry", ErrOutputDim, current.OutputDim(), p.OutputDim())
+	}
+	m.versions[version] = p
+	return nil
+}
+
+// Activate makes the version of the named model serve the predictions
+func (r *ModelRegistry) Activate(name, version string) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	m, err := r.model(name)
+	if err != nil {
+		return err
+	}
+	if _, ok := m.versions[version]; !ok {
+		return errors.New("registry: unknown version " + version + " of " + name)
+	}
+	if version != m.active {
+		m.previous, m.active = m.active, version
+	}
+	return nil
+}
+
+// Rollback reactivates the version of the named model which was active before
+// the last call to Activate
+func (r *ModelRegistry) Rollback(name string) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	m, err := r.model(name)
+	if err != nil {
+		return err
+	}
+	if m.previous == "" {
+		return errors.New("registry: no previous version of " + name)
+	}
+	m.previous, m.active = m.active, m.previous
+	return nil
+}
+
+// Remove removes a version of the named model. The active version cannot be
+// removed.
+func (r *ModelRegistry) Remove(name, version string) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	m, err := r.model(name)
+	if err != nil {
+		return err
+	}
+	if version == m.active {
+		return errors.New("registry: cannot remove active version " + version + " of " + name)
+	}
+	if _, ok := m.versions[version]; !ok {
+		return errors.New("registry: unknown version " + version + " of " + name)
+	}
+	delete(m.versions, version)
+	if m.previous == version {
+		m.previous = ""
+	}
+	return nil
+}
+
+// Active returns the active version of the named model and its predictor
+func (r *ModelRegistry) Active(name string) (string, Predictor, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	m, err := r.model(name)
+	if err != nil {
+		return "", nil, err
+	}
+	return m.active, m.versions[m.active], nil
+}
+
+// Versions returns the registered versions of the named model in sorted order
+func (r *ModelRegistry) Versions(name string) ([]string, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	m, err := r.model(name)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]string, 0, len(m.versions))
+	for v := range m.versions {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
+
+// Names returns the names of the registered models in sorted order
+func (r *ModelRegistry) Names() []string {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	names := make([]string, 0, len(r.models))
+	for name := range r.models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Predict predicts with the active version of the named model and returns the
+// version which served the prediction
+func (r *ModelRegistry) Predict(name string, input, output []float64) ([]float64, string, error) {
+	version, p, err := r.Active(name)
+	if err != nil {
+		return nil, "", err
+	}
+	output, err = p.Predict(input, output)
+	return output, version, err
+}
+
+// PredictBatch predicts all of the inputs with the active version of the named
+// model and returns the version which served the predictions
+func (r *ModelRegistry) PredictBatch(name string, inputs RowMatrix, outputs MutableRowMatrix) (MutableRowMatrix, string, error) {
+	version, p, err := r.Active(name)
+	if err != nil {
+		return nil, "", err
+	}
+	outputs, err = p.PredictBatch(inputs, outputs)
+	return outputs, version, err
+}
+
+// model returns the named model. The lock must be held.
+func (r *ModelRegistry) model(name string) (*registryModel, error) {
+	m, ok := r.models[name]
+	if !ok {
+		return nil, errors.New("registry: unknown model " + name)
+	}
+	return m, nil
+}
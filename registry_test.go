@@ -0,0 +1,121 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestModelRegistry(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	versions := make(map[string]*FrozenNet)
+	for _, v := range []string{"v1", "v2"} {
+		trainer, err := NewSimpleTrainer(2, 1, 1, 3, Linear{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		trainer.SetRand(rnd)
+		trainer.RandomizeParameters()
+		versions[v] = trainer.Freeze()
+	}
+	r := NewModelRegistry()
+	for _, v := range []string{"v1", "v2"} {
+		if err := r.Register("model", v, versions[v]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Register("model", "v1", versions["v1"]); err == nil {
+		t.Errorf("Expected error for duplicate version")
+	}
+	wrong, _ := NewSimpleTrainer(3, 1, 1, 3, Linear{})
+	if err := r.Register("model", "v3", wrong); !errors.Is(err, ErrInputDim) {
+		t.Errorf("Expected ErrInputDim, found %v", err)
+	}
+	if got, _ := r.Versions("model"); !equalStrings(got, []string{"v1", "v2"}) {
+		t.Errorf("Wrong versions %v", got)
+	}
+	if got := r.Names(); !equalStrings(got, []string{"model"}) {
+		t.Errorf("Wrong names %v", got)
+	}
+
+	input := []float64{0.3, -0.7}
+	check := func(want string) {
+		t.Helper()
+		output, version, err := r.Predict("model", input, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, _ := versions[want].Predict(input, nil)
+		if version != want || !floatsEqual(output, expected) {
+			t.Errorf("Expected prediction by %v, found %v", want, version)
+		}
+		_, version, err = r.PredictBatch("model", SosMatrix{input}, nil)
+		if err != nil || version != want {
+			t.Errorf("Expected batch prediction by %v, found %v", want, version)
+		}
+	}
+	check("v1")
+	if err := r.Rollback("model"); err == nil {
+		t.Errorf("Expected error for rollback without previous version")
+	}
+	if err := r.Activate("model", "v2"); err != nil {
+		t.Fatal(err)
+	}
+	check("v2")
+	if err := r.Remove("model", "v2"); err == nil {
+		t.Errorf("Expected error for removing the active version")
+	}
+	if err := r.Rollback("model"); err != nil {
+		t.Fatal(err)
+	}
+	check("v1")
+	if err := r.Activate("model", "v3"); err == nil {
+		t.Errorf("Expected error for unknown version")
+	}
+	if _, _, err := r.Predict("other", input, nil); err == nil {
+		t.Errorf("Expected error for unknown model")
+	}
+
+	// Swap versions while predicting concurrently. Every prediction must come
+	// entirely from the version reported.
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				output, version, err := r.Predict("model", input, nil)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				expected, _ := versions[version].Predict(input, nil)
+				if !floatsEqual(output, expected) {
+					t.Errorf("Prediction does not match version %v", version)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		r.Activate("model", []string{"v1", "v2"}[i%2])
+	}
+	wg.Wait()
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,168 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+)
+
+// SampleScheduler chooses the samples used in each epoch of TrainEpoch and
+// their order, for example to present easy samples before hard ones or to
+// emphasize the samples the net gets wrong.
+type SampleScheduler interface {
+	// Order returns the indices of the samples in the order they are used in
+	// the given epoch, counting from zero. Indices may be repeated or left out
+	// to re-weight the samples. losses[i] is the current loss of sample i.
+	Order(epoch int, losses []float64, rnd *rand.Rand) []int
+}
+
+// Curriculum orders the samples from easy to hard by their current loss.
+// Training starts on the easiest fraction Start of the samples, which grows
+// linearly to all of the samples over Epochs epochs.
+type Curriculum struct {
+	Start  float64 // In (0, 1]
+	Epochs int
+}
+
+func (c Curriculum) Order(epoch int, losses []float64, rnd *rand.Rand) []int {
+	frac := 1.0
+	if epoch < c.Epochs {
+		frac = c.Start + (1-c.Start)*float64(epoch)/float64(c.Epochs)
+	}
+	n := int(frac*float64(len(losses)) + 0.5)
+	if n < 1 {
+		n = 1
+	}
+	order := make([]int, len(losses))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return losses[order[i]] < losses[order[j]]
+	})
+	return order[:n]
+}
+
+// Emphasis resamples the samples with replacement with probability
+// proportional to their current loss, in the style of boosting, so that the
+// samples the net gets wrong are seen more often. The probabilities are mixed
+// with the uniform distribution as (1 - Smoothing) * p + Smoothing / n so that
+// samples with small loss are not forgotten.
+type Emphasis struct {
+	Smoothing float64 // In [0, 1]
+}
+
+func (e Emphasis) Order(epoch int, losses []float64, rnd *rand.Rand) []int {
+	n := len(losses)
+	var total float64
+	for _, l := range losses {
+		total += l
+	}
+	cumulative := make([]float64, n)
+	var sum float64
+	for i, l := range losses {
+		p := 1 / float64(n)
+		if total > 0 {
+			p = (1-e.Smoothing)*l/total + e.Smoothing/float64(n)
+		}
+		sum += p
+		cumulative[i] = sum
+	}
+	order := make([]int, n)
+	for i := range order {
+		idx := sort.SearchFloat64s(cumulative, rnd.Float64()*sum)
+		if idx == n {
+			idx = n - 1
+		}
+		order[i] = idx
+	}
+	return order
+}
+
+// SampleLosses returns the loss of each sample, where row i of targets is the
+// target for row i of inputs. The predictions are computed in parallel. If
+// losses is nil, new memory is allocated, otherwise it must have one element
+// per sample.
+func (s *Trainer) SampleLosses(inputs, targets RowMatrix, losses []float64) ([]float64, error) {
+	if err := s.checkTargets("sample losses", inputs, targets); err != nil {
+		return nil, err
+	}
+	nSamples, _ := inputs.Dims()
+	if losses == nil {
+		losses = make([]float64, nSamples)
+	}
+	if len(losses) != nSamples {
+		return nil, dimensionError("sample losses", ErrRows, nSamples, len(losses))
+	}
+	predictions, err := s.PredictBatch(inputs, nil)
+	if err != nil {
+		return nil, err
+	}
+	lossFunc := s.Loss()
+	target := make([]float64, s.targetDim())
+	deriv := make([]float64, s.outputDim)
+	for i, prediction := range predictions.(SosMatrix) {
+		target = targets.Row(target, i)
+		losses[i] = lossFunc.LossDeriv(prediction, target, deriv)
+	}
+	return losses, nil
+}
+
+// TrainEpoch performs one epoch of mini-batch training with PartialFit. The
+// samples are taken in the order chosen by sched given their loss at the start
+// of the epoch, or in a random order if sched is nil. TrainEpoch returns the
+// mean of the mini-batch losses. If a History is set, the progress of the
+// epoch is recorded into it.
+func (s *Trainer) TrainEpoch(inputs, targets RowMatrix, batchSize int, sched SampleScheduler) (float64, error) {
+	if batchSize <= 0 {
+		return 0, errors.New("train epoch: non-positive batch size")
+	}
+	if err := s.checkTargets("train epoch", inputs, targets); err != nil {
+		return 0, err
+	}
+	nSamples, _ := inputs.Dims()
+	var order []int
+	if sched == nil {
+		order = s.rand().Perm(nSamples)
+	} else {
+		losses, err := s.SampleLosses(inputs, targets, nil)
+		if err != nil {
+			return 0, err
+		}
+		order = sched.Order(s.epochs, losses, s.rand())
+	}
+	var loss float64
+	var nBatches int
+	for start := 0; start < len(order); start += batchSize {
+		end := start + batchSize
+		if end > len(order) {
+			end = len(order)
+		}
+		rows := order[start:end]
+		l, err := s.PartialFit(SelectRows(inputs, rows), SelectRows(targets, rows))
+		if err != nil {
+			return 0, err
+		}
+		loss += l
+		nBatches++
+	}
+	s.epochs++
+	if nBatches != 0 {
+		loss /= float64(nBatches)
+	}
+	if s.history != nil {
+		if err := s.history.record(s, s.epochs, loss, inputs, targets); err != nil {
+			return loss, err
+		}
+	}
+	return loss, nil
+}
+
+// Epochs returns the number of epochs completed by TrainEpoch
+func (s *Trainer) Epochs() int {
+	return s.epochs
+}
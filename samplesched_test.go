@@ -0,0 +1,120 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestCurriculum(t *testing.T) {
+	losses := []float64{4, 1, 3, 0, 2}
+	c := Curriculum{Start: 0.4, Epochs: 3}
+	for _, test := range []struct {
+		epoch int
+		want  []int
+	}{
+		{0, []int{3, 1}},
+		{1, []int{3, 1, 4}},
+		{2, []int{3, 1, 4, 2}},
+		{3, []int{3, 1, 4, 2, 0}},
+		{10, []int{3, 1, 4, 2, 0}},
+	} {
+		if order := c.Order(test.epoch, losses, nil); !equalInts(order, test.want) {
+			t.Errorf("Epoch %v: expected %v, found %v", test.epoch, test.want, order)
+		}
+	}
+}
+
+func TestEmphasis(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	losses := make([]float64, 10)
+	losses[7] = 1
+	for _, i := range (Emphasis{}).Order(0, losses, rnd) {
+		if i != 7 {
+			t.Fatalf("Sample %v with zero loss chosen", i)
+		}
+	}
+	counts := make([]int, len(losses))
+	for i := 0; i < 100; i++ {
+		for _, idx := range (Emphasis{Smoothing: 0.5}).Order(0, losses, rnd) {
+			counts[idx]++
+		}
+	}
+	// Sample 7 has probability 0.55, the others 0.05
+	if math.Abs(float64(counts[7])/1000-0.55) > 0.05 || counts[0] == 0 {
+		t.Errorf("Wrong sample counts %v", counts)
+	}
+	// All zero losses give a uniform distribution
+	for _, idx := range (Emphasis{}).Order(0, make([]float64, 3), rnd) {
+		if idx < 0 || idx >= 3 {
+			t.Errorf("Index %v out of range", idx)
+		}
+	}
+}
+
+func TestTrainEpoch(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	inputs, targets := NoisySine(200, 0.05, rnd)
+	for _, sched := range []SampleScheduler{
+		nil,
+		Curriculum{Start: 0.3, Epochs: 5},
+		Emphasis{Smoothing: 0.5},
+	} {
+		trainer, err := NewSimpleTrainer(1, 1, 1, 10, Linear{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		trainer.SetRand(rand.New(rand.NewSource(2)))
+		trainer.RandomizeParameters()
+		trainer.SetOptimizer(&Adam{Rate: 0.01})
+
+		losses, err := trainer.SampleLosses(inputs, targets, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		first, _, err := trainer.lossGradient(inputs, targets)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var mean float64
+		for _, l := range losses {
+			mean += l
+		}
+		mean /= float64(len(losses))
+		if math.Abs(mean-first) > 1e-12 {
+			t.Errorf("Mean of sample losses %v differs from loss %v", mean, first)
+		}
+
+		for i := 0; i < 20; i++ {
+			if _, err := trainer.TrainEpoch(inputs, targets, 20, sched); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if trainer.Epochs() != 20 {
+			t.Errorf("Wrong number of epochs. Expected 20, found %v", trainer.Epochs())
+		}
+		last, _, err := trainer.lossGradient(inputs, targets)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last > first/4 {
+			t.Errorf("%v: loss not reduced enough. First %v, last %v", sched, first, last)
+		}
+	}
+
+	trainer, _ := NewSimpleTrainer(1, 1, 1, 10, Linear{})
+	if _, err := trainer.TrainEpoch(inputs, targets[:10], 20, nil); !errors.Is(err, ErrRows) {
+		t.Errorf("Expected ErrRows, found %v", err)
+	}
+	if _, err := trainer.SampleLosses(inputs, targets, make([]float64, 3)); !errors.Is(err, ErrRows) {
+		t.Errorf("Expected ErrRows, found %v", err)
+	}
+	if _, err := trainer.TrainEpoch(inputs, targets, 0, nil); err == nil {
+		t.Errorf("Expected error for zero batch size")
+	}
+}
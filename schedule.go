@@ -0,0 +1,135 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import "math"
+
+// Schedule gives the learning rate as a function of the number of optimizer
+// steps taken. Set a schedule on a trainer with Trainer.SetSchedule.
+type Schedule interface {
+	Rate(step int) float64
+}
+
+// Triangular is a cyclical schedule where the learning rate rises linearly
+// from MinRate to MaxRate over the first half of every cycle of CycleSteps
+// steps and falls back to MinRate over the second half. If Decay is in (0, 1),
+// the amplitude MaxRate - MinRate is multiplied by Decay after every cycle.
+type Triangular struct {
+	MinRate    float64
+	MaxRate    float64
+	CycleSteps int
+	Decay      float64
+}
+
+func (t Triangular) Rate(step int) float64 {
+	cycle, pos := cyclePosition(step, t.CycleSteps)
+	// Distance from the peak in the middle of the cycle, in [0, 1]
+	x := math.Abs(2*pos - 1)
+	return t.MinRate + (1-x)*amplitude(t.MaxRate-t.MinRate, t.Decay, cycle)
+}
+
+// CosineCycle is a cyclical schedule where the learning rate starts every
+// cycle of CycleSteps steps at MaxRate and decreases to MinRate following half
+// a cosine wave, then restarts at MaxRate. If Decay is in (0, 1), the amplitude
+// MaxRate - MinRate is multiplied by Decay after every cycle.
+type CosineCycle struct {
+	MinRate    float64
+	MaxRate    float64
+	CycleSteps int
+	Decay      float64
+}
+
+func (c CosineCycle) Rate(step int) float64 {
+	cycle, pos := cyclePosition(step, c.CycleSteps)
+	return c.MinRate + 0.5*(1+math.Cos(math.Pi*pos))*amplitude(c.MaxRate-c.MinRate, c.Decay, cycle)
+}
+
+// cyclePosition returns the index of the cycle of the step and the position
+// of the step within the cycle in [0, 1)
+func cyclePosition(step, cycleSteps int) (cycle int, pos float64) {
+	if cycleSteps <= 0 {
+		panic("nnet: non-positive cycle length")
+	}
+	return step / cycleSteps, float64(step%cycleSteps) / float64(cycleSteps)
+}
+
+func amplitude(amp, decay float64, cycle int) float64 {
+	if decay > 0 && decay < 1 {
+		amp *= math.Pow(decay, float64(cycle))
+	}
+	return amp
+}
+
+// SetSchedule sets the schedule of the learning rate. Before every step of
+// PartialFit, the learning rate of the optimizer is set to the rate of the
+// schedule at the number of steps taken so far. If sched is nil, the learning
+// rate of the optimizer is left unchanged.
+func (s *Trainer) SetSchedule(sched Schedule) {
+	s.schedule = sched
+}
+
+// LinearDecay changes linearly from Start to End over Steps steps and stays
+// at End afterwards
+type LinearDecay struct {
+	Start, End float64
+	Steps      int
+}
+
+func (l LinearDecay) Rate(step int) float64 {
+	if step >= l.Steps {
+		return l.End
+	}
+	return l.Start + (l.End-l.Start)*float64(step)/float64(l.Steps)
+}
+
+// ReduceOnPlateau is a schedule which multiplies the learning rate by Factor
+// when the validation loss has not improved for Patience consecutive
+// observations, without going below MinRate. Report the validation loss with
+// Observe, typically after every epoch. The loss is considered improved if it
+// is less than the best loss so far by more than the relative Threshold.
+type ReduceOnPlateau struct {
+	Factor    float64 // In (0, 1)
+	Patience  int
+	MinRate   float64
+	Threshold float64
+
+	rate    float64
+	best    float64
+	bad     int
+	started bool
+}
+
+// NewReduceOnPlateau returns a schedule starting at the given rate with the
+// given reduction factor and patience, a minimum rate of zero and a threshold
+// of 1e-4
+func NewReduceOnPlateau(rate, factor float64, patience int) *ReduceOnPlateau {
+	return &ReduceOnPlateau{
+		Factor:    factor,
+		Patience:  patience,
+		Threshold: 1e-4,
+		rate:      rate,
+	}
+}
+
+// Rate returns the current learning rate regardless of the step
+func (r *ReduceOnPlateau) Rate(step int) float64 {
+	return r.rate
+}
+
+// Observe reports a validation loss, reducing the learning rate if the loss
+// has not improved within the patience
+func (r *ReduceOnPlateau) Observe(loss float64) {
+	if !r.started || loss < r.best*(1-r.Threshold) {
+		r.started = true
+		r.best = loss
+		r.bad = 0
+		return
+	}
+	r.bad++
+	if r.bad > r.Patience {
+		r.rate = math.Max(r.rate*r.Factor, r.MinRate)
+		r.bad = 0
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestCyclicalSchedules(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		sched Schedule
+		steps []int
+		rates []float64
+	}{
+		{
+			name:  "triangular",
+			sched: Triangular{MinRate: 1, MaxRate: 3, CycleSteps: 4},
+			steps: []int{0, 1, 2, 3, 4, 6},
+			rates: []float64{1, 2, 3, 2, 1, 3},
+		},
+		{
+			name:  "triangular decay",
+			sched: Triangular{MinRate: 1, MaxRate: 3, CycleSteps: 4, Decay: 0.5},
+			steps: []int{2, 6, 10},
+			rates: []float64{3, 2, 1.5},
+		},
+		{
+			name:  "cosine",
+			sched: CosineCycle{MinRate: 1, MaxRate: 3, CycleSteps: 4},
+			steps: []int{0, 1, 2, 3, 4},
+			rates: []float64{3, 2 + math.Sqrt(0.5), 2, 2 - math.Sqrt(0.5), 3},
+		},
+		{
+			name:  "cosine decay",
+			sched: CosineCycle{MinRate: 1, MaxRate: 3, CycleSteps: 4, Decay: 0.5},
+			steps: []int{0, 4, 8},
+			rates: []float64{3, 2, 1.5},
+		},
+	} {
+		for i, step := range test.steps {
+			if rate := test.sched.Rate(step); math.Abs(rate-test.rates[i]) > 1e-12 {
+				t.Errorf("%v: wrong rate at step %v. Expected %v, found %v", test.name, step, test.rates[i], rate)
+			}
+		}
+	}
+}
+
+// recordingOptimizer records the learning rate of every step
+type recordingOptimizer struct {
+	SGD
+	rates []float64
+}
+
+func (r *recordingOptimizer) Step(parameters, gradient []float64) {
+	r.rates = append(r.rates, r.Rate)
+	r.SGD.Step(parameters, gradient)
+}
+
+func TestSetSchedule(t *testing.T) {
+	trainer, err := NewSimpleTrainer(1, 1, 1, 2, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trainer.RandomizeParameters()
+	opt := &recordingOptimizer{}
+	trainer.SetOptimizer(opt)
+	sched := Triangular{MinRate: 0.001, MaxRate: 0.003, CycleSteps: 4}
+	trainer.SetSchedule(sched)
+	rnd := rand.New(rand.NewSource(1))
+	inputs, targets := NoisySine(10, 0, rnd)
+	for i := 0; i < 6; i++ {
+		if _, err := trainer.PartialFit(inputs, targets); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, rate := range opt.rates {
+		if rate != sched.Rate(i) {
+			t.Errorf("Wrong rate at step %v. Expected %v, found %v", i, sched.Rate(i), rate)
+		}
+	}
+}
+
+func TestReduceOnPlateau(t *testing.T) {
+	r := NewReduceOnPlateau(1, 0.5, 2)
+	r.MinRate = 0.2
+	var rates []float64
+	for _, loss := range []float64{10, 9, 9, 9, 9, 8, 8, 8, 8, 8, 8, 8, 8, 8} {
+		r.Observe(loss)
+		rates = append(rates, r.Rate(0))
+	}
+	want := []float64{1, 1, 1, 1, 0.5, 0.5, 0.5, 0.5, 0.25, 0.25, 0.25, 0.2, 0.2, 0.2}
+	if !floatsEqual(rates, want) {
+		t.Errorf("Wrong rates. Expected %v, found %v", want, rates)
+	}
+}
+
+func TestLinearDecay(t *testing.T) {
+	l := LinearDecay{Start: 1, End: 0.2, Steps: 4}
+	var rates []float64
+	for step := 0; step < 6; step++ {
+		rates = append(rates, l.Rate(step))
+	}
+	want := []float64{1, 0.8, 0.6, 0.4, 0.2, 0.2}
+	if !floatsEqualApprox(rates, want, 1e-14) {
+		t.Errorf("Wrong rates. Expected %v, found %v", want, rates)
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"errors"
+	"math"
+	"sync"
+)
+
+// ShadowPredictor serves the predictions of a primary predictor while
+// evaluating a candidate predictor on the same inputs in the background, to
+// validate a new model on production traffic before it replaces the primary.
+// The divergence between the two is accumulated in ShadowStats. The candidate
+// never affects the returned predictions, and a slow candidate does not slow
+// down the primary: if too many evaluations are already in flight, the
+// candidate evaluation is skipped. Both predictors must be safe for concurrent
+// use, and the ShadowPredictor is itself safe for concurrent use.
+type ShadowPredictor struct {
+	primary   Predictor
+	candidate Predictor
+
+	// Tolerance is the largest absolute difference between the outputs of a
+	// sample for which the predictions agree. It must be set before the
+	// ShadowPredictor is used.
+	Tolerance float64
+
+	slots chan struct{}
+
+	mux      sync.Mutex
+	inFlight int        // number of candidate evaluations running
+	idle     *sync.Cond // signaled when inFlight drops to zero
+	stats    ShadowStats
+	sumAbs   float64 // sum of the absolute output differences
+	sumSq    float64 // sum of the squared output differences
+}
+
+// ShadowStats are the divergence statistics of a ShadowPredictor
+type ShadowStats struct {
+	Samples    int     // Number of samples compared
+	Skipped    int     // Number of samples not compared because too many were in flight
+	Errors     int     // Number of samples where the candidate returned an error
+	Mismatches int     // Number of samples with an output difference beyond the tolerance
+	MeanAbs    float64 // Mean absolute difference of the outputs
+	MaxAbs     float64 // Largest absolute difference of the outputs
+	RMS        float64 // Root mean square difference of the outputs
+}
+
+// NewShadowPredictor returns a predictor serving the predictions of primary
+// and shadowing them with candidate, with at most maxInFlight candidate
+// evaluations running at once. The two must have the same input and output
+// dimensions.
+func NewShadowPredictor(primary, candidate Predictor, maxInFlight int) (*ShadowPredictor, error) {
+	if primary.InputDim() != candidate.InputDim() {
+		return nil, dimensionError("shadow", ErrInputDim, primary.InputDim(), candidate.InputDim())
+	}
+	if primary.OutputDim() != candidate.OutputDim() {
+		return nil, dimensionError("shadow", ErrOutputDim, primary.OutputDim(), candidate.OutputDim())
+	}
+	if maxInFlight <= 0 {
+		return nil, errors.New("shadow: non-positive number of evaluations in flight")
+	}
+	s := &ShadowPredictor{
+		primary:   primary,
+		candidate: candidate,
+		slots:     make(chan struct{}, maxInFlight),
+	}
+	s.idle = sync.NewCond(&s.mux)
+	return s, nil
+}
+
+// InputDim returns the number of inputs of the predictors
+func (s *ShadowPredictor) InputDim() int {
+	return s.primary.InputDim()
+}
+
+// OutputDim returns the number of outputs of the predictors
+func (s *ShadowPredictor) OutputDim() int {
+	return s.primary.OutputDim()
+}
+
+// Predict returns the prediction of the primary predictor and starts the
+// evaluation of the candidate on a copy of the input
+func (s *ShadowPredictor) Predict(input, output []float64) ([]float64, error) {
+	output, err := s.primary.Predict(input, output)
+	if err != nil {
+		return output, err
+	}
+	if !s.acquire(1) {
+		return output, nil
+	}
+	in := append([]float64(nil), input...)
+	want := append([]float64(nil), output...)
+	go func() {
+		defer s.release()
+		got, err := s.candidate.Predict(in, nil)
+		if err != nil {
+			s.record(nil, nil, 1)
+			return
+		}
+		s.record([][]float64{want}, [][]float64{got}, 0)
+	}()
+	return output, nil
+}
+
+// PredictBatch returns the predictions of the primary predictor and starts the
+// evaluation of the candidate on a copy of the inputs
+func (s *ShadowPredictor) PredictBatch(inputs RowMatrix, outputs MutableRowMatrix) (MutableRowMatrix, error) {
+	outputs, err := s.primary.PredictBatch(inputs, outputs)
+	if err != nil {
+		return outputs, err
+	}
+	n, _ := inputs.Dims()
+	if !s.acquire(n) {
+		return outputs, nil
+	}
+	in := copySos(inputs)
+	want := copySos(outputs)
+	go func() {
+		defer s.release()
+		got, err := s.candidate.PredictBatch(in, nil)
+		var batchErr *BatchError
+		if err != nil && !errors.As(err, &batchErr) {
+			s.record(nil, nil, n)
+			return
+		}
+		// Only compare the rows the candidate predicted
+		failed := make(map[int]bool)
+		if batchErr != nil {
+			for _, e := range batchErr.Errors {
+				failed[e.Row] = true
+			}
+		}
+		var w, g [][]float64
+		for i := range want {
+			if !failed[i] {
+				w = append(w, want[i])
+				g = append(g, got.Row(nil, i))
+			}
+		}
+		s.record(w, g, len(failed))
+	}()
+	return outputs, nil
+}
+
+// acquire reserves a slot for a candidate evaluation of n samples. If there is
+// no free slot, the samples are counted as skipped and acquire returns false.
+func (s *ShadowPredictor) acquire(n int) bool {
+	select {
+	case s.slots <- struct{}{}:
+		s.mux.Lock()
+		s.inFlight++
+		s.mux.Unlock()
+		return true
+	default:
+		s.mux.Lock()
+		s.stats.Skipped += n
+		s.mux.Unlock()
+		return false
+	}
+}
+
+// release frees the slot of a finished candidate evaluation
+func (s *ShadowPredictor) release() {
+	s.mux.Lock()
+	s.inFlight--
+	if s.inFlight == 0 {
+		s.idle.Broadcast()
+	}
+	s.mux.Unlock()
+	<-s.slots
+}
+
+// record adds the differences between the primary and candidate outputs of the
+// samples to the statistics, along with the number of candidate errors
+func (s *ShadowPredictor) record(want, got [][]float64, errs int) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.stats.Errors += errs
+	for i, w := range want {
+		var maxDiff float64
+		for j, v := range w {
+			d := math.Abs(v - got[i][j])
+			s.sumAbs += d
+			s.sumSq += d * d
+			maxDiff = math.Max(maxDiff, d)
+		}
+		s.stats.MaxAbs = math.Max(s.stats.MaxAbs, maxDiff)
+		if maxDiff > s.Tolerance {
+			s.stats.Mismatches++
+		}
+		s.stats.Samples++
+	}
+	if s.stats.Samples > 0 {
+		n := float64(s.stats.Samples * s.OutputDim())
+		s.stats.MeanAbs = s.sumAbs / n
+		s.stats.RMS = math.Sqrt(s.sumSq / n)
+	}
+}
+
+// Stats returns the divergence statistics of the evaluations completed so far
+func (s *ShadowPredictor) Stats() ShadowStats {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.stats
+}
+
+// Wait waits until no candidate evaluations are in flight. It may be called
+// concurrently with predictions, in which case it also waits for the
+// evaluations they start before it returns.
+func (s *ShadowPredictor) Wait() {
+	s.mux.Lock()
+	for s.inFlight > 0 {
+		s.idle.Wait()
+	}
+	s.mux.Unlock()
+}
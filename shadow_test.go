@@ -0,0 +1,109 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// offsetPredictor adds an offset to the input. If fail is true, it fails for
+// rows whose first input is negative. If block is not nil, predictions wait
+// until it is closed.
+type offsetPredictor struct {
+	dim    int
+	offset float64
+	fail   bool
+	block  chan struct{}
+}
+
+func (o offsetPredictor) NewPredictor() Predictor { return o }
+func (o offsetPredictor) InputDim() int           { return o.dim }
+func (o offsetPredictor) OutputDim() int          { return o.dim }
+
+func (o offsetPredictor) Predict(input, output []float64) ([]float64, error) {
+	if o.block != nil {
+		<-o.block
+	}
+	if o.fail && input[0] < 0 {
+		return output, errNegative
+	}
+	if output == nil {
+		output = make([]float64, o.dim)
+	}
+	for i, v := range input {
+		output[i] = v + o.offset
+	}
+	return output, nil
+}
+
+func (o offsetPredictor) PredictBatch(inputs RowMatrix, outputs MutableRowMatrix) (MutableRowMatrix, error) {
+	return BatchPredict(o, inputs, outputs, o.dim, o.dim, 3)
+}
+
+func TestShadowPredictor(t *testing.T) {
+	candidate := offsetPredictor{dim: 2, offset: 0.1, fail: true}
+	s, err := NewShadowPredictor(offsetPredictor{dim: 2}, candidate, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Tolerance = 0.05
+	output, err := s.Predict([]float64{1, 2}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !floatsEqual(output, []float64{1, 2}) {
+		t.Errorf("Output not from the primary: %v", output)
+	}
+	// The candidate fails for the second row, which is counted as an error
+	// and not compared
+	outputs, err := s.PredictBatch(SosMatrix{{1, 1}, {-1, 0}, {2, 3}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !floatsEqual(outputs.(SosMatrix)[2], []float64{2, 3}) {
+		t.Errorf("Batch output not from the primary")
+	}
+	s.Predict([]float64{-1, 0}, nil)
+	s.Wait()
+	stats := s.Stats()
+	if stats.Samples != 3 || stats.Errors != 2 || stats.Mismatches != 3 || stats.Skipped != 0 {
+		t.Errorf("Wrong stats %+v", stats)
+	}
+	if math.Abs(stats.MeanAbs-0.1) > 1e-12 || math.Abs(stats.MaxAbs-0.1) > 1e-12 || math.Abs(stats.RMS-0.1) > 1e-12 {
+		t.Errorf("Wrong differences %+v", stats)
+	}
+
+	// Errors of the primary are returned without evaluating the candidate
+	s, _ = NewShadowPredictor(candidate, offsetPredictor{dim: 2}, 4)
+	if _, err := s.Predict([]float64{-1, 0}, nil); err == nil {
+		t.Errorf("Expected error from the primary")
+	}
+	s.Wait()
+	if stats := s.Stats(); stats.Samples != 0 || stats.Errors != 0 {
+		t.Errorf("Candidate evaluated after a primary error %+v", stats)
+	}
+
+	// Evaluations beyond the number in flight are skipped
+	block := make(chan struct{})
+	s, _ = NewShadowPredictor(offsetPredictor{dim: 2}, offsetPredictor{dim: 2, block: block}, 1)
+	s.Predict([]float64{1, 2}, nil)
+	s.Predict([]float64{1, 2}, nil)
+	s.PredictBatch(SosMatrix{{1, 1}, {2, 2}}, nil)
+	close(block)
+	s.Wait()
+	stats = s.Stats()
+	if stats.Samples != 1 || stats.Skipped != 3 || stats.Mismatches != 0 {
+		t.Errorf("Wrong stats with a blocked candidate %+v", stats)
+	}
+
+	if _, err := NewShadowPredictor(offsetPredictor{dim: 2}, offsetPredictor{dim: 3}, 1); !errors.Is(err, ErrInputDim) {
+		t.Errorf("Expected ErrInputDim, found %v", err)
+	}
+	if _, err := NewShadowPredictor(offsetPredictor{dim: 2}, offsetPredictor{dim: 2}, 0); err == nil {
+		t.Errorf("Expected error for no evaluations in flight")
+	}
+}
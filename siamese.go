@@ -0,0 +1,208 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"errors"
+	"math"
+)
+
+// Contrastive is the contrastive loss of a pair of embeddings at Euclidean
+// distance d. The loss of a similar pair is d^2 / 2, pulling the embeddings
+// together, and the loss of a dissimilar pair is max(0, Margin - d)^2 / 2,
+// pushing the embeddings at least Margin apart. If Margin is zero, 1 is used.
+type Contrastive struct {
+	Margin float64
+}
+
+// PairLossDeriv returns the loss of the pair of embeddings a and b, and stores
+// the derivatives of the loss with respect to a and b in dA and dB
+func (c Contrastive) PairLossDeriv(a, b []float64, similar bool, dA, dB []float64) float64 {
+	margin := c.Margin
+	if margin == 0 {
+		margin = 1
+	}
+	d := euclidean(a, b)
+	// The derivative of the loss with respect to a is scale * (a - b)
+	var loss, scale float64
+	switch {
+	case similar:
+		loss = d * d / 2
+		scale = 1
+	case d < margin:
+		loss = (margin - d) * (margin - d) / 2
+		if d > 0 {
+			scale = -(margin - d) / d
+		}
+	}
+	for i := range a {
+		dA[i] = scale * (a[i] - b[i])
+		dB[i] = -dA[i]
+	}
+	return loss
+}
+
+// euclidean returns the Euclidean distance between a and b
+func euclidean(a, b []float64) float64 {
+	var sum float64
+	for i, v := range a {
+		diff := v - b[i]
+		sum += diff * diff
+	}
+	return math.Sqrt(sum)
+}
+
+// SiameseTrainer trains an embedding net for similarity learning. Both inputs
+// of a pair are embedded by the same net, so the two branches of the siamese
+// net share their parameters, and the net is trained with a contrastive loss
+// on the pair of embeddings. The outputs of the net are the embedding.
+type SiameseTrainer struct {
+	*Trainer
+	loss Contrastive
+}
+
+// NewSiameseTrainer returns a siamese trainer of the embedding net. The
+// optimizer, schedule and other training settings of the embedding trainer
+// are used.
+func NewSiameseTrainer(embedding *Trainer, loss Contrastive) *SiameseTrainer {
+	return &SiameseTrainer{
+		Trainer: embedding,
+		loss:    loss,
+	}
+}
+
+// Distance returns the Euclidean distance between the embeddings of a and b
+func (s *SiameseTrainer) Distance(a, b []float64) (float64, error) {
+	embA, err := s.Predict(a, nil)
+	if err != nil {
+		return 0, err
+	}
+	embB, err := s.Predict(b, nil)
+	if err != nil {
+		return 0, err
+	}
+	return euclidean(embA, embB), nil
+}
+
+// PartialFitPairs performs one optimizer step on the mini-batch of pairs,
+// where row i of first and row i of second are a pair which is similar if
+// similar[i] is true. It returns the mean contrastive loss of the pairs before
+// the step.
+func (s *SiameseTrainer) PartialFitPairs(first, second RowMatrix, similar []bool) (float64, error) {
+	nPairs, _ := first.Dims()
+	if len(similar) != nPairs {
+		return 0, dimensionError("partial fit pairs", ErrRows, nPairs, len(similar))
+	}
+	return s.tupleFit("partial fit pairs", []RowMatrix{first, second}, func(i int, emb, deriv [][]float64) float64 {
+		return s.loss.PairLossDeriv(emb[0], emb[1], similar[i], deriv[0], deriv[1])
+	})
+}
+
+// TrainPairEpoch performs one epoch of mini-batch training with
+// PartialFitPairs, taking the pairs in a random order. It returns the mean of
+// the mini-batch losses.
+func (s *SiameseTrainer) TrainPairEpoch(first, second RowMatrix, similar []bool, batchSize int) (float64, error) {
+	if batchSize <= 0 {
+		return 0, errors.New("train pair epoch: non-positive batch size")
+	}
+	nPairs := checkPair(first, second)
+	if len(similar) != nPairs {
+		return 0, dimensionError("train pair epoch", ErrRows, nPairs, len(similar))
+	}
+	order := s.rand().Perm(nPairs)
+	batchSimilar := make([]bool, 0, batchSize)
+	var loss float64
+	var nBatches int
+	for start := 0; start < nPairs; start += batchSize {
+		end := start + batchSize
+		if end > nPairs {
+			end = nPairs
+		}
+		rows := order[start:end]
+		batchSimilar = batchSimilar[:0]
+		for _, r := range rows {
+			batchSimilar = append(batchSimilar, similar[r])
+		}
+		l, err := s.PartialFitPairs(SelectRows(first, rows), SelectRows(second, rows), batchSimilar)
+		if err != nil {
+			return 0, err
+		}
+		loss += l
+		nBatches++
+	}
+	if nBatches == 0 {
+		return 0, nil
+	}
+	return loss / float64(nBatches), nil
+}
+
+// tupleFit performs one optimizer step on a mini-batch of tuples of inputs,
+// such as pairs or triplets, which are all embedded by the net. Row i of each
+// of the inputs makes tuple i. lossDeriv returns the loss of tuple i given the
+// embeddings of its members, and stores the derivative of the loss with
+// respect to each embedding into deriv. The gradient is that of the mean loss
+// over the tuples, and the mean loss before the step is returned.
+func (s *Trainer) tupleFit(op string, inputs []RowMatrix, lossDeriv func(i int, emb, deriv [][]float64) float64) (float64, error) {
+	nTuples, _ := inputs[0].Dims()
+	for _, in := range inputs {
+		r, dim := in.Dims()
+		if dim != s.inputDim {
+			return 0, dimensionError(op, ErrInputDim, s.inputDim, dim)
+		}
+		if r != nTuples {
+			return 0, dimensionError(op, ErrRows, nTuples, r)
+		}
+	}
+	embeddings := make([]SosMatrix, len(inputs))
+	for k, in := range inputs {
+		emb, err := s.PredictBatch(in, nil)
+		if err != nil {
+			return 0, err
+		}
+		embeddings[k] = emb.(SosMatrix)
+	}
+
+	// All of the members of the tuples are stacked into one batch so that the
+	// gradient is computed with a single parallel loop
+	stacked := make(SosMatrix, 0, len(inputs)*nTuples)
+	dLoss := make(SosMatrix, len(inputs)*nTuples)
+	for k, in := range inputs {
+		for i := 0; i < nTuples; i++ {
+			stacked = append(stacked, in.Row(make([]float64, s.inputDim), i))
+			dLoss[k*nTuples+i] = make([]float64, s.outputDim)
+		}
+	}
+	emb := make([][]float64, len(inputs))
+	deriv := make([][]float64, len(inputs))
+	var loss float64
+	for i := 0; i < nTuples; i++ {
+		for k := range inputs {
+			emb[k] = embeddings[k][i]
+			deriv[k] = dLoss[k*nTuples+i]
+		}
+		loss += lossDeriv(i, emb, deriv)
+	}
+	if nTuples == 0 {
+		return 0, nil
+	}
+	n := float64(nTuples)
+	for _, row := range dLoss {
+		for j := range row {
+			row[j] /= n
+		}
+	}
+
+	if len(s.grad) != s.totalNumParameters {
+		s.grad = make([]float64, s.totalNumParameters)
+	}
+	if _, err := s.BatchParameterGradient(stacked, dLoss, s.grad); err != nil {
+		return 0, err
+	}
+	if s.history != nil {
+		s.history.observeGradient(s.grad)
+	}
+	s.step(s.grad)
+	return loss / n, nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestContrastive(t *testing.T) {
+	a, b := []float64{1, 2}, []float64{1.3, 1.6}
+	dA, dB := make([]float64, 2), make([]float64, 2)
+	if loss := (Contrastive{}).PairLossDeriv(a, b, true, dA, dB); math.Abs(loss-0.125) > 1e-14 {
+		t.Errorf("Wrong similar loss %v", loss)
+	}
+	if loss := (Contrastive{Margin: 2}).PairLossDeriv(a, b, false, dA, dB); math.Abs(loss-1.125) > 1e-14 {
+		t.Errorf("Wrong dissimilar loss %v", loss)
+	}
+	if loss := (Contrastive{Margin: 0.1}).PairLossDeriv(a, b, false, dA, dB); loss != 0 || dA[0] != 0 {
+		t.Errorf("Dissimilar pair beyond the margin has loss %v", loss)
+	}
+	for _, similar := range []bool{true, false} {
+		c := Contrastive{Margin: 2}
+		c.PairLossDeriv(a, b, similar, dA, dB)
+		const h = 1e-6
+		tmpA, tmpB := make([]float64, 2), make([]float64, 2)
+		for i := range a {
+			p := append([]float64(nil), a...)
+			p[i] += h
+			plus := c.PairLossDeriv(p, b, similar, tmpA, tmpB)
+			p[i] -= 2 * h
+			minus := c.PairLossDeriv(p, b, similar, tmpA, tmpB)
+			if fd := (plus - minus) / (2 * h); math.Abs(fd-dA[i]) > 1e-6 || dB[i] != -dA[i] {
+				t.Errorf("Similar %v: wrong derivative %v. Finite difference %v, found %v", similar, i, fd, dA[i])
+			}
+		}
+	}
+}
+
+func TestSiameseGradient(t *testing.T) {
+	net, err := NewSimpleTrainer(3, 2, 1, 4, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	net.SetRand(rand.New(rand.NewSource(1)))
+	net.RandomizeParameters()
+	rate := 1e-3
+	net.SetOptimizer(&SGD{Rate: rate})
+	s := NewSiameseTrainer(net, Contrastive{Margin: 3})
+	first := RandomMat(5, 3, rand.NormFloat64)
+	second := RandomMat(5, 3, rand.NormFloat64)
+	similar := []bool{true, false, false, true, false}
+
+	meanLoss := func(params []float64) float64 {
+		c := net.Clone()
+		c.SetParameters(params)
+		var loss float64
+		d := make([]float64, 2)
+		for i := range first {
+			a, _ := c.Predict(first[i], nil)
+			b, _ := c.Predict(second[i], nil)
+			loss += s.loss.PairLossDeriv(a, b, similar[i], d, make([]float64, 2))
+		}
+		return loss / float64(len(first))
+	}
+	before := net.Parameters(nil)
+	loss, err := s.PartialFitPairs(first, second, similar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := meanLoss(before); math.Abs(loss-want) > 1e-14 {
+		t.Errorf("Wrong loss. Expected %v, found %v", want, loss)
+	}
+	after := net.Parameters(nil)
+	const h = 1e-6
+	for i := range before {
+		p := append([]float64(nil), before...)
+		p[i] += h
+		plus := meanLoss(p)
+		p[i] -= 2 * h
+		minus := meanLoss(p)
+		fd := (plus - minus) / (2 * h)
+		if grad := (before[i] - after[i]) / rate; math.Abs(fd-grad) > 1e-5 {
+			t.Errorf("Wrong gradient %v. Finite difference %v, found %v", i, fd, grad)
+		}
+	}
+}
+
+func TestSiameseTrainer(t *testing.T) {
+	net, err := NewSimpleTrainer(2, 2, 1, 6, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(2))
+	net.SetRand(rnd)
+	net.RandomizeParameters()
+	net.SetOptimizer(&Adam{Rate: 0.01})
+	s := NewSiameseTrainer(net, Contrastive{Margin: 2})
+
+	// Inputs are similar if they are on the same side of the line x0 = x1
+	side := func(x []float64) bool { return x[0] > x[1] }
+	nPairs := 400
+	first := RandomMat(nPairs, 2, rnd.NormFloat64)
+	second := RandomMat(nPairs, 2, rnd.NormFloat64)
+	similar := make([]bool, nPairs)
+	for i := range similar {
+		similar[i] = side(first[i]) == side(second[i])
+	}
+	for i := 0; i < 50; i++ {
+		if _, err := s.TrainPairEpoch(first, second, similar, 20); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	test := RandomMat(200, 2, rnd.NormFloat64)
+	var simDist, disDist float64
+	var nSim, nDis int
+	for i := 0; i < len(test)-1; i++ {
+		d, err := s.Distance(test[i], test[i+1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if side(test[i]) == side(test[i+1]) {
+			simDist += d
+			nSim++
+		} else {
+			disDist += d
+			nDis++
+		}
+	}
+	simDist /= float64(nSim)
+	disDist /= float64(nDis)
+	if disDist < 2*simDist {
+		t.Errorf("Dissimilar pairs not separated. Mean distance of similar pairs %v, of dissimilar pairs %v", simDist, disDist)
+	}
+	if _, err := s.PartialFitPairs(first, second, similar[:3]); err == nil {
+		t.Errorf("Expected rows error")
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+// pruneLayer sets the given fraction of the weights of the layer to zero
+func pruneLayer(s *Trainer, layer int, fraction float64, rnd *rand.Rand) {
+	for _, p := range s.parameters[layer] {
+		for k := range p[:len(p)-1] {
+			if rnd.Float64() < fraction {
+				p[k] = 0
+			}
+		}
+	}
+}
+
+func TestSparseLayers(t *testing.T) {
+	s, err := NewSimpleTrainer(20, 3, 2, 30, Tanh{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(1))
+	s.SetRand(rnd)
+	s.RandomizeParameters()
+	pruneLayer(s, 0, 0.9, rnd)
+	pruneLayer(s, 1, 0.2, rnd)
+
+	f := s.Freeze()
+	if l := f.layers[0].(*sumLayer); l.sparse == nil {
+		t.Errorf("Sparse layer not sparsified, sparsity %v", l.sparsity())
+	}
+	if l := f.layers[1].(*sumLayer); l.sparse != nil {
+		t.Errorf("Dense layer sparsified, sparsity %v", l.sparsity())
+	}
+	if s.layers[0].(*sumLayer).sparse != nil {
+		t.Errorf("Trainer layer sparsified")
+	}
+
+	inputs := RandomMat(50, 20, rnd.NormFloat64)
+	want, _ := s.PredictBatch(inputs, nil)
+	got, err := f.PredictBatch(inputs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, row := range got.(SosMatrix) {
+		if !floatsEqualApprox(row, want.(SosMatrix)[i], 1e-13) {
+			t.Errorf("Sample %v: sparse prediction %v, dense %v", i, row, want.(SosMatrix)[i])
+		}
+		out, _ := f.Predict(inputs[i], nil)
+		if !floatsEqualApprox(out, want.(SosMatrix)[i], 1e-13) {
+			t.Errorf("Sample %v: wrong sparse Predict", i)
+		}
+	}
+
+	// Training the trainer does not affect the frozen net
+	params := s.Parameters(nil)
+	for i := range params {
+		params[i] = 1
+	}
+	s.SetParameters(params)
+	again, _ := f.PredictBatch(inputs, nil)
+	if !sosEqual(again.(SosMatrix), got.(SosMatrix)) {
+		t.Errorf("Frozen sparse net changed")
+	}
+}
+
+// BenchmarkSumLayer compares the forward pass of a dense layer of 200 × 200
+// weights with the sparse forward pass at increasing sparsity, to find the
+// crossover used by sparseThreshold
+func BenchmarkSumLayer(b *testing.B) {
+	const n = 200
+	rnd := rand.New(rand.NewSource(1))
+	input := make([]float64, n)
+	for i := range input {
+		input[i] = rnd.NormFloat64()
+	}
+	output := make([]float64, n)
+	for _, sparsity := range []float64{0, 0.25, 0.5, 0.75, 0.9, 0.95} {
+		parameters := make([][]float64, n)
+		for i := range parameters {
+			parameters[i] = make([]float64, n+1)
+			for k := range parameters[i] {
+				if rnd.Float64() >= sparsity {
+					parameters[i][k] = rnd.NormFloat64()
+				}
+			}
+		}
+		dense := newSumLayer(Tanh{}, parameters)
+		sparse := *dense
+		sparse.sparse = newSparseWeights(dense)
+		name := strconv.Itoa(int(100*sparsity)) + "%"
+		b.Run("Dense_"+name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				dense.Forward(input, output)
+			}
+		})
+		b.Run("Sparse_"+name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				sparse.Forward(input, output)
+			}
+		})
+	}
+}
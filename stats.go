@@ -0,0 +1,79 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"expvar"
+	"sync/atomic"
+)
+
+// Counters of the package, updated atomically
+var (
+	statPredictions uint64 // rows predicted by BatchPredict
+	statBatches     uint64 // calls to BatchPredict
+	statWorkers     int64  // workers of the last parallel loop
+	statGrainSize   int64  // grain size of the last parallel loop
+)
+
+// Stats are counters of the work done by the package since the program
+// started
+type Stats struct {
+	Predictions uint64 // Number of rows predicted in batches
+	Batches     uint64 // Number of batch predictions
+	Workers     int    // Number of workers used by the last parallel loop
+	GrainSize   int    // Grain size of the last parallel loop
+}
+
+// ReadStats returns the current counters of the package
+func ReadStats() Stats {
+	return Stats{
+		Predictions: atomic.LoadUint64(&statPredictions),
+		Batches:     atomic.LoadUint64(&statBatches),
+		Workers:     int(atomic.LoadInt64(&statWorkers)),
+		GrainSize:   int(atomic.LoadInt64(&statGrainSize)),
+	}
+}
+
+// PublishStats publishes the counters of the package (see Stats) with expvar
+// under the given name, so they are served at /debug/vars along with the other
+// expvar variables. Like expvar.Publish, PublishStats panics if the name is
+// already in use.
+func PublishStats(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return ReadStats()
+	}))
+}
+
+// NetStats describe the size of a net
+type NetStats struct {
+	InputDim      int
+	OutputDim     int
+	LayerSizes    []int
+	NumParameters int
+	GrainSize     int
+}
+
+// Stats returns the size of the net
+func (n *Net) Stats() NetStats {
+	return NetStats{
+		InputDim:      n.inputDim,
+		OutputDim:     n.outputDim,
+		LayerSizes:    n.LayerSizes(),
+		NumParameters: n.totalNumParameters,
+		GrainSize:     n.grainSize,
+	}
+}
+
+// Publish publishes the size of the net (see NetStats) with expvar under the
+// given name. The values are a snapshot of the net when Publish is called, so
+// serving them does not race with training or surgery on the net, but later
+// changes to its size are not shown. Like expvar.Publish, Publish panics if
+// the name is already in use.
+func (n *Net) Publish(name string) {
+	stats := n.Stats()
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return stats
+	}))
+}
@@ -0,0 +1,67 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"encoding/json"
+	"expvar"
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	trainer, err := NewSimpleTrainer(3, 2, 1, 4, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trainer.RandomizeParameters()
+	before := ReadStats()
+	inputs := RandomMat(50, 3, rand.NormFloat64)
+	if _, err := trainer.PredictBatch(inputs, nil); err != nil {
+		t.Fatal(err)
+	}
+	after := ReadStats()
+	if after.Batches-before.Batches != 1 || after.Predictions-before.Predictions != 50 {
+		t.Errorf("Wrong counters. Before %+v, after %+v", before, after)
+	}
+	workers := runtime.GOMAXPROCS(0)
+	if sequential {
+		workers = 1
+	}
+	if after.Workers != workers || after.GrainSize != trainer.GrainSize() {
+		t.Errorf("Wrong parallel loop stats %+v", after)
+	}
+
+	PublishStats("nnet_test_stats")
+	var published Stats
+	if err := json.Unmarshal([]byte(expvar.Get("nnet_test_stats").String()), &published); err != nil {
+		t.Fatal(err)
+	}
+	if published.Predictions < after.Predictions {
+		t.Errorf("Wrong published stats %+v", published)
+	}
+
+	trainer.Publish("nnet_test_net")
+	var net NetStats
+	if err := json.Unmarshal([]byte(expvar.Get("nnet_test_net").String()), &net); err != nil {
+		t.Fatal(err)
+	}
+	if net.InputDim != 3 || net.OutputDim != 2 || net.NumParameters != trainer.TotalNumParameters() ||
+		!equalInts(net.LayerSizes, []int{4, 2}) {
+		t.Errorf("Wrong published net stats %+v", net)
+	}
+
+	// The published values are a snapshot, not changed by surgery on the net
+	if err := trainer.InsertLayer(1, []Neuron{TanhNeuron, TanhNeuron}); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(expvar.Get("nnet_test_net").String()), &net); err != nil {
+		t.Fatal(err)
+	}
+	if !equalInts(net.LayerSizes, []int{4, 2}) {
+		t.Errorf("Published net stats changed to %+v", net)
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// SelectRows returns a view of the given rows of m, so that row i of the view
+// is row rows[i] of m. The data is not copied. If m is a RowViewer, so is the
+// view. Rows may be repeated.
+func SelectRows(m RowMatrix, rows []int) RowMatrix {
+	nRows, _ := m.Dims()
+	for _, r := range rows {
+		if r < 0 || r >= nRows {
+			panic("nnet: row index out of range")
+		}
+	}
+	s := rowSubset{m: m, rows: rows}
+	if rv, ok := m.(RowViewer); ok {
+		return rowViewSubset{rowSubset: s, rv: rv}
+	}
+	return s
+}
+
+// rowSubset is a view of some of the rows of a matrix
+type rowSubset struct {
+	m    RowMatrix
+	rows []int
+}
+
+func (s rowSubset) Dims() (r, c int) {
+	_, c = s.m.Dims()
+	return len(s.rows), c
+}
+
+func (s rowSubset) At(i, j int) float64 {
+	return s.m.At(s.rows[i], j)
+}
+
+func (s rowSubset) Row(d []float64, i int) []float64 {
+	return s.m.Row(d, s.rows[i])
+}
+
+type rowViewSubset struct {
+	rowSubset
+	rv RowViewer
+}
+
+func (s rowViewSubset) RowView(r int) []float64 {
+	return s.rv.RowView(s.rows[r])
+}
+
+// Shuffle returns views of the rows of inputs and targets in the same random
+// order. If rnd is nil, the global source of math/rand is used.
+func Shuffle(inputs, targets RowMatrix, rnd *rand.Rand) (RowMatrix, RowMatrix) {
+	n := checkPair(inputs, targets)
+	perm := orGlobal(rnd).Perm(n)
+	return SelectRows(inputs, perm), SelectRows(targets, perm)
+}
+
+// Split returns views of the first n rows and of the remaining rows of inputs
+// and targets, for example to split a shuffled data set into training and test
+// sets.
+func Split(inputs, targets RowMatrix, n int) (headInputs, headTargets, tailInputs, tailTargets RowMatrix) {
+	nRows := checkPair(inputs, targets)
+	if n < 0 || n > nRows {
+		panic("nnet: split index out of range")
+	}
+	head := rangeRows(0, n)
+	tail := rangeRows(n, nRows)
+	return SelectRows(inputs, head), SelectRows(targets, head), SelectRows(inputs, tail), SelectRows(targets, tail)
+}
+
+// HeadRows returns views of the first n rows of inputs and targets, or all of
+// the rows if there are fewer than n
+func HeadRows(inputs, targets RowMatrix, n int) (RowMatrix, RowMatrix) {
+	nRows := checkPair(inputs, targets)
+	if n > nRows {
+		n = nRows
+	}
+	rows := rangeRows(0, n)
+	return SelectRows(inputs, rows), SelectRows(targets, rows)
+}
+
+// TailRows returns views of the last n rows of inputs and targets, or all of
+// the rows if there are fewer than n
+func TailRows(inputs, targets RowMatrix, n int) (RowMatrix, RowMatrix) {
+	nRows := checkPair(inputs, targets)
+	if n > nRows {
+		n = nRows
+	}
+	rows := rangeRows(nRows-n, nRows)
+	return SelectRows(inputs, rows), SelectRows(targets, rows)
+}
+
+// BalancedSubsample returns views of a random subset of the samples with at
+// most perClass samples of each class, keeping the original order of the rows.
+// The class of a sample is the index of the largest element of its target, as
+// for one-hot targets, or the value of the target rounded to the nearest
+// integer if the targets have a single column. If rnd is nil, the global
+// source of math/rand is used.
+func BalancedSubsample(inputs, targets RowMatrix, perClass int, rnd *rand.Rand) (RowMatrix, RowMatrix) {
+	nRows := checkPair(inputs, targets)
+	rnd = orGlobal(rnd)
+	classes := make(map[int][]int)
+	var order []int
+	_, c := targets.Dims()
+	target := make([]float64, c)
+	for i := 0; i < nRows; i++ {
+		target = targets.Row(target, i)
+		class := classOf(target)
+		if _, ok := classes[class]; !ok {
+			order = append(order, class)
+		}
+		classes[class] = append(classes[class], i)
+	}
+	var rows []int
+	for _, class := range order {
+		members := classes[class]
+		if len(members) > perClass {
+			rnd.Shuffle(len(members), func(i, j int) { members[i], members[j] = members[j], members[i] })
+			members = members[:perClass]
+		}
+		rows = append(rows, members...)
+	}
+	sort.Ints(rows)
+	return SelectRows(inputs, rows), SelectRows(targets, rows)
+}
+
+func classOf(target []float64) int {
+	if len(target) == 1 {
+		v := target[0]
+		if v < 0 {
+			return -int(-v + 0.5)
+		}
+		return int(v + 0.5)
+	}
+	var best int
+	for j, v := range target {
+		if v > target[best] {
+			best = j
+		}
+	}
+	return best
+}
+
+// checkPair panics if inputs and targets have different numbers of rows, and
+// returns the number of rows otherwise
+func checkPair(inputs, targets RowMatrix) int {
+	r, _ := inputs.Dims()
+	rt, _ := targets.Dims()
+	if r != rt {
+		panic(dimensionError("", ErrRows, r, rt))
+	}
+	return r
+}
+
+func rangeRows(start, end int) []int {
+	rows := make([]int, end-start)
+	for i := range rows {
+		rows[i] = start + i
+	}
+	return rows
+}
@@ -0,0 +1,119 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// rowsOf returns the first element of every row of m
+func rowsOf(m RowMatrix) []int {
+	r, c := m.Dims()
+	row := make([]float64, c)
+	rows := make([]int, r)
+	for i := range rows {
+		rows[i] = int(m.Row(row, i)[0])
+	}
+	return rows
+}
+
+func TestSubsets(t *testing.T) {
+	const n = 10
+	inputs := make(SosMatrix, n)
+	targets := make(SosMatrix, n)
+	for i := range inputs {
+		inputs[i] = []float64{float64(i), 0}
+		targets[i] = []float64{float64(i)}
+	}
+
+	view := SelectRows(inputs, []int{3, 1, 3})
+	if r, c := view.Dims(); r != 3 || c != 2 {
+		t.Errorf("Wrong view dimensions %v×%v", r, c)
+	}
+	if !equalInts(rowsOf(view), []int{3, 1, 3}) || view.At(1, 0) != 1 {
+		t.Errorf("Wrong view rows %v", rowsOf(view))
+	}
+	rv, ok := view.(RowViewer)
+	if !ok {
+		t.Fatalf("View of a RowViewer is not a RowViewer")
+	}
+	// The view shares the data
+	rv.RowView(0)[1] = 5
+	if inputs[3][1] != 5 {
+		t.Errorf("View does not share the data")
+	}
+	inputs[3][1] = 0
+	if _, ok := SelectRows(rowSubset{m: inputs, rows: []int{0}}, []int{0}).(RowViewer); ok {
+		t.Errorf("View of a non-RowViewer is a RowViewer")
+	}
+
+	in, tgt := Shuffle(inputs, targets, rand.New(rand.NewSource(1)))
+	shuffled := rowsOf(in)
+	if !equalInts(shuffled, rowsOf(tgt)) {
+		t.Errorf("Inputs and targets shuffled differently")
+	}
+	sorted := append([]int(nil), shuffled...)
+	sort.Ints(sorted)
+	if !equalInts(sorted, rangeRows(0, n)) {
+		t.Errorf("Shuffle is not a permutation: %v", shuffled)
+	}
+	in2, _ := Shuffle(inputs, targets, rand.New(rand.NewSource(1)))
+	if !equalInts(rowsOf(in2), shuffled) {
+		t.Errorf("Shuffle not reproducible")
+	}
+
+	hi, ht, ti, tt := Split(inputs, targets, 7)
+	if !equalInts(rowsOf(hi), rangeRows(0, 7)) || !equalInts(rowsOf(ht), rangeRows(0, 7)) ||
+		!equalInts(rowsOf(ti), rangeRows(7, 10)) || !equalInts(rowsOf(tt), rangeRows(7, 10)) {
+		t.Errorf("Wrong split")
+	}
+	hi, _ = HeadRows(inputs, targets, 3)
+	if !equalInts(rowsOf(hi), []int{0, 1, 2}) {
+		t.Errorf("Wrong head %v", rowsOf(hi))
+	}
+	ti, _ = TailRows(inputs, targets, 3)
+	if !equalInts(rowsOf(ti), []int{7, 8, 9}) {
+		t.Errorf("Wrong tail %v", rowsOf(ti))
+	}
+	hi, _ = HeadRows(inputs, targets, 20)
+	if r, _ := hi.Dims(); r != n {
+		t.Errorf("Head longer than the data has %v rows", r)
+	}
+}
+
+func TestBalancedSubsample(t *testing.T) {
+	// Class 0 has 6 samples, class 1 has 3 and class 2 has 1
+	classes := []int{0, 1, 0, 0, 2, 1, 0, 0, 1, 0}
+	inputs := make(SosMatrix, len(classes))
+	oneHot := make(SosMatrix, len(classes))
+	labels := make(SosMatrix, len(classes))
+	for i, c := range classes {
+		inputs[i] = []float64{float64(i)}
+		oneHot[i] = make([]float64, 3)
+		oneHot[i][c] = 1
+		labels[i] = []float64{float64(c)}
+	}
+	for _, targets := range []SosMatrix{oneHot, labels} {
+		in, tgt := BalancedSubsample(inputs, targets, 2, rand.New(rand.NewSource(1)))
+		rows := rowsOf(in)
+		if !sort.IntsAreSorted(rows) {
+			t.Errorf("Rows not in the original order: %v", rows)
+		}
+		counts := make(map[int]int)
+		r, c := tgt.Dims()
+		target := make([]float64, c)
+		for i := 0; i < r; i++ {
+			counts[classOf(tgt.Row(target, i))]++
+			if classes[rows[i]] != classOf(target) {
+				t.Errorf("Inputs and targets do not match")
+			}
+		}
+		if counts[0] != 2 || counts[1] != 2 || counts[2] != 1 {
+			t.Errorf("Wrong class counts %v", counts)
+		}
+	}
+}
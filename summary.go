@@ -0,0 +1,67 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// Summary returns a human-readable table describing the architecture of the
+// net, similar to model.summary() in Keras. There is one row per layer giving
+// the layer index, the type of its neurons, their activator, the number of
+// outputs, the number of parameters, and the cumulative number of parameters up
+// to and including the layer. Frozen layers are marked, and the table is
+// followed by the total, trainable and non-trainable parameter counts.
+func (n *Net) Summary() string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(&buf, "Input dimension: %d\n", n.InputDim())
+	fmt.Fprintln(w, "Layer\tType\tActivator\tOutputs\tParameters\tCumulative")
+	var total, trainable int
+	for i := 0; i < n.NumLayers(); i++ {
+		types, activators := n.layerKinds(i)
+		nParams := n.LayerNumParameters(i)
+		total += nParams
+		index := fmt.Sprint(i)
+		if n.LayerTrainable(i) {
+			trainable += nParams
+		} else {
+			index += " (frozen)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\n", index, types, activators, len(n.neurons[i]), nParams, total)
+	}
+	w.Flush()
+	fmt.Fprintf(&buf, "Total parameters: %d\n", total)
+	fmt.Fprintf(&buf, "Trainable parameters: %d\n", trainable)
+	fmt.Fprintf(&buf, "Non-trainable parameters: %d\n", total-trainable)
+	return buf.String()
+}
+
+// layerKinds returns the distinct neuron types and activators of the given
+// layer, separated by commas. Neurons other than SumNeurons have no separate
+// activator and are listed as "-".
+func (n *Net) layerKinds(layer int) (types, activators string) {
+	var ts, as []string
+	seenType := make(map[string]bool)
+	seenAct := make(map[string]bool)
+	for _, neuron := range n.neurons[layer] {
+		t, a := describeNeuron(neuron), "-"
+		if sum, ok := neuron.(SumNeuron); ok {
+			t, a = "Sum", describeActivator(sum.Activator)
+		}
+		if !seenType[t] {
+			seenType[t] = true
+			ts = append(ts, t)
+		}
+		if !seenAct[a] {
+			seenAct[a] = true
+			as = append(as, a)
+		}
+	}
+	return strings.Join(ts, ", "), strings.Join(as, ", ")
+}
@@ -0,0 +1,46 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSummary(t *testing.T) {
+	s, err := NewTrainer(3, 2, [][]Neuron{
+		{TanhNeuron, TanhNeuron, TanhNeuron, TanhNeuron},
+		{LinearNeuron, SigmoidNeuron},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetLayerTrainable(0, false)
+	lines := strings.Split(strings.TrimSuffix(s.Summary(), "\n"), "\n")
+	want := [][]string{
+		{"Input", "dimension:", "3"},
+		{"Layer", "Type", "Activator", "Outputs", "Parameters", "Cumulative"},
+		{"0", "(frozen)", "Sum", "Tanh", "4", "16", "16"},
+		{"1", "Sum", "Linear,", "Sigmoid", "2", "10", "26"},
+		{"Total", "parameters:", "26"},
+		{"Trainable", "parameters:", "10"},
+		{"Non-trainable", "parameters:", "16"},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("Wrong number of lines. Expected %v, found %v:\n%v", len(want), len(lines), s.Summary())
+	}
+	for i, line := range lines {
+		if got := strings.Fields(line); strings.Join(got, " ") != strings.Join(want[i], " ") {
+			t.Errorf("Wrong line %v. Expected %q, found %q", i, want[i], line)
+		}
+	}
+	// The columns of the table are aligned
+	header := lines[1]
+	for _, line := range lines[2:4] {
+		if strings.Index(line, "Sum") != strings.Index(header, "Type") {
+			t.Errorf("Columns not aligned:\n%v", s.Summary())
+		}
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import "errors"
+
+// InsertLayer inserts a new layer with the given neurons so that it becomes
+// layer idx. The layer must be a hidden layer, so idx may not be larger than
+// the index of the output layer. The parameters of the new layer are randomized
+// using the net-wide initializer, and the layer after it is resized to account
+// for its new number of inputs, preserving the existing weights where possible.
+func (s *Trainer) InsertLayer(idx int, neurons []Neuron) error {
+	if idx < 0 || idx > len(s.neurons)-1 {
+		return errors.New("net: layer index out of range")
+	}
+	if len(neurons) == 0 {
+		return errors.New("net: layer with no neurons")
+	}
+	layer := make([]Neuron, len(neurons))
+	copy(layer, neurons)
+	nInputs := s.layerInputs(idx)
+	params := make([][]float64, len(layer))
+	for j, neuron := range layer {
+		params[j] = make([]float64, neuron.NumParameters(nInputs))
+	}
+
+	s.neurons = append(s.neurons, nil)
+	copy(s.neurons[idx+1:], s.neurons[idx:])
+	s.neurons[idx] = layer
+	s.parameters = append(s.parameters, nil)
+	copy(s.parameters[idx+1:], s.parameters[idx:])
+	s.parameters[idx] = params
+	s.frozen = append(s.frozen, false)
+	copy(s.frozen[idx+1:], s.frozen[idx:])
+	s.frozen[idx] = false
+	s.layerInitializers = append(s.layerInitializers, nil)
+	copy(s.layerInitializers[idx+1:], s.layerInitializers[idx:])
+	s.layerInitializers[idx] = nil
+	s.randomizeLayer(idx)
+
+	s.resizeLayerInputs(idx+1, nInputs, len(layer))
+	s.updateSize()
+	return nil
+}
+
+// RemoveLayer removes the hidden layer with the given index. The layer after it
+// is resized to account for its new number of inputs, preserving the existing
+// weights where possible.
+func (s *Trainer) RemoveLayer(idx int) error {
+	if idx < 0 || idx >= len(s.neurons)-1 {
+		return errors.New("net: can only remove hidden layers")
+	}
+	oldInputs := len(s.neurons[idx])
+	newInputs := s.layerInputs(idx)
+
+	s.neurons = append(s.neurons[:idx], s.neurons[idx+1:]...)
+	s.parameters = append(s.parameters[:idx], s.parameters[idx+1:]...)
+	s.frozen = append(s.frozen[:idx], s.frozen[idx+1:]...)
+	s.layerInitializers = append(s.layerInitializers[:idx], s.layerInitializers[idx+1:]...)
+
+	s.resizeLayerInputs(idx, oldInputs, newInputs)
+	s.updateSize()
+	return nil
+}
+
+// ResizeLayer changes the number of neurons in the hidden layer with the given
+// index. If the layer grows, the new neurons have the same definition as the
+// last neuron of the layer and have random parameters. If the layer shrinks,
+// the last neurons are removed. The layer after it is resized to account for
+// its new number of inputs, preserving the existing weights where possible.
+func (s *Trainer) ResizeLayer(idx, size int) error {
+	if idx < 0 || idx >= len(s.neurons)-1 {
+		return errors.New("net: can only resize hidden layers")
+	}
+	if size <= 0 {
+		return errors.New("net: layer with no neurons")
+	}
+	oldSize := len(s.neurons[idx])
+	if size == oldSize {
+		return nil
+	}
+	if size < oldSize {
+		s.neurons[idx] = s.neurons[idx][:size:size]
+		s.parameters[idx] = s.parameters[idx][:size:size]
+	} else {
+		nInputs := s.layerInputs(idx)
+		last := s.neurons[idx][oldSize-1]
+		for j := oldSize; j < size; j++ {
+			p := make([]float64, last.NumParameters(nInputs))
+			s.randomizeNeuron(last, p)
+			s.neurons[idx] = append(s.neurons[idx], last)
+			s.parameters[idx] = append(s.parameters[idx], p)
+		}
+	}
+	s.resizeLayerInputs(idx+1, oldSize, size)
+	s.updateSize()
+	return nil
+}
+
+// layerInputs returns the number of inputs to the layer with the given index
+func (s *Trainer) layerInputs(idx int) int {
+	if idx == 0 {
+		return s.inputDim
+	}
+	return len(s.neurons[idx-1])
+}
+
+// resizeLayerInputs changes the number of inputs of all the neurons in the given
+// layer. The parameters are randomized, and then for SumNeurons the weights of
+// the inputs which still exist and the bias are copied from the old parameters.
+func (s *Trainer) resizeLayerInputs(idx, oldInputs, newInputs int) {
+	for j, neuron := range s.neurons[idx] {
+		old := s.parameters[idx][j]
+		p := make([]float64, neuron.NumParameters(newInputs))
+		s.randomizeNeuron(neuron, p)
+		if _, ok := neuron.(SumNeuron); ok {
+			n := oldInputs
+			if newInputs < n {
+				n = newInputs
+			}
+			copy(p[:n], old[:n])
+			p[len(p)-1] = old[len(old)-1]
+		}
+		s.parameters[idx][j] = p
+	}
+}
+
+// updateSize recomputes the values that depend on the size of the net after
+// the architecture has changed
+func (s *Trainer) updateSize() {
+	var total int
+	for _, layer := range s.parameters {
+		for _, p := range layer {
+			total += len(p)
+		}
+	}
+	s.totalNumParameters = total
+	s.tied = nil
+	s.pruned = nil
+	s.updateLayers()
+	s.setGrainSize()
+}
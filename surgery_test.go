@@ -0,0 +1,95 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import "testing"
+
+// checkArchitecture checks that the parameters of the trainer are consistent
+// with the neurons and that the net can make predictions
+func checkArchitecture(t *testing.T, s *Trainer, sizes []int, name string) {
+	if got := s.LayerSizes(); !equalInts(got, sizes) {
+		t.Errorf("%v: wrong layer sizes. Expected %v, found %v", name, sizes, got)
+		return
+	}
+	nInputs := s.InputDim()
+	var total int
+	for i, layer := range s.neurons {
+		for j, neuron := range layer {
+			if len(s.parameters[i][j]) != neuron.NumParameters(nInputs) {
+				t.Errorf("%v: wrong number of parameters for neuron %v in layer %v", name, j, i)
+			}
+			total += len(s.parameters[i][j])
+		}
+		nInputs = len(layer)
+	}
+	if total != s.TotalNumParameters() {
+		t.Errorf("%v: total number of parameters not updated", name)
+	}
+	if _, err := s.Predict(make([]float64, s.InputDim()), nil); err != nil {
+		t.Errorf("%v: error predicting: %v", name, err)
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, v := range a {
+		if v != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNetworkSurgery(t *testing.T) {
+	s, err := NewSimpleTrainer(3, 2, 2, 4, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.RandomizeParameters()
+	outputParams := append([]float64(nil), s.parameters[2][0]...)
+
+	newLayer := []Neuron{SigmoidNeuron, SigmoidNeuron, SigmoidNeuron}
+	if err := s.InsertLayer(3, newLayer); err == nil {
+		t.Errorf("No error inserting a layer after the output layer")
+	}
+	if err := s.InsertLayer(1, newLayer); err != nil {
+		t.Fatal(err)
+	}
+	checkArchitecture(t, s, []int{4, 3, 4, 2}, "insert")
+
+	firstLayer := append([]float64(nil), s.parameters[0][1]...)
+	if err := s.ResizeLayer(2, 6); err != nil {
+		t.Fatal(err)
+	}
+	checkArchitecture(t, s, []int{4, 3, 6, 2}, "grow")
+	if !floatsEqual(s.parameters[0][1], firstLayer) {
+		t.Errorf("Resizing a layer changed an unrelated layer")
+	}
+	// The weights of the output layer for the first 4 inputs and the bias
+	// should be preserved
+	out := s.parameters[3][0]
+	if !floatsEqual(out[:4], outputParams[:4]) || out[len(out)-1] != outputParams[len(outputParams)-1] {
+		t.Errorf("Output weights not preserved when growing a layer")
+	}
+
+	if err := s.ResizeLayer(2, 2); err != nil {
+		t.Fatal(err)
+	}
+	checkArchitecture(t, s, []int{4, 3, 2, 2}, "shrink")
+	out = s.parameters[3][0]
+	if !floatsEqual(out[:2], outputParams[:2]) || out[len(out)-1] != outputParams[len(outputParams)-1] {
+		t.Errorf("Output weights not preserved when shrinking a layer")
+	}
+
+	if err := s.RemoveLayer(3); err == nil {
+		t.Errorf("No error removing the output layer")
+	}
+	if err := s.RemoveLayer(1); err != nil {
+		t.Fatal(err)
+	}
+	checkArchitecture(t, s, []int{4, 2, 2}, "remove")
+}
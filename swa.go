@@ -0,0 +1,57 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+// SWA performs stochastic weight averaging. It keeps the running mean of
+// snapshots of the parameters of a trainer, typically taken at the end of
+// every epoch (or cycle of a cyclical schedule) over the last part of
+// training. The net with the averaged parameters often generalizes better than
+// the final parameters.
+//
+// The architecture of the trainer must not change while averaging.
+type SWA struct {
+	trainer *Trainer
+	mean    []float64
+	params  []float64
+	n       int
+}
+
+// NewSWA returns an averager of the parameters of the trainer with no
+// snapshots
+func NewSWA(s *Trainer) *SWA {
+	return &SWA{trainer: s}
+}
+
+// Update adds a snapshot of the current parameters of the trainer to the
+// average
+func (w *SWA) Update() {
+	w.params = w.trainer.Parameters(w.params)
+	if w.n == 0 || len(w.mean) != len(w.params) {
+		w.mean = append(w.mean[:0], w.params...)
+		w.n = 1
+		return
+	}
+	w.n++
+	n := float64(w.n)
+	for i, p := range w.params {
+		w.mean[i] += (p - w.mean[i]) / n
+	}
+}
+
+// NumSnapshots returns the number of snapshots in the average
+func (w *SWA) NumSnapshots() int {
+	return w.n
+}
+
+// Net returns a new net with the architecture of the trainer and the averaged
+// parameters. It panics if there are no snapshots.
+func (w *SWA) Net() *Net {
+	if w.n == 0 {
+		panic("nnet: no snapshots to average")
+	}
+	t := w.trainer.Clone()
+	t.SetParameters(w.mean)
+	return t.Net
+}
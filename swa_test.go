@@ -0,0 +1,46 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSWA(t *testing.T) {
+	trainer, err := NewSimpleTrainer(2, 1, 1, 3, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(1))
+	trainer.SetRand(rnd)
+	swa := NewSWA(trainer)
+
+	var snapshots [][]float64
+	for i := 0; i < 3; i++ {
+		trainer.RandomizeParameters()
+		snapshots = append(snapshots, trainer.Parameters(nil))
+		swa.Update()
+	}
+	if swa.NumSnapshots() != 3 {
+		t.Errorf("Wrong number of snapshots. Expected 3, found %v", swa.NumSnapshots())
+	}
+	want := make([]float64, len(snapshots[0]))
+	for _, s := range snapshots {
+		for i, v := range s {
+			want[i] += v / 3
+		}
+	}
+	net := swa.Net()
+	if !floatsEqualApprox(net.Parameters(nil), want, 1e-12) {
+		t.Errorf("Wrong averaged parameters")
+	}
+	if !floatsEqual(trainer.Parameters(nil), snapshots[2]) {
+		t.Errorf("Trainer parameters changed")
+	}
+	if !SameArchitecture(net, trainer.Net) {
+		t.Errorf("Averaged net has a different architecture")
+	}
+}
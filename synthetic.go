@@ -0,0 +1,158 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math"
+	"math/rand"
+)
+
+// The functions below generate data sets for standard test problems. Each
+// returns n samples as inputs and targets. Random numbers are drawn from rnd so
+// that the data is reproducible; if rnd is nil the global source of math/rand is
+// used. The noise is the standard deviation of Gaussian noise added to the
+// targets (or to the inputs for the classification problems).
+
+// Friedman1 generates the Friedman #1 regression problem. There are ten inputs
+// drawn from U(0, 1), of which only the first five affect the target
+//
+//	y = 10 sin(π x0 x1) + 20 (x2 - 0.5)² + 10 x3 + 5 x4
+func Friedman1(n int, noise float64, rnd *rand.Rand) (inputs, targets SosMatrix) {
+	rnd = orGlobal(rnd)
+	inputs, targets = newDataset(n, 10, 1)
+	for i, x := range inputs {
+		for j := range x {
+			x[j] = rnd.Float64()
+		}
+		targets[i][0] = 10*math.Sin(math.Pi*x[0]*x[1]) + 20*(x[2]-0.5)*(x[2]-0.5) + 10*x[3] + 5*x[4] +
+			noise*rnd.NormFloat64()
+	}
+	return inputs, targets
+}
+
+// Friedman2 generates the Friedman #2 regression problem, with four inputs
+// x0 ~ U(0, 100), x1 ~ U(40π, 560π), x2 ~ U(0, 1) and x3 ~ U(1, 11), and
+//
+//	y = sqrt(x0² + (x1 x2 - 1/(x1 x3))²)
+func Friedman2(n int, noise float64, rnd *rand.Rand) (inputs, targets SosMatrix) {
+	rnd = orGlobal(rnd)
+	inputs, targets = newDataset(n, 4, 1)
+	for i, x := range inputs {
+		friedmanInputs(x, rnd)
+		v := x[1]*x[2] - 1/(x[1]*x[3])
+		targets[i][0] = math.Sqrt(x[0]*x[0]+v*v) + noise*rnd.NormFloat64()
+	}
+	return inputs, targets
+}
+
+// Friedman3 generates the Friedman #3 regression problem, with the same inputs
+// as Friedman2 and
+//
+//	y = atan((x1 x2 - 1/(x1 x3)) / x0)
+func Friedman3(n int, noise float64, rnd *rand.Rand) (inputs, targets SosMatrix) {
+	rnd = orGlobal(rnd)
+	inputs, targets = newDataset(n, 4, 1)
+	for i, x := range inputs {
+		friedmanInputs(x, rnd)
+		targets[i][0] = math.Atan((x[1]*x[2]-1/(x[1]*x[3]))/x[0]) + noise*rnd.NormFloat64()
+	}
+	return inputs, targets
+}
+
+func friedmanInputs(x []float64, rnd *rand.Rand) {
+	x[0] = 100 * rnd.Float64()
+	x[1] = 40*math.Pi + 520*math.Pi*rnd.Float64()
+	x[2] = rnd.Float64()
+	x[3] = 1 + 10*rnd.Float64()
+}
+
+// TwoSpirals generates the two spirals classification problem. The inputs are
+// points on two interleaved spirals in the plane, each making one and a half
+// turns, and the target is 0 for the first spiral and 1 for the second. The
+// samples alternate between the spirals.
+func TwoSpirals(n int, noise float64, rnd *rand.Rand) (inputs, targets SosMatrix) {
+	rnd = orGlobal(rnd)
+	inputs, targets = newDataset(n, 2, 1)
+	for i, x := range inputs {
+		class := i % 2
+		// Taking the square root spreads the points evenly along the spiral
+		theta := math.Sqrt(rnd.Float64()) * 3 * math.Pi
+		r := theta / (3 * math.Pi)
+		phase := float64(class) * math.Pi
+		x[0] = r*math.Cos(theta+phase) + noise*rnd.NormFloat64()
+		x[1] = r*math.Sin(theta+phase) + noise*rnd.NormFloat64()
+		targets[i][0] = float64(class)
+	}
+	return inputs, targets
+}
+
+// XOR generates the exclusive or problem. Each input is a random corner of the
+// unit square, and the target is 1 if exactly one of the coordinates of the
+// corner is 1 and 0 otherwise.
+func XOR(n int, noise float64, rnd *rand.Rand) (inputs, targets SosMatrix) {
+	rnd = orGlobal(rnd)
+	inputs, targets = newDataset(n, 2, 1)
+	for i, x := range inputs {
+		a := rnd.Intn(2)
+		b := rnd.Intn(2)
+		x[0] = float64(a) + noise*rnd.NormFloat64()
+		x[1] = float64(b) + noise*rnd.NormFloat64()
+		targets[i][0] = float64(a ^ b)
+	}
+	return inputs, targets
+}
+
+// NoisySine generates the one-dimensional regression problem y = sin(x) with
+// x ~ U(-π, π)
+func NoisySine(n int, noise float64, rnd *rand.Rand) (inputs, targets SosMatrix) {
+	rnd = orGlobal(rnd)
+	inputs, targets = newDataset(n, 1, 1)
+	for i, x := range inputs {
+		x[0] = math.Pi * (2*rnd.Float64() - 1)
+		targets[i][0] = math.Sin(x[0]) + noise*rnd.NormFloat64()
+	}
+	return inputs, targets
+}
+
+// GaussianBlobs generates a classification problem with one isotropic Gaussian
+// cluster of points around each of the centers, where noise is the standard
+// deviation of the clusters. The target is the one-hot encoding of the cluster,
+// so it has one element per center. The samples are assigned to the clusters
+// in turn.
+func GaussianBlobs(n int, centers [][]float64, noise float64, rnd *rand.Rand) (inputs, targets SosMatrix) {
+	if len(centers) == 0 {
+		panic("nnet: no blob centers")
+	}
+	rnd = orGlobal(rnd)
+	inputs, targets = newDataset(n, len(centers[0]), len(centers))
+	for i, x := range inputs {
+		class := i % len(centers)
+		if len(centers[class]) != len(x) {
+			panic("nnet: blob centers of different dimensions")
+		}
+		for j, c := range centers[class] {
+			x[j] = c + noise*rnd.NormFloat64()
+		}
+		targets[i][class] = 1
+	}
+	return inputs, targets
+}
+
+func newDataset(n, inputDim, outputDim int) (inputs, targets SosMatrix) {
+	inputs = make(SosMatrix, n)
+	targets = make(SosMatrix, n)
+	for i := range inputs {
+		inputs[i] = make([]float64, inputDim)
+		targets[i] = make([]float64, outputDim)
+	}
+	return inputs, targets
+}
+
+func orGlobal(rnd *rand.Rand) *rand.Rand {
+	if rnd == nil {
+		return globalRand
+	}
+	return rnd
+}
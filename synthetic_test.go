@@ -0,0 +1,79 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestSynthetic(t *testing.T) {
+	const n = 50
+	blobs := func(n int, noise float64, rnd *rand.Rand) (SosMatrix, SosMatrix) {
+		return GaussianBlobs(n, [][]float64{{0, 0, 0}, {5, 5, 5}}, noise, rnd)
+	}
+	for _, test := range []struct {
+		name      string
+		gen       func(int, float64, *rand.Rand) (SosMatrix, SosMatrix)
+		inputDim  int
+		outputDim int
+		// Target for a noise-free input
+		target func(x []float64) []float64
+	}{
+		{"Friedman1", Friedman1, 10, 1, func(x []float64) []float64 {
+			return []float64{10*math.Sin(math.Pi*x[0]*x[1]) + 20*(x[2]-0.5)*(x[2]-0.5) + 10*x[3] + 5*x[4]}
+		}},
+		{"Friedman2", Friedman2, 4, 1, func(x []float64) []float64 {
+			return []float64{math.Hypot(x[0], x[1]*x[2]-1/(x[1]*x[3]))}
+		}},
+		{"Friedman3", Friedman3, 4, 1, func(x []float64) []float64 {
+			return []float64{math.Atan((x[1]*x[2] - 1/(x[1]*x[3])) / x[0])}
+		}},
+		{"TwoSpirals", TwoSpirals, 2, 1, nil},
+		{"XOR", XOR, 2, 1, func(x []float64) []float64 {
+			if x[0] != x[1] {
+				return []float64{1}
+			}
+			return []float64{0}
+		}},
+		{"NoisySine", NoisySine, 1, 1, func(x []float64) []float64 {
+			return []float64{math.Sin(x[0])}
+		}},
+		{"GaussianBlobs", blobs, 3, 2, func(x []float64) []float64 {
+			if x[0] == 0 {
+				return []float64{1, 0}
+			}
+			return []float64{0, 1}
+		}},
+	} {
+		inputs, targets := test.gen(n, 0, rand.New(rand.NewSource(1)))
+		if len(inputs) != n || len(targets) != n {
+			t.Errorf("%v: wrong number of samples", test.name)
+			continue
+		}
+		if r, c := inputs.Dims(); r != n || c != test.inputDim {
+			t.Errorf("%v: wrong input dimension. Expected %v, found %v", test.name, test.inputDim, c)
+		}
+		if _, c := targets.Dims(); c != test.outputDim {
+			t.Errorf("%v: wrong output dimension. Expected %v, found %v", test.name, test.outputDim, c)
+		}
+		if test.target != nil {
+			for i := range inputs {
+				if !floatsEqualApprox(targets[i], test.target(inputs[i]), 1e-10) {
+					t.Errorf("%v: wrong target for %v. Expected %v, found %v", test.name, inputs[i], test.target(inputs[i]), targets[i])
+					break
+				}
+			}
+		}
+
+		// The same source gives the same data
+		inputs2, targets2 := test.gen(n, 0.1, rand.New(rand.NewSource(1)))
+		inputs3, targets3 := test.gen(n, 0.1, rand.New(rand.NewSource(1)))
+		if !sosEqual(inputs2, inputs3) || !sosEqual(targets2, targets3) {
+			t.Errorf("%v: data not reproducible", test.name)
+		}
+	}
+}
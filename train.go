@@ -0,0 +1,192 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"errors"
+	"math"
+)
+
+// Default learning rate of the SGD optimizer used if none is set
+const defaultLearningRate = 0.01
+
+// SetLoss sets the loss function minimized by PartialFit. The default is
+// SquaredDistance.
+func (s *Trainer) SetLoss(loss Losser) {
+	s.loss = loss
+}
+
+// Loss returns the loss function minimized by PartialFit
+func (s *Trainer) Loss() Losser {
+	if s.loss == nil {
+		return SquaredDistance{}
+	}
+	return s.loss
+}
+
+// SetOptimizer sets the optimizer used by PartialFit. The optimizer keeps its
+// state, such as momentum, across calls to PartialFit. The default is SGD with
+// a learning rate of 0.01 and no momentum.
+func (s *Trainer) SetOptimizer(opt Optimizer) {
+	s.optimizer = opt
+}
+
+// Optimizer returns the optimizer used by PartialFit
+func (s *Trainer) Optimizer() Optimizer {
+	if s.optimizer == nil {
+		s.optimizer = &SGD{Rate: defaultLearningRate}
+	}
+	return s.optimizer
+}
+
+// GradientNoise adds annealed Gaussian noise to the gradient at every step of
+// PartialFit. The noise at step t has variance Eta / (1 + t)^Gamma, so it
+// decays as training progresses. This helps deep nets escape plateaus early in
+// training. If Gamma is zero, 0.55 is used.
+type GradientNoise struct {
+	Eta   float64
+	Gamma float64
+}
+
+// variance returns the variance of the noise at the given step
+func (g GradientNoise) variance(step int) float64 {
+	gamma := g.Gamma
+	if gamma == 0 {
+		gamma = 0.55
+	}
+	return g.Eta / math.Pow(1+float64(step), gamma)
+}
+
+// SetGradientNoise sets the noise added to the gradient by PartialFit. The
+// noise is drawn from the trainer's source of randomness. The default is no
+// noise, as is an Eta of zero.
+func (s *Trainer) SetGradientNoise(noise GradientNoise) {
+	s.gradientNoise = noise
+}
+
+// Steps returns the number of optimizer steps taken by PartialFit
+func (s *Trainer) Steps() int {
+	return s.steps
+}
+
+// PartialFit performs one optimizer step on the mini-batch of inputs and
+// targets, where row i of targets is the target for row i of inputs. The
+// gradient is that of the mean loss over the mini-batch. The optimizer state
+// persists between calls, so PartialFit can be called repeatedly as new data
+// arrives for online learning. The parameters of frozen layers are not
+// changed. PartialFit returns the mean loss of the mini-batch before the step.
+func (s *Trainer) PartialFit(inputs, targets RowMatrix) (float64, error) {
+	if err := s.checkTargets("partial fit", inputs, targets); err != nil {
+		return 0, err
+	}
+	loss, grad, err := s.lossGradient(inputs, targets)
+	if err != nil {
+		return 0, err
+	}
+	if s.history != nil {
+		s.history.observeGradient(grad)
+	}
+	s.step(grad)
+	return loss, nil
+}
+
+// targetDim returns the number of elements of the true values expected by the
+// loss (see TargetDimer)
+func (s *Trainer) targetDim() int {
+	if t, ok := s.Loss().(TargetDimer); ok {
+		return t.TargetDim(s.outputDim)
+	}
+	return s.outputDim
+}
+
+// checkTargets checks that targets has the target dimension of the loss and
+// one row per row of inputs, and that the loss accepts them (see TargetChecker)
+func (s *Trainer) checkTargets(op string, inputs, targets RowMatrix) error {
+	nSamples, _ := inputs.Dims()
+	nTargets, targetDim := targets.Dims()
+	if want := s.targetDim(); targetDim != want {
+		return dimensionError(op, ErrOutputDim, want, targetDim)
+	}
+	if nTargets != nSamples {
+		return dimensionError(op, ErrRows, nSamples, nTargets)
+	}
+	if c, ok := s.Loss().(TargetChecker); ok {
+		if err := c.CheckTargets(s.outputDim, targets); err != nil {
+			return errors.New(op + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+// lossGradient returns the mean loss over the samples and its gradient with
+// respect to the parameters
+func (s *Trainer) lossGradient(inputs, targets RowMatrix) (float64, []float64, error) {
+	nSamples, _ := inputs.Dims()
+	predictions, err := s.PredictBatch(inputs, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	// Compute the derivative of the loss in place of the predictions
+	dLoss := predictions.(SosMatrix)
+	lossFunc := s.Loss()
+	target := make([]float64, s.targetDim())
+	prediction := make([]float64, s.outputDim)
+	var loss float64
+	for i, row := range dLoss {
+		copy(prediction, row)
+		target = targets.Row(target, i)
+		loss += lossFunc.LossDeriv(prediction, target, row)
+	}
+	n := float64(nSamples)
+	for _, row := range dLoss {
+		for j := range row {
+			row[j] /= n
+		}
+	}
+
+	if len(s.grad) != s.totalNumParameters {
+		s.grad = make([]float64, s.totalNumParameters)
+	}
+	if _, err := s.BatchParameterGradient(inputs, dLoss, s.grad); err != nil {
+		return 0, nil, err
+	}
+	return loss / n, s.grad, nil
+}
+
+// step applies the optimizer to the gradient, leaving frozen layers unchanged
+func (s *Trainer) step(grad []float64) {
+	if len(s.params) != s.totalNumParameters {
+		s.params = make([]float64, s.totalNumParameters)
+	}
+	params := s.Parameters(s.params)
+	opt := s.Optimizer()
+	if s.schedule != nil {
+		opt.SetLearningRate(s.schedule.Rate(s.steps))
+	}
+	if s.ewc != nil {
+		s.ewc.Penalty(params, grad)
+	}
+	if s.gradientNoise.Eta != 0 {
+		std := math.Sqrt(s.gradientNoise.variance(s.steps))
+		rnd := s.rand()
+		for i := range grad {
+			grad[i] += std * rnd.NormFloat64()
+		}
+	}
+	s.tieGradient(grad)
+	opt.Step(params, grad)
+	s.steps++
+	var idx int
+	for i, layer := range s.parameters {
+		for _, p := range layer {
+			if !s.frozen[i] {
+				copy(p, params[idx:])
+			}
+			idx += len(p)
+		}
+	}
+	s.tieParameters()
+	s.applyPruneMask()
+}
@@ -0,0 +1,271 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// testLossDeriv checks the derivative of the loss with finite differences
+func testLossDeriv(t *testing.T, name string, loss Losser, prediction, truth []float64) {
+	const h = 1e-6
+	deriv := make([]float64, len(prediction))
+	loss.LossDeriv(prediction, truth, deriv)
+	tmp := make([]float64, len(prediction))
+	p := make([]float64, len(prediction))
+	for i := range prediction {
+		copy(p, prediction)
+		p[i] += h
+		plus := loss.LossDeriv(p, truth, tmp)
+		p[i] -= 2 * h
+		minus := loss.LossDeriv(p, truth, tmp)
+		fd := (plus - minus) / (2 * h)
+		if !floatsEqualApprox([]float64{fd}, []float64{deriv[i]}, 1e-6) {
+			t.Errorf("%v: wrong derivative %v. Finite difference %v, found %v", name, i, fd, deriv[i])
+		}
+	}
+}
+
+func TestSquaredDistance(t *testing.T) {
+	deriv := make([]float64, 2)
+	loss := SquaredDistance{}.LossDeriv([]float64{1, 2}, []float64{3, 2}, deriv)
+	if loss != 2 {
+		t.Errorf("Wrong loss. Expected 2, found %v", loss)
+	}
+	testLossDeriv(t, "SquaredDistance", SquaredDistance{}, []float64{1, -2, 0.5}, []float64{0.3, 1, 0.5})
+}
+
+func TestPartialFit(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	inputs, targets := NoisySine(200, 0.05, rnd)
+	for _, opt := range []Optimizer{
+		&SGD{Rate: 0.1},
+		&SGD{Rate: 0.05, Momentum: 0.9},
+	} {
+		trainer, err := NewSimpleTrainer(1, 1, 1, 10, Linear{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		trainer.SetRand(rand.New(rand.NewSource(2)))
+		trainer.RandomizeParameters()
+		trainer.SetOptimizer(opt)
+
+		first, err := trainer.PartialFit(inputs, targets)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Alternate between two mini-batches as if they arrived in turn
+		head, headTargets, tail, tailTargets := Split(inputs, targets, 100)
+		for i := 0; i < 150; i++ {
+			if _, err := trainer.PartialFit(head, headTargets); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := trainer.PartialFit(tail, tailTargets); err != nil {
+				t.Fatal(err)
+			}
+		}
+		last, _, err := trainer.lossGradient(inputs, targets)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last > first/4 {
+			t.Errorf("Momentum %v: loss not reduced enough. First %v, last %v", opt.(*SGD).Momentum, first, last)
+		}
+		if trainer.Steps() != 301 {
+			t.Errorf("Wrong number of steps. Expected 301, found %v", trainer.Steps())
+		}
+	}
+}
+
+func TestPartialFitFrozen(t *testing.T) {
+	trainer, err := NewSimpleTrainer(2, 1, 2, 3, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trainer.RandomizeParameters()
+	trainer.SetLayerTrainable(0, false)
+	before := trainer.Parameters(nil)
+	n0 := trainer.LayerNumParameters(0)
+	rnd := rand.New(rand.NewSource(1))
+	inputs := RandomMat(10, 2, rnd.NormFloat64)
+	targets := RandomMat(10, 1, rnd.NormFloat64)
+	if _, err := trainer.PartialFit(inputs, targets); err != nil {
+		t.Fatal(err)
+	}
+	after := trainer.Parameters(nil)
+	if !floatsEqual(before[:n0], after[:n0]) {
+		t.Errorf("Frozen layer changed")
+	}
+	if floatsEqual(before[n0:], after[n0:]) {
+		t.Errorf("Trainable layers not changed")
+	}
+
+	if _, err := trainer.PartialFit(inputs, RandomMat(9, 1, rnd.NormFloat64)); !errors.Is(err, ErrRows) {
+		t.Errorf("Expected ErrRows, found %v", err)
+	}
+	if _, err := trainer.PartialFit(inputs, RandomMat(10, 2, rnd.NormFloat64)); !errors.Is(err, ErrOutputDim) {
+		t.Errorf("Expected ErrOutputDim, found %v", err)
+	}
+	if _, err := trainer.PartialFit(RandomMat(10, 3, rnd.NormFloat64), targets); !errors.Is(err, ErrInputDim) {
+		t.Errorf("Expected ErrInputDim, found %v", err)
+	}
+}
+
+func TestCrossEntropy(t *testing.T) {
+	deriv := make([]float64, 3)
+	logits := []float64{1, 2, 3}
+	loss := CrossEntropy{}.LossDeriv(logits, []float64{0, 0, 1}, deriv)
+	sum := math.Exp(1) + math.Exp(2) + math.Exp(3)
+	if want := -math.Log(math.Exp(3) / sum); math.Abs(loss-want) > 1e-14 {
+		t.Errorf("Wrong loss. Expected %v, found %v", want, loss)
+	}
+	// Fully smoothed targets are uniform, so the loss is that of the uniform
+	// distribution whatever the truth
+	uniform := CrossEntropy{Smoothing: 1}.LossDeriv(logits, []float64{0, 0, 1}, deriv)
+	other := CrossEntropy{Smoothing: 1}.LossDeriv(logits, []float64{1, 0, 0}, deriv)
+	if math.Abs(uniform-other) > 1e-14 {
+		t.Errorf("Fully smoothed loss depends on the truth: %v and %v", uniform, other)
+	}
+	// Large logits must not overflow
+	loss = CrossEntropy{}.LossDeriv([]float64{1000, 0}, []float64{1, 0}, deriv[:2])
+	if math.IsNaN(loss) || math.IsInf(loss, 0) || loss > 1e-10 {
+		t.Errorf("Wrong loss for large logits %v", loss)
+	}
+	loss = CrossEntropy{}.LossDeriv([]float64{-1000}, []float64{1}, deriv[:1])
+	if math.Abs(loss-1000) > 1e-10 {
+		t.Errorf("Wrong binary loss for large logits. Expected 1000, found %v", loss)
+	}
+
+	for _, smoothing := range []float64{0, 0.1} {
+		loss := CrossEntropy{Smoothing: smoothing}
+		testLossDeriv(t, "CrossEntropy", loss, []float64{0.3, -1, 2}, []float64{0, 1, 0})
+		testLossDeriv(t, "CrossEntropy binary", loss, []float64{0.7}, []float64{1})
+		testLossDeriv(t, "CrossEntropy binary", loss, []float64{-0.4}, []float64{0})
+	}
+}
+
+func TestFocal(t *testing.T) {
+	// With a Gamma of zero and no weighting, the focal loss is the cross entropy
+	for _, c := range []struct {
+		prediction, truth []float64
+	}{
+		{[]float64{0.3, -1, 2}, []float64{0, 1, 0}},
+		{[]float64{0.7}, []float64{1}},
+		{[]float64{-0.4}, []float64{0}},
+	} {
+		d1 := make([]float64, len(c.prediction))
+		d2 := make([]float64, len(c.prediction))
+		l1 := Focal{}.LossDeriv(c.prediction, c.truth, d1)
+		l2 := CrossEntropy{}.LossDeriv(c.prediction, c.truth, d2)
+		if math.Abs(l1-l2) > 1e-14 || !floatsEqualApprox(d1, d2, 1e-14) {
+			t.Errorf("Focal with zero Gamma differs from CrossEntropy")
+		}
+	}
+	// Well classified samples contribute less than with cross entropy
+	deriv := make([]float64, 2)
+	focal := Focal{Gamma: 2}.LossDeriv([]float64{3, 0}, []float64{1, 0}, deriv)
+	ce := CrossEntropy{}.LossDeriv([]float64{3, 0}, []float64{1, 0}, deriv)
+	if focal >= ce/100 {
+		t.Errorf("Focal loss %v not down-weighted relative to cross entropy %v", focal, ce)
+	}
+
+	for _, loss := range []Focal{
+		{Gamma: 2},
+		{Gamma: 0.5, Alpha: 0.25},
+		{Gamma: 1.5, Alpha: 0.75},
+	} {
+		testLossDeriv(t, "Focal", loss, []float64{0.3, -1, 2}, []float64{0, 1, 0})
+		testLossDeriv(t, "Focal", loss, []float64{0.3, -1, 2}, []float64{0.2, 0.5, 0.3})
+		testLossDeriv(t, "Focal binary", loss, []float64{0.7}, []float64{1})
+		testLossDeriv(t, "Focal binary", loss, []float64{-0.4}, []float64{0})
+		testLossDeriv(t, "Focal binary", loss, []float64{1.2}, []float64{0.3})
+	}
+}
+
+func TestSquaredDistanceWeights(t *testing.T) {
+	loss := SquaredDistance{Weights: []float64{2, 0, 1}}
+	deriv := make([]float64, 3)
+	l := loss.LossDeriv([]float64{1, 5, 0}, []float64{0, 0, 2}, deriv)
+	if want := (2.0 + 4) / 3; math.Abs(l-want) > 1e-14 {
+		t.Errorf("Wrong loss. Expected %v, found %v", want, l)
+	}
+	if deriv[1] != 0 {
+		t.Errorf("Non-zero derivative %v for zero weight", deriv[1])
+	}
+	testLossDeriv(t, "SquaredDistance weighted", loss, []float64{1, -2, 0.5}, []float64{0.3, 1, 0.5})
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("Expected panic for wrong number of weights")
+		}
+	}()
+	loss.LossDeriv([]float64{1, 2}, []float64{0, 0}, deriv[:2])
+}
+
+func TestCompositeLoss(t *testing.T) {
+	loss := CompositeLoss{
+		{Start: 0, End: 2, Loss: SquaredDistance{}},
+		{Start: 3, End: 5, Loss: CrossEntropy{}, Weight: 0.5},
+	}
+	prediction := []float64{1, 2, 7, 0.5, -1}
+	truth := []float64{0, 2, 3, 1, 0}
+	deriv := make([]float64, 5)
+	l := loss.LossDeriv(prediction, truth, deriv)
+	want := SquaredDistance{}.LossDeriv(prediction[:2], truth[:2], make([]float64, 2)) +
+		0.5*CrossEntropy{}.LossDeriv(prediction[3:], truth[3:], make([]float64, 2))
+	if math.Abs(l-want) > 1e-14 {
+		t.Errorf("Wrong loss. Expected %v, found %v", want, l)
+	}
+	if deriv[2] != 0 {
+		t.Errorf("Non-zero derivative %v for uncovered output", deriv[2])
+	}
+	testLossDeriv(t, "CompositeLoss", loss, prediction, truth)
+}
+
+func TestGradientNoise(t *testing.T) {
+	g := GradientNoise{Eta: 0.3}
+	if v := g.variance(0); v != 0.3 {
+		t.Errorf("Wrong initial variance %v", v)
+	}
+	if v, want := g.variance(9), 0.3/math.Pow(10, 0.55); math.Abs(v-want) > 1e-15 {
+		t.Errorf("Wrong variance. Expected %v, found %v", want, v)
+	}
+
+	// With a learning rate of one the difference between noisy and exact steps
+	// is the noise
+	plain, err := NewSimpleTrainer(3, 2, 2, 40, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain.RandomizeParameters()
+	noisy := plain.Clone()
+	noisy.SetRand(rand.New(rand.NewSource(1)))
+	noisy.SetGradientNoise(GradientNoise{Eta: 0.01, Gamma: 1})
+	plain.SetOptimizer(&SGD{Rate: 1})
+	noisy.SetOptimizer(&SGD{Rate: 1})
+	rnd := rand.New(rand.NewSource(2))
+	inputs := RandomMat(10, 3, rnd.NormFloat64)
+	targets := RandomMat(10, 2, rnd.NormFloat64)
+	for step := 0; step < 2; step++ {
+		before := noisy.Parameters(nil)
+		plain.SetParameters(before)
+		plain.PartialFit(inputs, targets)
+		noisy.PartialFit(inputs, targets)
+		a, b := plain.Parameters(nil), noisy.Parameters(nil)
+		var ss float64
+		for i := range a {
+			d := a[i] - b[i]
+			ss += d * d
+		}
+		std := math.Sqrt(ss / float64(len(a)))
+		want := math.Sqrt(0.01 / float64(1+step))
+		if math.Abs(std-want) > 0.1*want {
+			t.Errorf("Step %v: wrong noise standard deviation. Expected %v, found %v", step, want, std)
+		}
+	}
+}
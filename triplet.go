@@ -0,0 +1,158 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"errors"
+	"math/rand"
+)
+
+// TripletMargin is the triplet margin loss of the embeddings of an anchor, a
+// positive sample of the same class as the anchor and a negative sample of
+// another class. The loss is max(0, d(anchor, positive) - d(anchor, negative)
+// + Margin) for the Euclidean distance d, so the positive should be closer to
+// the anchor than the negative by at least Margin. If Margin is zero, 1 is used.
+type TripletMargin struct {
+	Margin float64
+}
+
+// TripletLossDeriv returns the loss of the triplet of embeddings, and stores
+// the derivatives of the loss with respect to the anchor, positive and
+// negative in dA, dP and dN
+func (t TripletMargin) TripletLossDeriv(anchor, positive, negative, dA, dP, dN []float64) float64 {
+	margin := t.Margin
+	if margin == 0 {
+		margin = 1
+	}
+	dPos := euclidean(anchor, positive)
+	dNeg := euclidean(anchor, negative)
+	loss := dPos - dNeg + margin
+	for i := range anchor {
+		dA[i], dP[i], dN[i] = 0, 0, 0
+	}
+	if loss <= 0 {
+		return 0
+	}
+	for i, a := range anchor {
+		if dPos > 0 {
+			g := (a - positive[i]) / dPos
+			dA[i] += g
+			dP[i] -= g
+		}
+		if dNeg > 0 {
+			g := (a - negative[i]) / dNeg
+			dA[i] -= g
+			dN[i] += g
+		}
+	}
+	return loss
+}
+
+// SampleTriplets draws nTriplets random triplets of sample indices given the
+// class label of every sample. The anchor of each triplet is drawn uniformly
+// from the samples whose class has at least two samples, the positive is
+// another sample of the same class, and the negative is a sample of a
+// different class. Random numbers are drawn from rnd, or from the global
+// source of math/rand if rnd is nil.
+func SampleTriplets(labels []int, nTriplets int, rnd *rand.Rand) (anchors, positives, negatives []int, err error) {
+	rnd = orGlobal(rnd)
+	byClass := make(map[int][]int)
+	for i, l := range labels {
+		byClass[l] = append(byClass[l], i)
+	}
+	if len(byClass) < 2 {
+		return nil, nil, nil, errors.New("triplets: need at least two classes")
+	}
+	var candidates []int
+	for i, l := range labels {
+		if len(byClass[l]) >= 2 {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil, nil, errors.New("triplets: no class has two samples")
+	}
+	anchors = make([]int, nTriplets)
+	positives = make([]int, nTriplets)
+	negatives = make([]int, nTriplets)
+	for k := range anchors {
+		a := candidates[rnd.Intn(len(candidates))]
+		same := byClass[labels[a]]
+		p := a
+		for p == a {
+			p = same[rnd.Intn(len(same))]
+		}
+		n := rnd.Intn(len(labels))
+		for labels[n] == labels[a] {
+			n = rnd.Intn(len(labels))
+		}
+		anchors[k], positives[k], negatives[k] = a, p, n
+	}
+	return anchors, positives, negatives, nil
+}
+
+// TripletTrainer trains an embedding net with the triplet margin loss for
+// retrieval, so that samples of the same class are embedded close together
+// and samples of different classes far apart. The anchor, positive and
+// negative of a triplet are all embedded by the same net.
+type TripletTrainer struct {
+	*Trainer
+	loss TripletMargin
+}
+
+// NewTripletTrainer returns a triplet trainer of the embedding net. The
+// optimizer, schedule and other training settings of the embedding trainer
+// are used.
+func NewTripletTrainer(embedding *Trainer, loss TripletMargin) *TripletTrainer {
+	return &TripletTrainer{
+		Trainer: embedding,
+		loss:    loss,
+	}
+}
+
+// PartialFitTriplets performs one optimizer step on the mini-batch of
+// triplets, where row i of anchors, positives and negatives form triplet i. It
+// returns the mean loss of the triplets before the step.
+func (t *TripletTrainer) PartialFitTriplets(anchors, positives, negatives RowMatrix) (float64, error) {
+	return t.tupleFit("partial fit triplets", []RowMatrix{anchors, positives, negatives}, func(i int, emb, deriv [][]float64) float64 {
+		return t.loss.TripletLossDeriv(emb[0], emb[1], emb[2], deriv[0], deriv[1], deriv[2])
+	})
+}
+
+// TrainTripletEpoch draws nTriplets random triplets from the labeled inputs
+// with SampleTriplets and trains on them in mini-batches with
+// PartialFitTriplets. It returns the mean of the mini-batch losses.
+func (t *TripletTrainer) TrainTripletEpoch(inputs RowMatrix, labels []int, nTriplets, batchSize int) (float64, error) {
+	if batchSize <= 0 {
+		return 0, errors.New("train triplet epoch: non-positive batch size")
+	}
+	nSamples, _ := inputs.Dims()
+	if len(labels) != nSamples {
+		return 0, dimensionError("train triplet epoch", ErrRows, nSamples, len(labels))
+	}
+	anchors, positives, negatives, err := SampleTriplets(labels, nTriplets, t.rand())
+	if err != nil {
+		return 0, err
+	}
+	var loss float64
+	var nBatches int
+	for start := 0; start < nTriplets; start += batchSize {
+		end := start + batchSize
+		if end > nTriplets {
+			end = nTriplets
+		}
+		l, err := t.PartialFitTriplets(SelectRows(inputs, anchors[start:end]),
+			SelectRows(inputs, positives[start:end]), SelectRows(inputs, negatives[start:end]))
+		if err != nil {
+			return 0, err
+		}
+		loss += l
+		nBatches++
+	}
+	if nBatches == 0 {
+		return 0, nil
+	}
+	return loss / float64(nBatches), nil
+}
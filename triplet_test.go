@@ -0,0 +1,181 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestTripletMargin(t *testing.T) {
+	a, p, n := []float64{0, 0}, []float64{3, 4}, []float64{0, 2}
+	dA, dP, dN := make([]float64, 2), make([]float64, 2), make([]float64, 2)
+	if loss := (TripletMargin{}).TripletLossDeriv(a, p, n, dA, dP, dN); math.Abs(loss-4) > 1e-14 {
+		t.Errorf("Wrong loss %v", loss)
+	}
+	if loss := (TripletMargin{Margin: 0.5}).TripletLossDeriv(a, n, p, dA, dP, dN); loss != 0 || dA[0] != 0 || dN[1] != 0 {
+		t.Errorf("Triplet beyond the margin has loss %v", loss)
+	}
+
+	tm := TripletMargin{Margin: 2}
+	a, p, n = []float64{1, 2}, []float64{1.5, 1}, []float64{0.3, 2.4}
+	tm.TripletLossDeriv(a, p, n, dA, dP, dN)
+	const h = 1e-6
+	tmp := [3][]float64{make([]float64, 2), make([]float64, 2), make([]float64, 2)}
+	for k, d := range [][]float64{dA, dP, dN} {
+		for i := range a {
+			x := [][]float64{
+				append([]float64(nil), a...),
+				append([]float64(nil), p...),
+				append([]float64(nil), n...),
+			}
+			x[k][i] += h
+			plus := tm.TripletLossDeriv(x[0], x[1], x[2], tmp[0], tmp[1], tmp[2])
+			x[k][i] -= 2 * h
+			minus := tm.TripletLossDeriv(x[0], x[1], x[2], tmp[0], tmp[1], tmp[2])
+			if fd := (plus - minus) / (2 * h); math.Abs(fd-d[i]) > 1e-6 {
+				t.Errorf("Member %v: wrong derivative %v. Finite difference %v, found %v", k, i, fd, d[i])
+			}
+		}
+	}
+}
+
+func TestSampleTriplets(t *testing.T) {
+	labels := []int{0, 1, 0, 2, 1, 0, 3}
+	rnd := rand.New(rand.NewSource(1))
+	anchors, positives, negatives, err := SampleTriplets(labels, 100, rnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anchors) != 100 || len(positives) != 100 || len(negatives) != 100 {
+		t.Fatalf("Wrong number of triplets")
+	}
+	for i, a := range anchors {
+		p, n := positives[i], negatives[i]
+		if a == p || labels[a] != labels[p] || labels[a] == labels[n] {
+			t.Errorf("Bad triplet %v, %v, %v", a, p, n)
+		}
+		if labels[a] == 2 || labels[a] == 3 {
+			t.Errorf("Anchor %v has no positive", a)
+		}
+	}
+	if _, _, _, err := SampleTriplets([]int{1, 1, 1}, 3, rnd); err == nil {
+		t.Errorf("Expected error for a single class")
+	}
+	if _, _, _, err := SampleTriplets([]int{0, 1, 2}, 3, rnd); err == nil {
+		t.Errorf("Expected error with no positives")
+	}
+}
+
+func TestTripletGradient(t *testing.T) {
+	net, err := NewSimpleTrainer(3, 2, 1, 4, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	net.SetRand(rand.New(rand.NewSource(1)))
+	net.RandomizeParameters()
+	rate := 1e-3
+	net.SetOptimizer(&SGD{Rate: rate})
+	s := NewTripletTrainer(net, TripletMargin{Margin: 3})
+	anchors := RandomMat(5, 3, rand.NormFloat64)
+	positives := RandomMat(5, 3, rand.NormFloat64)
+	negatives := RandomMat(5, 3, rand.NormFloat64)
+
+	meanLoss := func(params []float64) float64 {
+		c := net.Clone()
+		c.SetParameters(params)
+		var loss float64
+		d := [3][]float64{make([]float64, 2), make([]float64, 2), make([]float64, 2)}
+		for i := range anchors {
+			a, _ := c.Predict(anchors[i], nil)
+			p, _ := c.Predict(positives[i], nil)
+			n, _ := c.Predict(negatives[i], nil)
+			loss += s.loss.TripletLossDeriv(a, p, n, d[0], d[1], d[2])
+		}
+		return loss / float64(len(anchors))
+	}
+	before := net.Parameters(nil)
+	loss, err := s.PartialFitTriplets(anchors, positives, negatives)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := meanLoss(before); math.Abs(loss-want) > 1e-14 {
+		t.Errorf("Wrong loss. Expected %v, found %v", want, loss)
+	}
+	after := net.Parameters(nil)
+	const h = 1e-6
+	for i := range before {
+		p := append([]float64(nil), before...)
+		p[i] += h
+		plus := meanLoss(p)
+		p[i] -= 2 * h
+		minus := meanLoss(p)
+		fd := (plus - minus) / (2 * h)
+		if grad := (before[i] - after[i]) / rate; math.Abs(fd-grad) > 1e-5 {
+			t.Errorf("Wrong gradient %v. Finite difference %v, found %v", i, fd, grad)
+		}
+	}
+}
+
+func TestTripletTrainer(t *testing.T) {
+	net, err := NewSimpleTrainer(2, 2, 1, 6, Linear{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(2))
+	net.SetRand(rnd)
+	net.RandomizeParameters()
+	net.SetOptimizer(&Adam{Rate: 0.01})
+	s := NewTripletTrainer(net, TripletMargin{Margin: 1})
+
+	// The class is the side of the line x0 = x1
+	label := func(x []float64) int {
+		if x[0] > x[1] {
+			return 1
+		}
+		return 0
+	}
+	inputs := RandomMat(200, 2, rnd.NormFloat64)
+	labels := make([]int, len(inputs))
+	for i, x := range inputs {
+		labels[i] = label(x)
+	}
+	for i := 0; i < 50; i++ {
+		if _, err := s.TrainTripletEpoch(inputs, labels, 200, 20); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	test := RandomMat(200, 2, rnd.NormFloat64)
+	emb, err := s.PredictBatch(test, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	embs := emb.(SosMatrix)
+	var sameDist, diffDist float64
+	var nSame, nDiff int
+	for i := 0; i < len(test)-1; i++ {
+		d := euclidean(embs[i], embs[i+1])
+		if label(test[i]) == label(test[i+1]) {
+			sameDist += d
+			nSame++
+		} else {
+			diffDist += d
+			nDiff++
+		}
+	}
+	sameDist /= float64(nSame)
+	diffDist /= float64(nDiff)
+	if diffDist < 2*sameDist {
+		t.Errorf("Classes not separated. Mean distance within classes %v, between classes %v", sameDist, diffDist)
+	}
+	if _, err := s.TrainTripletEpoch(inputs, labels[:3], 10, 5); err == nil {
+		t.Errorf("Expected rows error")
+	}
+	if _, err := s.PartialFitTriplets(inputs, inputs[:3], inputs); err == nil {
+		t.Errorf("Expected rows error")
+	}
+}
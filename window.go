@@ -0,0 +1,92 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import "errors"
+
+// WindowConfig configures the conversion of a time series into samples of a
+// lagged window of the series and the values which follow it.
+type WindowConfig struct {
+	// Window is the number of consecutive time steps in each input. It must be
+	// positive.
+	Window int
+	// Horizon is the number of time steps following the window in each target.
+	// If Horizon is zero, 1 is used.
+	Horizon int
+	// Stride is the number of time steps between the starts of consecutive
+	// windows. If Stride is zero, 1 is used.
+	Stride int
+	// Targets are the columns of the series which are forecast. If Targets is
+	// nil, all of the columns are used.
+	Targets []int
+}
+
+// Windows converts a time series into input and target pairs for forecasting.
+// Row t of series is the value of the (possibly multivariate) series at time
+// step t. The input of each sample is Window consecutive rows of the series
+// laid end to end, earliest first, and the target is the target columns of the
+// Horizon rows which follow, also earliest first. The windows start at time
+// steps 0, Stride, 2*Stride, ... for as long as the target fits in the series.
+// The samples are copies, so the series may be modified afterwards.
+//
+// The inputs can be used with any net. Each input can also be read as a
+// sequence of Window steps, each with the dimension of the series.
+func Windows(series RowMatrix, cfg WindowConfig) (inputs, targets SosMatrix, err error) {
+	if cfg.Window <= 0 {
+		return nil, nil, errors.New("windows: non-positive window")
+	}
+	horizon := cfg.Horizon
+	if horizon == 0 {
+		horizon = 1
+	}
+	stride := cfg.Stride
+	if stride == 0 {
+		stride = 1
+	}
+	if horizon < 0 || stride < 0 {
+		return nil, nil, errors.New("windows: negative horizon or stride")
+	}
+	nSteps, dim := series.Dims()
+	columns := cfg.Targets
+	if columns == nil {
+		columns = rangeRows(0, dim)
+	}
+	for _, c := range columns {
+		if c < 0 || c >= dim {
+			return nil, nil, errors.New("windows: target column out of range")
+		}
+	}
+	span := cfg.Window + horizon
+	if nSteps < span {
+		return nil, nil, errors.New("windows: series shorter than window and horizon")
+	}
+	nSamples := (nSteps-span)/stride + 1
+	inputs, targets = newDataset(nSamples, cfg.Window*dim, horizon*len(columns))
+
+	row := make([]float64, dim)
+	for i := range inputs {
+		start := i * stride
+		for k := 0; k < cfg.Window; k++ {
+			series.Row(inputs[i][k*dim:(k+1)*dim], start+k)
+		}
+		for k := 0; k < horizon; k++ {
+			series.Row(row, start+cfg.Window+k)
+			for j, c := range columns {
+				targets[i][k*len(columns)+j] = row[c]
+			}
+		}
+	}
+	return inputs, targets, nil
+}
+
+// UnivariateSeries returns the univariate time series x as a matrix with one
+// row per time step, for use with Windows. The data is not copied.
+func UnivariateSeries(x []float64) SosMatrix {
+	series := make(SosMatrix, len(x))
+	for i := range x {
+		series[i] = x[i : i+1 : i+1]
+	}
+	return series
+}
@@ -0,0 +1,53 @@
+// Copyright 2013 Brendan Tracey. All rights reserved.
+// Use of this code is governed by a BSD-style
+// license that can be found in the LICENSE file
+
+package nnet
+
+import "testing"
+
+func TestWindows(t *testing.T) {
+	series := UnivariateSeries([]float64{0, 1, 2, 3, 4, 5, 6})
+	inputs, targets, err := Windows(series, WindowConfig{Window: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sosEqual(inputs, SosMatrix{{0, 1, 2}, {1, 2, 3}, {2, 3, 4}, {3, 4, 5}}) {
+		t.Errorf("Wrong inputs %v", inputs)
+	}
+	if !sosEqual(targets, SosMatrix{{3}, {4}, {5}, {6}}) {
+		t.Errorf("Wrong targets %v", targets)
+	}
+
+	inputs, targets, err = Windows(series, WindowConfig{Window: 2, Horizon: 2, Stride: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sosEqual(inputs, SosMatrix{{0, 1}, {2, 3}}) || !sosEqual(targets, SosMatrix{{2, 3}, {4, 5}}) {
+		t.Errorf("Wrong strided windows %v, %v", inputs, targets)
+	}
+
+	multi := SosMatrix{{0, 10, 20}, {1, 11, 21}, {2, 12, 22}, {3, 13, 23}}
+	inputs, targets, err = Windows(multi, WindowConfig{Window: 2, Horizon: 2, Targets: []int{2, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sosEqual(inputs, SosMatrix{{0, 10, 20, 1, 11, 21}}) || !sosEqual(targets, SosMatrix{{22, 2, 23, 3}}) {
+		t.Errorf("Wrong multivariate windows %v, %v", inputs, targets)
+	}
+	multi[0][0] = 100
+	if inputs[0][0] != 0 {
+		t.Errorf("Windows share memory with the series")
+	}
+
+	for _, cfg := range []WindowConfig{
+		{},
+		{Window: 6, Horizon: 2},
+		{Window: 2, Stride: -1},
+		{Window: 2, Targets: []int{1}},
+	} {
+		if _, _, err := Windows(series, cfg); err == nil {
+			t.Errorf("Expected error for %+v", cfg)
+		}
+	}
+}